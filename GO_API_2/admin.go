@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ConfiguracaoAdmin define quem pode entrar na área administrativa (/api/admin), por
+// quanto tempo uma sessão sem uso continua válida e por quanto tempo ela vale no máximo.
+type ConfiguracaoAdmin struct {
+	Usuarios     []UsuarioAdmin `mapstructure:"usuarios" yaml:"usuarios"` // Vazio: a área administrativa fica desativada.
+	Sessao       time.Duration  `mapstructure:"sessao" yaml:"sessao"`
+	SessaoMaxima time.Duration  `mapstructure:"sessao_maxima" yaml:"sessao_maxima"` // Mesmo com uso contínuo.
+}
+
+// UsuarioAdmin é um usuário da área administrativa. A senha fica guardada só como hash
+// bcrypt, gerado com --hash-senha.
+type UsuarioAdmin struct {
+	Usuario string `mapstructure:"usuario" yaml:"usuario"`
+	Senha   string `mapstructure:"senha" yaml:"senha"`
+}
+
+// Cookie e cabeçalho da sessão administrativa.
+const (
+	cookieSessaoAdmin  = "painel_sessao"
+	caminhoAdmin       = "/api/admin"
+	cabecalhoCSRF      = "X-CSRF-Token"
+	tamanhoMinimoSenha = 8
+)
+
+// chaveSessaoAdmin guarda no contexto a sessão já conferida pelo exigirAdmin.
+const chaveSessaoAdmin chaveContexto = "sessao_admin"
+
+// aplicarPadroes preenche as opções não informadas da área administrativa.
+func (a *ConfiguracaoAdmin) aplicarPadroes() {
+	if a.Sessao == 0 {
+		a.Sessao = 8 * time.Hour
+	}
+	if a.SessaoMaxima == 0 {
+		a.SessaoMaxima = 24 * time.Hour
+	}
+}
+
+// validar confere se cada usuário tem nome único e senha em hash bcrypt.
+func (a *ConfiguracaoAdmin) validar() []error {
+	var erros []error
+	vistos := map[string]bool{}
+	for i, usuario := range a.Usuarios {
+		chave := fmt.Sprintf("admin.usuarios[%d]", i)
+		switch {
+		case strings.TrimSpace(usuario.Usuario) == "":
+			erros = append(erros, fmt.Errorf("%s.usuario: não pode ser vazio", chave))
+		case vistos[usuario.Usuario]:
+			erros = append(erros, fmt.Errorf("%s.usuario: '%s' aparece mais de uma vez", chave, usuario.Usuario))
+		}
+		vistos[usuario.Usuario] = true
+		if _, err := bcrypt.Cost([]byte(usuario.Senha)); err != nil {
+			erros = append(erros, fmt.Errorf("%s.senha: não é um hash bcrypt; gere um com --hash-senha (nunca guarde a senha em texto)", chave))
+		}
+	}
+	return erros
+}
+
+// ============== SESSÕES ==============
+
+// sessaoAdmin é uma sessão aberta no login. O token CSRF acompanha a sessão e precisa
+// ser reenviado no cabeçalho X-CSRF-Token em toda requisição que altera dados.
+type sessaoAdmin struct {
+	usuario string
+	csrf    string
+	expira  time.Time
+	limite  time.Time // O uso estende 'expira', mas nunca além deste prazo.
+}
+
+// SessoesAdmin guarda as sessões abertas em memória (um reinício encerra todas).
+// A chave é o hash do token do cookie, e não o token em si.
+type SessoesAdmin struct {
+	mu      sync.Mutex
+	sessoes map[string]*sessaoAdmin
+	agora   func() time.Time // Substituível nos testes por um relógio falso.
+}
+
+// novasSessoesAdmin cria um armazém de sessões vazio que usa o relógio informado.
+func novasSessoesAdmin(agora func() time.Time) *SessoesAdmin {
+	return &SessoesAdmin{sessoes: map[string]*sessaoAdmin{}, agora: agora}
+}
+
+// sessoesAdmin são as sessões da área administrativa.
+var sessoesAdmin = novasSessoesAdmin(time.Now)
+
+// tokenAleatorio gera um token imprevisível de 256 bits, seguro para cookies e cabeçalhos.
+func tokenAleatorio() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken devolve o hash usado como chave da sessão.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Abrir cria uma sessão para o usuário, válida por no máximo 'maxima', e devolve o token do cookie.
+func (s *SessoesAdmin) Abrir(usuario string, duracao, maxima time.Duration) (string, sessaoAdmin) {
+	token := tokenAleatorio()
+	sessao := &sessaoAdmin{usuario: usuario, csrf: tokenAleatorio()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	agora := s.agora()
+	sessao.expira = agora.Add(min(duracao, maxima))
+	sessao.limite = agora.Add(maxima)
+	// Aproveita o login para descartar as sessões vencidas.
+	for chave, aberta := range s.sessoes {
+		if !agora.Before(aberta.expira) {
+			delete(s.sessoes, chave)
+		}
+	}
+	s.sessoes[hashToken(token)] = sessao
+	return token, *sessao
+}
+
+// Obter devolve a sessão do token, se ainda válida, e estende o prazo dela por 'duracao',
+// sem passar do limite definido na abertura.
+func (s *SessoesAdmin) Obter(token string, duracao time.Duration) (sessaoAdmin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chave := hashToken(token)
+	sessao, ok := s.sessoes[chave]
+	if !ok {
+		return sessaoAdmin{}, false
+	}
+	agora := s.agora()
+	if !agora.Before(sessao.expira) {
+		delete(s.sessoes, chave)
+		return sessaoAdmin{}, false
+	}
+	sessao.expira = agora.Add(duracao)
+	if sessao.expira.After(sessao.limite) {
+		sessao.expira = sessao.limite
+	}
+	return *sessao, true
+}
+
+// Encerrar remove a sessão do token (logout).
+func (s *SessoesAdmin) Encerrar(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessoes, hashToken(token))
+}
+
+// ============== AUTENTICAÇÃO ==============
+
+// hashSenhaFalsa é comparado quando o usuário não existe, para que o login demore o
+// mesmo tempo e não revele quais usuários estão cadastrados.
+var hashSenhaFalsa = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(tokenAleatorio()), bcrypt.DefaultCost)
+	return hash
+})
+
+// autenticar confere o usuário e a senha com os usuários do config.yaml.
+func autenticar(usuarios []UsuarioAdmin, usuario, senha string) bool {
+	hash := hashSenhaFalsa()
+	encontrado := false
+	for _, cadastrado := range usuarios {
+		if subtle.ConstantTimeCompare([]byte(cadastrado.Usuario), []byte(usuario)) == 1 {
+			hash, encontrado = []byte(cadastrado.Senha), true
+		}
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(senha)) == nil && encontrado
+}
+
+// usuarioCadastrado informa se o usuário ainda está no config.yaml. Assim, remover um
+// usuário (com o servidor no ar) encerra as sessões dele.
+func usuarioCadastrado(usuarios []UsuarioAdmin, usuario string) bool {
+	for _, cadastrado := range usuarios {
+		if cadastrado.Usuario == usuario {
+			return true
+		}
+	}
+	return false
+}
+
+// cookieSessao monta o cookie da sessão. Ele só é enviado para /api/admin, fica fora do
+// alcance do JavaScript (HttpOnly), não acompanha requisições de outros sites
+// (SameSite=Strict) e só trafega cifrado (Secure). O Secure vale mesmo quando o servidor
+// recebe HTTP (TLS desligado ou encerrado em um proxy): em HTTP, os navegadores só
+// aceitam o cookie em localhost.
+func cookieSessao(valor string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     cookieSessaoAdmin,
+		Value:    valor,
+		Path:     caminhoAdmin,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// alteraDados informa se o método HTTP altera dados e, por isso, exige o token CSRF.
+func alteraDados(metodo string) bool {
+	return metodo != http.MethodGet && metodo != http.MethodHead && metodo != http.MethodOptions
+}
+
+// exigirAdmin protege as rotas de /api/admin: exige uma sessão válida e, nas requisições
+// que alteram dados, o token CSRF da sessão no cabeçalho X-CSRF-Token.
+func exigirAdmin(proximo http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		admin := configuracao().Admin
+		if len(admin.Usuarios) == 0 {
+			responderErro(w, http.StatusForbidden, RespostaErro{Erro: "área administrativa desativada: cadastre um usuário em 'admin.usuarios' no config.yaml"})
+			return
+		}
+		cookie, err := r.Cookie(cookieSessaoAdmin)
+		if err != nil {
+			responderErro(w, http.StatusUnauthorized, RespostaErro{Erro: "sessão ausente: faça login em " + caminhoAdmin + "/login"})
+			return
+		}
+		sessao, ok := sessoesAdmin.Obter(cookie.Value, admin.Sessao)
+		if ok && !usuarioCadastrado(admin.Usuarios, sessao.usuario) {
+			sessoesAdmin.Encerrar(cookie.Value)
+			ok = false
+		}
+		if !ok {
+			http.SetCookie(w, cookieSessao("", -1))
+			responderErro(w, http.StatusUnauthorized, RespostaErro{Erro: "sessão expirada ou encerrada: faça login novamente"})
+			return
+		}
+		if alteraDados(r.Method) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(cabecalhoCSRF)), []byte(sessao.csrf)) != 1 {
+				responderErro(w, http.StatusForbidden, RespostaErro{Erro: "token CSRF ausente ou inválido: envie o 'csrf' da sessão no cabeçalho " + cabecalhoCSRF})
+				return
+			}
+			log.Printf("Admin '%s': %s %s", sessao.usuario, r.Method, r.URL.Path)
+		}
+		proximo(w, r.WithContext(context.WithValue(r.Context(), chaveSessaoAdmin, sessao)))
+	}
+}
+
+// ============== HANDLERS ==============
+
+// CredenciaisAdmin é o corpo do login.
+type CredenciaisAdmin struct {
+	Usuario string `json:"usuario"`
+	Senha   string `json:"senha"`
+}
+
+// RespostaSessaoAdmin descreve a sessão aberta. O 'csrf' deve ser reenviado no cabeçalho
+// X-CSRF-Token nas requisições que alteram dados.
+type RespostaSessaoAdmin struct {
+	Usuario  string `json:"usuario"`
+	CSRF     string `json:"csrf"`
+	ExpiraEm string `json:"expira_em"`
+}
+
+// novaRespostaSessao converte a sessão para a resposta JSON.
+func novaRespostaSessao(sessao sessaoAdmin) RespostaSessaoAdmin {
+	return RespostaSessaoAdmin{Usuario: sessao.usuario, CSRF: sessao.csrf, ExpiraEm: sessao.expira.Format(time.RFC3339)}
+}
+
+// POST /api/admin/login
+func loginAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	admin := configuracao().Admin
+	if len(admin.Usuarios) == 0 {
+		responderErro(w, http.StatusForbidden, RespostaErro{Erro: "área administrativa desativada: cadastre um usuário em 'admin.usuarios' no config.yaml"})
+		return
+	}
+	// Formulários de outros sites não conseguem enviar JSON sem a permissão do CORS,
+	// o que impede que um site force o login do navegador em outra conta.
+	if tipo, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); tipo != "application/json" {
+		responderErro(w, http.StatusUnsupportedMediaType, RespostaErro{Erro: "envie as credenciais em JSON (Content-Type: application/json)"})
+		return
+	}
+	var credenciais CredenciaisAdmin
+	decodificador := json.NewDecoder(http.MaxBytesReader(w, r.Body, tamanhoMaximoCorpo))
+	decodificador.DisallowUnknownFields()
+	if err := decodificador.Decode(&credenciais); err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: fmt.Sprintf("JSON inválido: %v", err)})
+		return
+	}
+
+	if !autenticar(admin.Usuarios, credenciais.Usuario, credenciais.Senha) {
+		log.Printf("AVISO: login administrativo recusado para '%s' (%s)", credenciais.Usuario, r.RemoteAddr)
+		responderErro(w, http.StatusUnauthorized, RespostaErro{Erro: "usuário ou senha inválidos"})
+		return
+	}
+
+	// Um cookie anterior (de outra conta, por exemplo) deixa de valer.
+	if anterior, err := r.Cookie(cookieSessaoAdmin); err == nil {
+		sessoesAdmin.Encerrar(anterior.Value)
+	}
+	token, sessao := sessoesAdmin.Abrir(credenciais.Usuario, admin.Sessao, admin.SessaoMaxima)
+	http.SetCookie(w, cookieSessao(token, 0))
+	log.Printf("Admin '%s': login (%s)", sessao.usuario, r.RemoteAddr)
+	json.NewEncoder(w).Encode(novaRespostaSessao(sessao))
+}
+
+// POST /api/admin/logout
+func logoutAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(cookieSessaoAdmin); err == nil {
+		sessoesAdmin.Encerrar(cookie.Value)
+	}
+	http.SetCookie(w, cookieSessao("", -1))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/admin/sessao
+func sessaoAdminHandler(w http.ResponseWriter, r *http.Request) {
+	sessao, _ := r.Context().Value(chaveSessaoAdmin).(sessaoAdmin)
+	json.NewEncoder(w).Encode(novaRespostaSessao(sessao))
+}
+
+// RespostaLimpezaCache informa quantas respostas guardadas foram descartadas.
+type RespostaLimpezaCache struct {
+	Removidas int `json:"removidas"`
+}
+
+// POST /api/admin/cache/limpar?prefixo=clima
+func limparCacheHandler(w http.ResponseWriter, r *http.Request) {
+	removidas := cacheAPIs.Limpar(r.URL.Query().Get("prefixo"))
+	json.NewEncoder(w).Encode(RespostaLimpezaCache{Removidas: removidas})
+}
+
+// registrarRotasAdmin registra as rotas da área administrativa. As de frases e alertas
+// reaproveitam os handlers públicos, mas com login em vez dos tokens do config.yaml.
+func registrarRotasAdmin(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+caminhoAdmin+"/login", loginAdminHandler)
+	mux.HandleFunc("POST "+caminhoAdmin+"/logout", exigirAdmin(logoutAdminHandler))
+	mux.HandleFunc("GET "+caminhoAdmin+"/sessao", exigirAdmin(sessaoAdminHandler))
+
+	mux.HandleFunc("GET "+caminhoAdmin+"/frases", exigirAdmin(listarFrasesHandler))
+	mux.HandleFunc("POST "+caminhoAdmin+"/frases", exigirAdmin(criarFraseHandler))
+	mux.HandleFunc("PUT "+caminhoAdmin+"/frases/{id}", exigirAdmin(atualizarFraseHandler))
+	mux.HandleFunc("DELETE "+caminhoAdmin+"/frases/{id}", exigirAdmin(removerFraseHandler))
+
+	mux.HandleFunc("GET "+caminhoAdmin+"/alertas", exigirAdmin(listarAlertasHandler))
+	mux.HandleFunc("GET "+caminhoAdmin+"/alertas/{id}", exigirAdmin(obterAlertaHandler))
+	mux.HandleFunc("POST "+caminhoAdmin+"/alertas", exigirAdmin(criarAlertaHandler))
+	mux.HandleFunc("PUT "+caminhoAdmin+"/alertas/{id}", exigirAdmin(atualizarAlertaHandler))
+	mux.HandleFunc("DELETE "+caminhoAdmin+"/alertas/{id}", exigirAdmin(removerAlertaHandler))
+	mux.HandleFunc("POST "+caminhoAdmin+"/alertas/{id}/testar", exigirAdmin(testarAlertaHandler))
+
+	mux.HandleFunc("POST "+caminhoAdmin+"/cache/limpar", exigirAdmin(limparCacheHandler))
+}
+
+// ============== HASH DE SENHA ==============
+
+// gerarHashSenha lê uma senha (perguntando, se houver um terminal, ou da entrada padrão,
+// ex: echo "$SENHA" | ./app --hash-senha) e imprime o hash bcrypt para o config.yaml.
+func gerarHashSenha() error {
+	var senha string
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		if err := survey.AskOne(&survey.Password{Message: "Senha do usuário administrador:"}, &senha); err != nil {
+			return err
+		}
+	} else {
+		linha, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && linha == "" {
+			return fmt.Errorf("nenhuma senha recebida na entrada padrão: %w", err)
+		}
+		senha = strings.TrimRight(linha, "\r\n")
+	}
+
+	if len([]rune(senha)) < tamanhoMinimoSenha {
+		return fmt.Errorf("a senha deve ter ao menos %d caracteres", tamanhoMinimoSenha)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(senha), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return fmt.Errorf("a senha deve ter no máximo 72 bytes")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(hash))
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// prepararAdminDeTeste cadastra o usuário "ana" (senha "senha-forte"), com sessões de 10
+// minutos sem uso e 1 hora no máximo, e troca as sessões globais por outras com o relógio falso.
+func prepararAdminDeTeste(t *testing.T, relogio *relogioFalso) *http.ServeMux {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("senha-forte"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config Configuracao
+	config.aplicarPadroes()
+	config.Admin = ConfiguracaoAdmin{
+		Usuarios:     []UsuarioAdmin{{Usuario: "ana", Senha: string(hash)}},
+		Sessao:       10 * time.Minute,
+		SessaoMaxima: time.Hour,
+	}
+	configAnterior, sessoesAnteriores, cacheAnterior := configuracaoAtual.Load(), sessoesAdmin, cacheAPIs
+	configuracaoAtual.Store(&config)
+	sessoesAdmin = novasSessoesAdmin(relogio.agora)
+	cacheAPIs = novoCache(relogio.agora)
+	t.Cleanup(func() {
+		configuracaoAtual.Store(configAnterior)
+		sessoesAdmin, cacheAPIs = sessoesAnteriores, cacheAnterior
+	})
+
+	mux := http.NewServeMux()
+	registrarRotasAdmin(mux)
+	return mux
+}
+
+// pedirAdmin faz a requisição à área administrativa com o cookie e o token CSRF informados
+// (vazios: não enviados).
+func pedirAdmin(mux *http.ServeMux, metodo, caminho, corpo, cookie, csrf string) *httptest.ResponseRecorder {
+	pedido := httptest.NewRequest(metodo, caminho, strings.NewReader(corpo))
+	if corpo != "" {
+		pedido.Header.Set("Content-Type", "application/json")
+	}
+	if cookie != "" {
+		pedido.AddCookie(&http.Cookie{Name: cookieSessaoAdmin, Value: cookie})
+	}
+	if csrf != "" {
+		pedido.Header.Set(cabecalhoCSRF, csrf)
+	}
+	gravador := httptest.NewRecorder()
+	mux.ServeHTTP(gravador, pedido)
+	return gravador
+}
+
+// cookieDaResposta devolve o cookie de sessão gravado na resposta.
+func cookieDaResposta(t *testing.T, gravador *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range gravador.Result().Cookies() {
+		if cookie.Name == cookieSessaoAdmin {
+			return cookie
+		}
+	}
+	t.Fatalf("resposta sem o cookie %s", cookieSessaoAdmin)
+	return nil
+}
+
+// entrarComoAdmin faz login e devolve o token do cookie e a sessão recebida.
+func entrarComoAdmin(t *testing.T, mux *http.ServeMux) (string, RespostaSessaoAdmin) {
+	t.Helper()
+	gravador := pedirAdmin(mux, http.MethodPost, "/api/admin/login", `{"usuario": "ana", "senha": "senha-forte"}`, "", "")
+	if gravador.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", gravador.Code, gravador.Body)
+	}
+	var sessao RespostaSessaoAdmin
+	if err := json.Unmarshal(gravador.Body.Bytes(), &sessao); err != nil {
+		t.Fatal(err)
+	}
+	return cookieDaResposta(t, gravador).Value, sessao
+}
+
+func TestAdminLogin(t *testing.T) {
+	relogio := novoRelogioFalso()
+	mux := prepararAdminDeTeste(t, relogio)
+
+	recusas := []struct {
+		nome, corpo, tipo string
+		status            int
+	}{
+		{"senha errada", `{"usuario": "ana", "senha": "senha-fraca"}`, "application/json", http.StatusUnauthorized},
+		{"usuário inexistente", `{"usuario": "bia", "senha": "senha-forte"}`, "application/json", http.StatusUnauthorized},
+		{"formulário", `usuario=ana&senha=senha-forte`, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"campo desconhecido", `{"usuario": "ana", "senha": "senha-forte", "admin": true}`, "application/json", http.StatusBadRequest},
+	}
+	for _, recusa := range recusas {
+		pedido := httptest.NewRequest(http.MethodPost, "/api/admin/login", strings.NewReader(recusa.corpo))
+		pedido.Header.Set("Content-Type", recusa.tipo)
+		gravador := httptest.NewRecorder()
+		mux.ServeHTTP(gravador, pedido)
+		if gravador.Code != recusa.status || len(gravador.Result().Cookies()) != 0 {
+			t.Errorf("%s: status %d com cookies %v, esperava %d sem cookie", recusa.nome, gravador.Code, gravador.Result().Cookies(), recusa.status)
+		}
+	}
+
+	gravador := pedirAdmin(mux, http.MethodPost, "/api/admin/login", `{"usuario": "ana", "senha": "senha-forte"}`, "", "")
+	if gravador.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", gravador.Code, gravador.Body)
+	}
+	// A requisição chegou sem TLS (como atrás de um proxy que encerra o HTTPS), e mesmo
+	// assim o cookie só pode voltar por HTTPS.
+	cookie := cookieDaResposta(t, gravador)
+	if !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Path != caminhoAdmin || cookie.Value == "" {
+		t.Fatalf("cookie = %+v", cookie)
+	}
+	var sessao RespostaSessaoAdmin
+	if err := json.Unmarshal(gravador.Body.Bytes(), &sessao); err != nil {
+		t.Fatal(err)
+	}
+	if sessao.Usuario != "ana" || sessao.CSRF == "" || sessao.CSRF == cookie.Value || sessao.ExpiraEm != relogio.agora().Add(10*time.Minute).Format(time.RFC3339) {
+		t.Fatalf("sessão = %+v", sessao)
+	}
+	if gravador.Header().Get("Cache-Control") != "no-store" {
+		t.Fatal("a resposta do login pode ir para o cache")
+	}
+}
+
+func TestAdminCSRF(t *testing.T) {
+	mux := prepararAdminDeTeste(t, novoRelogioFalso())
+	token, sessao := entrarComoAdmin(t, mux)
+
+	if gravador := pedirAdmin(mux, http.MethodPost, "/api/admin/cache/limpar", "", "", sessao.CSRF); gravador.Code != http.StatusUnauthorized {
+		t.Fatalf("sem cookie: status %d, esperava 401", gravador.Code)
+	}
+	for _, csrf := range []string{"", "token-inventado", token} {
+		if gravador := pedirAdmin(mux, http.MethodPost, "/api/admin/cache/limpar", "", token, csrf); gravador.Code != http.StatusForbidden {
+			t.Errorf("CSRF %q: status %d, esperava 403", csrf, gravador.Code)
+		}
+	}
+	if gravador := pedirAdmin(mux, http.MethodPost, "/api/admin/cache/limpar", "", token, sessao.CSRF); gravador.Code != http.StatusOK {
+		t.Fatalf("com o CSRF da sessão: status %d: %s", gravador.Code, gravador.Body)
+	}
+	// Leituras não exigem o token CSRF.
+	if gravador := pedirAdmin(mux, http.MethodGet, "/api/admin/sessao", "", token, ""); gravador.Code != http.StatusOK {
+		t.Fatalf("GET sem CSRF: status %d", gravador.Code)
+	}
+}
+
+func TestAdminLogout(t *testing.T) {
+	mux := prepararAdminDeTeste(t, novoRelogioFalso())
+	token, sessao := entrarComoAdmin(t, mux)
+
+	// O logout também altera dados: sem o CSRF, um site externo poderia derrubar a sessão.
+	if gravador := pedirAdmin(mux, http.MethodPost, "/api/admin/logout", "", token, ""); gravador.Code != http.StatusForbidden {
+		t.Fatalf("logout sem CSRF: status %d, esperava 403", gravador.Code)
+	}
+	gravador := pedirAdmin(mux, http.MethodPost, "/api/admin/logout", "", token, sessao.CSRF)
+	if gravador.Code != http.StatusNoContent {
+		t.Fatalf("logout: status %d: %s", gravador.Code, gravador.Body)
+	}
+	if cookie := cookieDaResposta(t, gravador); cookie.MaxAge >= 0 || cookie.Value != "" || !cookie.Secure {
+		t.Fatalf("o logout não apagou o cookie: %+v", cookie)
+	}
+	if gravador := pedirAdmin(mux, http.MethodGet, "/api/admin/sessao", "", token, ""); gravador.Code != http.StatusUnauthorized {
+		t.Fatalf("sessão depois do logout: status %d, esperava 401", gravador.Code)
+	}
+}
+
+func TestAdminSessaoDeslizanteComLimite(t *testing.T) {
+	relogio := novoRelogioFalso()
+	mux := prepararAdminDeTeste(t, relogio)
+
+	// Sem uso por 10 minutos, a sessão expira.
+	token, _ := entrarComoAdmin(t, mux)
+	relogio.avancar(10 * time.Minute)
+	if gravador := pedirAdmin(mux, http.MethodGet, "/api/admin/sessao", "", token, ""); gravador.Code != http.StatusUnauthorized {
+		t.Fatalf("sessão ociosa: status %d, esperava 401", gravador.Code)
+	}
+	if cookie := cookieDaResposta(t, pedirAdmin(mux, http.MethodGet, "/api/admin/sessao", "", token, "")); cookie.MaxAge >= 0 {
+		t.Fatalf("a sessão expirada não apagou o cookie: %+v", cookie)
+	}
+
+	// Em uso, cada requisição estende o prazo por mais 10 minutos, até o limite de 1 hora
+	// contado do login.
+	relogio.avancar(5 * time.Minute)
+	login := relogio.agora()
+	token, _ = entrarComoAdmin(t, mux)
+	limite := login.Add(time.Hour)
+	for _, minutos := range []int{8, 16, 24, 32, 40, 48, 56, 59} {
+		relogio.avancar(login.Add(time.Duration(minutos) * time.Minute).Sub(relogio.agora()))
+		gravador := pedirAdmin(mux, http.MethodGet, "/api/admin/sessao", "", token, "")
+		if gravador.Code != http.StatusOK {
+			t.Fatalf("%d minutos depois do login: status %d", minutos, gravador.Code)
+		}
+		var sessao RespostaSessaoAdmin
+		json.Unmarshal(gravador.Body.Bytes(), &sessao)
+		esperado := relogio.agora().Add(10 * time.Minute)
+		if esperado.After(limite) {
+			esperado = limite
+		}
+		if sessao.ExpiraEm != esperado.Format(time.RFC3339) {
+			t.Fatalf("%d minutos depois do login: expira em %s, esperava %s", minutos, sessao.ExpiraEm, esperado.Format(time.RFC3339))
+		}
+	}
+	relogio.avancar(time.Minute)
+	if gravador := pedirAdmin(mux, http.MethodGet, "/api/admin/sessao", "", token, ""); gravador.Code != http.StatusUnauthorized {
+		t.Fatalf("sessão além do limite: status %d, esperava 401", gravador.Code)
+	}
+}
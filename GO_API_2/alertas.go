@@ -0,0 +1,720 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfiguracaoAlertas define onde as regras ficam guardadas, o token da API de alertas
+// e os canais usados para avisar quando uma regra dispara.
+type ConfiguracaoAlertas struct {
+	Arquivo  string               `mapstructure:"arquivo" yaml:"arquivo"`
+	Token    string               `mapstructure:"token" yaml:"token"` // Vazio: a API de alertas fica desativada.
+	Webhook  ConfiguracaoWebhook  `mapstructure:"webhook" yaml:"webhook"`
+	SMTP     ConfiguracaoSMTP     `mapstructure:"smtp" yaml:"smtp"`
+	Telegram ConfiguracaoTelegram `mapstructure:"telegram" yaml:"telegram"`
+}
+
+// Operadores das regras de alerta.
+const (
+	operadorMaior      = ">"
+	operadorMaiorIgual = ">="
+	operadorMenor      = "<"
+	operadorMenorIgual = "<="
+	operadorVariacao   = "variacao" // Variação percentual desde o último disparo.
+	operadorMuda       = "muda"     // Mudança na descrição do clima (ex: "Sunny" -> "Light rain").
+)
+
+// Limites das regras de alerta.
+const (
+	cooldownPadrao          = 15 * time.Minute
+	tamanhoMaximoNomeAlerta = 100
+	prazoEntregaAlerta      = 15 * time.Second
+)
+
+// errAlertaNaoEncontrado indica que não há regra com o ID pedido.
+var errAlertaNaoEncontrado = errors.New("regra de alerta não encontrada")
+
+// Duracao é um time.Duration que aparece no JSON e no YAML como texto ("15m", "1h30m").
+type Duracao time.Duration
+
+func (d Duracao) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duracao) UnmarshalJSON(dados []byte) error {
+	var texto string
+	if err := json.Unmarshal(dados, &texto); err != nil {
+		return fmt.Errorf("use uma duração como \"15m\" ou \"1h\"")
+	}
+	return d.lerTexto(texto)
+}
+
+func (d Duracao) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duracao) UnmarshalYAML(no *yaml.Node) error {
+	return d.lerTexto(no.Value)
+}
+
+// lerTexto interpreta a duração no formato do time.ParseDuration.
+func (d *Duracao) lerTexto(texto string) error {
+	duracao, err := time.ParseDuration(strings.TrimSpace(texto))
+	if err != nil {
+		return fmt.Errorf("duração inválida: '%s' (use, por exemplo, 15m ou 1h)", texto)
+	}
+	*d = Duracao(duracao)
+	return nil
+}
+
+// RegraAlerta diz quando avisar: "bitcoin:brl > 350000", "clima:joao+pessoa < 20"
+// ou "clima:joao+pessoa muda".
+type RegraAlerta struct {
+	ID            int        `json:"id" yaml:"id"`
+	Nome          string     `json:"nome" yaml:"nome"`
+	Fonte         string     `json:"fonte" yaml:"fonte"` // "<ativo>:<moeda>" ou "clima:<cidade>".
+	Operador      string     `json:"operador" yaml:"operador"`
+	Limite        float64    `json:"limite" yaml:"limite"`               // Valor, ou porcentagem no operador "variacao".
+	Cooldown      Duracao    `json:"cooldown" yaml:"cooldown"`           // Tempo mínimo entre dois disparos.
+	Notificadores []string   `json:"notificadores" yaml:"notificadores"` // Vazio: todos os configurados.
+	Pausada       bool       `json:"pausada" yaml:"pausada"`
+	UltimoDisparo *time.Time `json:"ultimo_disparo,omitempty" yaml:"ultimo_disparo,omitempty"`
+}
+
+// Leitura é um novo dado de uma fonte: o preço de uma cotação ou a temperatura e a
+// descrição do clima de uma cidade.
+type Leitura struct {
+	Valor     float64
+	Descricao string
+}
+
+// Alerta é o aviso entregue pelos notificadores quando uma regra dispara.
+type Alerta struct {
+	RegraID   int       `json:"regra_id"`
+	Nome      string    `json:"nome"`
+	Fonte     string    `json:"fonte"`
+	Operador  string    `json:"operador"`
+	Limite    float64   `json:"limite"`
+	Valor     float64   `json:"valor"`
+	Descricao string    `json:"descricao,omitempty"`
+	Mensagem  string    `json:"mensagem"`
+	Momento   time.Time `json:"momento"`
+	Teste     bool      `json:"teste,omitempty"` // Enviado por POST /api/alertas/{id}/testar.
+}
+
+// ============== MOTOR DE ALERTAS ==============
+
+// estadoAlerta é o que o motor lembra de cada regra entre duas leituras (só em memória).
+type estadoAlerta struct {
+	iniciado   bool
+	ativa      bool    // A condição do limite já era verdadeira no último disparo.
+	referencia float64 // Valor do último disparo, para o operador "variacao".
+	descricao  string  // Descrição do último disparo, para o operador "muda".
+}
+
+// MotorAlertas guarda as regras, avalia cada novo dado recebido das APIs externas e
+// entrega os alertas disparados.
+type MotorAlertas struct {
+	mu            sync.Mutex
+	arquivo       string
+	regras        []RegraAlerta // Sempre ordenadas por ID.
+	estados       map[int]*estadoAlerta
+	notificadores map[string]Notificador
+	agora         func() time.Time // Substituível nos testes por um relógio falso.
+	envios        sync.WaitGroup
+}
+
+// arquivoAlertas é o formato do arquivo YAML.
+type arquivoAlertas struct {
+	Regras []RegraAlerta `yaml:"regras"`
+}
+
+// motorAlertas é o motor usado pelos handlers e pelas buscas às APIs externas, criado no main.
+var motorAlertas *MotorAlertas
+
+// carregarAlertas lê as regras do arquivo. Se o arquivo não existir, começa sem regras.
+func carregarAlertas(arquivo string, notificadores map[string]Notificador, agora func() time.Time) (*MotorAlertas, error) {
+	m := &MotorAlertas{arquivo: arquivo, estados: map[int]*estadoAlerta{}, notificadores: notificadores, agora: agora}
+
+	dados, err := os.ReadFile(arquivo)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var conteudo arquivoAlertas
+	if err := yaml.Unmarshal(dados, &conteudo); err != nil {
+		return nil, fmt.Errorf("arquivo '%s' inválido: %w", arquivo, err)
+	}
+	vistos := map[int]bool{}
+	for _, regra := range conteudo.Regras {
+		if err := validarRegra(&regra); err != nil {
+			return nil, fmt.Errorf("regra %d de '%s': %w", regra.ID, arquivo, err)
+		}
+		if regra.ID <= 0 || vistos[regra.ID] {
+			return nil, fmt.Errorf("regra com ID inválido ou repetido em '%s': %d", arquivo, regra.ID)
+		}
+		vistos[regra.ID] = true
+		m.regras = append(m.regras, regra)
+	}
+	sort.Slice(m.regras, func(i, j int) bool { return m.regras[i].ID < m.regras[j].ID })
+	return m, nil
+}
+
+// salvar grava as regras no arquivo. Deve ser chamada com o mutex travado.
+func (m *MotorAlertas) salvar() error {
+	return gravarYAML(m.arquivo, arquivoAlertas{Regras: m.regras})
+}
+
+// validarRegra confere os campos, normaliza a fonte e preenche o nome e o cooldown padrão.
+func validarRegra(regra *RegraAlerta) error {
+	if err := conferirRegra(regra); err != nil {
+		return erroValidacao{err}
+	}
+	return nil
+}
+
+// conferirRegra faz o trabalho de 'validarRegra'.
+func conferirRegra(regra *RegraAlerta) error {
+	fonte, err := normalizarFonte(regra.Fonte)
+	if err != nil {
+		return err
+	}
+	regra.Fonte = fonte
+	regra.Operador = strings.ToLower(strings.TrimSpace(regra.Operador))
+	ehClima := strings.HasPrefix(fonte, eventoClima+":")
+
+	switch regra.Operador {
+	case operadorMaior, operadorMaiorIgual, operadorMenor, operadorMenorIgual:
+	case operadorVariacao:
+		if regra.Limite <= 0 {
+			return fmt.Errorf("no operador 'variacao', o limite é a porcentagem e deve ser maior que zero")
+		}
+	case operadorMuda:
+		if !ehClima {
+			return fmt.Errorf("o operador 'muda' só vale para fontes de clima")
+		}
+	default:
+		return fmt.Errorf("operador inválido: '%s' (use >, >=, <, <=, variacao ou muda)", regra.Operador)
+	}
+	if math.IsNaN(regra.Limite) || math.IsInf(regra.Limite, 0) {
+		return fmt.Errorf("limite inválido")
+	}
+
+	regra.Nome = strings.TrimSpace(regra.Nome)
+	if regra.Nome == "" {
+		regra.Nome = strings.TrimSpace(fmt.Sprintf("%s %s %s", fonte, regra.Operador, limiteComoTexto(*regra)))
+	}
+	if len([]rune(regra.Nome)) > tamanhoMaximoNomeAlerta {
+		return fmt.Errorf("nome muito longo (máximo de %d caracteres)", tamanhoMaximoNomeAlerta)
+	}
+
+	if regra.Cooldown < 0 {
+		return fmt.Errorf("o cooldown não pode ser negativo")
+	}
+	if regra.Cooldown == 0 {
+		regra.Cooldown = Duracao(cooldownPadrao)
+	}
+
+	notificadores := []string{}
+	for _, nome := range regra.Notificadores {
+		nome = strings.ToLower(strings.TrimSpace(nome))
+		switch nome {
+		case notificadorWebhook, notificadorSMTP, notificadorTelegram:
+		default:
+			return fmt.Errorf("notificador inválido: '%s' (use webhook, smtp ou telegram)", nome)
+		}
+		if !slices.Contains(notificadores, nome) {
+			notificadores = append(notificadores, nome)
+		}
+	}
+	regra.Notificadores = notificadores
+	return nil
+}
+
+// normalizarFonte valida a fonte de uma regra e devolve a forma usada nas leituras:
+// "Bitcoin" -> "bitcoin:brl" (moeda padrão) e "clima:São Paulo" -> "clima:sao+paulo".
+func normalizarFonte(fonte string) (string, error) {
+	fonte = strings.TrimSpace(fonte)
+	if fonte == "" {
+		return "", fmt.Errorf("informe a fonte: '<ativo>:<moeda>' (ex: bitcoin:brl) ou 'clima:<cidade>'")
+	}
+	if nome, _, _ := strings.Cut(fonte, ":"); strings.EqualFold(nome, eventoClima) {
+		// Aceita também a forma já normalizada ("clima:sao+paulo"), gravada no arquivo.
+		return normalizarTopico(strings.ReplaceAll(fonte, "+", " "))
+	}
+
+	ativo, moeda, _ := strings.Cut(strings.ToLower(fonte), ":")
+	if moeda == "" && len(configuracao().Padroes.Moedas) > 0 {
+		moeda = strings.ToLower(configuracao().Padroes.Moedas[0])
+	}
+	if !identificadorValido.MatchString(ativo) || !identificadorValido.MatchString(moeda) {
+		return "", fmt.Errorf("fonte inválida: '%s' (use '<ativo>:<moeda>', ex: bitcoin:brl, ou 'clima:<cidade>')", fonte)
+	}
+	return ativo + ":" + moeda, nil
+}
+
+// limiteComoTexto formata o limite da regra para nomes e mensagens.
+func limiteComoTexto(regra RegraAlerta) string {
+	switch regra.Operador {
+	case operadorMuda:
+		return ""
+	case operadorVariacao:
+		return strconv.FormatFloat(regra.Limite, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatFloat(regra.Limite, 'f', -1, 64)
+}
+
+// Listar devolve todas as regras, ordenadas por ID.
+func (m *MotorAlertas) Listar() []RegraAlerta {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]RegraAlerta{}, m.regras...)
+}
+
+// Obter devolve a regra com o ID informado.
+func (m *MotorAlertas) Obter(id int) (RegraAlerta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.indice(id); i >= 0 {
+		return m.regras[i], nil
+	}
+	return RegraAlerta{}, errAlertaNaoEncontrado
+}
+
+// indice devolve a posição da regra na lista, ou -1. Deve ser chamada com o mutex travado.
+func (m *MotorAlertas) indice(id int) int {
+	for i, regra := range m.regras {
+		if regra.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Adicionar cadastra a regra com um novo ID e devolve a regra gravada.
+func (m *MotorAlertas) Adicionar(regra RegraAlerta) (RegraAlerta, error) {
+	if err := validarRegra(&regra); err != nil {
+		return RegraAlerta{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	regra.ID = 1
+	if len(m.regras) > 0 {
+		regra.ID = m.regras[len(m.regras)-1].ID + 1
+	}
+	regra.UltimoDisparo = nil
+	m.regras = append(m.regras, regra)
+	if err := m.salvar(); err != nil {
+		m.regras = m.regras[:len(m.regras)-1]
+		return RegraAlerta{}, err
+	}
+	return regra, nil
+}
+
+// Atualizar substitui a regra. A avaliação recomeça do zero, mas o cooldown do último
+// disparo continua valendo.
+func (m *MotorAlertas) Atualizar(id int, regra RegraAlerta) (RegraAlerta, error) {
+	if err := validarRegra(&regra); err != nil {
+		return RegraAlerta{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.indice(id)
+	if i < 0 {
+		return RegraAlerta{}, errAlertaNaoEncontrado
+	}
+	anterior := m.regras[i]
+	regra.ID = id
+	regra.UltimoDisparo = anterior.UltimoDisparo
+	m.regras[i] = regra
+	if err := m.salvar(); err != nil {
+		m.regras[i] = anterior
+		return RegraAlerta{}, err
+	}
+	delete(m.estados, id)
+	return regra, nil
+}
+
+// Remover apaga a regra.
+func (m *MotorAlertas) Remover(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.indice(id)
+	if i < 0 {
+		return errAlertaNaoEncontrado
+	}
+	anteriores := m.regras
+	m.regras = append(append([]RegraAlerta{}, m.regras[:i]...), m.regras[i+1:]...)
+	if err := m.salvar(); err != nil {
+		m.regras = anteriores
+		return err
+	}
+	delete(m.estados, id)
+	return nil
+}
+
+// ============== AVALIAÇÃO ==============
+
+// AvaliarCotacoes confere as regras de cada cotação recebida dos provedores de mercado.
+func (m *MotorAlertas) AvaliarCotacoes(cotacoes []Cotacao) {
+	if m == nil {
+		return
+	}
+	for _, cotacao := range cotacoes {
+		m.Avaliar(cotacao.Ativo+":"+cotacao.Moeda, Leitura{Valor: cotacao.Preco})
+	}
+}
+
+// AvaliarClima confere as regras do clima recebido dos provedores para a cidade (já normalizada).
+func (m *MotorAlertas) AvaliarClima(cidade string, clima Clima) {
+	if m == nil {
+		return
+	}
+	m.Avaliar(eventoClima+":"+strings.ToLower(cidade), Leitura{Valor: clima.TemperaturaC, Descricao: clima.Descricao})
+}
+
+// Avaliar confere as regras da fonte com a nova leitura e entrega os alertas disparados.
+func (m *MotorAlertas) Avaliar(fonte string, leitura Leitura) {
+	m.mu.Lock()
+	agora := m.agora()
+	var alertas []Alerta
+	var destinos [][]string
+	for i := range m.regras {
+		regra := &m.regras[i]
+		if regra.Fonte != fonte || regra.Pausada {
+			continue
+		}
+		estado := m.estados[regra.ID]
+		if estado == nil {
+			estado = &estadoAlerta{}
+			m.estados[regra.ID] = estado
+		}
+		mensagem, disparou := estado.avaliar(*regra, leitura, regra.UltimoDisparo, agora)
+		if !disparou {
+			continue
+		}
+		momento := agora
+		regra.UltimoDisparo = &momento
+		alertas = append(alertas, novoAlerta(*regra, leitura, mensagem, agora))
+		destinos = append(destinos, regra.Notificadores)
+	}
+	if len(alertas) > 0 {
+		if err := m.salvar(); err != nil {
+			log.Printf("AVISO: alertas: falha ao gravar o último disparo: %v", err)
+		}
+	}
+	m.mu.Unlock()
+
+	for i, alerta := range alertas {
+		m.entregar(alerta, destinos[i])
+	}
+}
+
+// avaliar decide se a regra dispara com a nova leitura e devolve a mensagem do alerta.
+// Os limites só disparam quando a condição passa a ser verdadeira (e não a cada leitura
+// acima dele); nenhum operador dispara antes de terminar o cooldown do último disparo.
+func (e *estadoAlerta) avaliar(regra RegraAlerta, leitura Leitura, ultimoDisparo *time.Time, agora time.Time) (string, bool) {
+	if !e.iniciado {
+		// A primeira leitura só serve de referência para "variacao" e "muda".
+		e.iniciado = true
+		e.referencia = leitura.Valor
+		e.descricao = leitura.Descricao
+		if regra.Operador == operadorVariacao || regra.Operador == operadorMuda {
+			return "", false
+		}
+	}
+	emCooldown := ultimoDisparo != nil && agora.Sub(*ultimoDisparo) < time.Duration(regra.Cooldown)
+
+	switch regra.Operador {
+	case operadorVariacao:
+		if e.referencia == 0 {
+			e.referencia = leitura.Valor
+			return "", false
+		}
+		variacao := (leitura.Valor - e.referencia) / e.referencia * 100
+		if math.Abs(variacao) < regra.Limite || emCooldown {
+			return "", false
+		}
+		mensagem := fmt.Sprintf("%s variou %+.2f%% (de %.2f para %.2f)", regra.Fonte, variacao, e.referencia, leitura.Valor)
+		e.referencia = leitura.Valor
+		return mensagem, true
+
+	case operadorMuda:
+		if strings.EqualFold(leitura.Descricao, e.descricao) || emCooldown {
+			return "", false
+		}
+		mensagem := fmt.Sprintf("o clima em %s mudou de '%s' para '%s' (%.0f °C)",
+			strings.TrimPrefix(regra.Fonte, eventoClima+":"), e.descricao, leitura.Descricao, leitura.Valor)
+		e.descricao = leitura.Descricao
+		return mensagem, true
+	}
+
+	if !condicaoAtendida(regra.Operador, leitura.Valor, regra.Limite) {
+		e.ativa = false
+		return "", false
+	}
+	if e.ativa || emCooldown {
+		return "", false
+	}
+	e.ativa = true
+	return fmt.Sprintf("%s está em %.2f (%s %s)", regra.Fonte, leitura.Valor, regra.Operador, limiteComoTexto(regra)), true
+}
+
+// condicaoAtendida compara o valor com o limite usando o operador da regra.
+func condicaoAtendida(operador string, valor, limite float64) bool {
+	switch operador {
+	case operadorMaior:
+		return valor > limite
+	case operadorMaiorIgual:
+		return valor >= limite
+	case operadorMenor:
+		return valor < limite
+	case operadorMenorIgual:
+		return valor <= limite
+	}
+	return false
+}
+
+// novoAlerta monta o aviso de uma regra disparada.
+func novoAlerta(regra RegraAlerta, leitura Leitura, mensagem string, momento time.Time) Alerta {
+	return Alerta{
+		RegraID:   regra.ID,
+		Nome:      regra.Nome,
+		Fonte:     regra.Fonte,
+		Operador:  regra.Operador,
+		Limite:    regra.Limite,
+		Valor:     leitura.Valor,
+		Descricao: leitura.Descricao,
+		Mensagem:  mensagem,
+		Momento:   momento,
+	}
+}
+
+// ============== ENTREGA ==============
+
+// destinatarios devolve os notificadores pedidos pela regra (todos os configurados,
+// se a lista estiver vazia), avisando no log sobre os que não estão configurados.
+func (m *MotorAlertas) destinatarios(nomes []string) []Notificador {
+	if len(nomes) == 0 {
+		for nome := range m.notificadores {
+			nomes = append(nomes, nome)
+		}
+		sort.Strings(nomes)
+	}
+	var lista []Notificador
+	for _, nome := range nomes {
+		notificador, ok := m.notificadores[nome]
+		if !ok {
+			log.Printf("AVISO: alertas: notificador '%s' não configurado em alertas.%s", nome, nome)
+			continue
+		}
+		lista = append(lista, notificador)
+	}
+	return lista
+}
+
+// entregar envia o alerta em segundo plano, para que uma API de notificação lenta não
+// atrase a resposta que trouxe o dado novo.
+func (m *MotorAlertas) entregar(alerta Alerta, nomes []string) {
+	notificadores := m.destinatarios(nomes)
+	if len(notificadores) == 0 {
+		log.Printf("AVISO: alerta '%s' disparado sem notificadores configurados: %s", alerta.Nome, alerta.Mensagem)
+		return
+	}
+	for _, notificador := range notificadores {
+		m.envios.Add(1)
+		go func() {
+			defer m.envios.Done()
+			ctx, cancelar := context.WithTimeout(context.Background(), prazoEntregaAlerta)
+			defer cancelar()
+			if err := notificador.Notificar(ctx, alerta); err != nil {
+				log.Printf("AVISO: falha ao enviar o alerta '%s' por %s: %v", alerta.Nome, notificador.Nome(), err)
+			}
+		}()
+	}
+}
+
+// Aguardar espera os alertas que ainda estão sendo enviados.
+func (m *MotorAlertas) Aguardar() {
+	m.envios.Wait()
+}
+
+// Testar envia um alerta de teste da regra por cada notificador, na hora, e devolve o
+// resultado de cada um ("ok" ou a mensagem de erro).
+func (m *MotorAlertas) Testar(ctx context.Context, id int) (map[string]string, error) {
+	regra, err := m.Obter(id)
+	if err != nil {
+		return nil, err
+	}
+	alerta := novoAlerta(regra, Leitura{}, fmt.Sprintf("alerta de teste da regra '%s %s %s'",
+		regra.Fonte, regra.Operador, limiteComoTexto(regra)), m.agora())
+	alerta.Teste = true
+
+	resultados := map[string]string{}
+	ctx, cancelar := context.WithTimeout(ctx, prazoEntregaAlerta)
+	defer cancelar()
+	for _, notificador := range m.destinatarios(regra.Notificadores) {
+		resultados[notificador.Nome()] = "ok"
+		if err := notificador.Notificar(ctx, alerta); err != nil {
+			resultados[notificador.Nome()] = err.Error()
+		}
+	}
+	return resultados, nil
+}
+
+// AtualizarFontes busca os dados de todas as fontes usadas pelas regras ativas, para que
+// elas sejam avaliadas mesmo sem nenhum navegador aberto. As APIs externas só são
+// chamadas quando o cache vence; é a busca que avalia as regras.
+func (m *MotorAlertas) AtualizarFontes() {
+	fontes := map[string]bool{}
+	for _, regra := range m.Listar() {
+		if !regra.Pausada {
+			fontes[regra.Fonte] = true
+		}
+	}
+	for fonte := range fontes {
+		var err error
+		if cidade, ehClima := strings.CutPrefix(fonte, eventoClima+":"); ehClima {
+			_, _, err = obterClima(cidade)
+		} else {
+			ativo, moeda, _ := strings.Cut(fonte, ":")
+			_, _, err = obterCotacoes([]string{ativo}, []string{moeda})
+		}
+		if err != nil {
+			log.Printf("AVISO: alertas sem dados atualizados de '%s': %v", fonte, err)
+		}
+	}
+}
+
+// ============== HANDLERS /api/alertas ==============
+
+// lerRegra decodifica o corpo JSON da requisição.
+func lerRegra(w http.ResponseWriter, r *http.Request) (RegraAlerta, bool) {
+	var regra RegraAlerta
+	decodificador := json.NewDecoder(http.MaxBytesReader(w, r.Body, tamanhoMaximoCorpo))
+	decodificador.DisallowUnknownFields()
+	if err := decodificador.Decode(&regra); err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: fmt.Sprintf("JSON inválido: %v", err)})
+		return RegraAlerta{}, false
+	}
+	return regra, true
+}
+
+// responderErroAlerta traduz os erros do motor em 404, 400 ou 500.
+func responderErroAlerta(w http.ResponseWriter, id int, err error) {
+	var invalida erroValidacao
+	switch {
+	case errors.Is(err, errAlertaNaoEncontrado):
+		responderErro(w, http.StatusNotFound, RespostaErro{Erro: fmt.Sprintf("nenhuma regra de alerta encontrada com o ID %d", id)})
+	case errors.As(err, &invalida):
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
+	default:
+		log.Printf("ERRO: falha ao gravar o arquivo de alertas: %v", err)
+		responderErro(w, http.StatusInternalServerError, RespostaErro{Erro: "falha ao gravar o arquivo de alertas"})
+	}
+}
+
+// GET /api/alertas
+func listarAlertasHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(motorAlertas.Listar())
+}
+
+// GET /api/alertas/{id}
+func obterAlertaHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDaRota(w, r)
+	if !ok {
+		return
+	}
+	regra, err := motorAlertas.Obter(id)
+	if err != nil {
+		responderErroAlerta(w, id, err)
+		return
+	}
+	json.NewEncoder(w).Encode(regra)
+}
+
+// POST /api/alertas
+func criarAlertaHandler(w http.ResponseWriter, r *http.Request) {
+	regra, ok := lerRegra(w, r)
+	if !ok {
+		return
+	}
+	regra, err := motorAlertas.Adicionar(regra)
+	if err != nil {
+		responderErroAlerta(w, 0, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/alertas/%d", regra.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(regra)
+}
+
+// PUT /api/alertas/{id}
+func atualizarAlertaHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDaRota(w, r)
+	if !ok {
+		return
+	}
+	regra, ok := lerRegra(w, r)
+	if !ok {
+		return
+	}
+	regra, err := motorAlertas.Atualizar(id, regra)
+	if err != nil {
+		responderErroAlerta(w, id, err)
+		return
+	}
+	json.NewEncoder(w).Encode(regra)
+}
+
+// DELETE /api/alertas/{id}
+func removerAlertaHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDaRota(w, r)
+	if !ok {
+		return
+	}
+	if err := motorAlertas.Remover(id); err != nil {
+		responderErroAlerta(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/alertas/{id}/testar
+func testarAlertaHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDaRota(w, r)
+	if !ok {
+		return
+	}
+	resultados, err := motorAlertas.Testar(r.Context(), id)
+	if err != nil {
+		responderErroAlerta(w, id, err)
+		return
+	}
+	if len(resultados) == 0 {
+		responderErro(w, http.StatusConflict, RespostaErro{Erro: "nenhum notificador configurado para esta regra (veja 'alertas' no config.yaml)"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"resultados": resultados})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// notificadorFalso guarda os alertas recebidos em vez de entregá-los.
+type notificadorFalso struct {
+	mu      sync.Mutex
+	alertas []Alerta
+}
+
+func (n *notificadorFalso) Nome() string { return notificadorWebhook }
+
+func (n *notificadorFalso) Notificar(ctx context.Context, alerta Alerta) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alertas = append(n.alertas, alerta)
+	return nil
+}
+
+// recebidos devolve as mensagens dos alertas recebidos desde a última chamada.
+func (n *notificadorFalso) recebidos() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var mensagens []string
+	for _, alerta := range n.alertas {
+		mensagens = append(mensagens, alerta.Mensagem)
+	}
+	n.alertas = nil
+	return mensagens
+}
+
+// novoMotorDeTeste cria um motor sem regras, com o relógio falso e o notificador falso.
+func novoMotorDeTeste(t *testing.T, relogio *relogioFalso) (*MotorAlertas, *notificadorFalso) {
+	t.Helper()
+	notificador := &notificadorFalso{}
+	motor, err := carregarAlertas(filepath.Join(t.TempDir(), "alertas.yaml"), map[string]Notificador{notificadorWebhook: notificador}, relogio.agora)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return motor, notificador
+}
+
+// passoAlerta é uma leitura recebida depois de 'avancar', com os alertas esperados.
+type passoAlerta struct {
+	avancar time.Duration
+	leitura Leitura
+	alertas []string
+}
+
+// conferirPassos alimenta o motor com as leituras da fonte e confere os alertas de cada uma.
+func conferirPassos(t *testing.T, motor *MotorAlertas, notificador *notificadorFalso, relogio *relogioFalso, fonte string, passos []passoAlerta) {
+	t.Helper()
+	for i, passo := range passos {
+		relogio.avancar(passo.avancar)
+		motor.Avaliar(fonte, passo.leitura)
+		motor.Aguardar()
+		if obtidos := notificador.recebidos(); strings.Join(obtidos, "\n") != strings.Join(passo.alertas, "\n") {
+			t.Fatalf("passo %d (%+v): alertas %q, esperava %q", i+1, passo.leitura, obtidos, passo.alertas)
+		}
+	}
+}
+
+func TestAlertaLimiteCruzamentoECooldown(t *testing.T) {
+	relogio := novoRelogioFalso()
+	motor, notificador := novoMotorDeTeste(t, relogio)
+	regra, err := motor.Adicionar(RegraAlerta{Fonte: "bitcoin:brl", Operador: ">", Limite: 100, Cooldown: Duracao(10 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conferirPassos(t, motor, notificador, relogio, "bitcoin:brl", []passoAlerta{
+		{0, Leitura{Valor: 90}, nil},
+		{time.Minute, Leitura{Valor: 110}, []string{"bitcoin:brl está em 110.00 (> 100)"}},
+		// Continuar acima do limite não dispara de novo.
+		{time.Minute, Leitura{Valor: 120}, nil},
+		{time.Minute, Leitura{Valor: 95}, nil},
+		// Cruzou de novo, mas ainda no cooldown de 10 minutos.
+		{time.Minute, Leitura{Valor: 105}, nil},
+		// Terminado o cooldown, a condição ainda verdadeira dispara o alerta que foi segurado.
+		{8 * time.Minute, Leitura{Valor: 106}, []string{"bitcoin:brl está em 106.00 (> 100)"}},
+		{time.Hour, Leitura{Valor: 107}, nil},
+	})
+
+	// O último disparo fica gravado na regra, para valer o cooldown depois de reiniciar.
+	gravada, err := motor.Obter(regra.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esperado := novoRelogioFalso().agora().Add(12 * time.Minute); gravada.UltimoDisparo == nil || !gravada.UltimoDisparo.Equal(esperado) {
+		t.Fatalf("último disparo = %v, esperava %s", gravada.UltimoDisparo, esperado)
+	}
+
+	// Outras fontes e regras pausadas não disparam.
+	motor.Avaliar("bitcoin:usd", Leitura{Valor: 1})
+	gravada.Pausada = true
+	if _, err := motor.Atualizar(regra.ID, gravada); err != nil {
+		t.Fatal(err)
+	}
+	conferirPassos(t, motor, notificador, relogio, "bitcoin:brl", []passoAlerta{
+		{time.Hour, Leitura{Valor: 90}, nil},
+		{time.Hour, Leitura{Valor: 200}, nil},
+	})
+}
+
+func TestAlertaVariacao(t *testing.T) {
+	relogio := novoRelogioFalso()
+	motor, notificador := novoMotorDeTeste(t, relogio)
+	if _, err := motor.Adicionar(RegraAlerta{Fonte: "bitcoin:brl", Operador: "variacao", Limite: 5, Cooldown: Duracao(2 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	conferirPassos(t, motor, notificador, relogio, "bitcoin:brl", []passoAlerta{
+		// A primeira leitura é só a referência.
+		{0, Leitura{Valor: 100}, nil},
+		{time.Minute, Leitura{Valor: 104}, nil},
+		{time.Minute, Leitura{Valor: 106}, []string{"bitcoin:brl variou +6.00% (de 100.00 para 106.00)"}},
+		// A referência passa a ser o valor do disparo.
+		{time.Minute, Leitura{Valor: 110}, nil},
+		// Variação suficiente, mas dentro do cooldown: a referência não muda.
+		{20 * time.Second, Leitura{Valor: 100}, nil},
+		{time.Minute, Leitura{Valor: 100}, []string{"bitcoin:brl variou -5.66% (de 106.00 para 100.00)"}},
+	})
+}
+
+func TestAlertaMudancaDeClima(t *testing.T) {
+	relogio := novoRelogioFalso()
+	motor, notificador := novoMotorDeTeste(t, relogio)
+	regra, err := motor.Adicionar(RegraAlerta{Fonte: "clima:João Pessoa", Operador: "muda", Cooldown: Duracao(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conferirPassos(t, motor, notificador, relogio, regra.Fonte, []passoAlerta{
+		{0, Leitura{Valor: 30, Descricao: "Sunny"}, nil},
+		{time.Minute, Leitura{Valor: 31, Descricao: "sunny"}, nil},
+		{time.Minute, Leitura{Valor: 27, Descricao: "Light rain"}, []string{"o clima em " + strings.TrimPrefix(regra.Fonte, "clima:") + " mudou de 'Sunny' para 'Light rain' (27 °C)"}},
+		// Dentro do cooldown, a mudança não dispara nem vira a nova referência.
+		{time.Minute, Leitura{Valor: 29, Descricao: "Partly cloudy"}, nil},
+		{time.Hour, Leitura{Valor: 29, Descricao: "Partly cloudy"}, []string{"o clima em " + strings.TrimPrefix(regra.Fonte, "clima:") + " mudou de 'Light rain' para 'Partly cloudy' (29 °C)"}},
+	})
+}
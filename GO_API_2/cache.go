@@ -0,0 +1,175 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Valores do cabeçalho X-Cache, que informam ao navegador de onde veio a resposta.
+const (
+	cacheHit      = "HIT"   // Resposta dentro do TTL, sem chamar a API externa.
+	cacheMiss     = "MISS"  // Não havia resposta guardada: a API externa foi chamada.
+	cacheObsoleto = "STALE" // TTL vencido: devolvemos a resposta antiga e atualizamos em segundo plano.
+)
+
+// PoliticaCache define por quanto tempo uma resposta é considerada nova (TTL) e por
+// quanto tempo, depois disso, ela ainda pode ser servida enquanto é atualizada
+// em segundo plano (stale-while-revalidate).
+type PoliticaCache struct {
+	TTL      time.Duration `mapstructure:"ttl" yaml:"ttl"`
+	Obsoleto time.Duration `mapstructure:"obsoleto" yaml:"obsoleto"`
+}
+
+// entradaCache é uma resposta guardada e o momento em que foi obtida.
+type entradaCache struct {
+	valor    []byte
+	obtidoEm time.Time
+	venceEm  time.Time // Fim do TTL + obsoleto: depois disso a resposta não serve mais.
+}
+
+// maxEntradasCache limita as respostas guardadas. As chaves incluem o que o usuário
+// digita (como a cidade do clima), então sem limite a memória cresceria sem fim.
+const maxEntradasCache = 1000
+
+// chamadaEmAndamento representa uma busca na API externa que ainda não terminou.
+// Quem pedir a mesma chave nesse meio tempo espera por ela em vez de fazer outra chamada.
+type chamadaEmAndamento struct {
+	pronto chan struct{}
+	valor  []byte
+	err    error
+}
+
+// Cache guarda em memória as respostas das APIs externas.
+type Cache struct {
+	mu          sync.Mutex
+	entradas    map[string]entradaCache
+	emAndamento map[string]*chamadaEmAndamento
+	maxEntradas int
+	agora       func() time.Time // Substituível nos testes por um relógio falso.
+}
+
+// novoCache cria um cache vazio que usa o relógio informado.
+func novoCache(agora func() time.Time) *Cache {
+	return &Cache{
+		entradas:    map[string]entradaCache{},
+		emAndamento: map[string]*chamadaEmAndamento{},
+		maxEntradas: maxEntradasCache,
+		agora:       agora,
+	}
+}
+
+// cacheAPIs é o cache compartilhado pelos handlers das APIs externas.
+var cacheAPIs = novoCache(time.Now)
+
+// Obter devolve o valor da chave e o estado do cache (HIT, MISS ou STALE).
+// 'buscar' só é chamada quando não há valor novo guardado, e nunca mais de uma vez
+// ao mesmo tempo para a mesma chave.
+func (c *Cache) Obter(chave string, politica PoliticaCache, buscar func() ([]byte, error)) ([]byte, string, error) {
+	valor, estado, err := c.obter(chave, politica, buscar)
+	metricas.RegistrarCache(chave, estado)
+	return valor, estado, err
+}
+
+// obter faz o trabalho de 'Obter'.
+func (c *Cache) obter(chave string, politica PoliticaCache, buscar func() ([]byte, error)) ([]byte, string, error) {
+	c.mu.Lock()
+	entrada, existe := c.entradas[chave]
+	if existe {
+		idade := c.agora().Sub(entrada.obtidoEm)
+		if idade < politica.TTL {
+			c.mu.Unlock()
+			return entrada.valor, cacheHit, nil
+		}
+		if idade < politica.TTL+politica.Obsoleto {
+			// Serve o valor antigo e dispara a atualização, se ninguém já tiver disparado.
+			if _, atualizando := c.emAndamento[chave]; !atualizando {
+				chamada := c.iniciarChamada(chave)
+				go func() {
+					if err := c.executarChamada(chave, politica, chamada, buscar); err != nil {
+						log.Printf("AVISO: falha ao atualizar o cache de '%s': %v", chave, err)
+					}
+				}()
+			}
+			c.mu.Unlock()
+			return entrada.valor, cacheObsoleto, nil
+		}
+		// Vencida de vez: não serve nem se a nova busca falhar.
+		delete(c.entradas, chave)
+	}
+
+	// Sem valor utilizável: espera a chamada em andamento ou inicia uma nova.
+	chamada, andamento := c.emAndamento[chave]
+	if !andamento {
+		chamada = c.iniciarChamada(chave)
+	}
+	c.mu.Unlock()
+
+	if andamento {
+		<-chamada.pronto
+		return chamada.valor, cacheMiss, chamada.err
+	}
+	err := c.executarChamada(chave, politica, chamada, buscar)
+	return chamada.valor, cacheMiss, err
+}
+
+// iniciarChamada registra uma chamada em andamento. Deve ser chamada com o mutex travado.
+func (c *Cache) iniciarChamada(chave string) *chamadaEmAndamento {
+	chamada := &chamadaEmAndamento{pronto: make(chan struct{})}
+	c.emAndamento[chave] = chamada
+	return chamada
+}
+
+// executarChamada busca o valor, guarda-o se der certo e libera quem estiver esperando.
+func (c *Cache) executarChamada(chave string, politica PoliticaCache, chamada *chamadaEmAndamento, buscar func() ([]byte, error)) error {
+	chamada.valor, chamada.err = buscar()
+
+	c.mu.Lock()
+	if chamada.err == nil {
+		agora := c.agora()
+		if _, existe := c.entradas[chave]; !existe {
+			c.abrirEspaco(agora)
+		}
+		c.entradas[chave] = entradaCache{valor: chamada.valor, obtidoEm: agora, venceEm: agora.Add(politica.TTL + politica.Obsoleto)}
+	}
+	delete(c.emAndamento, chave)
+	c.mu.Unlock()
+
+	close(chamada.pronto)
+	return chamada.err
+}
+
+// abrirEspaco descarta as respostas vencidas e, se o cache ainda estiver cheio, a mais
+// antiga, para caber uma chave nova. Deve ser chamada com o mutex travado.
+func (c *Cache) abrirEspaco(agora time.Time) {
+	for chave, entrada := range c.entradas {
+		if !agora.Before(entrada.venceEm) {
+			delete(c.entradas, chave)
+		}
+	}
+	for len(c.entradas) >= c.maxEntradas {
+		maisAntiga, obtidoEm := "", time.Time{}
+		for chave, entrada := range c.entradas {
+			if obtidoEm.IsZero() || entrada.obtidoEm.Before(obtidoEm) {
+				maisAntiga, obtidoEm = chave, entrada.obtidoEm
+			}
+		}
+		delete(c.entradas, maisAntiga)
+	}
+}
+
+// Limpar descarta as respostas guardadas cujas chaves começam com o prefixo (todas, se
+// vazio) e devolve quantas foram removidas. Buscas em andamento não são interrompidas.
+func (c *Cache) Limpar(prefixo string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removidas := 0
+	for chave := range c.entradas {
+		if strings.HasPrefix(chave, prefixo) {
+			delete(c.entradas, chave)
+			removidas++
+		}
+	}
+	return removidas
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// relogioFalso é um relógio controlado pelo teste, para os campos 'agora' das estruturas.
+type relogioFalso struct {
+	mu      sync.Mutex
+	momento time.Time
+}
+
+func novoRelogioFalso() *relogioFalso {
+	return &relogioFalso{momento: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+}
+
+// agora é usada no lugar de time.Now.
+func (r *relogioFalso) agora() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.momento
+}
+
+// avancar passa o tempo simulado.
+func (r *relogioFalso) avancar(d time.Duration) {
+	r.mu.Lock()
+	r.momento = r.momento.Add(d)
+	r.mu.Unlock()
+}
+
+// esperarAtualizacoes espera terminarem as buscas disparadas em segundo plano pelo cache.
+func esperarAtualizacoes(t *testing.T, c *Cache) {
+	t.Helper()
+	for limite := time.Now().Add(2 * time.Second); time.Now().Before(limite); time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		pendentes := len(c.emAndamento)
+		c.mu.Unlock()
+		if pendentes == 0 {
+			return
+		}
+	}
+	t.Fatal("a atualização em segundo plano não terminou")
+}
+
+func TestCacheEstados(t *testing.T) {
+	relogio := novoRelogioFalso()
+	c := novoCache(relogio.agora)
+	politica := PoliticaCache{TTL: time.Minute, Obsoleto: 5 * time.Minute}
+
+	var buscas atomic.Int32
+	falhar := false
+	buscar := func() ([]byte, error) {
+		n := buscas.Add(1)
+		if falhar {
+			return nil, errors.New("api fora do ar")
+		}
+		return fmt.Appendf(nil, "v%d", n), nil
+	}
+	conferir := func(passo, valorEsperado, estadoEsperado string, buscasEsperadas int32) {
+		t.Helper()
+		valor, estado, err := c.Obter("chave", politica, buscar)
+		if err != nil {
+			t.Fatalf("%s: %v", passo, err)
+		}
+		if string(valor) != valorEsperado || estado != estadoEsperado {
+			t.Fatalf("%s: (%s, %s), esperava (%s, %s)", passo, valor, estado, valorEsperado, estadoEsperado)
+		}
+		if n := buscas.Load(); n != buscasEsperadas {
+			t.Fatalf("%s: %d busca(s), esperava %d", passo, n, buscasEsperadas)
+		}
+	}
+
+	conferir("primeira chamada", "v1", cacheMiss, 1)
+	relogio.avancar(59 * time.Second)
+	conferir("dentro do TTL", "v1", cacheHit, 1)
+
+	// Vencido, mas dentro da janela de obsoleto: devolve o antigo e atualiza em segundo plano.
+	relogio.avancar(time.Minute)
+	conferir("obsoleto", "v1", cacheObsoleto, 1)
+	esperarAtualizacoes(t, c)
+	conferir("depois da atualização", "v2", cacheHit, 2)
+
+	// Além do TTL + obsoleto, o valor antigo não serve mais: a busca é feita na hora.
+	relogio.avancar(6 * time.Minute)
+	conferir("expirado", "v3", cacheMiss, 3)
+
+	// Falhas não são guardadas: a próxima chamada tenta de novo.
+	relogio.avancar(10 * time.Minute)
+	falhar = true
+	for range 2 {
+		if _, estado, err := c.Obter("chave", politica, buscar); err == nil || estado != cacheMiss {
+			t.Fatalf("esperava MISS com erro, veio (%s, %v)", estado, err)
+		}
+	}
+	if n := buscas.Load(); n != 5 {
+		t.Fatalf("%d busca(s), esperava 5", n)
+	}
+}
+
+func TestCacheObsoletoComFalhaMantemValor(t *testing.T) {
+	relogio := novoRelogioFalso()
+	c := novoCache(relogio.agora)
+	politica := PoliticaCache{TTL: time.Minute, Obsoleto: 5 * time.Minute}
+
+	c.Obter("chave", politica, func() ([]byte, error) { return []byte("antigo"), nil })
+	relogio.avancar(2 * time.Minute)
+	falha := func() ([]byte, error) { return nil, errors.New("api fora do ar") }
+	for range 2 {
+		valor, estado, err := c.Obter("chave", politica, falha)
+		esperarAtualizacoes(t, c)
+		if err != nil || string(valor) != "antigo" || estado != cacheObsoleto {
+			t.Fatalf("(%s, %s, %v), esperava o valor antigo como STALE", valor, estado, err)
+		}
+	}
+}
+
+func TestCacheUmaBuscaPorChave(t *testing.T) {
+	c := novoCache(novoRelogioFalso().agora)
+	politica := PoliticaCache{TTL: time.Minute, Obsoleto: time.Minute}
+
+	var buscas atomic.Int32
+	liberar := make(chan struct{})
+	buscar := func() ([]byte, error) {
+		buscas.Add(1)
+		<-liberar
+		return []byte("valor"), nil
+	}
+
+	const concorrentes = 50
+	var wg sync.WaitGroup
+	erros := make(chan error, concorrentes)
+	for range concorrentes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			valor, _, err := c.Obter("chave", politica, buscar)
+			if err == nil && string(valor) != "valor" {
+				err = fmt.Errorf("valor inesperado %q", valor)
+			}
+			erros <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(liberar)
+	wg.Wait()
+	close(erros)
+
+	for err := range erros {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := buscas.Load(); n != 1 {
+		t.Fatalf("%d buscas para %d pedidos simultâneos, esperava 1", n, concorrentes)
+	}
+}
+
+// chavesDoCache devolve as chaves guardadas, em ordem alfabética.
+func chavesDoCache(c *Cache) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var chaves []string
+	for chave := range c.entradas {
+		chaves = append(chaves, chave)
+	}
+	slices.Sort(chaves)
+	return chaves
+}
+
+func TestCacheRemoveVencidas(t *testing.T) {
+	relogio := novoRelogioFalso()
+	c := novoCache(relogio.agora)
+	politica := PoliticaCache{TTL: time.Minute, Obsoleto: 5 * time.Minute}
+	valor := func() ([]byte, error) { return []byte("valor"), nil }
+	falha := func() ([]byte, error) { return nil, errors.New("api fora do ar") }
+
+	c.Obter("clima:recife", politica, valor)
+	c.Obter("clima:natal", politica, valor)
+	relogio.avancar(3 * time.Minute)
+	c.Obter("clima:olinda", politica, valor)
+
+	// Ao ler uma chave vencida, ela sai do cache mesmo que a nova busca falhe.
+	relogio.avancar(4 * time.Minute)
+	if _, _, err := c.Obter("clima:recife", politica, falha); err == nil {
+		t.Fatal("esperava a falha da busca")
+	}
+	if chaves := chavesDoCache(c); !slices.Equal(chaves, []string{"clima:natal", "clima:olinda"}) {
+		t.Fatalf("chaves = %v depois de ler a vencida", chaves)
+	}
+
+	// Ao guardar uma chave nova, as vencidas que ninguém leu também saem.
+	c.Obter("clima:caruaru", politica, valor)
+	if chaves := chavesDoCache(c); !slices.Equal(chaves, []string{"clima:caruaru", "clima:olinda"}) {
+		t.Fatalf("chaves = %v depois de guardar uma nova", chaves)
+	}
+}
+
+func TestCacheLimiteDeEntradas(t *testing.T) {
+	relogio := novoRelogioFalso()
+	c := novoCache(relogio.agora)
+	c.maxEntradas = 3
+	politica := PoliticaCache{TTL: 2 * time.Minute, Obsoleto: time.Hour}
+	valor := func() ([]byte, error) { return []byte("valor"), nil }
+
+	for _, cidade := range []string{"a", "b", "c"} {
+		c.Obter("clima:"+cidade, politica, valor)
+		relogio.avancar(time.Minute)
+	}
+	// Atualizar uma chave que já existe (aqui, em segundo plano) não tira ninguém, e a
+	// resposta atualizada passa a ser a mais nova.
+	if _, estado, _ := c.Obter("clima:a", politica, valor); estado != cacheObsoleto {
+		t.Fatalf("estado = %s, esperava STALE", estado)
+	}
+	esperarAtualizacoes(t, c)
+	if chaves := chavesDoCache(c); !slices.Equal(chaves, []string{"clima:a", "clima:b", "clima:c"}) {
+		t.Fatalf("chaves = %v", chaves)
+	}
+
+	// Cheio, a chave nova toma o lugar da resposta mais antiga.
+	relogio.avancar(time.Minute)
+	c.Obter("clima:d", politica, valor)
+	if chaves := chavesDoCache(c); !slices.Equal(chaves, []string{"clima:a", "clima:c", "clima:d"}) {
+		t.Fatalf("chaves = %v, esperava sem a mais antiga (clima:b)", chaves)
+	}
+}
+
+// prepararBitcoinDeTeste aponta o /api/bitcoin para uma CoinGecko falsa e troca o cache
+// global por um com relógio falso. Devolve a quantidade de chamadas feitas à API falsa.
+func prepararBitcoinDeTeste(t *testing.T, relogio *relogioFalso, liberar <-chan struct{}) *atomic.Int32 {
+	t.Helper()
+	var chamadas atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := chamadas.Add(1)
+		if liberar != nil {
+			<-liberar
+		}
+		if r.URL.Path != "/simple/price" || r.URL.Query().Get("ids") != "bitcoin" || r.URL.Query().Get("vs_currencies") != "brl" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"bitcoin":{"brl":%d}}`, 350000+n)
+	}))
+	t.Cleanup(api.Close)
+
+	var config Configuracao
+	config.aplicarPadroes()
+	config.Cache.Bitcoin = PoliticaCache{TTL: time.Minute, Obsoleto: 5 * time.Minute}
+	configAnterior, cacheAnterior, provedoresAnteriores := configuracaoAtual.Load(), cacheAPIs, provedoresMercado
+	configuracaoAtual.Store(&config)
+	cacheAPIs = novoCache(relogio.agora)
+	provedoresMercado = []ProvedorMercado{&ProvedorCoinGecko{
+		cliente: novoClienteUpstream(provedorCoinGecko, ConfiguracaoUpstream{URLBase: api.URL, Timeout: 2 * time.Second}, api.Client()),
+	}}
+	t.Cleanup(func() {
+		configuracaoAtual.Store(configAnterior)
+		cacheAPIs, provedoresMercado = cacheAnterior, provedoresAnteriores
+	})
+	return &chamadas
+}
+
+// pedirBitcoin chama o handler e devolve o X-Cache e o preço recebido.
+func pedirBitcoin(t *testing.T) (string, float64) {
+	t.Helper()
+	gravador := httptest.NewRecorder()
+	bitcoinHandler(gravador, httptest.NewRequest(http.MethodGet, "/api/bitcoin", nil))
+	if gravador.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", gravador.Code, gravador.Body)
+	}
+	var resposta RespostaCotacoes
+	if err := json.Unmarshal(gravador.Body.Bytes(), &resposta); err != nil || len(resposta.Cotacoes) != 1 {
+		t.Fatalf("resposta inválida %s: %v", gravador.Body, err)
+	}
+	return gravador.Header().Get("X-Cache"), resposta.Cotacoes[0].Preco
+}
+
+func TestBitcoinHandlerXCache(t *testing.T) {
+	relogio := novoRelogioFalso()
+	chamadas := prepararBitcoinDeTeste(t, relogio, nil)
+
+	passos := []struct {
+		avancar time.Duration
+		estado  string
+		preco   float64
+	}{
+		{0, cacheMiss, 350001},
+		{30 * time.Second, cacheHit, 350001},
+		{time.Minute, cacheObsoleto, 350001}, // Dispara a atualização em segundo plano.
+		{0, cacheHit, 350002},
+		{10 * time.Minute, cacheMiss, 350003},
+	}
+	for i, passo := range passos {
+		relogio.avancar(passo.avancar)
+		estado, preco := pedirBitcoin(t)
+		esperarAtualizacoes(t, cacheAPIs)
+		if estado != passo.estado || preco != passo.preco {
+			t.Fatalf("passo %d: X-Cache %s e preço %.0f, esperava %s e %.0f", i+1, estado, preco, passo.estado, passo.preco)
+		}
+	}
+	if n := chamadas.Load(); n != 3 {
+		t.Fatalf("%d chamadas à API, esperava 3", n)
+	}
+}
+
+func TestBitcoinHandlerPedidosSimultaneos(t *testing.T) {
+	liberar := make(chan struct{})
+	chamadas := prepararBitcoinDeTeste(t, novoRelogioFalso(), liberar)
+
+	const concorrentes = 20
+	estados := make(chan string, concorrentes)
+	var wg sync.WaitGroup
+	for range concorrentes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gravador := httptest.NewRecorder()
+			bitcoinHandler(gravador, httptest.NewRequest(http.MethodGet, "/api/bitcoin", nil))
+			estados <- fmt.Sprintf("%d %s", gravador.Code, gravador.Header().Get("X-Cache"))
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(liberar)
+	wg.Wait()
+	close(estados)
+
+	for estado := range estados {
+		if estado != "200 MISS" && estado != "200 HIT" {
+			t.Errorf("resposta %s", estado)
+		}
+	}
+	if n := chamadas.Load(); n != 1 {
+		t.Fatalf("%d chamadas à API para %d pedidos simultâneos, esperava 1", n, concorrentes)
+	}
+}
+
+func TestBitcoinHandlerFormatoAntigo(t *testing.T) {
+	prepararBitcoinDeTeste(t, novoRelogioFalso(), nil)
+
+	// A página compilada em public/ lê 'bitcoin.brl'; o campo precisa continuar na resposta.
+	gravador := httptest.NewRecorder()
+	bitcoinHandler(gravador, httptest.NewRequest(http.MethodGet, "/api/bitcoin", nil))
+	var resposta struct {
+		Cotacoes []Cotacao `json:"cotacoes"`
+		Bitcoin  struct {
+			BRL float64 `json:"brl"`
+		} `json:"bitcoin"`
+	}
+	if err := json.Unmarshal(gravador.Body.Bytes(), &resposta); err != nil {
+		t.Fatal(err)
+	}
+	if len(resposta.Cotacoes) != 1 || resposta.Bitcoin.BRL != resposta.Cotacoes[0].Preco || resposta.Bitcoin.BRL == 0 {
+		t.Fatalf("resposta sem o formato antigo: %s", gravador.Body)
+	}
+}
+
+func TestClimaHandlerFormatoAntigo(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"current_condition":[{"temp_C":"28","weatherDesc":[{"value":"Sunny"}],"lang_pt":[{"value":"Ensolarado"}]}]}`)
+	}))
+	defer api.Close()
+
+	var config Configuracao
+	config.aplicarPadroes()
+	configAnterior, cacheAnterior, provedoresAnteriores := configuracaoAtual.Load(), cacheAPIs, provedoresClima
+	configuracaoAtual.Store(&config)
+	cacheAPIs = novoCache(time.Now)
+	provedoresClima = []ProvedorClima{&ProvedorWttr{
+		cliente: novoClienteUpstream(provedorWttr, ConfiguracaoUpstream{URLBase: api.URL, Timeout: 2 * time.Second}, api.Client()),
+	}}
+	t.Cleanup(func() {
+		configuracaoAtual.Store(configAnterior)
+		cacheAPIs, provedoresClima = cacheAnterior, provedoresAnteriores
+	})
+
+	// A página compilada em public/ lê 'current_condition[0]'; o formato novo vem junto.
+	gravador := httptest.NewRecorder()
+	climaHandler(gravador, httptest.NewRequest(http.MethodGet, "/api/clima?cidade=Recife", nil))
+	var resposta Clima
+	if err := json.Unmarshal(gravador.Body.Bytes(), &resposta); err != nil {
+		t.Fatalf("status %d: %s", gravador.Code, gravador.Body)
+	}
+	antiga := CondicaoAntiga{TempC: "28", Descricao: []DescricaoAntiga{{Valor: "Ensolarado"}}}
+	if resposta.TemperaturaC != 28 || resposta.Descricao != "Ensolarado" || len(resposta.CondicaoAtual) != 1 ||
+		resposta.CondicaoAtual[0].TempC != antiga.TempC || !slices.Equal(resposta.CondicaoAtual[0].Descricao, antiga.Descricao) {
+		t.Fatalf("resposta sem o formato antigo: %s", gravador.Body)
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Prefixo das variáveis de ambiente que sobrescrevem o arquivo (ex: APP_SERVIDOR_PORTA=9090).
+const prefixoAmbiente = "APP"
+
+// configuracaoAtual guarda a configuração em vigor. Ela é trocada inteira quando o arquivo
+// muda, e por isso é lida de forma atômica pelos handlers.
+var configuracaoAtual atomic.Pointer[Configuracao]
+
+// configuracao devolve a configuração em vigor, para que os handlers possam consultá-la.
+func configuracao() *Configuracao {
+	return configuracaoAtual.Load()
+}
+
+// ============== ARGUMENTOS DE LINHA DE COMANDO ==============
+
+// Argumentos são as opções da linha de comando. Elas valem mais que as variáveis de
+// ambiente, que valem mais que o arquivo de configuração.
+type Argumentos struct {
+	Arquivo     string
+	Host        string
+	Porta       string
+	GerarConfig bool
+	HashSenha   bool
+}
+
+// lerArgumentos interpreta a linha de comando (ex: --porta 9090 --host 0.0.0.0).
+func lerArgumentos(argumentos []string) Argumentos {
+	var a Argumentos
+	opcoes := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	opcoes.StringVar(&a.Arquivo, "config", nomeArquivoConfig, "arquivo de configuração")
+	opcoes.StringVar(&a.Host, "host", "", "endereço que o servidor deve ouvir (sobrescreve servidor.host)")
+	opcoes.StringVar(&a.Porta, "porta", "", "porta do servidor (sobrescreve servidor.porta)")
+	opcoes.BoolVar(&a.GerarConfig, "gerar-config", false, "grava um arquivo de configuração documentado, com os valores padrão, e encerra")
+	opcoes.BoolVar(&a.HashSenha, "hash-senha", false, "lê uma senha e imprime o hash bcrypt para admin.usuarios, e encerra")
+	opcoes.Parse(argumentos)
+	return a
+}
+
+// sobrepor aplica à configuração os valores informados na linha de comando.
+func (a Argumentos) sobrepor(c *Configuracao) {
+	if a.Host != "" {
+		c.Servidor.Host = a.Host
+	}
+	if a.Porta != "" {
+		c.Servidor.Porta = a.Porta
+	}
+}
+
+// interativo informa se é possível perguntar a configuração a alguém: nada foi informado
+// por argumento ou variável de ambiente e há um terminal (e não o Docker ou o systemd).
+func (a Argumentos) interativo() bool {
+	if a.Host != "" || a.Porta != "" || os.Getenv(prefixoAmbiente+"_SERVIDOR_HOST") != "" || os.Getenv(prefixoAmbiente+"_SERVIDOR_PORTA") != "" {
+		return false
+	}
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// prepararConfiguracao carrega a configuração. Sem o arquivo, pergunta os valores principais
+// se houver um terminal; caso contrário, segue com os padrões, o ambiente e os argumentos.
+func prepararConfiguracao(a Argumentos) (Configuracao, error) {
+	switch {
+	case arquivoExiste(a.Arquivo):
+		log.Printf("Carregando configuração de '%s'...", a.Arquivo)
+	case a.interativo():
+		if err := perguntarECriarConfiguracao(a.Arquivo); err != nil {
+			return Configuracao{}, fmt.Errorf("configuração inicial cancelada ou falhou: %w", err)
+		}
+	default:
+		log.Printf("AVISO: '%s' não encontrado; usando os valores padrão, as variáveis %s_* e os argumentos (gere um arquivo documentado com --gerar-config).",
+			a.Arquivo, prefixoAmbiente)
+	}
+	return carregarConfiguracao(a)
+}
+
+// ============== VARIÁVEIS DE AMBIENTE ==============
+
+// chavesConfiguracao lista todas as chaves da configuração (ex: "servidor.porta").
+func chavesConfiguracao(tipo reflect.Type, prefixo string) []string {
+	var chaves []string
+	for i := 0; i < tipo.NumField(); i++ {
+		campo := tipo.Field(i)
+		chave := prefixo + campo.Tag.Get("mapstructure")
+		if campo.Type.Kind() == reflect.Struct && campo.Type != reflect.TypeOf(time.Duration(0)) {
+			chaves = append(chaves, chavesConfiguracao(campo.Type, chave+".")...)
+			continue
+		}
+		chaves = append(chaves, chave)
+	}
+	return chaves
+}
+
+// ============== VALIDAÇÃO ==============
+
+// padraoNomeHost aceita nomes como "localhost", "painel" ou "painel.exemplo.com.br".
+var padraoNomeHost = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// validarPorta confere se o texto é uma porta TCP entre 1 e 65535.
+func validarPorta(porta string) error {
+	numero, err := strconv.Atoi(strings.TrimSpace(porta))
+	if err != nil {
+		return fmt.Errorf("'%s' não é um número de porta (use de 1 a 65535)", porta)
+	}
+	if numero < 1 || numero > 65535 {
+		return fmt.Errorf("%d está fora do intervalo de portas (use de 1 a 65535)", numero)
+	}
+	return nil
+}
+
+// validarHost confere se o texto é um IP ou um nome de host. Vazio ouve todas as interfaces.
+func validarHost(host string) error {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	// Só aponta a porta quando ela é mesmo uma porta (em "http://painel", "//painel" não é).
+	if _, porta, err := net.SplitHostPort(host); err == nil && validarPorta(porta) == nil {
+		return fmt.Errorf("'%s' inclui a porta; informe só o endereço e use servidor.porta para a porta %s", host, porta)
+	}
+	if len(host) > 253 || !padraoNomeHost.MatchString(host) {
+		return fmt.Errorf("'%s' não é um IP nem um nome de host válido (ex: 0.0.0.0, localhost ou 192.168.0.10)", host)
+	}
+	return nil
+}
+
+// validar confere a configuração já com os padrões aplicados e devolve todos os problemas
+// de uma vez, cada um com a chave do arquivo (ex: "servidor.porta: 70000 está fora...").
+func (c *Configuracao) validar() error {
+	var erros []error
+	problema := func(chave string, err error) {
+		erros = append(erros, fmt.Errorf("%s: %w", chave, err))
+	}
+
+	if err := validarPorta(c.Servidor.Porta); err != nil {
+		problema("servidor.porta", err)
+	}
+	if err := validarHost(c.Servidor.Host); err != nil {
+		problema("servidor.host", err)
+	}
+	if c.TLS.PortaRedirecionamento != "" {
+		if err := validarPorta(c.TLS.PortaRedirecionamento); err != nil {
+			problema("tls.porta_redirecionamento", err)
+		} else if c.TLS.PortaRedirecionamento == c.Servidor.Porta {
+			problema("tls.porta_redirecionamento", fmt.Errorf("deve ser diferente de servidor.porta (%s)", c.Servidor.Porta))
+		}
+	}
+
+	upstreams := map[string]ConfiguracaoUpstream{
+		"apis.coingecko":                c.APIs.CoinGecko,
+		"apis.cryptocompare":            c.APIs.CryptoCompare,
+		"apis.wttr":                     c.APIs.Wttr,
+		"apis.openmeteo":                c.APIs.OpenMeteo,
+		"apis.openmeteo_geocodificacao": c.APIs.OpenMeteoGeocodificacao,
+	}
+	for chave, upstream := range upstreams {
+		if endereco, err := url.Parse(upstream.URLBase); err != nil || (endereco.Scheme != "http" && endereco.Scheme != "https") || endereco.Host == "" {
+			problema(chave+".url_base", fmt.Errorf("'%s' não é uma URL http(s) completa (ex: https://wttr.in)", upstream.URLBase))
+		}
+	}
+
+	erros = append(erros, c.Admin.validar()...)
+
+	// Tempos e quantidades negativos não fazem sentido em nenhuma opção.
+	erros = append(erros, negativos(reflect.ValueOf(*c), "")...)
+
+	// Ordena a saída pela chave, para que a mensagem seja a mesma a cada execução.
+	slices.SortFunc(erros, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(erros...)
+}
+
+// negativos percorre a configuração e aponta os tempos e números menores que zero.
+func negativos(valor reflect.Value, prefixo string) []error {
+	var erros []error
+	for i := 0; i < valor.NumField(); i++ {
+		campo, chave := valor.Field(i), prefixo+valor.Type().Field(i).Tag.Get("yaml")
+		if campo.Kind() == reflect.Pointer {
+			if campo.IsNil() {
+				continue
+			}
+			campo = campo.Elem()
+		}
+		switch {
+		case campo.Type() == reflect.TypeOf(time.Duration(0)):
+			if d := time.Duration(campo.Int()); d < 0 {
+				erros = append(erros, fmt.Errorf("%s: não pode ser negativo (recebido %s)", chave, d))
+			}
+		case campo.Kind() == reflect.Int:
+			if campo.Int() < 0 {
+				erros = append(erros, fmt.Errorf("%s: não pode ser negativo (recebido %d)", chave, campo.Int()))
+			}
+		case campo.Kind() == reflect.Struct:
+			erros = append(erros, negativos(campo, chave+".")...)
+		}
+	}
+	return erros
+}
+
+// ============== CARREGAMENTO ==============
+
+// carregarConfiguracao lê o arquivo (se existir), as variáveis de ambiente e os argumentos,
+// aplica os padrões e valida o resultado. Chaves desconhecidas no arquivo (ex: um erro de
+// digitação como "servidro") são rejeitadas, em vez de ignoradas em silêncio.
+func carregarConfiguracao(a Argumentos) (Configuracao, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetEnvPrefix(prefixoAmbiente)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	// Cada chave é registrada para poder vir do ambiente mesmo quando não está no arquivo
+	// (ex: servidor.porta de APP_SERVIDOR_PORTA). Listas usam vírgulas (ex: brl,usd).
+	conhecidas := chavesConfiguracao(reflect.TypeOf(Configuracao{}), "")
+	for _, chave := range conhecidas {
+		v.BindEnv(chave)
+	}
+
+	if arquivoExiste(a.Arquivo) {
+		v.SetConfigFile(a.Arquivo)
+		if err := v.ReadInConfig(); err != nil {
+			return Configuracao{}, fmt.Errorf("erro ao ler '%s': %w", a.Arquivo, err)
+		}
+	}
+	var desconhecidas []string
+	for _, chave := range v.AllKeys() {
+		if !slices.Contains(conhecidas, chave) {
+			desconhecidas = append(desconhecidas, chave)
+		}
+	}
+	if len(desconhecidas) > 0 {
+		slices.Sort(desconhecidas)
+		return Configuracao{}, fmt.Errorf("'%s' tem chaves desconhecidas (erro de digitação?): %s", a.Arquivo, strings.Join(desconhecidas, ", "))
+	}
+
+	var config Configuracao
+	if err := v.Unmarshal(&config); err != nil {
+		return Configuracao{}, fmt.Errorf("'%s' inválido: %w", a.Arquivo, err)
+	}
+	a.sobrepor(&config)
+	config.aplicarPadroes()
+	if err := config.validar(); err != nil {
+		return Configuracao{}, fmt.Errorf("configuração inválida:\n%w", err)
+	}
+	return config, nil
+}
+
+// ============== ARQUIVO DOCUMENTADO ==============
+
+// comentariosConfig explica cada seção e as opções menos óbvias no arquivo gerado.
+var comentariosConfig = map[string]string{
+	"servidor":                      "Endereço do servidor e tempos máximos de cada conexão.\nTambém pode vir de --host/--porta ou APP_SERVIDOR_HOST/APP_SERVIDOR_PORTA.",
+	"servidor.host":                 "0.0.0.0 ouve todas as interfaces (acesso pela rede); localhost, só esta máquina.",
+	"servidor.porta":                "De 1 a 65535.",
+	"servidor.prazo_encerramento":   "Tempo dado às requisições em andamento ao receber SIGINT/SIGTERM.",
+	"cache":                         "Respostas das APIs externas guardadas em memória. Até 'ttl' são servidas direto;\naté 'obsoleto', são servidas enquanto uma nova é buscada. Recarregado sem reiniciar.",
+	"apis":                          "Endereços e regras de resiliência das APIs externas.",
+	"apis.coingecko.timeout":        "Tempo máximo de cada tentativa.",
+	"apis.coingecko.tentativas":     "Novas tentativas após a primeira falha (0 = nenhuma).",
+	"apis.coingecko.espera_inicial": "Espera antes da segunda tentativa; dobra a cada nova tentativa.",
+	"apis.coingecko.limite_falhas":  "Falhas seguidas (5xx, tempo esgotado ou rede) que abrem o circuito: a API deixa\nde ser chamada por 'tempo_aberto'. Respostas como 404 não contam. 0 desliga o disjuntor.",
+	"provedores":                    "Serviços que fornecem o clima e as cotações, na ordem em que são tentados.\nClima: wttr, openmeteo. Mercado: coingecko, cryptocompare.",
+	"padroes":                       "Valores usados quando a requisição não informa cidade, moedas ou ativos.\nRecarregado sem reiniciar.",
+	"stream":                        "Atualizador periódico e limites do /api/stream.",
+	"stream.heartbeat":              "Comentário enviado para manter a conexão aberta.",
+	"stream.historico":              "Eventos guardados para quem reconectar.",
+	"websocket":                     "Keepalive e limites do /ws.",
+	"websocket.max_topicos":         "Tópicos que cada conexão pode assinar.",
+	"cors":                          "Páginas de outras origens (ex: o 'npm run dev' do Svelte) que podem chamar a API.\n\"*\" libera qualquer origem. Recarregado sem reiniciar.",
+	"frases":                        "Catálogo de frases. Sem token, a API de cadastro fica somente leitura.\nO token é recarregado sem reiniciar (ou use APP_FRASES_TOKEN).",
+	"tls":                           "HTTPS. Sem certificado e chave, um autoassinado é gerado em tls/.",
+	"tls.hosts":                     "Nomes e IPs extras do certificado autoassinado.",
+	"tls.porta_redirecionamento":    "Porta que só redireciona para o HTTPS (ex: \"80\"). Vazio: sem redirecionamento.",
+	"tls.hsts":                      "Tempo em que o navegador só aceita HTTPS neste endereço. 0s desliga.",
+	"historico":                     "Coleta e retenção do histórico de cotações (SQLite).",
+	"historico.retencao_amostras":   "Depois disso, as amostras viram pontos por hora.",
+	"historico.retencao_agregados":  "Depois disso, os pontos por hora são apagados.",
+	"alertas":                       "Regras de alerta e canais de notificação. Sem token, a API de alertas fica desativada.\nO token é recarregado sem reiniciar (ou use APP_ALERTAS_TOKEN).",
+	"alertas.smtp.endereco":         "Ex: smtp.gmail.com:587. Sem usuário, envia sem autenticação.",
+	"admin":                         "Área administrativa (/api/admin). Sem usuários, ela fica desativada.\nOs usuários são recarregados sem reiniciar; remover um usuário encerra as sessões dele.\nO cookie da sessão só trafega em HTTPS (em HTTP, o navegador só o aceita em localhost).",
+	"admin.usuarios":                "Lista de {usuario, senha}, com a senha em hash bcrypt (gere com --hash-senha).",
+	"admin.sessao":                  "Tempo sem uso até a sessão expirar.",
+	"admin.sessao_maxima":           "Duração máxima de uma sessão, mesmo em uso; depois dela, é preciso fazer login de novo.",
+}
+
+// gerarConfiguracao grava no arquivo a configuração padrão (com o host e a porta dos
+// argumentos, se informados), comentada. Um arquivo existente nunca é sobrescrito.
+func gerarConfiguracao(a Argumentos) error {
+	var config Configuracao
+	a.sobrepor(&config)
+	config.aplicarPadroes()
+	if err := config.validar(); err != nil {
+		return err
+	}
+
+	documento := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{noYAML(reflect.ValueOf(config), "")}}
+	documento.HeadComment = fmt.Sprintf("Configuração do painel, gerada com --gerar-config.\n"+
+		"Qualquer opção pode ser sobrescrita por uma variável de ambiente %s_<SEÇÃO>_<OPÇÃO>\n"+
+		"(ex: %s_SERVIDOR_PORTA=9090). Tempos usam o formato do Go: 500ms, 30s, 10m, 168h.\n"+
+		"Alterações em 'padroes', 'cache', 'cors', 'admin' e nos tokens valem sem reiniciar o servidor.", prefixoAmbiente, prefixoAmbiente)
+	dados, err := yaml.Marshal(documento)
+	if err != nil {
+		return fmt.Errorf("erro ao gerar YAML: %w", err)
+	}
+
+	arquivo, err := os.OpenFile(a.Arquivo, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("'%s' já existe; apague-o ou use --config para gerar em outro caminho", a.Arquivo)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao escrever arquivo de configuração: %w", err)
+	}
+	if _, err := arquivo.Write(separarSecoes(dados)); err != nil {
+		arquivo.Close()
+		return fmt.Errorf("erro ao escrever arquivo de configuração: %w", err)
+	}
+	return arquivo.Close()
+}
+
+// noYAML monta o YAML da configuração campo a campo, com os comentários de cada chave.
+// Os tempos são escritos como texto (ex: "30s"), e não em nanossegundos.
+func noYAML(valor reflect.Value, prefixo string) *yaml.Node {
+	if valor.Type() == reflect.TypeOf(time.Duration(0)) {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: textoDuracao(time.Duration(valor.Int()))}
+	}
+	if valor.Kind() != reflect.Struct {
+		no := &yaml.Node{}
+		no.Encode(valor.Interface())
+		if no.Kind == yaml.SequenceNode && len(no.Content) == 0 {
+			no.Style = yaml.FlowStyle // Lista vazia: "[]".
+		}
+		return no
+	}
+
+	no := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < valor.NumField(); i++ {
+		nome, _, _ := strings.Cut(valor.Type().Field(i).Tag.Get("yaml"), ",")
+		chave := &yaml.Node{Kind: yaml.ScalarNode, Value: nome, HeadComment: comentariosConfig[prefixo+nome]}
+		no.Content = append(no.Content, chave, noYAML(valor.Field(i), prefixo+nome+"."))
+	}
+	return no
+}
+
+// textoDuracao escreve o tempo sem zeros sobrando (ex: "2m" em vez de "2m0s").
+func textoDuracao(d time.Duration) string {
+	texto := d.String()
+	if strings.HasSuffix(texto, "m0s") {
+		texto = strings.TrimSuffix(texto, "0s")
+	}
+	if strings.HasSuffix(texto, "h0m") {
+		texto = strings.TrimSuffix(texto, "0m")
+	}
+	return texto
+}
+
+// separarSecoes deixa uma linha em branco antes de cada seção do arquivo, para facilitar a leitura.
+func separarSecoes(dados []byte) []byte {
+	linhas := strings.Split(string(dados), "\n")
+	var saida []string
+	for i, linha := range linhas {
+		inicioSecao := linha != "" && linha[0] != ' ' && i > 0 && linhas[i-1] != "" && !strings.HasPrefix(linhas[i-1], "#")
+		if inicioSecao {
+			saida = append(saida, "")
+		}
+		saida = append(saida, linha)
+	}
+	return []byte(strings.Join(saida, "\n"))
+}
+
+// ============== RECARGA AUTOMÁTICA ==============
+
+// observarConfiguracao recarrega o arquivo quando ele muda. Só as opções lidas a cada
+// requisição (padrões, cache, CORS, tokens e usuários do admin) mudam na hora; as demais
+// são apontadas no log como pendentes de reinício. Um arquivo inválido é ignorado, e a
+// configuração em vigor continua valendo.
+func observarConfiguracao(a Argumentos) {
+	if !arquivoExiste(a.Arquivo) {
+		return
+	}
+	observador := viper.New()
+	observador.SetConfigType("yaml")
+	observador.SetConfigFile(a.Arquivo)
+	observador.OnConfigChange(func(evento fsnotify.Event) {
+		// Alguns editores esvaziam o arquivo antes de gravar o novo conteúdo.
+		if info, err := os.Stat(a.Arquivo); err != nil || info.Size() == 0 {
+			return
+		}
+		nova, err := carregarConfiguracao(a)
+		if err != nil {
+			log.Printf("AVISO: '%s' foi alterado, mas não foi recarregado: %v", a.Arquivo, err)
+			return
+		}
+		recarregada, aplicadas, pendentes := mesclarRecarga(*configuracao(), nova)
+		configuracaoAtual.Store(&recarregada)
+		if len(aplicadas) > 0 {
+			log.Printf("Configuração recarregada de '%s': %s.", a.Arquivo, strings.Join(aplicadas, ", "))
+		}
+		if len(pendentes) > 0 {
+			log.Printf("AVISO: as alterações em %s só valem após reiniciar o servidor.", strings.Join(pendentes, ", "))
+		}
+	})
+	observador.WatchConfig()
+}
+
+// mesclarRecarga copia da nova configuração só as opções seguras de trocar com o servidor
+// no ar, e informa quais seções mudaram de fato e quais dependem de um reinício.
+func mesclarRecarga(atual, nova Configuracao) (resultado Configuracao, aplicadas, pendentes []string) {
+	resultado = atual
+	resultado.Padroes = nova.Padroes
+	resultado.Cache = nova.Cache
+	resultado.CORS = nova.CORS
+	resultado.Frases.Token = nova.Frases.Token
+	resultado.Alertas.Token = nova.Alertas.Token
+	resultado.Admin = nova.Admin
+
+	antes, depois, desejado := reflect.ValueOf(atual), reflect.ValueOf(resultado), reflect.ValueOf(nova)
+	for i := 0; i < antes.NumField(); i++ {
+		secao := antes.Type().Field(i).Tag.Get("yaml")
+		if !reflect.DeepEqual(antes.Field(i).Interface(), depois.Field(i).Interface()) {
+			aplicadas = append(aplicadas, secao)
+		}
+		if !reflect.DeepEqual(depois.Field(i).Interface(), desejado.Field(i).Interface()) {
+			pendentes = append(pendentes, secao)
+		}
+	}
+	return resultado, aplicadas, pendentes
+}
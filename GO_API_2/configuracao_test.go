@@ -0,0 +1,301 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// carregarConfiguracaoDeTeste grava o YAML em uma pasta temporária e o carrega.
+func carregarConfiguracaoDeTeste(t *testing.T, conteudo string) (Configuracao, error) {
+	t.Helper()
+	arquivo := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(arquivo, []byte(conteudo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return carregarConfiguracao(Argumentos{Arquivo: arquivo})
+}
+
+func TestConfiguracaoUpstreamZeroExplicito(t *testing.T) {
+	t.Setenv("APP_APIS_WTTR_LIMITE_FALHAS", "0")
+	config, err := carregarConfiguracaoDeTeste(t, `
+apis:
+    coingecko:
+        tentativas: 0
+        limite_falhas: 0
+    cryptocompare:
+        tentativas: 4
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nome                     string
+		upstream                 ConfiguracaoUpstream
+		tentativas, limiteFalhas int
+	}{
+		{"coingecko (zeros no arquivo)", config.APIs.CoinGecko, 0, 0},
+		{"cryptocompare (só tentativas)", config.APIs.CryptoCompare, 4, 5},
+		{"wttr (zero no ambiente)", config.APIs.Wttr, 2, 0},
+		{"openmeteo (padrões)", config.APIs.OpenMeteo, 2, 5},
+	}
+	for _, caso := range casos {
+		if caso.upstream.Tentativas == nil || caso.upstream.LimiteFalhas == nil {
+			t.Fatalf("%s: opções sem valor depois dos padrões", caso.nome)
+		}
+		if *caso.upstream.Tentativas != caso.tentativas || *caso.upstream.LimiteFalhas != caso.limiteFalhas {
+			t.Errorf("%s: tentativas %d e limite %d, esperava %d e %d", caso.nome,
+				*caso.upstream.Tentativas, *caso.upstream.LimiteFalhas, caso.tentativas, caso.limiteFalhas)
+		}
+	}
+
+	// Limite zero: o disjuntor nunca abre, mesmo depois de muitas falhas.
+	cliente := novoClienteUpstream(provedorCoinGecko, config.APIs.CoinGecko, clienteHTTP)
+	for range 10 {
+		cliente.disjuntor.RegistrarFalha()
+	}
+	if !cliente.disjuntor.Permitir() || cliente.Estado().Circuito != "fechado" {
+		t.Fatalf("com limite_falhas 0 o circuito abriu: %+v", cliente.Estado())
+	}
+}
+
+func TestConfiguracaoUpstreamNegativo(t *testing.T) {
+	_, err := carregarConfiguracaoDeTeste(t, `
+apis:
+    wttr:
+        tentativas: -1
+`)
+	if err == nil || !strings.Contains(err.Error(), "apis.wttr.tentativas: não pode ser negativo") {
+		t.Fatalf("esperava erro de valor negativo, veio %v", err)
+	}
+}
+
+func TestValidarPorta(t *testing.T) {
+	casos := []struct{ porta, erro string }{
+		{"8080", ""},
+		{"1", ""},
+		{"65535", ""},
+		{" 443 ", ""},
+		{"0", "0 está fora do intervalo de portas (use de 1 a 65535)"},
+		{"70000", "70000 está fora do intervalo de portas (use de 1 a 65535)"},
+		{"-1", "-1 está fora do intervalo de portas (use de 1 a 65535)"},
+		{"http", "'http' não é um número de porta (use de 1 a 65535)"},
+		{"", "'' não é um número de porta (use de 1 a 65535)"},
+	}
+	for _, caso := range casos {
+		if erro := textoDoErro(validarPorta(caso.porta)); erro != caso.erro {
+			t.Errorf("validarPorta(%q) = %q, esperava %q", caso.porta, erro, caso.erro)
+		}
+	}
+}
+
+func TestValidarHost(t *testing.T) {
+	casos := []struct{ host, erro string }{
+		{"", ""},
+		{"0.0.0.0", ""},
+		{"192.168.0.10", ""},
+		{"::1", ""},
+		{"localhost", ""},
+		{"painel.exemplo.com.br", ""},
+		{"localhost:8080", "'localhost:8080' inclui a porta; informe só o endereço e use servidor.porta para a porta 8080"},
+		{"[::1]:9090", "'[::1]:9090' inclui a porta; informe só o endereço e use servidor.porta para a porta 9090"},
+		{"meu painel", "'meu painel' não é um IP nem um nome de host válido (ex: 0.0.0.0, localhost ou 192.168.0.10)"},
+		{"-painel", "'-painel' não é um IP nem um nome de host válido (ex: 0.0.0.0, localhost ou 192.168.0.10)"},
+		{"http://painel", "'http://painel' não é um IP nem um nome de host válido (ex: 0.0.0.0, localhost ou 192.168.0.10)"},
+		{strings.Repeat("a.", 127) + "a", "'" + strings.Repeat("a.", 127) + "a' não é um IP nem um nome de host válido (ex: 0.0.0.0, localhost ou 192.168.0.10)"},
+	}
+	for _, caso := range casos {
+		if erro := textoDoErro(validarHost(caso.host)); erro != caso.erro {
+			t.Errorf("validarHost(%q) = %q, esperava %q", caso.host, erro, caso.erro)
+		}
+	}
+}
+
+// textoDoErro devolve a mensagem do erro, ou "" se não houver erro.
+func textoDoErro(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestConfiguracaoInvalida(t *testing.T) {
+	casos := []struct{ nome, conteudo, erro string }{
+		{"chaves desconhecidas", `
+servidro:
+    porta: 9090
+servidor:
+    prota: 9090
+`, "tem chaves desconhecidas (erro de digitação?): servidor.prota, servidro.porta"},
+		// Todos os problemas aparecem de uma vez, ordenados pela chave.
+		{"vários problemas", `
+servidor:
+    host: localhost:8080
+    porta: 70000
+apis:
+    wttr:
+        url_base: wttr.in
+cache:
+    clima:
+        ttl: -1m
+`, "configuração inválida:\n" +
+			"apis.wttr.url_base: 'wttr.in' não é uma URL http(s) completa (ex: https://wttr.in)\n" +
+			"cache.clima.ttl: não pode ser negativo (recebido -1m0s)\n" +
+			"servidor.host: 'localhost:8080' inclui a porta; informe só o endereço e use servidor.porta para a porta 8080\n" +
+			"servidor.porta: 70000 está fora do intervalo de portas (use de 1 a 65535)"},
+		{"redirecionamento na mesma porta", `
+servidor:
+    porta: 8443
+tls:
+    porta_redirecionamento: 8443
+`, "configuração inválida:\ntls.porta_redirecionamento: deve ser diferente de servidor.porta (8443)"},
+		{"senha do admin em texto", `
+admin:
+    usuarios:
+        - usuario: ana
+          senha: segredo
+`, "configuração inválida:\nadmin.usuarios[0].senha: não é um hash bcrypt; gere um com --hash-senha (nunca guarde a senha em texto)"},
+	}
+	for _, caso := range casos {
+		_, err := carregarConfiguracaoDeTeste(t, caso.conteudo)
+		if err == nil || !strings.HasSuffix(err.Error(), caso.erro) {
+			t.Errorf("%s: erro\n%v\nesperava terminar com\n%s", caso.nome, err, caso.erro)
+		}
+	}
+}
+
+func TestConfiguracaoAmbienteEArgumentos(t *testing.T) {
+	t.Setenv("APP_SERVIDOR_HOST", "0.0.0.0")
+	t.Setenv("APP_SERVIDOR_PORTA", "9090")
+	t.Setenv("APP_PADROES_MOEDAS", "brl,usd")
+	t.Setenv("APP_CACHE_BITCOIN_TTL", "2m")
+	arquivo := filepath.Join(t.TempDir(), "config.yaml")
+	conteudo := "servidor:\n    host: localhost\n    porta: 8000\npadroes:\n    cidade: Recife\n"
+	if err := os.WriteFile(arquivo, []byte(conteudo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A linha de comando vale mais que o ambiente, que vale mais que o arquivo.
+	config, err := carregarConfiguracao(Argumentos{Arquivo: arquivo, Host: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Servidor.Host != "127.0.0.1" || config.Servidor.Porta != "9090" {
+		t.Errorf("servidor em %s:%s, esperava 127.0.0.1:9090", config.Servidor.Host, config.Servidor.Porta)
+	}
+	if config.Padroes.Cidade != "Recife" || strings.Join(config.Padroes.Moedas, ",") != "brl,usd" {
+		t.Errorf("padrões = %+v", config.Padroes)
+	}
+	// Chaves só do ambiente também valem, e o resto da seção mantém os padrões.
+	if config.Cache.Bitcoin.TTL != 2*time.Minute || config.Cache.Clima.TTL != 10*time.Minute {
+		t.Errorf("cache = %+v", config.Cache)
+	}
+
+	// Sem o arquivo, o ambiente sozinho configura o servidor, e também é validado.
+	t.Setenv("APP_SERVIDOR_PORTA", "http")
+	_, err = carregarConfiguracao(Argumentos{Arquivo: filepath.Join(t.TempDir(), "nao-existe.yaml")})
+	if err == nil || !strings.Contains(err.Error(), "servidor.porta: 'http' não é um número de porta") {
+		t.Fatalf("esperava erro da porta do ambiente, veio %v", err)
+	}
+}
+
+func TestGerarConfiguracao(t *testing.T) {
+	arquivo := filepath.Join(t.TempDir(), "config.yaml")
+	if err := gerarConfiguracao(Argumentos{Arquivo: arquivo, Host: "localhost", Porta: "9000"}); err != nil {
+		t.Fatal(err)
+	}
+	dados, err := os.ReadFile(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trecho := range []string{
+		"# Configuração do painel, gerada com --gerar-config.\n",
+		"(ex: APP_SERVIDOR_PORTA=9090)",
+		"\nservidor:\n    # 0.0.0.0 ouve todas as interfaces (acesso pela rede); localhost, só esta máquina.\n    host: localhost\n    # De 1 a 65535.\n    porta: \"9000\"\n",
+		// Os tempos saem como texto, sem zeros sobrando.
+		"    timeout_cabecalho: 5s\n",
+		"    timeout_ocioso: 2m\n",
+		// Cada seção começa depois de uma linha em branco.
+		"\n\n# Respostas das APIs externas",
+	} {
+		if !strings.Contains(string(dados), trecho) {
+			t.Errorf("o arquivo gerado não tem %q", trecho)
+		}
+	}
+
+	// O arquivo gerado é carregado de volta sem erros e com os mesmos valores.
+	config, err := carregarConfiguracao(Argumentos{Arquivo: arquivo})
+	if err != nil {
+		t.Fatalf("o arquivo gerado não carrega: %v", err)
+	}
+	if config.Servidor.Host != "localhost" || config.Servidor.Porta != "9000" || config.Servidor.TimeoutOcioso != 2*time.Minute ||
+		config.Padroes.Cidade != "João Pessoa" || *config.APIs.Wttr.Tentativas != 2 {
+		t.Errorf("configuração carregada do arquivo gerado = %+v", config.Servidor)
+	}
+
+	// Um arquivo existente nunca é sobrescrito, e argumentos inválidos não geram nada.
+	if err := gerarConfiguracao(Argumentos{Arquivo: arquivo}); err == nil || !strings.Contains(err.Error(), "já existe") {
+		t.Fatalf("esperava erro de arquivo existente, veio %v", err)
+	}
+	outro := filepath.Join(t.TempDir(), "config.yaml")
+	if err := gerarConfiguracao(Argumentos{Arquivo: outro, Porta: "0"}); err == nil || arquivoExiste(outro) {
+		t.Fatalf("porta inválida: erro %v e arquivo criado = %v", err, arquivoExiste(outro))
+	}
+}
+
+func TestMesclarRecarga(t *testing.T) {
+	var atual Configuracao
+	atual.aplicarPadroes()
+
+	casos := []struct {
+		nome                string
+		alterar             func(c *Configuracao)
+		aplicadas, pendente string
+		conferir            func(c Configuracao) bool
+	}{
+		{"sem mudanças", func(c *Configuracao) {}, "", "",
+			func(c Configuracao) bool { return reflect.DeepEqual(c, atual) }},
+		{"padrões", func(c *Configuracao) { c.Padroes.Cidade = "Recife" }, "padroes", "",
+			func(c Configuracao) bool { return c.Padroes.Cidade == "Recife" }},
+		{"cache e CORS", func(c *Configuracao) {
+			c.Cache.Clima.TTL = time.Minute
+			c.CORS.Origens = []string{"*"}
+		}, "cache, cors", "",
+			func(c Configuracao) bool { return c.Cache.Clima.TTL == time.Minute && c.CORS.Origens[0] == "*" }},
+		{"porta", func(c *Configuracao) { c.Servidor.Porta = "9090" }, "", "servidor",
+			func(c Configuracao) bool { return c.Servidor.Porta == "8080" }},
+		{"token das frases", func(c *Configuracao) { c.Frases.Token = "novo" }, "frases", "",
+			func(c Configuracao) bool { return c.Frases.Token == "novo" }},
+		// Na mesma seção, o token muda na hora e o arquivo espera o reinício.
+		{"token e arquivo das frases", func(c *Configuracao) {
+			c.Frases.Token = "novo"
+			c.Frases.Arquivo = "outras.yaml"
+		}, "frases", "frases",
+			func(c Configuracao) bool { return c.Frases.Token == "novo" && c.Frases.Arquivo == atual.Frases.Arquivo }},
+		{"admin e stream", func(c *Configuracao) {
+			c.Admin.Sessao = time.Hour
+			c.Stream.MaxClientes = 1
+		}, "admin", "stream",
+			func(c Configuracao) bool {
+				return c.Admin.Sessao == time.Hour && c.Stream.MaxClientes == atual.Stream.MaxClientes
+			}},
+	}
+	for _, caso := range casos {
+		nova := atual
+		nova.CORS.Origens = slices.Clone(atual.CORS.Origens)
+		caso.alterar(&nova)
+		resultado, aplicadas, pendentes := mesclarRecarga(atual, nova)
+		if strings.Join(aplicadas, ", ") != caso.aplicadas || strings.Join(pendentes, ", ") != caso.pendente {
+			t.Errorf("%s: aplicadas [%s] e pendentes [%s], esperava [%s] e [%s]", caso.nome,
+				strings.Join(aplicadas, ", "), strings.Join(pendentes, ", "), caso.aplicadas, caso.pendente)
+		}
+		if !caso.conferir(resultado) {
+			t.Errorf("%s: configuração resultante incorreta", caso.nome)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Política de cache dos arquivos do frontend. Os arquivos de '_app/immutable' têm o hash
+// do conteúdo no nome e nunca mudam; os demais (ex: index.html) são revalidados pelo ETag.
+const (
+	pastaImutavel   = "_app/immutable/"
+	cacheImutavel   = "public, max-age=31536000, immutable"
+	cacheRevalidar  = "no-cache"
+	paginaPrincipal = "index.html"
+)
+
+// tiposCompressiveis são as extensões comprimidas com gzip na inicialização, quando o
+// build não trouxer a versão .gz. Imagens e fontes já são comprimidas.
+var tiposCompressiveis = map[string]bool{
+	".html": true, ".css": true, ".js": true, ".mjs": true, ".json": true,
+	".svg": true, ".txt": true, ".xml": true, ".map": true,
+}
+
+// arquivoEstatico é um arquivo do frontend já lido do embed, com as versões comprimidas.
+type arquivoEstatico struct {
+	conteudo []byte
+	gzip     []byte // Vazio se não compensar comprimir.
+	brotli   []byte // Só existe se o build gerar o arquivo .br.
+	etag     string
+	tipo     string
+}
+
+// Estaticos serve o frontend Svelte: arquivos com cache longo ou revalidação por ETag,
+// versões comprimidas e, para as rotas do próprio frontend (ex: /sobre), o index.html.
+type Estaticos struct {
+	arquivos map[string]*arquivoEstatico
+}
+
+// carregarEstaticos lê todos os arquivos do frontend e prepara o ETag e as versões
+// comprimidas de cada um. Os arquivos .gz e .br do build viram variantes do original.
+func carregarEstaticos(arquivos fs.FS) (*Estaticos, error) {
+	e := &Estaticos{arquivos: map[string]*arquivoEstatico{}}
+	comprimidos := map[string][]byte{}
+
+	err := fs.WalkDir(arquivos, ".", func(caminho string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		conteudo, err := fs.ReadFile(arquivos, caminho)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(caminho, ".gz") || strings.HasSuffix(caminho, ".br") {
+			comprimidos[caminho] = conteudo
+			return nil
+		}
+		hash := sha256.Sum256(conteudo)
+		e.arquivos[caminho] = &arquivoEstatico{
+			conteudo: conteudo,
+			etag:     `"` + hex.EncodeToString(hash[:8]) + `"`,
+			tipo:     tipoDoArquivo(caminho),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for caminho, arquivo := range e.arquivos {
+		arquivo.brotli = comprimidos[caminho+".br"]
+		arquivo.gzip = comprimidos[caminho+".gz"]
+		if arquivo.gzip == nil && tiposCompressiveis[path.Ext(caminho)] {
+			arquivo.gzip = comprimirGzip(arquivo.conteudo)
+		}
+	}
+	return e, nil
+}
+
+// tipoDoArquivo devolve o Content-Type pela extensão do arquivo.
+func tipoDoArquivo(caminho string) string {
+	if tipo := mime.TypeByExtension(path.Ext(caminho)); tipo != "" {
+		return tipo
+	}
+	return "application/octet-stream"
+}
+
+// comprimirGzip comprime o conteúdo e devolve nil se o resultado não ficar menor.
+func comprimirGzip(conteudo []byte) []byte {
+	var b bytes.Buffer
+	escritor, _ := gzip.NewWriterLevel(&b, gzip.BestCompression)
+	escritor.Write(conteudo)
+	escritor.Close()
+	if b.Len() >= len(conteudo) {
+		return nil
+	}
+	return b.Bytes()
+}
+
+// aceitaCodificacao informa se o navegador aceita a codificação no Accept-Encoding
+// (ex: "gzip, deflate, br"). "q=0" recusa a codificação, e o "*" só vale para as que não
+// aparecem pelo nome: em "*;q=0, gzip", o gzip é aceito e as demais, recusadas.
+func aceitaCodificacao(r *http.Request, codificacao string) bool {
+	aceitaCuringa := false
+	for _, item := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		nome, parametros, _ := strings.Cut(strings.TrimSpace(item), ";")
+		nome = strings.TrimSpace(nome)
+		positivo := true
+		if q, temQ := strings.CutPrefix(strings.TrimSpace(parametros), "q="); temQ {
+			if valor, err := strconv.ParseFloat(q, 64); err == nil && valor == 0 {
+				positivo = false
+			}
+		}
+		switch {
+		case strings.EqualFold(nome, codificacao):
+			return positivo
+		case nome == "*":
+			aceitaCuringa = positivo
+		}
+	}
+	return aceitaCuringa
+}
+
+// ServeHTTP atende GET e HEAD com o arquivo pedido ou, nas rotas do frontend, com o index.html.
+func (e *Estaticos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Rotas de API desconhecidas continuam respondendo JSON, e não a página do frontend.
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		responderErro(w, http.StatusNotFound, RespostaErro{Erro: "rota não encontrada: " + r.URL.Path})
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caminho := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if caminho == "" {
+		caminho = paginaPrincipal
+	}
+	arquivo, ok := e.arquivos[caminho]
+	if !ok {
+		// Caminhos com extensão (ex: /_app/x.js, /logo.png) são arquivos que não existem;
+		// os demais (ex: /sobre) são rotas do frontend, resolvidas pelo roteador do Svelte.
+		if path.Ext(caminho) != "" || strings.HasPrefix(caminho, "_app/") {
+			http.NotFound(w, r)
+			return
+		}
+		caminho = paginaPrincipal
+		if arquivo, ok = e.arquivos[caminho]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	cabecalho := w.Header()
+	cabecalho.Set("Content-Type", arquivo.tipo)
+	cabecalho.Set("X-Content-Type-Options", "nosniff")
+	if strings.HasPrefix(caminho, pastaImutavel) {
+		cabecalho.Set("Cache-Control", cacheImutavel)
+	} else {
+		cabecalho.Set("Cache-Control", cacheRevalidar)
+	}
+
+	// Cada codificação é uma representação diferente, com o seu próprio ETag.
+	conteudo, etag := arquivo.conteudo, arquivo.etag
+	if arquivo.gzip != nil || arquivo.brotli != nil {
+		cabecalho.Add("Vary", "Accept-Encoding")
+		switch {
+		case arquivo.brotli != nil && aceitaCodificacao(r, "br"):
+			conteudo, etag = arquivo.brotli, strings.TrimSuffix(etag, `"`)+`-br"`
+			cabecalho.Set("Content-Encoding", "br")
+		case arquivo.gzip != nil && aceitaCodificacao(r, "gzip"):
+			conteudo, etag = arquivo.gzip, strings.TrimSuffix(etag, `"`)+`-gzip"`
+			cabecalho.Set("Content-Encoding", "gzip")
+		}
+	}
+	cabecalho.Set("ETag", etag)
+
+	// O ServeContent responde 304 ao If-None-Match, além de HEAD e pedidos de Range.
+	http.ServeContent(w, r, caminho, time.Time{}, bytes.NewReader(conteudo))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Arquivos de um build de teste: o JS imutável vem com as versões .br e .gz do build; o
+// index.html é comprimido com gzip na carga; a imagem não é comprimida.
+const (
+	jsDeTeste    = "_app/immutable/entry/app.abc123.js"
+	indexDeTeste = "<!doctype html><html><body>" + "<p>painel</p>" + "</body></html>"
+)
+
+var arquivosDeTeste = fstest.MapFS{
+	"index.html":        {Data: []byte(strings.Repeat(indexDeTeste, 20))},
+	jsDeTeste:           {Data: []byte("console.log('app');")},
+	jsDeTeste + ".br":   {Data: []byte("brotli do app")},
+	jsDeTeste + ".gz":   {Data: []byte("gzip do app")},
+	"favicon.png":       {Data: []byte("\x89PNG imagem")},
+	"_app/version.json": {Data: []byte(`{"version":"1"}`)},
+}
+
+// pedirEstatico faz um GET no servidor de estáticos com os cabeçalhos informados.
+func pedirEstatico(t *testing.T, e *Estaticos, metodo, caminho string, cabecalhos map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	pedido := httptest.NewRequest(metodo, caminho, nil)
+	for nome, valor := range cabecalhos {
+		pedido.Header.Set(nome, valor)
+	}
+	gravador := httptest.NewRecorder()
+	e.ServeHTTP(gravador, pedido)
+	return gravador
+}
+
+func carregarEstaticosDeTeste(t *testing.T) *Estaticos {
+	t.Helper()
+	e, err := carregarEstaticos(arquivosDeTeste)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEstaticosRotas(t *testing.T) {
+	e := carregarEstaticosDeTeste(t)
+	indice := arquivosDeTeste["index.html"].Data
+	casos := []struct {
+		nome, metodo, caminho string
+		status                int
+		tipo                  string
+		corpo                 []byte // nil: não confere o corpo
+	}{
+		{"página principal", http.MethodGet, "/", http.StatusOK, "text/html; charset=utf-8", indice},
+		{"arquivo", http.MethodGet, "/favicon.png", http.StatusOK, "image/png", arquivosDeTeste["favicon.png"].Data},
+		// Rotas do frontend caem no index.html, que o roteador do Svelte resolve.
+		{"rota do frontend", http.MethodGet, "/sobre", http.StatusOK, "text/html; charset=utf-8", indice},
+		{"rota do frontend aninhada", http.MethodGet, "/painel/bitcoin", http.StatusOK, "text/html; charset=utf-8", indice},
+		{"HEAD", http.MethodHead, "/sobre", http.StatusOK, "text/html; charset=utf-8", []byte{}},
+		// Arquivos que não existem não viram a página principal.
+		{"arquivo inexistente", http.MethodGet, "/logo.png", http.StatusNotFound, "", nil},
+		{"pasta do build sem extensão", http.MethodGet, "/_app/immutable/nada", http.StatusNotFound, "", nil},
+		{"rota de API desconhecida", http.MethodGet, "/api/nada", http.StatusNotFound, "application/json", []byte(`{"erro":"rota não encontrada: /api/nada"}` + "\n")},
+		{"API com outro método", http.MethodPost, "/api/nada", http.StatusNotFound, "application/json", nil},
+		{"método não permitido", http.MethodPost, "/sobre", http.StatusMethodNotAllowed, "", nil},
+	}
+	for _, caso := range casos {
+		gravador := pedirEstatico(t, e, caso.metodo, caso.caminho, nil)
+		if gravador.Code != caso.status {
+			t.Errorf("%s: status %d, esperava %d", caso.nome, gravador.Code, caso.status)
+			continue
+		}
+		if caso.tipo != "" && gravador.Header().Get("Content-Type") != caso.tipo {
+			t.Errorf("%s: Content-Type %q, esperava %q", caso.nome, gravador.Header().Get("Content-Type"), caso.tipo)
+		}
+		if caso.corpo != nil && !bytes.Equal(gravador.Body.Bytes(), caso.corpo) {
+			t.Errorf("%s: corpo %q", caso.nome, gravador.Body)
+		}
+	}
+}
+
+func TestEstaticosCacheControl(t *testing.T) {
+	e := carregarEstaticosDeTeste(t)
+	casos := []struct{ caminho, politica string }{
+		{"/" + jsDeTeste, cacheImutavel},
+		{"/", cacheRevalidar},
+		{"/sobre", cacheRevalidar},
+		{"/favicon.png", cacheRevalidar},
+		// Fora de _app/immutable, o arquivo do build pode mudar sem mudar de nome.
+		{"/_app/version.json", cacheRevalidar},
+	}
+	for _, caso := range casos {
+		if politica := pedirEstatico(t, e, http.MethodGet, caso.caminho, nil).Header().Get("Cache-Control"); politica != caso.politica {
+			t.Errorf("%s: Cache-Control %q, esperava %q", caso.caminho, politica, caso.politica)
+		}
+	}
+}
+
+func TestEstaticosCodificacao(t *testing.T) {
+	e := carregarEstaticosDeTeste(t)
+	js := arquivosDeTeste[jsDeTeste].Data
+	casos := []struct {
+		aceita, codificacao string
+		corpo               []byte
+	}{
+		{"", "", js},
+		{"gzip", "gzip", arquivosDeTeste[jsDeTeste+".gz"].Data},
+		{"gzip, deflate, br", "br", arquivosDeTeste[jsDeTeste+".br"].Data},
+		{"br;q=0, gzip", "gzip", arquivosDeTeste[jsDeTeste+".gz"].Data},
+		{"BR;q=0.5", "br", arquivosDeTeste[jsDeTeste+".br"].Data},
+		{"deflate", "", js},
+		{"*", "br", arquivosDeTeste[jsDeTeste+".br"].Data},
+		{"*;q=0", "", js},
+		// O nome explícito vale mais que o "*", em qualquer ordem.
+		{"*;q=0, gzip", "gzip", arquivosDeTeste[jsDeTeste+".gz"].Data},
+		{"gzip;q=0, *", "br", arquivosDeTeste[jsDeTeste+".br"].Data},
+		{"br;q=0, gzip;q=0, *", "", js},
+	}
+	for _, caso := range casos {
+		gravador := pedirEstatico(t, e, http.MethodGet, "/"+jsDeTeste, map[string]string{"Accept-Encoding": caso.aceita})
+		if codificacao := gravador.Header().Get("Content-Encoding"); codificacao != caso.codificacao || !bytes.Equal(gravador.Body.Bytes(), caso.corpo) {
+			t.Errorf("Accept-Encoding %q: %q com %q, esperava %q com %q", caso.aceita, codificacao, gravador.Body, caso.codificacao, caso.corpo)
+		}
+		if gravador.Header().Get("Vary") != "Accept-Encoding" || gravador.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+			t.Errorf("Accept-Encoding %q: cabeçalhos %v", caso.aceita, gravador.Header())
+		}
+	}
+
+	// Sem o .gz no build, o gzip é gerado na carga; sem o .br, o brotli não é oferecido.
+	gravador := pedirEstatico(t, e, http.MethodGet, "/", map[string]string{"Accept-Encoding": "br, gzip"})
+	if gravador.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("index.html: Content-Encoding %q, esperava gzip", gravador.Header().Get("Content-Encoding"))
+	}
+	leitor, err := gzip.NewReader(gravador.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if descomprimido, err := io.ReadAll(leitor); err != nil || !bytes.Equal(descomprimido, arquivosDeTeste["index.html"].Data) {
+		t.Fatalf("o gzip do index.html não volta ao original: %v", err)
+	}
+
+	// Arquivos que não compensa comprimir são sempre enviados como estão.
+	gravador = pedirEstatico(t, e, http.MethodGet, "/favicon.png", map[string]string{"Accept-Encoding": "br, gzip"})
+	if gravador.Header().Get("Content-Encoding") != "" || gravador.Header().Get("Vary") != "" {
+		t.Fatalf("favicon.png: cabeçalhos %v", gravador.Header())
+	}
+}
+
+func TestEstaticosETag(t *testing.T) {
+	e := carregarEstaticosDeTeste(t)
+	etags := map[string]string{}
+	for _, aceita := range []string{"", "gzip", "br"} {
+		gravador := pedirEstatico(t, e, http.MethodGet, "/"+jsDeTeste, map[string]string{"Accept-Encoding": aceita})
+		etag := gravador.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("Accept-Encoding %q: sem ETag", aceita)
+		}
+		etags[aceita] = etag
+
+		// O navegador com a mesma representação recebe 304, sem o corpo.
+		revalidacao := pedirEstatico(t, e, http.MethodGet, "/"+jsDeTeste, map[string]string{"Accept-Encoding": aceita, "If-None-Match": etag})
+		if revalidacao.Code != http.StatusNotModified || revalidacao.Body.Len() != 0 {
+			t.Errorf("Accept-Encoding %q: status %d com %d bytes, esperava 304 vazio", aceita, revalidacao.Code, revalidacao.Body.Len())
+		}
+	}
+	// Cada codificação tem o seu ETag: um ETag do gzip não vale para quem pede sem compressão.
+	if etags[""] == etags["gzip"] || etags["gzip"] == etags["br"] || etags[""] == etags["br"] {
+		t.Fatalf("ETags repetidos entre as codificações: %v", etags)
+	}
+	if gravador := pedirEstatico(t, e, http.MethodGet, "/"+jsDeTeste, map[string]string{"If-None-Match": etags["gzip"]}); gravador.Code != http.StatusOK {
+		t.Fatalf("ETag do gzip sem Accept-Encoding: status %d, esperava 200", gravador.Code)
+	}
+
+	// As rotas do frontend têm o ETag do index.html.
+	principal := pedirEstatico(t, e, http.MethodGet, "/", nil).Header().Get("ETag")
+	if gravador := pedirEstatico(t, e, http.MethodGet, "/sobre", map[string]string{"If-None-Match": principal}); gravador.Code != http.StatusNotModified {
+		t.Fatalf("/sobre com o ETag do index.html: status %d, esperava 304", gravador.Code)
+	}
+}
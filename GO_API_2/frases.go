@@ -0,0 +1,454 @@
+package main
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfiguracaoFrases define onde as frases ficam guardadas e o token exigido para alterá-las.
+type ConfiguracaoFrases struct {
+	Arquivo string `mapstructure:"arquivo" yaml:"arquivo"`
+	Token   string `mapstructure:"token" yaml:"token"` // Vazio: a API de frases fica somente leitura.
+}
+
+// Frase é uma frase do catálogo exibido no painel.
+type Frase struct {
+	ID        int    `json:"id" yaml:"id"`
+	Texto     string `json:"texto" yaml:"texto"`
+	Categoria string `json:"categoria" yaml:"categoria"`
+	Idioma    string `json:"idioma" yaml:"idioma"`
+}
+
+// FiltroFrases seleciona as frases por categoria e idioma. Campos vazios não filtram.
+type FiltroFrases struct {
+	Categoria string
+	Idioma    string
+}
+
+// Valores usados quando a frase é cadastrada sem categoria ou idioma.
+const (
+	categoriaPadrao = "geral"
+	idiomaPadrao    = "pt-BR"
+)
+
+// Limite do texto de cada frase e do corpo das requisições de cadastro.
+const (
+	tamanhoMaximoFrase = 500
+	tamanhoMaximoCorpo = 4096
+)
+
+// categoriaValida segue o formato dos demais identificadores da API (ex: "motivacao").
+var categoriaValida = regexp.MustCompile(`^[a-z0-9][a-z0-9\-]*$`)
+
+// idiomaValido aceita códigos como "pt", "pt-BR" ou "en-US".
+var idiomaValido = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+
+// errFraseNaoEncontrada indica que não há frase com o ID pedido.
+var errFraseNaoEncontrada = errors.New("frase não encontrada")
+
+// errSemFrases indica que nenhuma frase atende ao filtro.
+var errSemFrases = errors.New("nenhuma frase encontrada para a categoria e o idioma informados")
+
+// frasesExemplo preenchem o catálogo na primeira execução, quando o arquivo ainda não existe.
+// O arquivo de frases em si é estado de execução e fica fora do git.
+//
+//go:embed frases.exemplo.yaml
+var frasesExemplo []byte
+
+// ============== ARMAZÉM DE FRASES ==============
+
+// ArmazemFrases guarda o catálogo em memória e o grava em um arquivo YAML a cada alteração.
+type ArmazemFrases struct {
+	mu      sync.RWMutex
+	arquivo string
+	frases  []Frase // Sempre ordenadas por ID.
+}
+
+// arquivoFrases é o formato do arquivo YAML.
+type arquivoFrases struct {
+	Frases []Frase `yaml:"frases"`
+}
+
+// armazemFrases é o catálogo usado pelos handlers, carregado no main.
+var armazemFrases *ArmazemFrases
+
+// carregarFrases lê o catálogo do arquivo. Se o arquivo não existir, cria-o com as frases de exemplo.
+func carregarFrases(arquivo string) (*ArmazemFrases, error) {
+	a := &ArmazemFrases{arquivo: arquivo}
+
+	dados, err := os.ReadFile(arquivo)
+	novo := errors.Is(err, os.ErrNotExist)
+	if novo {
+		dados, err = frasesExemplo, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var conteudo arquivoFrases
+	if err := yaml.Unmarshal(dados, &conteudo); err != nil {
+		return nil, fmt.Errorf("arquivo '%s' inválido: %w", arquivo, err)
+	}
+	vistos := map[int]bool{}
+	for _, frase := range conteudo.Frases {
+		if err := validarFrase(&frase); err != nil {
+			return nil, fmt.Errorf("frase %d de '%s': %w", frase.ID, arquivo, err)
+		}
+		if frase.ID <= 0 || vistos[frase.ID] {
+			return nil, fmt.Errorf("frase com ID inválido ou repetido em '%s': %d", arquivo, frase.ID)
+		}
+		vistos[frase.ID] = true
+		a.frases = append(a.frases, frase)
+	}
+	sort.Slice(a.frases, func(i, j int) bool { return a.frases[i].ID < a.frases[j].ID })
+	if novo {
+		return a, a.salvar()
+	}
+	return a, nil
+}
+
+// salvar grava o catálogo no arquivo. Deve ser chamada com o mutex travado.
+func (a *ArmazemFrases) salvar() error {
+	return gravarYAML(a.arquivo, arquivoFrases{Frases: a.frases})
+}
+
+// gravarYAML grava o conteúdo em um arquivo temporário e o renomeia, para que uma falha
+// no meio da escrita não corrompa o arquivo. O arquivo mantém as permissões que já tinha
+// (0644 se for novo), e não as 0600 do os.CreateTemp.
+func gravarYAML(arquivo string, conteudo any) error {
+	dados, err := yaml.Marshal(conteudo)
+	if err != nil {
+		return err
+	}
+	temporario, err := os.CreateTemp(filepath.Dir(arquivo), "."+filepath.Base(arquivo)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporario.Name())
+	if _, err := temporario.Write(dados); err != nil {
+		temporario.Close()
+		return err
+	}
+	if err := temporario.Close(); err != nil {
+		return err
+	}
+	permissoes := os.FileMode(0o644)
+	if info, err := os.Stat(arquivo); err == nil {
+		permissoes = info.Mode().Perm()
+	}
+	if err := os.Chmod(temporario.Name(), permissoes); err != nil {
+		return err
+	}
+	return os.Rename(temporario.Name(), arquivo)
+}
+
+// validarFrase confere os campos e preenche categoria e idioma padrão.
+func validarFrase(frase *Frase) error {
+	if err := conferirFrase(frase); err != nil {
+		return erroValidacao{err}
+	}
+	return nil
+}
+
+// conferirFrase faz o trabalho de 'validarFrase'.
+func conferirFrase(frase *Frase) error {
+	frase.Texto = strings.TrimSpace(frase.Texto)
+	frase.Categoria = strings.ToLower(strings.TrimSpace(frase.Categoria))
+	frase.Idioma = strings.TrimSpace(frase.Idioma)
+	if frase.Categoria == "" {
+		frase.Categoria = categoriaPadrao
+	}
+	if frase.Idioma == "" {
+		frase.Idioma = idiomaPadrao
+	}
+
+	if frase.Texto == "" {
+		return fmt.Errorf("o texto da frase não pode ser vazio")
+	}
+	if len([]rune(frase.Texto)) > tamanhoMaximoFrase {
+		return fmt.Errorf("frase muito longa (máximo de %d caracteres)", tamanhoMaximoFrase)
+	}
+	if !categoriaValida.MatchString(frase.Categoria) {
+		return fmt.Errorf("categoria inválida: '%s'", frase.Categoria)
+	}
+	if !idiomaValido.MatchString(frase.Idioma) {
+		return fmt.Errorf("idioma inválido: '%s' (use, por exemplo, pt-BR ou en)", frase.Idioma)
+	}
+	return nil
+}
+
+// atende informa se a frase passa no filtro. O idioma "pt" também aceita "pt-BR".
+func (f FiltroFrases) atende(frase Frase) bool {
+	if f.Categoria != "" && !strings.EqualFold(frase.Categoria, f.Categoria) {
+		return false
+	}
+	if f.Idioma != "" && !strings.EqualFold(frase.Idioma, f.Idioma) &&
+		!strings.HasPrefix(strings.ToLower(frase.Idioma), strings.ToLower(f.Idioma)+"-") {
+		return false
+	}
+	return true
+}
+
+// Listar devolve as frases que atendem ao filtro, ordenadas por ID.
+func (a *ArmazemFrases) Listar(filtro FiltroFrases) []Frase {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	lista := []Frase{}
+	for _, frase := range a.frases {
+		if filtro.atende(frase) {
+			lista = append(lista, frase)
+		}
+	}
+	return lista
+}
+
+// Obter devolve a frase com o ID informado.
+func (a *ArmazemFrases) Obter(id int) (Frase, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if i := a.indice(id); i >= 0 {
+		return a.frases[i], nil
+	}
+	return Frase{}, errFraseNaoEncontrada
+}
+
+// indice devolve a posição da frase na lista, ou -1. Deve ser chamada com o mutex travado.
+func (a *ArmazemFrases) indice(id int) int {
+	for i, frase := range a.frases {
+		if frase.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Adicionar cadastra a frase com um novo ID e devolve a frase gravada.
+func (a *ArmazemFrases) Adicionar(frase Frase) (Frase, error) {
+	if err := validarFrase(&frase); err != nil {
+		return Frase{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	frase.ID = 1
+	if len(a.frases) > 0 {
+		frase.ID = a.frases[len(a.frases)-1].ID + 1
+	}
+	a.frases = append(a.frases, frase)
+	if err := a.salvar(); err != nil {
+		a.frases = a.frases[:len(a.frases)-1]
+		return Frase{}, err
+	}
+	return frase, nil
+}
+
+// Atualizar substitui o texto, a categoria e o idioma da frase.
+func (a *ArmazemFrases) Atualizar(id int, frase Frase) (Frase, error) {
+	if err := validarFrase(&frase); err != nil {
+		return Frase{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := a.indice(id)
+	if i < 0 {
+		return Frase{}, errFraseNaoEncontrada
+	}
+	anterior := a.frases[i]
+	frase.ID = id
+	a.frases[i] = frase
+	if err := a.salvar(); err != nil {
+		a.frases[i] = anterior
+		return Frase{}, err
+	}
+	return frase, nil
+}
+
+// Remover apaga a frase.
+func (a *ArmazemFrases) Remover(id int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := a.indice(id)
+	if i < 0 {
+		return errFraseNaoEncontrada
+	}
+	anteriores := a.frases
+	a.frases = append(append([]Frase{}, a.frases[:i]...), a.frases[i+1:]...)
+	if err := a.salvar(); err != nil {
+		a.frases = anteriores
+		return err
+	}
+	return nil
+}
+
+// Sortear escolhe uma frase aleatória entre as que atendem ao filtro.
+func (a *ArmazemFrases) Sortear(filtro FiltroFrases) (Frase, error) {
+	lista := a.Listar(filtro)
+	if len(lista) == 0 {
+		return Frase{}, errSemFrases
+	}
+	return lista[rand.Intn(len(lista))], nil
+}
+
+// DoDia escolhe a "frase do dia": a mesma durante toda a data informada, para o mesmo
+// filtro, enquanto o catálogo não mudar.
+func (a *ArmazemFrases) DoDia(filtro FiltroFrases, dia time.Time) (Frase, error) {
+	lista := a.Listar(filtro)
+	if len(lista) == 0 {
+		return Frase{}, errSemFrases
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s|%s|%s", dia.Format("2006-01-02"), strings.ToLower(filtro.Categoria), strings.ToLower(filtro.Idioma))
+	return lista[h.Sum32()%uint32(len(lista))], nil
+}
+
+// ============== HANDLERS /api/frases ==============
+
+// filtroDaConsulta lê ?categoria= e ?idioma= da URL.
+func filtroDaConsulta(r *http.Request) FiltroFrases {
+	return FiltroFrases{
+		Categoria: strings.TrimSpace(r.URL.Query().Get("categoria")),
+		Idioma:    strings.TrimSpace(r.URL.Query().Get("idioma")),
+	}
+}
+
+// exigirToken libera o handler apenas para quem enviar "Authorization: Bearer <token>"
+// com o token configurado em '<secao>.token' (lido a cada requisição).
+func exigirToken(secao string, token func() string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(proximo http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			esperado := token()
+			if esperado == "" {
+				responderErro(w, http.StatusForbidden, RespostaErro{Erro: fmt.Sprintf("alteração de %s desativada: defina '%s.token' no config.yaml", secao, secao)})
+				return
+			}
+			recebido, temBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !temBearer || subtle.ConstantTimeCompare([]byte(recebido), []byte(esperado)) != 1 {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s"`, secao))
+				responderErro(w, http.StatusUnauthorized, RespostaErro{Erro: "token ausente ou inválido"})
+				return
+			}
+			proximo(w, r)
+		}
+	}
+}
+
+// idDaRota lê o {id} do caminho (ex: /api/frases/3).
+func idDaRota(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: fmt.Sprintf("ID inválido: '%s'. Deve ser um número.", r.PathValue("id"))})
+		return 0, false
+	}
+	return id, true
+}
+
+// lerFrase decodifica o corpo JSON da requisição.
+func lerFrase(w http.ResponseWriter, r *http.Request) (Frase, bool) {
+	var frase Frase
+	decodificador := json.NewDecoder(http.MaxBytesReader(w, r.Body, tamanhoMaximoCorpo))
+	decodificador.DisallowUnknownFields()
+	if err := decodificador.Decode(&frase); err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: fmt.Sprintf("JSON inválido: %v", err)})
+		return Frase{}, false
+	}
+	return frase, true
+}
+
+// responderErroFrase traduz os erros do armazém em 404, 400 ou 500.
+func responderErroFrase(w http.ResponseWriter, id int, err error) {
+	var invalida erroValidacao
+	switch {
+	case errors.Is(err, errFraseNaoEncontrada):
+		responderErro(w, http.StatusNotFound, RespostaErro{Erro: fmt.Sprintf("nenhuma frase encontrada com o ID %d", id)})
+	case errors.As(err, &invalida):
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
+	default:
+		log.Printf("ERRO: falha ao gravar o arquivo de frases: %v", err)
+		responderErro(w, http.StatusInternalServerError, RespostaErro{Erro: "falha ao gravar o arquivo de frases"})
+	}
+}
+
+// GET /api/frases?categoria=&idioma=
+func listarFrasesHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(armazemFrases.Listar(filtroDaConsulta(r)))
+}
+
+// GET /api/frases/{id}
+func obterFraseHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDaRota(w, r)
+	if !ok {
+		return
+	}
+	frase, err := armazemFrases.Obter(id)
+	if err != nil {
+		responderErroFrase(w, id, err)
+		return
+	}
+	json.NewEncoder(w).Encode(frase)
+}
+
+// POST /api/frases
+func criarFraseHandler(w http.ResponseWriter, r *http.Request) {
+	frase, ok := lerFrase(w, r)
+	if !ok {
+		return
+	}
+	frase, err := armazemFrases.Adicionar(frase)
+	if err != nil {
+		responderErroFrase(w, 0, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/frases/%d", frase.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(frase)
+}
+
+// PUT /api/frases/{id}
+func atualizarFraseHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDaRota(w, r)
+	if !ok {
+		return
+	}
+	frase, ok := lerFrase(w, r)
+	if !ok {
+		return
+	}
+	frase, err := armazemFrases.Atualizar(id, frase)
+	if err != nil {
+		responderErroFrase(w, id, err)
+		return
+	}
+	json.NewEncoder(w).Encode(frase)
+}
+
+// DELETE /api/frases/{id}
+func removerFraseHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDaRota(w, r)
+	if !ok {
+		return
+	}
+	if err := armazemFrases.Remover(id); err != nil {
+		responderErroFrase(w, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
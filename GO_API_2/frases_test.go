@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCarregarFrasesCriaArquivoComExemplos(t *testing.T) {
+	arquivo := filepath.Join(t.TempDir(), "frases.yaml")
+	armazem, err := carregarFrases(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(armazem.frases) != 4 || armazem.frases[0].ID != 1 || armazem.frases[0].Categoria != "motivacao" {
+		t.Fatalf("frases = %+v, esperava as 4 de exemplo", armazem.frases)
+	}
+	info, err := os.Stat(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("permissões %v, esperava 0644", info.Mode().Perm())
+	}
+
+	// Lido de novo, o arquivo gravado devolve o mesmo catálogo.
+	relido, err := carregarFrases(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relido.frases) != len(armazem.frases) {
+		t.Fatalf("relido %+v, esperava %+v", relido.frases, armazem.frases)
+	}
+}
+
+func TestGravarYAMLMantemPermissoes(t *testing.T) {
+	arquivo := filepath.Join(t.TempDir(), "frases.yaml")
+	if err := os.WriteFile(arquivo, []byte("frases: []\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(arquivo, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := gravarYAML(arquivo, arquivoFrases{Frases: []Frase{{ID: 1, Texto: "Olá"}}}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Fatalf("permissões %v, esperava 0640", info.Mode().Perm())
+	}
+}
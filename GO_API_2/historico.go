@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ConfiguracaoHistorico define onde as cotações são guardadas, de quanto em quanto tempo
+// são coletadas e por quanto tempo ficam no banco.
+type ConfiguracaoHistorico struct {
+	Arquivo           string        `mapstructure:"arquivo" yaml:"arquivo"`
+	Intervalo         time.Duration `mapstructure:"intervalo" yaml:"intervalo"`                   // Entre duas coletas.
+	RetencaoAmostras  time.Duration `mapstructure:"retencao_amostras" yaml:"retencao_amostras"`   // Depois disso, as amostras viram pontos por hora.
+	RetencaoAgregados time.Duration `mapstructure:"retencao_agregados" yaml:"retencao_agregados"` // Depois disso, os pontos por hora são apagados.
+}
+
+// Limites da consulta ao histórico.
+const (
+	intervaloMinimoHistorico = time.Minute
+	maximoPontosHistorico    = 1000
+)
+
+// PontoOHLC resume as cotações de um período: abertura, máxima, mínima e fechamento.
+type PontoOHLC struct {
+	Inicio     time.Time `json:"inicio"`
+	Abertura   float64   `json:"abertura"`
+	Maxima     float64   `json:"maxima"`
+	Minima     float64   `json:"minima"`
+	Fechamento float64   `json:"fechamento"`
+	Amostras   int       `json:"amostras"`
+}
+
+// incluir junta ao ponto um período posterior a ele.
+func (p *PontoOHLC) incluir(outro PontoOHLC) {
+	p.Maxima = max(p.Maxima, outro.Maxima)
+	p.Minima = min(p.Minima, outro.Minima)
+	p.Fechamento = outro.Fechamento
+	p.Amostras += outro.Amostras
+}
+
+// RespostaHistorico é o corpo JSON de /api/bitcoin/historico.
+type RespostaHistorico struct {
+	Ativo     string      `json:"ativo"`
+	Moeda     string      `json:"moeda"`
+	De        time.Time   `json:"de"`
+	Ate       time.Time   `json:"ate"`
+	Intervalo string      `json:"intervalo"`
+	Pontos    []PontoOHLC `json:"pontos"`
+}
+
+// esquemaHistorico cria as tabelas na primeira execução. As amostras recentes ficam como
+// foram coletadas; as antigas são resumidas em um ponto OHLC por hora.
+var esquemaHistorico = []string{
+	`CREATE TABLE IF NOT EXISTS amostras (
+		ativo   TEXT NOT NULL,
+		moeda   TEXT NOT NULL,
+		momento INTEGER NOT NULL,
+		preco   REAL NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS amostras_ativo_moeda_momento ON amostras(ativo, moeda, momento)`,
+	`CREATE TABLE IF NOT EXISTS agregados_hora (
+		ativo      TEXT NOT NULL,
+		moeda      TEXT NOT NULL,
+		inicio     INTEGER NOT NULL,
+		abertura   REAL NOT NULL,
+		maxima     REAL NOT NULL,
+		minima     REAL NOT NULL,
+		fechamento REAL NOT NULL,
+		quantidade INTEGER NOT NULL,
+		PRIMARY KEY (ativo, moeda, inicio)
+	)`,
+}
+
+// ============== HISTÓRICO DE PREÇOS ==============
+
+// HistoricoPrecos grava e consulta as cotações no SQLite.
+type HistoricoPrecos struct {
+	bd     *sql.DB
+	config ConfiguracaoHistorico
+	agora  func() time.Time // Substituível nos testes por um relógio falso.
+}
+
+// historicoPrecos é o histórico usado pelo handler, aberto no main.
+var historicoPrecos *HistoricoPrecos
+
+// abrirHistorico abre (ou cria) o banco do histórico.
+func abrirHistorico(config ConfiguracaoHistorico, agora func() time.Time) (*HistoricoPrecos, error) {
+	bd, err := sql.Open("sqlite3", config.Arquivo+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// Uma conexão só: o coletor e o handler se revezam, sem "database is locked".
+	bd.SetMaxOpenConns(1)
+	for _, comando := range esquemaHistorico {
+		if _, err := bd.Exec(comando); err != nil {
+			bd.Close()
+			return nil, fmt.Errorf("erro ao criar as tabelas do histórico: %w", err)
+		}
+	}
+	return &HistoricoPrecos{bd: bd, config: config, agora: agora}, nil
+}
+
+// Fechar fecha o banco.
+func (h *HistoricoPrecos) Fechar() error {
+	return h.bd.Close()
+}
+
+// Gravar guarda as cotações como amostras do momento atual.
+func (h *HistoricoPrecos) Gravar(cotacoes []Cotacao) error {
+	tx, err := h.bd.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	momento := h.agora().Unix()
+	for _, cotacao := range cotacoes {
+		if _, err := tx.Exec("INSERT INTO amostras(ativo, moeda, momento, preco) VALUES(?, ?, ?, ?)",
+			cotacao.Ativo, cotacao.Moeda, momento, cotacao.Preco); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Compactar resume em pontos por hora as amostras mais antigas que a retenção, e apaga
+// os pontos por hora mais antigos que a retenção deles. O corte é feito em hora cheia,
+// para que cada hora seja resumida de uma só vez.
+func (h *HistoricoPrecos) Compactar() error {
+	agora := h.agora()
+	limiteAmostras := agora.Add(-h.config.RetencaoAmostras).Truncate(time.Hour).Unix()
+	limiteAgregados := agora.Add(-h.config.RetencaoAgregados).Unix()
+
+	tx, err := h.bd.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT ativo, moeda, momento, preco FROM amostras WHERE momento < ?
+		ORDER BY ativo, moeda, momento`, limiteAmostras)
+	if err != nil {
+		return err
+	}
+	type chaveSerie struct{ ativo, moeda string }
+	var series []chaveSerie
+	pontos := map[chaveSerie][]PontoOHLC{}
+	for rows.Next() {
+		var chave chaveSerie
+		var momento int64
+		var preco float64
+		if err := rows.Scan(&chave.ativo, &chave.moeda, &momento, &preco); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := pontos[chave]; !ok {
+			series = append(series, chave)
+		}
+		pontos[chave] = acumularPonto(pontos[chave], amostraComoPonto(momento, preco), time.Hour)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, chave := range series {
+		for _, p := range pontos[chave] {
+			if _, err := tx.Exec(`INSERT INTO agregados_hora(ativo, moeda, inicio, abertura, maxima, minima, fechamento, quantidade)
+				VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT(ativo, moeda, inicio) DO UPDATE SET
+					maxima = MAX(maxima, excluded.maxima),
+					minima = MIN(minima, excluded.minima),
+					fechamento = excluded.fechamento,
+					quantidade = quantidade + excluded.quantidade`,
+				chave.ativo, chave.moeda, p.Inicio.Unix(), p.Abertura, p.Maxima, p.Minima, p.Fechamento, p.Amostras); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM amostras WHERE momento < ?", limiteAmostras); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM agregados_hora WHERE inicio < ?", limiteAgregados); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Consultar devolve os pontos OHLC do período [de, ate), um a cada 'intervalo'.
+// Os períodos já compactados entram com a resolução de uma hora.
+func (h *HistoricoPrecos) Consultar(ativo, moeda string, de, ate time.Time, intervalo time.Duration) ([]PontoOHLC, error) {
+	rows, err := h.bd.Query(`
+		SELECT momento, preco, preco, preco, preco, 1 FROM amostras
+		WHERE ativo = ? AND moeda = ? AND momento >= ? AND momento < ?
+		UNION ALL
+		SELECT inicio, abertura, maxima, minima, fechamento, quantidade FROM agregados_hora
+		WHERE ativo = ? AND moeda = ? AND inicio >= ? AND inicio < ?
+		ORDER BY 1`,
+		ativo, moeda, de.Unix(), ate.Unix(), ativo, moeda, de.Unix(), ate.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pontos := []PontoOHLC{}
+	for rows.Next() {
+		var momento int64
+		var p PontoOHLC
+		if err := rows.Scan(&momento, &p.Abertura, &p.Maxima, &p.Minima, &p.Fechamento, &p.Amostras); err != nil {
+			return nil, err
+		}
+		p.Inicio = time.Unix(momento, 0).UTC()
+		pontos = acumularPonto(pontos, p, intervalo)
+	}
+	return pontos, rows.Err()
+}
+
+// amostraComoPonto transforma uma cotação isolada em um ponto OHLC.
+func amostraComoPonto(momento int64, preco float64) PontoOHLC {
+	return PontoOHLC{Inicio: time.Unix(momento, 0).UTC(), Abertura: preco, Maxima: preco, Minima: preco, Fechamento: preco, Amostras: 1}
+}
+
+// acumularPonto junta o ponto ao último da lista, se cair no mesmo período de 'intervalo',
+// ou o adiciona como um período novo. Os pontos devem chegar em ordem cronológica.
+func acumularPonto(pontos []PontoOHLC, p PontoOHLC, intervalo time.Duration) []PontoOHLC {
+	p.Inicio = p.Inicio.Truncate(intervalo)
+	if n := len(pontos); n > 0 && pontos[n-1].Inicio.Equal(p.Inicio) {
+		pontos[n-1].incluir(p)
+		return pontos
+	}
+	return append(pontos, p)
+}
+
+// ============== COLETOR ==============
+
+// ColetorHistorico grava periodicamente as cotações dos ativos e moedas padrão.
+type ColetorHistorico struct {
+	historico         *HistoricoPrecos
+	buscar            func() ([]Cotacao, error) // Substituível nos testes por uma API falsa.
+	ultimaCompactacao time.Time
+}
+
+// novoColetorHistorico cria o coletor que busca as cotações direto nos provedores de mercado.
+func novoColetorHistorico(historico *HistoricoPrecos) *ColetorHistorico {
+	return &ColetorHistorico{historico: historico, buscar: cotacoesPadrao}
+}
+
+// cotacoesPadrao busca as cotações dos ativos e moedas padrão do config.yaml. A busca não
+// passa pelo cache: com o stale-while-revalidate, ele pode devolver a mesma cotação antiga
+// em várias coletas, e o histórico a gravaria como se fosse um preço novo.
+func cotacoesPadrao() ([]Cotacao, error) {
+	ativos, err := listaIdentificadores("", configuracao().Padroes.Ativos, "ativos")
+	if err != nil {
+		return nil, err
+	}
+	moedas, err := listaIdentificadores("", configuracao().Padroes.Moedas, "moeda")
+	if err != nil {
+		return nil, err
+	}
+	cotacoes, _, err := buscarCotacoesComFallback(context.Background(), ativos, moedas)
+	return cotacoes, err
+}
+
+// Coletar grava uma amostra de cada cotação e, uma vez por hora, compacta o histórico.
+func (c *ColetorHistorico) Coletar() error {
+	cotacoes, err := c.buscar()
+	if err != nil {
+		return fmt.Errorf("falha ao buscar as cotações: %w", err)
+	}
+	if err := c.historico.Gravar(cotacoes); err != nil {
+		return fmt.Errorf("falha ao gravar as cotações: %w", err)
+	}
+
+	agora := c.historico.agora()
+	if agora.Sub(c.ultimaCompactacao) >= time.Hour {
+		if err := c.historico.Compactar(); err != nil {
+			return fmt.Errorf("falha ao compactar o histórico: %w", err)
+		}
+		c.ultimaCompactacao = agora
+	}
+	return nil
+}
+
+// Iniciar coleta a cada intervalo configurado, até o contexto ser cancelado.
+func (c *ColetorHistorico) Iniciar(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.historico.config.Intervalo)
+		defer ticker.Stop()
+		for {
+			if err := c.Coletar(); err != nil {
+				log.Printf("AVISO: histórico de cotações: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ============== HANDLER ==============
+
+// lerMomento interpreta uma data ("2026-10-19") ou data e hora RFC3339 ("2026-10-19T12:00:00Z").
+func lerMomento(valor string, padrao time.Time, nome string) (time.Time, error) {
+	if valor == "" {
+		return padrao, nil
+	}
+	if momento, err := time.Parse(time.RFC3339, valor); err == nil {
+		return momento.UTC(), nil
+	}
+	if dia, err := time.Parse("2006-01-02", valor); err == nil {
+		return dia, nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' inválido: '%s' (use 2006-01-02 ou 2006-01-02T15:04:05Z)", nome, valor)
+}
+
+// Handler do histórico de cotações.
+// Ex: /api/bitcoin/historico?ativo=bitcoin&moeda=brl&de=2026-10-01&ate=2026-10-02&intervalo=1h
+func historicoHandler(w http.ResponseWriter, r *http.Request) {
+	consulta := r.URL.Query()
+	ativos, err := listaIdentificadores(consulta.Get("ativo"), configuracao().Padroes.Ativos[:1], "ativo")
+	if err == nil && len(ativos) > 1 {
+		err = fmt.Errorf("informe apenas um valor em 'ativo'")
+	}
+	if err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
+		return
+	}
+	moedas, err := listaIdentificadores(consulta.Get("moeda"), configuracao().Padroes.Moedas[:1], "moeda")
+	if err == nil && len(moedas) > 1 {
+		err = fmt.Errorf("informe apenas um valor em 'moeda'")
+	}
+	if err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
+		return
+	}
+
+	// Sem parâmetros: as últimas 24 horas, de hora em hora.
+	textoIntervalo := strings.TrimSpace(consulta.Get("intervalo"))
+	if textoIntervalo == "" {
+		textoIntervalo = "1h"
+	}
+	intervalo, err := time.ParseDuration(textoIntervalo)
+	if err != nil || intervalo < intervaloMinimoHistorico {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: fmt.Sprintf("intervalo inválido: '%s' (mínimo de %s, ex: 15m, 1h, 24h)", textoIntervalo, intervaloMinimoHistorico)})
+		return
+	}
+	ate, err := lerMomento(consulta.Get("ate"), historicoPrecos.agora().UTC(), "ate")
+	if err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
+		return
+	}
+	de, err := lerMomento(consulta.Get("de"), ate.Add(-24*time.Hour), "de")
+	if err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
+		return
+	}
+	if !de.Before(ate) {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: "'de' deve ser anterior a 'ate'"})
+		return
+	}
+	if ate.Sub(de)/intervalo > maximoPontosHistorico {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: fmt.Sprintf("período longo demais para o intervalo (máximo de %d pontos)", maximoPontosHistorico)})
+		return
+	}
+
+	pontos, err := historicoPrecos.Consultar(ativos[0], moedas[0], de, ate, intervalo)
+	if err != nil {
+		log.Printf("ERRO: falha ao consultar o histórico: %v", err)
+		responderErro(w, http.StatusInternalServerError, RespostaErro{Erro: "falha ao consultar o histórico"})
+		return
+	}
+	json.NewEncoder(w).Encode(RespostaHistorico{
+		Ativo: ativos[0], Moeda: moedas[0], De: de, Ate: ate, Intervalo: intervalo.String(), Pontos: pontos,
+	})
+}
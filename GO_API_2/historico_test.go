@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// abrirHistoricoDeTeste abre um histórico em uma pasta temporária, com amostras guardadas
+// por 2 horas e pontos por hora guardados por 24 horas.
+func abrirHistoricoDeTeste(t *testing.T, relogio *relogioFalso) *HistoricoPrecos {
+	t.Helper()
+	historico, err := abrirHistorico(ConfiguracaoHistorico{
+		Arquivo:           filepath.Join(t.TempDir(), "historico.db"),
+		Intervalo:         15 * time.Minute,
+		RetencaoAmostras:  2 * time.Hour,
+		RetencaoAgregados: 24 * time.Hour,
+	}, relogio.agora)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { historico.Fechar() })
+	return historico
+}
+
+// linhasHistorico lista as linhas de uma série nas duas tabelas, como texto, na ordem do tempo.
+func linhasHistorico(t *testing.T, h *HistoricoPrecos, moeda string) (amostras, agregados []string) {
+	t.Helper()
+	rows, err := h.bd.Query("SELECT momento, preco FROM amostras WHERE moeda = ? ORDER BY momento", moeda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var momento int64
+		var preco float64
+		if err := rows.Scan(&momento, &preco); err != nil {
+			t.Fatal(err)
+		}
+		amostras = append(amostras, fmt.Sprintf("%s %.0f", time.Unix(momento, 0).UTC().Format("02 15:04"), preco))
+	}
+	rows.Close()
+
+	rows, err = h.bd.Query(`SELECT inicio, abertura, maxima, minima, fechamento, quantidade FROM agregados_hora
+		WHERE moeda = ? ORDER BY inicio`, moeda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var inicio int64
+		var p PontoOHLC
+		if err := rows.Scan(&inicio, &p.Abertura, &p.Maxima, &p.Minima, &p.Fechamento, &p.Amostras); err != nil {
+			t.Fatal(err)
+		}
+		p.Inicio = time.Unix(inicio, 0)
+		agregados = append(agregados, formatarPonto(p))
+	}
+	return amostras, agregados
+}
+
+// formatarPonto resume o ponto em uma linha: "dia hora abertura/máxima/mínima/fechamento xN".
+func formatarPonto(p PontoOHLC) string {
+	return fmt.Sprintf("%s %.0f/%.0f/%.0f/%.0f x%d", p.Inicio.UTC().Format("02 15:04"), p.Abertura, p.Maxima, p.Minima, p.Fechamento, p.Amostras)
+}
+
+func conferirLinhas(t *testing.T, nome string, obtidas []string, esperadas ...string) {
+	t.Helper()
+	if strings.Join(obtidas, ", ") != strings.Join(esperadas, ", ") {
+		t.Fatalf("%s = [%s], esperava [%s]", nome, strings.Join(obtidas, ", "), strings.Join(esperadas, ", "))
+	}
+}
+
+// coletarSerie roda o coletor com uma API falsa que devolve os preços em BRL informados,
+// um por coleta, avançando o relógio 'passo' depois de cada uma. A cotação em USD é
+// sempre um quinto da cotação em BRL.
+func coletarSerie(t *testing.T, coletor *ColetorHistorico, relogio *relogioFalso, passo time.Duration, precos ...float64) {
+	t.Helper()
+	for _, preco := range precos {
+		coletor.buscar = func() ([]Cotacao, error) {
+			return []Cotacao{{Ativo: "bitcoin", Moeda: "brl", Preco: preco}, {Ativo: "bitcoin", Moeda: "usd", Preco: preco / 5}}, nil
+		}
+		if err := coletor.Coletar(); err != nil {
+			t.Fatal(err)
+		}
+		relogio.avancar(passo)
+	}
+}
+
+// prepararHistoricoDeTeste grava as cotações usadas nos testes de consulta:
+// amostras de 15 em 15 minutos das 12:00 às 13:15 e uma às 15:10, quando a coleta
+// compacta a hora das 12:00.
+func prepararHistoricoDeTeste(t *testing.T, relogio *relogioFalso) *HistoricoPrecos {
+	t.Helper()
+	historico := abrirHistoricoDeTeste(t, relogio)
+	coletor := novoColetorHistorico(historico)
+	coletarSerie(t, coletor, relogio, 15*time.Minute, 100, 110, 90, 105, 120, 115)
+	relogio.avancar(time.Hour + 40*time.Minute)
+	coletarSerie(t, coletor, relogio, 0, 130)
+	return historico
+}
+
+func TestColetorHistorico(t *testing.T) {
+	relogio := novoRelogioFalso()
+	historico := abrirHistoricoDeTeste(t, relogio)
+	coletor := novoColetorHistorico(historico)
+
+	// Cada coleta grava uma amostra por cotação, no momento do relógio.
+	coletarSerie(t, coletor, relogio, 15*time.Minute, 100, 110, 90, 105, 120, 115)
+	amostras, agregados := linhasHistorico(t, historico, "brl")
+	conferirLinhas(t, "amostras em BRL", amostras, "19 12:00 100", "19 12:15 110", "19 12:30 90", "19 12:45 105", "19 13:00 120", "19 13:15 115")
+	conferirLinhas(t, "agregados em BRL", agregados)
+	if amostras, _ := linhasHistorico(t, historico, "usd"); len(amostras) != 6 || amostras[0] != "19 12:00 20" {
+		t.Fatalf("amostras em USD = %v", amostras)
+	}
+
+	// Às 15:10 as amostras antes das 13:00 passaram da retenção de 2 horas: a coleta
+	// compacta a hora das 12:00 em um ponto OHLC e apaga as amostras dela.
+	relogio.avancar(time.Hour + 40*time.Minute)
+	coletarSerie(t, coletor, relogio, 0, 130)
+	amostras, agregados = linhasHistorico(t, historico, "brl")
+	conferirLinhas(t, "amostras em BRL depois da compactação", amostras, "19 13:00 120", "19 13:15 115", "19 15:10 130")
+	conferirLinhas(t, "agregados em BRL depois da compactação", agregados, "19 12:00 100/110/90/105 x4")
+	if _, agregados := linhasHistorico(t, historico, "usd"); len(agregados) != 1 || agregados[0] != "19 12:00 20/22/18/21 x4" {
+		t.Fatalf("agregados em USD = %v", agregados)
+	}
+
+	// Uma busca com falha não grava nada.
+	coletor.buscar = func() ([]Cotacao, error) { return nil, errors.New("api fora do ar") }
+	if err := coletor.Coletar(); err == nil {
+		t.Fatal("esperava erro da busca")
+	}
+	if amostras, _ := linhasHistorico(t, historico, "brl"); len(amostras) != 3 {
+		t.Fatalf("a falha gravou amostras: %v", amostras)
+	}
+}
+
+func TestColetorIgnoraCache(t *testing.T) {
+	relogio := novoRelogioFalso()
+	chamadas := prepararBitcoinDeTeste(t, relogio, nil)
+	historico := abrirHistoricoDeTeste(t, relogio)
+	coletor := novoColetorHistorico(historico)
+
+	// O navegador deixa a cotação no cache; 2 minutos depois ela está obsoleta, mas o cache
+	// ainda a serviria enquanto revalida em segundo plano.
+	if _, preco := pedirBitcoin(t); preco != 350001 {
+		t.Fatalf("preço %.0f, esperava 350001", preco)
+	}
+	for range 2 {
+		relogio.avancar(2 * time.Minute)
+		if err := coletor.Coletar(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Cada coleta buscou um preço novo na API, em vez de repetir o do cache.
+	amostras, _ := linhasHistorico(t, historico, "brl")
+	conferirLinhas(t, "amostras em BRL", amostras, "19 12:02 350002", "19 12:04 350003")
+	if chamadas.Load() != 3 {
+		t.Fatalf("%d chamadas à API, esperava 3", chamadas.Load())
+	}
+}
+
+func TestHistoricoCompactarRetencao(t *testing.T) {
+	relogio := novoRelogioFalso()
+	historico := prepararHistoricoDeTeste(t, relogio)
+
+	// Compactar de novo na mesma hora não muda nada: a hora das 13:00 ainda está na retenção.
+	if err := historico.Compactar(); err != nil {
+		t.Fatal(err)
+	}
+	amostras, agregados := linhasHistorico(t, historico, "brl")
+	conferirLinhas(t, "amostras", amostras, "19 13:00 120", "19 13:15 115", "19 15:10 130")
+	conferirLinhas(t, "agregados", agregados, "19 12:00 100/110/90/105 x4")
+
+	// No dia seguinte às 12:30, todas as amostras viram pontos por hora e o ponto das 12:00
+	// do dia anterior passa da retenção de 24 horas.
+	relogio.avancar(21*time.Hour + 20*time.Minute)
+	if err := historico.Compactar(); err != nil {
+		t.Fatal(err)
+	}
+	amostras, agregados = linhasHistorico(t, historico, "brl")
+	conferirLinhas(t, "amostras no dia seguinte", amostras)
+	conferirLinhas(t, "agregados no dia seguinte", agregados, "19 13:00 120/120/115/115 x2", "19 15:00 130/130/130/130 x1")
+
+	// Depois de mais 24 horas, não sobra nada.
+	relogio.avancar(24 * time.Hour)
+	if err := historico.Compactar(); err != nil {
+		t.Fatal(err)
+	}
+	amostras, agregados = linhasHistorico(t, historico, "brl")
+	conferirLinhas(t, "amostras dois dias depois", amostras)
+	conferirLinhas(t, "agregados dois dias depois", agregados)
+}
+
+func TestHistoricoConsultar(t *testing.T) {
+	relogio := novoRelogioFalso()
+	historico := prepararHistoricoDeTeste(t, relogio)
+	dia := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	casos := []struct {
+		nome      string
+		de, ate   time.Duration // A partir da meia-noite.
+		intervalo time.Duration
+		pontos    []string
+	}{
+		{"o dia inteiro de hora em hora", 0, 24 * time.Hour, time.Hour, []string{
+			"19 12:00 100/110/90/105 x4", // Já compactado.
+			"19 13:00 120/120/115/115 x2",
+			"19 15:00 130/130/130/130 x1",
+		}},
+		// 'ate' não entra no período.
+		{"só a hora compactada", 12 * time.Hour, 13 * time.Hour, time.Hour, []string{"19 12:00 100/110/90/105 x4"}},
+		{"sem a última amostra", 13 * time.Hour, 15*time.Hour + 10*time.Minute, time.Hour, []string{"19 13:00 120/120/115/115 x2"}},
+		// Com intervalo de 2 horas, o ponto compactado e as amostras se juntam.
+		{"de duas em duas horas", 12 * time.Hour, 16 * time.Hour, 2 * time.Hour, []string{
+			"19 12:00 100/120/90/115 x6",
+			"19 14:00 130/130/130/130 x1",
+		}},
+		{"período sem cotações", 0, 12 * time.Hour, time.Hour, nil},
+	}
+	for _, caso := range casos {
+		pontos, err := historico.Consultar("bitcoin", "brl", dia.Add(caso.de), dia.Add(caso.ate), caso.intervalo)
+		if err != nil {
+			t.Fatalf("%s: %v", caso.nome, err)
+		}
+		var obtidos []string
+		for _, p := range pontos {
+			obtidos = append(obtidos, formatarPonto(p))
+		}
+		conferirLinhas(t, caso.nome, obtidos, caso.pontos...)
+	}
+
+	if pontos, err := historico.Consultar("bitcoin", "eur", dia, dia.Add(24*time.Hour), time.Hour); err != nil || len(pontos) != 0 {
+		t.Fatalf("moeda sem cotações: %v, %v", pontos, err)
+	}
+}
+
+func TestHistoricoHandler(t *testing.T) {
+	relogio := novoRelogioFalso()
+	historico := prepararHistoricoDeTeste(t, relogio)
+	var config Configuracao
+	config.aplicarPadroes()
+	configAnterior, historicoAnterior := configuracaoAtual.Load(), historicoPrecos
+	configuracaoAtual.Store(&config)
+	historicoPrecos = historico
+	t.Cleanup(func() {
+		configuracaoAtual.Store(configAnterior)
+		historicoPrecos = historicoAnterior
+	})
+
+	gravador := httptest.NewRecorder()
+	historicoHandler(gravador, httptest.NewRequest(http.MethodGet,
+		"/api/bitcoin/historico?ativo=bitcoin&moeda=brl&de=2026-10-19T12:00:00Z&ate=2026-10-19T14:00:00Z&intervalo=1h", nil))
+	if gravador.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", gravador.Code, gravador.Body)
+	}
+	var resposta RespostaHistorico
+	if err := json.Unmarshal(gravador.Body.Bytes(), &resposta); err != nil {
+		t.Fatal(err)
+	}
+	var obtidos []string
+	for _, p := range resposta.Pontos {
+		obtidos = append(obtidos, formatarPonto(p))
+	}
+	conferirLinhas(t, "pontos", obtidos, "19 12:00 100/110/90/105 x4", "19 13:00 120/120/115/115 x2")
+	if resposta.Intervalo != "1h0m0s" || resposta.Moeda != "brl" {
+		t.Fatalf("resposta = %+v", resposta)
+	}
+
+	// Sem parâmetros de período: as últimas 24 horas até o momento atual (15:10), que fica
+	// de fora como qualquer 'ate'.
+	gravador = httptest.NewRecorder()
+	historicoHandler(gravador, httptest.NewRequest(http.MethodGet, "/api/bitcoin/historico", nil))
+	if err := json.Unmarshal(gravador.Body.Bytes(), &resposta); err != nil || len(resposta.Pontos) != 2 || !resposta.Ate.Equal(relogio.agora()) {
+		t.Fatalf("resposta padrão %s: %v", gravador.Body, err)
+	}
+
+	for _, consulta := range []string{"intervalo=30s", "de=ontem", "de=2026-10-20&ate=2026-10-19", "moeda=brl,usd", "de=2026-01-01&intervalo=1m"} {
+		gravador := httptest.NewRecorder()
+		historicoHandler(gravador, httptest.NewRequest(http.MethodGet, "/api/bitcoin/historico?"+consulta, nil))
+		if gravador.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, esperava 400", consulta, gravador.Code)
+		}
+	}
+}
@@ -1,20 +1,23 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"io/fs"
 	"log"
-	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
-	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 // Nova diretiva 'embed' para incluir todos os arquivos da pasta 'public' no binário.
@@ -32,6 +35,7 @@ Esta diretiva diz respeito ao arquivosPublicos  logo abaixo
 var arquivosPublicos embed.FS
 
 // Nova constante para o nome do arquivo de configuração, facilitando a manutenção.
+// Outro arquivo pode ser usado com --config.
 const nomeArquivoConfig = "config.yaml"
 
 // Structs que vao "entender o formato das respostas JSON"
@@ -42,7 +46,206 @@ type Configuracao struct {
 	Servidor struct {
 		Host  string `mapstructure:"host" yaml:"host"`
 		Porta string `mapstructure:"porta" yaml:"porta"`
+		// Tempos máximos de cada conexão, que protegem o servidor de clientes lentos ou parados.
+		TimeoutCabecalho time.Duration `mapstructure:"timeout_cabecalho" yaml:"timeout_cabecalho"`
+		TimeoutLeitura   time.Duration `mapstructure:"timeout_leitura" yaml:"timeout_leitura"`
+		TimeoutEscrita   time.Duration `mapstructure:"timeout_escrita" yaml:"timeout_escrita"`
+		TimeoutOcioso    time.Duration `mapstructure:"timeout_ocioso" yaml:"timeout_ocioso"`
+		// Tempo dado às requisições em andamento ao receber SIGINT/SIGTERM.
+		PrazoEncerramento time.Duration `mapstructure:"prazo_encerramento" yaml:"prazo_encerramento"`
 	} `mapstructure:"servidor" yaml:"servidor"`
+	// Tempo de vida das respostas das APIs externas guardadas em memória.
+	Cache struct {
+		Bitcoin PoliticaCache `mapstructure:"bitcoin" yaml:"bitcoin"`
+		Clima   PoliticaCache `mapstructure:"clima" yaml:"clima"`
+	} `mapstructure:"cache" yaml:"cache"`
+	// Endereços e regras de resiliência das APIs externas.
+	APIs struct {
+		CoinGecko               ConfiguracaoUpstream `mapstructure:"coingecko" yaml:"coingecko"`
+		CryptoCompare           ConfiguracaoUpstream `mapstructure:"cryptocompare" yaml:"cryptocompare"`
+		Wttr                    ConfiguracaoUpstream `mapstructure:"wttr" yaml:"wttr"`
+		OpenMeteo               ConfiguracaoUpstream `mapstructure:"openmeteo" yaml:"openmeteo"`
+		OpenMeteoGeocodificacao ConfiguracaoUpstream `mapstructure:"openmeteo_geocodificacao" yaml:"openmeteo_geocodificacao"`
+	} `mapstructure:"apis" yaml:"apis"`
+	// Serviços que fornecem o clima e as cotações, na ordem em que são tentados.
+	Provedores ConfiguracaoProvedores `mapstructure:"provedores" yaml:"provedores"`
+	// Valores usados quando a requisição não informa cidade, moedas ou ativos.
+	Padroes struct {
+		Cidade string   `mapstructure:"cidade" yaml:"cidade"`
+		Moedas []string `mapstructure:"moedas" yaml:"moedas"`
+		Ativos []string `mapstructure:"ativos" yaml:"ativos"`
+	} `mapstructure:"padroes" yaml:"padroes"`
+	// Atualizador periódico e limites do /api/stream.
+	Stream ConfiguracaoStream `mapstructure:"stream" yaml:"stream"`
+	// Keepalive e limites do /ws.
+	WebSocket ConfiguracaoWebSocket `mapstructure:"websocket" yaml:"websocket"`
+	// Páginas de outras origens (ex: o 'npm run dev' do Svelte) que podem chamar a API.
+	CORS ConfiguracaoCORS `mapstructure:"cors" yaml:"cors"`
+	// Catálogo de frases e token da API de cadastro.
+	Frases ConfiguracaoFrases `mapstructure:"frases" yaml:"frases"`
+	// HTTPS com certificado próprio ou autoassinado, redirecionamento e HSTS.
+	TLS ConfiguracaoTLS `mapstructure:"tls" yaml:"tls"`
+	// Coleta e retenção do histórico de cotações.
+	Historico ConfiguracaoHistorico `mapstructure:"historico" yaml:"historico"`
+	// Regras de alerta, token da API de alertas e canais de notificação.
+	Alertas ConfiguracaoAlertas `mapstructure:"alertas" yaml:"alertas"`
+	// Usuários e sessões da área administrativa (/api/admin).
+	Admin ConfiguracaoAdmin `mapstructure:"admin" yaml:"admin"`
+}
+
+// aplicarPadroes preenche as opções que não foram informadas no arquivo de configuração.
+func (c *Configuracao) aplicarPadroes() {
+	if c.Servidor.Host == "" {
+		c.Servidor.Host = "0.0.0.0"
+	}
+	if c.Servidor.Porta == "" {
+		c.Servidor.Porta = "8080"
+	}
+	if c.Servidor.TimeoutCabecalho == 0 {
+		c.Servidor.TimeoutCabecalho = 5 * time.Second
+	}
+	if c.Servidor.TimeoutLeitura == 0 {
+		c.Servidor.TimeoutLeitura = 15 * time.Second
+	}
+	if c.Servidor.TimeoutEscrita == 0 {
+		c.Servidor.TimeoutEscrita = 30 * time.Second
+	}
+	if c.Servidor.TimeoutOcioso == 0 {
+		c.Servidor.TimeoutOcioso = 2 * time.Minute
+	}
+	if c.Servidor.PrazoEncerramento == 0 {
+		c.Servidor.PrazoEncerramento = 15 * time.Second
+	}
+	if c.Cache.Bitcoin.TTL == 0 {
+		c.Cache.Bitcoin = PoliticaCache{TTL: time.Minute, Obsoleto: 5 * time.Minute}
+	}
+	if c.Cache.Clima.TTL == 0 {
+		c.Cache.Clima = PoliticaCache{TTL: 10 * time.Minute, Obsoleto: 30 * time.Minute}
+	}
+	if c.Padroes.Cidade == "" {
+		c.Padroes.Cidade = "João Pessoa"
+	}
+	if len(c.Padroes.Moedas) == 0 {
+		c.Padroes.Moedas = []string{"brl"}
+	}
+	if len(c.Padroes.Ativos) == 0 {
+		c.Padroes.Ativos = []string{"bitcoin"}
+	}
+	c.APIs.CoinGecko.aplicarPadroes("https://api.coingecko.com/api/v3")
+	c.APIs.CryptoCompare.aplicarPadroes("https://min-api.cryptocompare.com")
+	c.APIs.Wttr.aplicarPadroes("https://wttr.in")
+	c.APIs.OpenMeteo.aplicarPadroes("https://api.open-meteo.com/v1")
+	c.APIs.OpenMeteoGeocodificacao.aplicarPadroes("https://geocoding-api.open-meteo.com/v1")
+	if len(c.Provedores.Clima) == 0 {
+		c.Provedores.Clima = []string{provedorWttr, provedorOpenMeteo}
+	}
+	if len(c.Provedores.Mercado) == 0 {
+		c.Provedores.Mercado = []string{provedorCoinGecko, provedorCryptoCompare}
+	}
+	c.Stream.aplicarPadroes()
+	c.WebSocket.aplicarPadroes()
+	c.CORS.aplicarPadroes()
+	if c.Frases.Arquivo == "" {
+		c.Frases.Arquivo = "frases.yaml"
+	}
+	c.Historico.aplicarPadroes()
+	if c.Alertas.Arquivo == "" {
+		c.Alertas.Arquivo = "alertas.yaml"
+	}
+	if c.Alertas.Telegram.URLBase == "" {
+		c.Alertas.Telegram.URLBase = "https://api.telegram.org"
+	}
+	c.Admin.aplicarPadroes()
+}
+
+// aplicarPadroes preenche as opções não informadas do histórico de cotações.
+func (h *ConfiguracaoHistorico) aplicarPadroes() {
+	if h.Arquivo == "" {
+		h.Arquivo = "historico.db"
+	}
+	if h.Intervalo == 0 {
+		h.Intervalo = time.Minute
+	}
+	if h.RetencaoAmostras == 0 {
+		h.RetencaoAmostras = 7 * 24 * time.Hour
+	}
+	if h.RetencaoAgregados == 0 {
+		h.RetencaoAgregados = 365 * 24 * time.Hour
+	}
+}
+
+// aplicarPadroes preenche as opções de CORS não informadas. Por padrão, só o servidor
+// de desenvolvimento do Svelte (vite) pode chamar a API de outra origem.
+func (c *ConfiguracaoCORS) aplicarPadroes() {
+	if len(c.Origens) == 0 {
+		c.Origens = []string{"http://localhost:5173", "http://localhost:4173"}
+	}
+	if len(c.Metodos) == 0 {
+		c.Metodos = []string{"GET", "POST", "PUT", "DELETE"}
+	}
+	if len(c.Cabecalhos) == 0 {
+		c.Cabecalhos = []string{"Content-Type", "Authorization", cabecalhoIDRequisicao}
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = 10 * time.Minute
+	}
+}
+
+// aplicarPadroes preenche as opções não informadas do /api/stream.
+func (s *ConfiguracaoStream) aplicarPadroes() {
+	if s.Intervalo == 0 {
+		s.Intervalo = 30 * time.Second
+	}
+	if s.Heartbeat == 0 {
+		s.Heartbeat = 15 * time.Second
+	}
+	if s.MaxClientes == 0 {
+		s.MaxClientes = 100
+	}
+	if s.Historico == 0 {
+		s.Historico = 50
+	}
+}
+
+// aplicarPadroes preenche as opções não informadas do /ws.
+func (w *ConfiguracaoWebSocket) aplicarPadroes() {
+	if w.Ping == 0 {
+		w.Ping = 30 * time.Second
+	}
+	if w.MaxClientes == 0 {
+		w.MaxClientes = 100
+	}
+	if w.MaxTopicos == 0 {
+		w.MaxTopicos = 10
+	}
+}
+
+// aplicarPadroes preenche as opções não informadas de uma API externa. Um zero
+// explícito em 'tentativas' ou 'limite_falhas' é mantido.
+func (u *ConfiguracaoUpstream) aplicarPadroes(urlBase string) {
+	if u.URLBase == "" {
+		u.URLBase = urlBase
+	}
+	if u.Timeout == 0 {
+		u.Timeout = 5 * time.Second
+	}
+	if u.Tentativas == nil {
+		u.Tentativas = ponteiro(2)
+	}
+	if u.EsperaInicial == 0 {
+		u.EsperaInicial = 200 * time.Millisecond
+	}
+	if u.LimiteFalhas == nil {
+		u.LimiteFalhas = ponteiro(5)
+	}
+	if u.TempoAberto == 0 {
+		u.TempoAberto = 30 * time.Second
+	}
+}
+
+// ponteiro devolve o endereço de uma cópia do valor, para preencher opções opcionais.
+func ponteiro[T any](valor T) *T {
+	return &valor
 }
 
 // Estrutura para a nossa resposta da API
@@ -50,146 +253,229 @@ type MensagemAPI struct {
 	Texto     string `json:"texto"`
 	Timestamp string `json:"timestamp"`
 	Frase     string `json:"frase"`
+	FraseID   int    `json:"frase_id"`
+	Categoria string `json:"categoria"`
+	Idioma    string `json:"idioma"`
 }
 
-// Estrutura para decodificar a resposta da API CoinGecko
-type BitCoinDados struct {
-	Bitcoin struct {
-		BRL float64 `json:"brl"`
-	} `json:"bitcoin"`
+// Cotacao é o preço de um ativo em uma moeda.
+type Cotacao struct {
+	Ativo string  `json:"ativo"`
+	Moeda string  `json:"moeda"`
+	Preco float64 `json:"preco"`
 }
 
-// Estrutura para decodificar a resposta da API wttr.in
-type ClimaDados struct {
-	CondicoesTempo []struct {
-		TempC    string `json:"temp_C"`
-		DescTemp []struct {
-			Valor string `json:"value"`
-		} `json:"weatherDesc"`
-	} `json:"current_condition"`
+// Estrutura da resposta de /api/bitcoin, que não depende do provedor que respondeu.
+type RespostaCotacoes struct {
+	Cotacoes     []Cotacao `json:"cotacoes"`
+	Provedor     string    `json:"provedor"`
+	AtualizadoEm string    `json:"atualizado_em"`
+
+	// Bitcoin repete os preços do bitcoin por moeda ({"brl": 350000}), no formato antigo
+	// que a página compilada em public/ ainda lê. Sai quando a página for recompilada
+	// lendo 'cotacoes' (veja meu-app-frontend/README.md).
+	Bitcoin map[string]float64 `json:"bitcoin,omitempty"`
 }
 
-// Lista de frases motivacionais que nosso backend pode escolher.
-var frasesMotivacionais = []string{
-	"O sucesso é a soma de pequenos esforços repetidos dia após dia.",
-	"Acredite em você mesmo e tudo será possível.",
-	"O único lugar onde o sucesso vem antes do trabalho é no dicionário.",
-	"Comece onde você está. Use o que você tem. Faça o que você pode.",
+// Estrutura da resposta de /api/clima, que não depende do provedor que respondeu.
+type Clima struct {
+	Cidade       string  `json:"cidade"`
+	TemperaturaC float64 `json:"temperatura_c"`
+	Descricao    string  `json:"descricao"`
+	Provedor     string  `json:"provedor"`
+	AtualizadoEm string  `json:"atualizado_em"`
+
+	// CondicaoAtual repete a temperatura e a descrição no formato antigo do wttr.in
+	// ({"current_condition": [{"temp_C": "30", "weatherDesc": [{"value": "Sol"}]}]}), que a
+	// página compilada em public/ ainda lê. Sai junto com o campo 'bitcoin' das cotações.
+	CondicaoAtual []CondicaoAntiga `json:"current_condition,omitempty"`
 }
 
-// Teremos apenas um handler, que faz tudo.
-func mensagemHandler(w http.ResponseWriter, r *http.Request) {
-	// Configura os cabeçalhos essenciais (CORS e tipo de conteúdo)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Content-Type", "application/json")
+// CondicaoAntiga é uma condição do formato antigo da resposta de /api/clima.
+type CondicaoAntiga struct {
+	TempC     string            `json:"temp_C"`
+	Descricao []DescricaoAntiga `json:"weatherDesc"`
+}
 
-	// --- Lógica para escolher a frase aleatória (movida para cá) ---
-	indiceAleatorio := rand.Intn(len(frasesMotivacionais))
-	fraseEscolhida := frasesMotivacionais[indiceAleatorio]
+// DescricaoAntiga é o texto da condição no formato antigo ({"value": "Sol"}).
+type DescricaoAntiga struct {
+	Valor string `json:"value"`
+}
 
-	// --- Monta a resposta completa com todos os dados ---
-	respostaCompleta := MensagemAPI{
-		Texto:     "Dados recebidos do Backend Go! 🚀",
-		Timestamp: time.Now().Format(time.RFC3339),
-		Frase:     fraseEscolhida, // Adiciona a frase escolhida à resposta
+// Handler da mensagem com a frase do catálogo.
+// Ex: /api/mensagem?categoria=motivacao&idioma=pt-BR&modo=dia
+func mensagemHandler(w http.ResponseWriter, r *http.Request) {
+	// 'modo=dia' devolve a frase do dia (a mesma durante toda a data); o padrão é sortear.
+	modo := r.URL.Query().Get("modo")
+	if modo != "" && modo != modoAleatorio && modo != modoDoDia {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: fmt.Sprintf("modo inválido: '%s' (use aleatorio ou dia)", modo)})
+		return
 	}
 
-	// Codifica e envia a resposta completa
-	err := json.NewEncoder(w).Encode(respostaCompleta)
+	mensagem, err := novaMensagem(filtroDaConsulta(r), modo)
 	if err != nil {
+		responderErro(w, http.StatusNotFound, RespostaErro{Erro: err.Error()})
+		return
+	}
+
+	// Codifica e envia a resposta completa
+	if err := json.NewEncoder(w).Encode(mensagem); err != nil {
 		log.Printf("Erro ao codificar JSON: %v", err)
 	}
 }
 
-// Handler para cotação do Bitcoin
-func bitcoinHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Content-Type", "application/json")
+// Modos de escolha da frase em /api/mensagem.
+const (
+	modoAleatorio = "aleatorio"
+	modoDoDia     = "dia"
+)
+
+// novaMensagem monta a mensagem com uma frase do catálogo (usada também pelo /api/stream e pelo /ws).
+func novaMensagem(filtro FiltroFrases, modo string) (MensagemAPI, error) {
+	// --- Lógica para escolher a frase ---
+	agora := time.Now()
+	var frase Frase
+	var err error
+	if modo == modoDoDia {
+		frase, err = armazemFrases.DoDia(filtro, agora)
+	} else {
+		frase, err = armazemFrases.Sortear(filtro)
+	}
+	if err != nil {
+		return MensagemAPI{}, err
+	}
 
-	// 1. Chama a API externa da CoinGecko
-	resp, err := http.Get("https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=brl")
+	// --- Monta a resposta completa com todos os dados ---
+	return MensagemAPI{
+		Texto:     "Dados recebidos do Backend Go! 🚀",
+		Timestamp: agora.Format(time.RFC3339),
+		Frase:     frase.Texto, // Adiciona a frase escolhida à resposta
+		FraseID:   frase.ID,
+		Categoria: frase.Categoria,
+		Idioma:    frase.Idioma,
+	}, nil
+}
+
+// Handler para cotação do Bitcoin (e de outros ativos).
+// Ex: /api/bitcoin?moeda=usd,eur&ativos=bitcoin,ethereum
+func bitcoinHandler(w http.ResponseWriter, r *http.Request) {
+	// Sem parâmetros, usa as moedas e os ativos padrão do config.yaml.
+	moedas, err := listaIdentificadores(r.URL.Query().Get("moeda"), configuracao().Padroes.Moedas, "moeda")
 	if err != nil {
-		http.Error(w, "Falha ao buscar dados da CoinGecko", http.StatusInternalServerError)
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
+		return
+	}
+	ativos, err := listaIdentificadores(r.URL.Query().Get("ativos"), configuracao().Padroes.Ativos, "ativos")
+	if err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
 		return
 	}
-	defer resp.Body.Close()
 
-	// 2. Decodifica a resposta JSON na nossa struct
-	var bitcoinDado BitCoinDados
-	if err := json.NewDecoder(resp.Body).Decode(&bitcoinDado); err != nil {
-		http.Error(w, "Falha ao decodificar resposta da CoinGecko", http.StatusInternalServerError)
+	corpo, estado, err := obterCotacoes(ativos, moedas)
+	if errors.Is(err, errSemCotacoes) {
+		responderErro(w, http.StatusNotFound, RespostaErro{Erro: err.Error()})
+		return
+	}
+	if err != nil {
+		responderErroUpstream(w, err, "Falha ao buscar as cotações")
 		return
 	}
 
-	// 3. Envia os dados para o frontend
-	json.NewEncoder(w).Encode(bitcoinDado)
+	// Envia os dados para o frontend
+	w.Header().Set("X-Cache", estado)
+	w.Write(corpo)
 }
 
-// Handler para a temperatura
-func climaHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Content-Type", "application/json")
+// obterCotacoes devolve as cotações do cache; os provedores só são chamados quando o valor guardado vence.
+func obterCotacoes(ativos, moedas []string) ([]byte, string, error) {
+	chave := "cotacoes:" + strings.Join(ativos, ",") + ":" + strings.Join(moedas, ",")
+	return cacheAPIs.Obter(chave, configuracao().Cache.Bitcoin, func() ([]byte, error) {
+		return buscarCotacoes(ativos, moedas)
+	})
+}
 
-	// IMPORTANTE: Troque "Joao+Pessoa" pela sua cidade!
-	// O formato é Nome+Da+Cidade, sem acentos.
-	cidade := "Joao+Pessoa"
-	url := fmt.Sprintf("http://wttr.in/%s?format=j1", cidade)
+// errSemCotacoes indica que nenhum provedor conhece os ativos/moedas pedidos.
+var errSemCotacoes = errors.New("nenhuma cotação encontrada para os ativos e moedas informados")
 
-	// 1. Chama a API externa do wttr.in
-	resp, err := http.Get(url)
+// buscarCotacoes busca as cotações nos provedores de mercado e devolve o nosso formato JSON.
+func buscarCotacoes(ativos, moedas []string) ([]byte, error) {
+	// 1. Tenta cada provedor, na ordem do config.yaml, até um deles responder.
+	// O contexto não vem da requisição porque a mesma busca atende vários navegadores (cache).
+	cotacoes, provedor, err := buscarCotacoesComFallback(context.Background(), ativos, moedas)
 	if err != nil {
-		log.Printf("ERRO: Falha ao fazer a requisição para wttr.in: %v", err)
-		http.Error(w, "Falha ao buscar dados de temperatura", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("ERRO: Falha ao ler o corpo da resposta de wttr.in: %v", err)
-		http.Error(w, "Falha ao ler a resposta da API de clima", http.StatusInternalServerError)
-		return
+	motorAlertas.AvaliarCotacoes(cotacoes)
+
+	// 2. Codifica no formato que o frontend espera, qualquer que seja o provedor.
+	resposta := RespostaCotacoes{Cotacoes: cotacoes, Provedor: provedor, AtualizadoEm: time.Now().Format(time.RFC3339)}
+	for _, cotacao := range cotacoes {
+		if cotacao.Ativo == "bitcoin" {
+			if resposta.Bitcoin == nil {
+				resposta.Bitcoin = map[string]float64{}
+			}
+			resposta.Bitcoin[cotacao.Moeda] = cotacao.Preco
+		}
 	}
-	//log.Printf("Resposta recebida de wttr.in: %s", string(bodyBytes))
+	return json.Marshal(resposta)
+}
 
-	// 2. Decodifica a resposta JSON
-	var climadado ClimaDados
-	if err := json.Unmarshal(bodyBytes, &climadado); err != nil {
-		log.Printf("ERRO: Falha ao decodificar o JSON de wttr.in: %v", err)
-		http.Error(w, "Falha ao decodificar resposta de temperatura", http.StatusInternalServerError)
+// Handler para a temperatura
+func climaHandler(w http.ResponseWriter, r *http.Request) {
+	// A cidade vem de ?cidade= (ex: /api/clima?cidade=São Paulo) ou do config.yaml.
+	// A cidade é normalizada no formato Nome+Da+Cidade, sem acentos, usado na chave do cache.
+	nomeCidade := r.URL.Query().Get("cidade")
+	if nomeCidade == "" {
+		nomeCidade = configuracao().Padroes.Cidade
+	}
+	cidade, err := normalizarCidade(nomeCidade)
+	if err != nil {
+		responderErro(w, http.StatusBadRequest, RespostaErro{Erro: err.Error()})
 		return
 	}
 
-	if len(climadado.CondicoesTempo) == 0 {
-		log.Println("AVISO: O JSON foi decodificado, mas o array 'current_condition' está vazio.")
-		http.Error(w, "Dados de condição atual não encontrados na resposta da API", http.StatusInternalServerError)
+	corpo, estado, err := obterClima(cidade)
+	if err != nil {
+		responderErroUpstream(w, err, "Falha ao buscar dados de temperatura")
 		return
 	}
 
-	// 3. Envia os dados para o frontend
-	json.NewEncoder(w).Encode(climadado)
+	// Envia os dados para o frontend
+	w.Header().Set("X-Cache", estado)
+	w.Write(corpo)
 }
 
-// Nova função que usa o Viper para carregar o arquivo de configuração que já existe.
-func carregarConfiguracao() (config Configuracao, err error) {
-	// Define o nome do arquivo que o Viper deve procurar.
-	viper.SetConfigFile(nomeArquivoConfig)
-	// Permite que variáveis de ambiente sobreescrevam as do arquivo.
-	viper.AutomaticEnv()
+// obterClima devolve o clima da cidade (já normalizada) do cache; os provedores só são
+// chamados quando o valor guardado vence.
+func obterClima(cidade string) ([]byte, string, error) {
+	return cacheAPIs.Obter("clima:"+strings.ToLower(cidade), configuracao().Cache.Clima, func() ([]byte, error) {
+		return buscarClima(cidade)
+	})
+}
 
-	// Tenta ler o arquivo de configuração.
-	if err = viper.ReadInConfig(); err != nil {
-		// Retorna um erro se não conseguir ler o arquivo.
-		return
+// buscarClima busca o clima da cidade nos provedores de clima e devolve o nosso formato JSON.
+func buscarClima(cidade string) ([]byte, error) {
+	// 1. Tenta cada provedor, na ordem do config.yaml, até um deles responder.
+	clima, provedor, err := buscarClimaComFallback(context.Background(), cidade)
+	if err != nil {
+		return nil, err
 	}
-
-	// Transfere os dados lidos do arquivo para a nossa struct 'Configuracao'.
-	err = viper.Unmarshal(&config)
-	return
+	motorAlertas.AvaliarClima(cidade, clima)
+
+	// 2. Completa os campos comuns a todos os provedores.
+	clima.Cidade = nomeDaCidade(cidade)
+	clima.Provedor = provedor
+	clima.AtualizadoEm = time.Now().Format(time.RFC3339)
+	clima.CondicaoAtual = []CondicaoAntiga{{
+		TempC:     strconv.FormatFloat(clima.TemperaturaC, 'f', -1, 64),
+		Descricao: []DescricaoAntiga{{Valor: clima.Descricao}},
+	}}
+	return json.Marshal(clima)
 }
 
 // Função para o prompt interativo que cria o arquivo de configuração na primeira execução.
-func perguntarECriarConfiguracao() (Configuracao, error) {
+// Só é usada quando há um terminal; no Docker ou no systemd, use --host/--porta ou o ambiente.
+func perguntarECriarConfiguracao(arquivo string) error {
 	// Declara uma struct anônima para receber as respostas das perguntas.
 	// As tags `survey:"porta"` correspondem ao nome que daremos a cada pergunta.
 	respostas := struct {
@@ -205,7 +491,8 @@ func perguntarECriarConfiguracao() (Configuracao, error) {
 				Message: "Qual porta o servidor deve usar?",
 				Default: "8080", // Podemos sugerir um valor padrão.
 			},
-			Validate: survey.Required, // Torna a resposta obrigatória.
+			// Torna a resposta obrigatória e confere se é uma porta válida.
+			Validate: func(resposta any) error { return validarPorta(resposta.(string)) },
 		},
 		{
 			Name: "host",
@@ -219,35 +506,27 @@ func perguntarECriarConfiguracao() (Configuracao, error) {
 
 	// Imprime o cabeçalho da configuração.
 	fmt.Println("--- Configuração Inicial ---")
-	fmt.Printf("Arquivo '%s' não encontrado. Vamos criá-lo.\n", nomeArquivoConfig)
+	fmt.Printf("Arquivo '%s' não encontrado. Vamos criá-lo.\n", arquivo)
 
 	// Faz a "mágica": exibe as perguntas e preenche a struct 'respostas' com o que o usuário digitar.
 	err := survey.Ask(perguntas, &respostas)
 	if err != nil {
 		// Se o usuário cancelar (Ctrl+C), o erro é tratado aqui.
-		return Configuracao{}, err
+		return err
 	}
 
-	// Cria a nossa struct de configuração final a partir das respostas.
-	var configPreenchida Configuracao
-	configPreenchida.Servidor.Porta = respostas.Porta
+	// Monta os argumentos a partir das respostas.
+	escolhas := Argumentos{Arquivo: arquivo, Porta: respostas.Porta}
 	// O 'survey.Select' retorna o texto completo, então pegamos só o que importa (antes do espaço).
-	fmt.Sscanf(respostas.Host, "%s", &configPreenchida.Servidor.Host)
+	fmt.Sscanf(respostas.Host, "%s", &escolhas.Host)
 
-	// Converte a struct para o formato de texto YAML.
-	dadosYaml, err := yaml.Marshal(&configPreenchida)
-	if err != nil {
-		return Configuracao{}, fmt.Errorf("erro ao gerar YAML: %w", err)
+	// Escreve o arquivo completo e comentado, com as respostas e os valores padrão.
+	if err := gerarConfiguracao(escolhas); err != nil {
+		return err
 	}
 
-	// Escreve os dados YAML no arquivo 'config.yaml'.
-	err = os.WriteFile(nomeArquivoConfig, dadosYaml, 0644)
-	if err != nil {
-		return Configuracao{}, fmt.Errorf("erro ao escrever arquivo de configuração: %w", err)
-	}
-
-	fmt.Printf("\n✅ Arquivo '%s' criado com sucesso!\n", nomeArquivoConfig)
-	return configPreenchida, nil
+	fmt.Printf("\n✅ Arquivo '%s' criado com sucesso!\n", arquivo)
+	return nil
 }
 
 // Nova função auxiliar que verifica se um arquivo existe no disco.
@@ -263,26 +542,68 @@ func arquivoExiste(nomeArquivo string) bool {
 }
 
 func main() {
-
-	// Declara as variáveis que usaremos para a configuração e para erros.
-	var config Configuracao
-	var err error
+	// A partir daqui, todos os logs saem em JSON (log/slog).
+	logger := configurarLogs()
+
+	// Lê os argumentos (ex: --porta 9090). Com --gerar-config, só grava o arquivo e encerra;
+	// com --hash-senha, só imprime o hash de uma senha de administrador.
+	argumentos := lerArgumentos(os.Args[1:])
+	if argumentos.HashSenha {
+		if err := gerarHashSenha(); err != nil {
+			log.Fatalf("Erro ao gerar o hash da senha: %v", err)
+		}
+		return
+	}
+	if argumentos.GerarConfig {
+		if err := gerarConfiguracao(argumentos); err != nil {
+			log.Fatalf("Erro ao gerar a configuração: %v", err)
+		}
+		fmt.Printf("✅ Arquivo '%s' criado com sucesso!\n", argumentos.Arquivo)
+		return
+	}
 
 	// LÓGICA PRINCIPAL DA INICIALIZAÇÃO:
-	// Verifica se o arquivo de configuração já existe.
-	if arquivoExiste(nomeArquivoConfig) {
-		// Se existir, carrega as configurações dele.
-		log.Printf("Carregando configuração de '%s'...", nomeArquivoConfig)
-		config, err = carregarConfiguracao()
-	} else {
-		// Se não existir, inicia o processo interativo para criá-lo.
-		config, err = perguntarECriarConfiguracao()
+	// Carrega o arquivo de configuração (ou o cria, se houver um terminal para perguntar),
+	// junta o ambiente e os argumentos e valida tudo antes de subir o servidor.
+	config, err := prepararConfiguracao(argumentos)
+	if err != nil {
+		log.Fatalf("Processo de configuração cancelado ou falhou: %v", err)
+	}
+	configuracaoAtual.Store(&config)
+	if err := configurarProvedores(config); err != nil {
+		log.Fatalf("Erro na configuração dos provedores: %v", err)
 	}
 
-	// Se houve qualquer erro no processo de configuração, encerra o programa.
+	// Carrega o catálogo de frases (ou o cria, na primeira execução).
+	armazemFrases, err = carregarFrases(config.Frases.Arquivo)
 	if err != nil {
-		log.Fatalf("Processo de configuração cancelado ou falhou: %v", err)
+		log.Fatalf("Erro ao carregar as frases: %v", err)
+	}
+
+	// Carrega as regras de alerta e prepara os canais de notificação configurados.
+	motorAlertas, err = carregarAlertas(config.Alertas.Arquivo, montarNotificadores(config.Alertas, clienteHTTP), time.Now)
+	if err != nil {
+		log.Fatalf("Erro ao carregar os alertas: %v", err)
+	}
+	defer motorAlertas.Aguardar()
+
+	// O contexto é cancelado ao receber Ctrl+C (SIGINT) ou SIGTERM (ex: 'docker stop').
+	ctx, pararSinais := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer pararSinais()
+
+	// Abre o histórico de cotações e inicia a coleta periódica.
+	historicoPrecos, err = abrirHistorico(config.Historico, time.Now)
+	if err != nil {
+		log.Fatalf("Erro ao abrir o histórico de cotações: %v", err)
 	}
+	defer historicoPrecos.Fechar()
+	novoColetorHistorico(historicoPrecos).Iniciar(ctx)
+
+	// Inicia o atualizador que alimenta o /api/stream e o /ws e busca as fontes dos alertas.
+	difusorEventos = novoDifusor(config.Stream.MaxClientes, config.Stream.Historico)
+	hubWS = novoHub(config.WebSocket)
+	iniciarAtualizador(ctx, config.Stream.Intervalo,
+		func() { atualizarPainel(difusorEventos) }, hubWS.AtualizarTopicos, motorAlertas.AtualizarFontes)
 
 	// Prepara o sistema de arquivos embutido para ser servido via HTTP.
 	// O 'fs.Sub' cria uma "visão" da pasta 'public' a partir da raiz dos arquivos embutidos.
@@ -290,6 +611,11 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// Lê os arquivos uma única vez, calculando o ETag e a versão comprimida de cada um.
+	estaticos, err := carregarEstaticos(arquivosEstaticosFS)
+	if err != nil {
+		log.Fatalf("Erro ao carregar os arquivos do frontend: %v", err)
+	}
 
 	//log.Println("--- Verificando arquivos embutidos (embed)... ---")
 	//err = fs.WalkDir(arquivosPublicos, ".", func(path string, d fs.DirEntry, err error) error {
@@ -310,21 +636,82 @@ func main() {
 	// Registra nossas rotas de API no roteador.
 	mux.HandleFunc("/api/mensagem", mensagemHandler)
 	mux.HandleFunc("/api/bitcoin", bitcoinHandler)
+	mux.HandleFunc("GET /api/bitcoin/historico", historicoHandler)
 	mux.HandleFunc("/api/clima", climaHandler)
 
-	// Registra o manipulador de arquivos estáticos para a rota raiz "/".
-	// Qualquer rota que não for de API será tratada por ele, servindo o frontend Svelte.
-	mux.Handle("/", http.FileServer(http.FS(arquivosEstaticosFS)))
+	// Catálogo de frases: leitura livre, alteração com o token de frases.token.
+	tokenFrases := exigirToken("frases", func() string { return configuracao().Frases.Token })
+	mux.HandleFunc("GET /api/frases", listarFrasesHandler)
+	mux.HandleFunc("GET /api/frases/{id}", obterFraseHandler)
+	mux.HandleFunc("POST /api/frases", tokenFrases(criarFraseHandler))
+	mux.HandleFunc("PUT /api/frases/{id}", tokenFrases(atualizarFraseHandler))
+	mux.HandleFunc("DELETE /api/frases/{id}", tokenFrases(removerFraseHandler))
+
+	// Regras de alerta: todas as rotas exigem o token de alertas.token.
+	tokenAlertas := exigirToken("alertas", func() string { return configuracao().Alertas.Token })
+	mux.HandleFunc("GET /api/alertas", tokenAlertas(listarAlertasHandler))
+	mux.HandleFunc("GET /api/alertas/{id}", tokenAlertas(obterAlertaHandler))
+	mux.HandleFunc("POST /api/alertas", tokenAlertas(criarAlertaHandler))
+	mux.HandleFunc("PUT /api/alertas/{id}", tokenAlertas(atualizarAlertaHandler))
+	mux.HandleFunc("DELETE /api/alertas/{id}", tokenAlertas(removerAlertaHandler))
+	mux.HandleFunc("POST /api/alertas/{id}/testar", tokenAlertas(testarAlertaHandler))
+
+	// Área administrativa: login com usuário e senha, sessão em cookie e token CSRF.
+	registrarRotasAdmin(mux)
+
+	mux.HandleFunc("/api/stream", streamHandler)
+	mux.HandleFunc("/ws", wsHandler)
 
-	// Monta o endereço completo do servidor (host:porta) a partir da configuração carregada.
-	enderecoServidor := fmt.Sprintf("%s:%s", config.Servidor.Host, config.Servidor.Porta)
+	// Rotas de saúde, usadas pelo orquestrador de containers.
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
 
-	// Para criar links clicáveis, se o host for 0.0.0.0, usamos 'localhost'.
+	// Métricas no formato do Prometheus.
+	mux.Handle("/metrics", metricas.Handler())
+
+	// Registra o manipulador de arquivos estáticos para a rota raiz "/".
+	// Qualquer rota que não for de API será tratada por ele, servindo o frontend Svelte
+	// (e o index.html nas rotas do próprio frontend, como /sobre).
+	mux.Handle("/", estaticos)
+
+	// Envolve o roteador com os middlewares comuns a todas as rotas, do mais externo
+	// para o mais interno: ID da requisição, HSTS, log de acesso, métricas, recuperação de panic e CORS.
+	handler := encadear(mux,
+		comIDRequisicao,
+		comHSTS(config.TLS.HSTS),
+		comLogDeAcesso(logger),
+		comMetricas(metricas),
+		comRecuperacao(logger),
+		comCORS(func() ConfiguracaoCORS { return configuracao().CORS }),
+		comJSONNaAPI,
+	)
+
+	// Cria o servidor com os tempos máximos do config.yaml. No encerramento, as conexões
+	// de streaming (SSE e WebSocket) são desconectadas para não segurar o desligamento.
+	servidor := novoServidor(config, handler)
+	servidor.RegisterOnShutdown(difusorEventos.Encerrar)
+	servidor.RegisterOnShutdown(hubWS.Encerrar)
+	servidores := []*http.Server{servidor}
+
+	// Com o TLS ativo, atende em HTTPS e, opcionalmente, redireciona a porta HTTP para ele.
+	esquema, esquemaWS := "http", "ws"
+	if config.TLS.Ativo {
+		servidor.TLSConfig, err = configurarTLS(config.TLS)
+		if err != nil {
+			log.Fatalf("Erro na configuração do HTTPS: %v", err)
+		}
+		esquema, esquemaWS = "https", "wss"
+		if config.TLS.PortaRedirecionamento != "" {
+			servidores = append(servidores, novoRedirecionador(config))
+		}
+	}
+
+	// Para criar links clicáveis, se o host for 0.0.0.0 (ou :: no IPv6), usamos 'localhost'.
 	hostParaLink := config.Servidor.Host
-	if hostParaLink == "0.0.0.0" {
+	if hostParaLink == "0.0.0.0" || hostParaLink == "::" {
 		hostParaLink = "localhost"
 	}
-	baseUrl := fmt.Sprintf("http://%s:%s", hostParaLink, config.Servidor.Porta)
+	baseUrl := fmt.Sprintf("%s://%s", esquema, net.JoinHostPort(hostParaLink, config.Servidor.Porta))
 
 	// Exibe mensagens informativas no console sobre o estado do servidor.
 	fmt.Printf("\n🚀 Servidor Go rodando em %s\n", baseUrl)
@@ -334,9 +721,25 @@ func main() {
 	fmt.Println("Endpoints de API disponíveis:")
 	fmt.Printf("  - %s/api/mensagem\n", baseUrl)
 	fmt.Printf("  - %s/api/bitcoin\n", baseUrl)
+	fmt.Printf("  - %s/api/bitcoin/historico\n", baseUrl)
 	fmt.Printf("  - %s/api/clima\n", baseUrl)
+	fmt.Printf("  - %s/api/frases\n", baseUrl)
+	fmt.Printf("  - %s/api/alertas\n", baseUrl)
+	fmt.Printf("  - %s/api/stream (Server-Sent Events)\n", baseUrl)
+	fmt.Printf("  - %s://%s/ws (WebSocket)\n", esquemaWS, net.JoinHostPort(hostParaLink, config.Servidor.Porta))
+
+	fmt.Printf("  - %s/healthz e %s/readyz\n", baseUrl, baseUrl)
+	fmt.Printf("  - %s/metrics (Prometheus)\n", baseUrl)
+	if len(servidores) > 1 {
+		fmt.Printf("  - http://%s redireciona para o HTTPS\n", net.JoinHostPort(hostParaLink, config.TLS.PortaRedirecionamento))
+	}
+
+	// A partir daqui, alterações no arquivo de configuração são aplicadas sem reiniciar.
+	observarConfiguracao(argumentos)
 
-	// Inicia o servidor HTTP e o faz "ouvir" no endereço configurado.
-	// O 'log.Fatal' fará com que o programa encerre se houver um erro ao iniciar o servidor.
-	log.Fatal(http.ListenAndServe(enderecoServidor, mux))
+	// Inicia os servidores e os faz "ouvir" nos endereços configurados, até receber o sinal de encerramento.
+	// O 'log.Fatal' fará com que o programa encerre se houver um erro ao iniciar ou encerrar o servidor.
+	if err := executarServidores(ctx, config.Servidor.PrazoEncerramento, servidores...); err != nil {
+		log.Fatal(err)
+	}
 }
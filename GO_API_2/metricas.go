@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// versao é a versão do binário, definida na compilação:
+// go build -ldflags "-X main.versao=1.2.3"
+var versao = "dev"
+
+// Metricas reúne os indicadores expostos em /metrics. Os handlers, o cache e o cliente
+// das APIs externas registram neles o que acontece.
+//
+// Taxa de acerto do cache (PromQL):
+//
+//	sum(rate(painel_cache_consultas_total{resultado="HIT"}[5m])) / sum(rate(painel_cache_consultas_total[5m]))
+type Metricas struct {
+	registro           *prometheus.Registry
+	requisicoes        *prometheus.CounterVec
+	duracaoRequisicoes *prometheus.HistogramVec
+	duracaoUpstreams   *prometheus.HistogramVec
+	errosUpstreams     *prometheus.CounterVec
+	consultasCache     *prometheus.CounterVec
+}
+
+// metricas é o registro usado por todo o servidor.
+var metricas = novasMetricas()
+
+// novasMetricas cria os indicadores em um registro próprio, com as métricas do runtime do Go,
+// do processo e da versão do binário.
+func novasMetricas() *Metricas {
+	m := &Metricas{
+		registro: prometheus.NewRegistry(),
+		requisicoes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "painel_http_requisicoes_total",
+			Help: "Requisições atendidas, por rota, método e status.",
+		}, []string{"rota", "metodo", "status"}),
+		duracaoRequisicoes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "painel_http_duracao_segundos",
+			Help:    "Tempo de resposta das requisições, por rota, método e status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"rota", "metodo", "status"}),
+		duracaoUpstreams: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "painel_upstream_duracao_segundos",
+			Help:    "Tempo de cada chamada às APIs externas, por provedor e resultado (ok ou erro).",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"provedor", "resultado"}),
+		errosUpstreams: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "painel_upstream_erros_total",
+			Help: "Falhas nas chamadas às APIs externas, por provedor e tipo.",
+		}, []string{"provedor", "tipo"}),
+		consultasCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "painel_cache_consultas_total",
+			Help: "Consultas ao cache das APIs externas, por cache e resultado (HIT, MISS ou STALE).",
+		}, []string{"cache", "resultado"}),
+	}
+
+	infoBuild := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "painel_build_info",
+		Help:        "Versão do binário em execução (valor sempre 1).",
+		ConstLabels: prometheus.Labels{"versao": versao, "revisao": revisaoGit(), "go": runtime.Version()},
+	})
+	infoBuild.Set(1)
+
+	m.registro.MustRegister(
+		m.requisicoes, m.duracaoRequisicoes, m.duracaoUpstreams, m.errosUpstreams, m.consultasCache, infoBuild,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// revisaoGit devolve o commit usado na compilação, quando o Go o registrou no binário.
+func revisaoGit() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, ajuste := range info.Settings {
+			if ajuste.Key == "vcs.revision" {
+				return ajuste.Value
+			}
+		}
+	}
+	return "desconhecida"
+}
+
+// Handler devolve o handler do /metrics.
+func (m *Metricas) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registro, promhttp.HandlerOpts{})
+}
+
+// RegistrarRequisicao conta uma requisição atendida e o seu tempo de resposta.
+func (m *Metricas) RegistrarRequisicao(rota, metodo string, status int, duracao time.Duration) {
+	codigo := strconv.Itoa(status)
+	m.requisicoes.WithLabelValues(rota, metodo, codigo).Inc()
+	m.duracaoRequisicoes.WithLabelValues(rota, metodo, codigo).Observe(duracao.Seconds())
+}
+
+// RegistrarUpstream registra uma chamada a uma API externa e, se ela falhou, o tipo da falha.
+func (m *Metricas) RegistrarUpstream(provedor string, duracao time.Duration, err error) {
+	resultado := "ok"
+	if err != nil {
+		resultado = "erro"
+		m.RegistrarErroUpstream(provedor, tipoErroUpstream(err))
+	}
+	m.duracaoUpstreams.WithLabelValues(provedor, resultado).Observe(duracao.Seconds())
+}
+
+// RegistrarErroUpstream conta uma falha que não chegou a virar chamada (ex: circuito aberto)
+// ou que aconteceu depois dela (ex: JSON inválido).
+func (m *Metricas) RegistrarErroUpstream(provedor, tipo string) {
+	m.errosUpstreams.WithLabelValues(provedor, tipo).Inc()
+}
+
+// RegistrarCache conta uma consulta ao cache. O nome do cache é o prefixo da chave
+// ("clima:joao+pessoa" -> "clima"), para não criar uma série por cidade.
+func (m *Metricas) RegistrarCache(chave, resultado string) {
+	nome, _, _ := strings.Cut(chave, ":")
+	m.consultasCache.WithLabelValues(nome, resultado).Inc()
+}
+
+// tipoErroUpstream classifica a falha de uma chamada para o rótulo 'tipo'.
+func tipoErroUpstream(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || ehTimeout(err):
+		return "timeout"
+	case errors.Is(err, errCircuitoAberto):
+		return "circuito_aberto"
+	}
+	var status erroStatus
+	if errors.As(err, &status) {
+		return "status_" + strconv.Itoa(status.codigo)
+	}
+	return "rede"
+}
+
+// comMetricas registra cada requisição nas métricas, pela rota do ServeMux (ex: "/api/clima"),
+// e não pelo caminho, para que cada arquivo estático ou cidade não vire uma série nova.
+func comMetricas(m *Metricas) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inicio := time.Now()
+			resposta := &respostaRegistrada{ResponseWriter: w}
+			next.ServeHTTP(resposta, r)
+
+			// O ServeMux preenche r.Pattern ao escolher o handler.
+			rota := r.Pattern
+			if rota == "" {
+				rota = "desconhecida"
+			}
+			if resposta.status == 0 {
+				resposta.status = http.StatusOK
+			}
+			m.RegistrarRequisicao(rota, r.Method, resposta.status, time.Since(inicio))
+		})
+	}
+}
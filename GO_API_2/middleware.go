@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfiguracaoCORS define quais páginas (origens) podem chamar a API pelo navegador.
+type ConfiguracaoCORS struct {
+	Origens    []string      `mapstructure:"origens" yaml:"origens"`       // Ex: http://localhost:5173. "*" libera qualquer origem.
+	Metodos    []string      `mapstructure:"metodos" yaml:"metodos"`       // Métodos HTTP aceitos nas chamadas de outras origens.
+	Cabecalhos []string      `mapstructure:"cabecalhos" yaml:"cabecalhos"` // Cabeçalhos que o navegador pode enviar.
+	MaxAge     time.Duration `mapstructure:"max_age" yaml:"max_age"`       // Por quanto tempo o navegador guarda a resposta do preflight.
+}
+
+// Middleware envolve um handler com um comportamento comum a todas as rotas.
+type Middleware func(http.Handler) http.Handler
+
+// encadear aplica os middlewares na ordem informada: o primeiro é o mais externo.
+func encadear(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// configurarLogs troca a saída do pacote 'log' por JSON estruturado (log/slog), para
+// que os logs de acesso e os avisos dos handlers saiam no mesmo formato.
+func configurarLogs() *slog.Logger {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ============== RESPOSTA REGISTRADA ==============
+
+// respostaRegistrada guarda o status e o tamanho da resposta, para o log de acesso.
+type respostaRegistrada struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *respostaRegistrada) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *respostaRegistrada) Write(dados []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(dados)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap permite que o http.ResponseController alcance a resposta original (SSE).
+func (r *respostaRegistrada) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush repassa o envio imediato dos dados (SSE).
+func (r *respostaRegistrada) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack entrega a conexão ao WebSocket, que passa a responder por ela.
+func (r *respostaRegistrada) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
+// ============== ID DA REQUISIÇÃO ==============
+
+// chaveContexto evita colisões com valores de outros pacotes no contexto da requisição.
+type chaveContexto string
+
+const chaveIDRequisicao chaveContexto = "id_requisicao"
+
+// Cabeçalho usado para receber e devolver o ID da requisição.
+const cabecalhoIDRequisicao = "X-Request-ID"
+
+// idRequisicaoValido aceita IDs vindos de proxies (ex: UUIDs), sem caracteres que sujem os logs.
+var idRequisicaoValido = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// idRequisicao devolve o ID da requisição guardado no contexto.
+func idRequisicao(ctx context.Context) string {
+	id, _ := ctx.Value(chaveIDRequisicao).(string)
+	return id
+}
+
+// novoIDRequisicao gera um ID aleatório de 16 caracteres hexadecimais.
+func novoIDRequisicao() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// comIDRequisicao reaproveita o X-Request-ID recebido (ex: de um proxy) ou gera um novo,
+// guarda-o no contexto e o devolve na resposta.
+func comIDRequisicao(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(cabecalhoIDRequisicao)
+		if !idRequisicaoValido.MatchString(id) {
+			id = novoIDRequisicao()
+		}
+		w.Header().Set(cabecalhoIDRequisicao, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chaveIDRequisicao, id)))
+	})
+}
+
+// ============== LOG DE ACESSO ==============
+
+// comLogDeAcesso registra uma linha JSON por requisição, com status, tamanho e duração.
+func comLogDeAcesso(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inicio := time.Now()
+			resposta := &respostaRegistrada{ResponseWriter: w}
+			next.ServeHTTP(resposta, r)
+
+			if resposta.status == 0 {
+				resposta.status = http.StatusOK
+			}
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "requisicao",
+				slog.String("id_requisicao", idRequisicao(r.Context())),
+				slog.String("metodo", r.Method),
+				slog.String("caminho", r.URL.Path),
+				slog.String("consulta", r.URL.RawQuery),
+				slog.Int("status", resposta.status),
+				slog.Int("bytes", resposta.bytes),
+				slog.Float64("duracao_ms", float64(time.Since(inicio).Microseconds())/1000),
+				slog.String("ip", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
+// ============== RECUPERAÇÃO DE PANIC ==============
+
+// comRecuperacao transforma um panic em um erro 500 em JSON, em vez de derrubar a conexão.
+func comRecuperacao(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				erro := recover()
+				if erro == nil {
+					return
+				}
+				if erro == http.ErrAbortHandler {
+					// Usado de propósito para abortar a resposta; o net/http trata.
+					panic(erro)
+				}
+				logger.ErrorContext(r.Context(), "panic no handler",
+					slog.String("id_requisicao", idRequisicao(r.Context())),
+					slog.Any("erro", erro),
+					slog.String("pilha", string(debug.Stack())),
+				)
+				// Só dá para responder se o handler ainda não tiver começado a resposta.
+				if resposta, ok := w.(*respostaRegistrada); !ok || resposta.status == 0 {
+					responderErro(w, http.StatusInternalServerError, RespostaErro{Erro: "erro interno do servidor"})
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ============== CORS ==============
+
+// origemPermitida informa se a origem pode chamar a API. Requisições sem Origin (ex: curl)
+// ou da própria página servida por este servidor sempre são aceitas.
+func origemPermitida(config ConfiguracaoCORS, r *http.Request) bool {
+	origem := r.Header.Get("Origin")
+	if origem == "" || origem == "http://"+r.Host || origem == "https://"+r.Host {
+		return true
+	}
+	return slices.Contains(config.Origens, "*") || slices.Contains(config.Origens, origem)
+}
+
+// comCORS aplica a política de CORS do config.yaml e responde às requisições de preflight.
+// A política é consultada a cada requisição, para valer logo após a recarga do arquivo.
+func comCORS(politica func() ConfiguracaoCORS) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			config := politica()
+			origem := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if origem != "" {
+				if !origemPermitida(config, r) {
+					if preflight {
+						responderErro(w, http.StatusForbidden, RespostaErro{Erro: "origem não permitida: " + origem})
+						return
+					}
+					// Sem os cabeçalhos de CORS, o navegador bloqueia a leitura da resposta.
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set("Access-Control-Allow-Origin", origem)
+				w.Header().Set("Access-Control-Expose-Headers", cabecalhoIDRequisicao+", X-Cache")
+			}
+
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(config.Metodos, ", "))
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(config.Cabecalhos, ", "))
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ============== CONTENT-TYPE DA API ==============
+
+// comJSONNaAPI define o Content-Type das rotas /api/* como JSON. Handlers que respondem
+// em outro formato (ex: /api/stream) sobrescrevem o cabeçalho.
+func comJSONNaAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// linhasDoLog decodifica as linhas JSON gravadas pelo slog.
+func linhasDoLog(t *testing.T, saida *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var linhas []map[string]any
+	for _, linha := range strings.Split(strings.TrimSpace(saida.String()), "\n") {
+		var campos map[string]any
+		if err := json.Unmarshal([]byte(linha), &campos); err != nil {
+			t.Fatalf("linha de log inválida %q: %v", linha, err)
+		}
+		linhas = append(linhas, campos)
+	}
+	return linhas
+}
+
+func TestCORS(t *testing.T) {
+	politica := ConfiguracaoCORS{
+		Origens:    []string{"http://localhost:5173"},
+		Metodos:    []string{"GET", "POST"},
+		Cabecalhos: []string{"Content-Type"},
+		MaxAge:     10 * time.Minute,
+	}
+	casos := []struct {
+		nome      string
+		origens   []string
+		metodo    string
+		origem    string
+		status    int
+		permitida string // Access-Control-Allow-Origin esperado
+	}{
+		{"sem Origin (curl)", nil, http.MethodGet, "", http.StatusOK, ""},
+		{"a própria página", nil, http.MethodGet, "http://api.exemplo.com", http.StatusOK, "http://api.exemplo.com"},
+		{"a própria página em HTTPS", nil, http.MethodGet, "https://api.exemplo.com", http.StatusOK, "https://api.exemplo.com"},
+		{"origem da lista", nil, http.MethodGet, "http://localhost:5173", http.StatusOK, "http://localhost:5173"},
+		// Fora da lista, a requisição simples passa, mas sem CORS o navegador não lê a resposta.
+		{"origem fora da lista", nil, http.MethodGet, "http://malicioso.com", http.StatusOK, ""},
+		{"qualquer origem com *", []string{"*"}, http.MethodGet, "http://malicioso.com", http.StatusOK, "http://malicioso.com"},
+		{"preflight permitido", nil, http.MethodOptions, "http://localhost:5173", http.StatusNoContent, "http://localhost:5173"},
+		{"preflight recusado", nil, http.MethodOptions, "http://malicioso.com", http.StatusForbidden, ""},
+	}
+	for _, caso := range casos {
+		config := politica
+		if caso.origens != nil {
+			config.Origens = caso.origens
+		}
+		chamouHandler := false
+		handler := comCORS(func() ConfiguracaoCORS { return config })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			chamouHandler = true
+		}))
+
+		pedido := httptest.NewRequest(caso.metodo, "http://api.exemplo.com/api/mensagem", nil)
+		if caso.origem != "" {
+			pedido.Header.Set("Origin", caso.origem)
+		}
+		if caso.metodo == http.MethodOptions {
+			pedido.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		gravador := httptest.NewRecorder()
+		handler.ServeHTTP(gravador, pedido)
+
+		if gravador.Code != caso.status || gravador.Header().Get("Access-Control-Allow-Origin") != caso.permitida {
+			t.Errorf("%s: status %d e Allow-Origin %q, esperava %d e %q", caso.nome,
+				gravador.Code, gravador.Header().Get("Access-Control-Allow-Origin"), caso.status, caso.permitida)
+		}
+		// O preflight é respondido pelo próprio middleware.
+		if preflight := caso.metodo == http.MethodOptions; chamouHandler == preflight {
+			t.Errorf("%s: handler chamado = %v", caso.nome, chamouHandler)
+		}
+		if gravador.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: Vary = %q, esperava Origin", caso.nome, gravador.Header().Get("Vary"))
+		}
+
+		switch caso.status {
+		case http.StatusNoContent:
+			cabecalhos := gravador.Header()
+			if cabecalhos.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+				cabecalhos.Get("Access-Control-Allow-Headers") != "Content-Type" ||
+				cabecalhos.Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("%s: cabeçalhos do preflight = %v", caso.nome, cabecalhos)
+			}
+		case http.StatusForbidden:
+			var resposta RespostaErro
+			if err := json.Unmarshal(gravador.Body.Bytes(), &resposta); err != nil || resposta.Erro != "origem não permitida: http://malicioso.com" {
+				t.Errorf("%s: corpo %q", caso.nome, gravador.Body.String())
+			}
+		}
+	}
+}
+
+func TestIDRequisicao(t *testing.T) {
+	idGerado := regexp.MustCompile(`^[0-9a-f]{16}$`)
+	casos := []struct {
+		nome, recebido string
+		reaproveita    bool
+	}{
+		{"sem ID", "", false},
+		{"UUID de um proxy", "3f2c9a1e-7b4d-4c2a-9e8f-1a2b3c4d5e6f", true},
+		{"com ponto e sublinhado", "lb_01.req-42", true},
+		{"com espaço", "id com espaço", false},
+		{"quebra de linha forjando o log", "abc\nfalso", false},
+		{"longo demais", strings.Repeat("a", 65), false},
+	}
+	for _, caso := range casos {
+		var noContexto string
+		handler := comIDRequisicao(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			noContexto = idRequisicao(r.Context())
+		}))
+		pedido := httptest.NewRequest(http.MethodGet, "/api/mensagem", nil)
+		if caso.recebido != "" {
+			// Atribuição direta, para que a quebra de linha chegue ao middleware como veio.
+			pedido.Header[http.CanonicalHeaderKey(cabecalhoIDRequisicao)] = []string{caso.recebido}
+		}
+		gravador := httptest.NewRecorder()
+		handler.ServeHTTP(gravador, pedido)
+
+		id := gravador.Header().Get(cabecalhoIDRequisicao)
+		if id != noContexto {
+			t.Errorf("%s: resposta com %q e contexto com %q", caso.nome, id, noContexto)
+		}
+		if caso.reaproveita && id != caso.recebido {
+			t.Errorf("%s: ID %q, esperava o recebido %q", caso.nome, id, caso.recebido)
+		}
+		if !caso.reaproveita && !idGerado.MatchString(id) {
+			t.Errorf("%s: ID %q, esperava um novo com 16 caracteres hexadecimais", caso.nome, id)
+		}
+	}
+}
+
+func TestRecuperacaoELogDeAcesso(t *testing.T) {
+	var saida bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&saida, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/quebra", func(w http.ResponseWriter, r *http.Request) {
+		panic("índice fora do intervalo")
+	})
+	mux.HandleFunc("/api/quebra-no-meio", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"parcial":`))
+		panic("falhou no meio da resposta")
+	})
+	handler := encadear(mux, comIDRequisicao, comLogDeAcesso(logger), comRecuperacao(logger), comJSONNaAPI)
+
+	pedido := httptest.NewRequest(http.MethodGet, "/api/quebra?x=1", nil)
+	pedido.Header.Set(cabecalhoIDRequisicao, "req-1")
+	pedido.Header.Set("User-Agent", "teste/1.0")
+	pedido.RemoteAddr = "192.0.2.7:51000"
+	gravador := httptest.NewRecorder()
+	handler.ServeHTTP(gravador, pedido)
+
+	// O panic vira um 500 em JSON, e a conexão não cai.
+	var resposta RespostaErro
+	if gravador.Code != http.StatusInternalServerError || gravador.Header().Get("Content-Type") != "application/json" ||
+		json.Unmarshal(gravador.Body.Bytes(), &resposta) != nil || resposta.Erro != "erro interno do servidor" {
+		t.Fatalf("status %d, corpo %q", gravador.Code, gravador.Body.String())
+	}
+
+	linhas := linhasDoLog(t, &saida)
+	if len(linhas) != 2 {
+		t.Fatalf("%d linhas de log, esperava o panic e o acesso", len(linhas))
+	}
+	if panico := linhas[0]; panico["msg"] != "panic no handler" || panico["id_requisicao"] != "req-1" ||
+		panico["erro"] != "índice fora do intervalo" || !strings.Contains(panico["pilha"].(string), "TestRecuperacaoELogDeAcesso") {
+		t.Errorf("log do panic = %v", panico)
+	}
+
+	acesso := linhas[1]
+	for campo, esperado := range map[string]any{
+		"msg":           "requisicao",
+		"id_requisicao": "req-1",
+		"metodo":        "GET",
+		"caminho":       "/api/quebra",
+		"consulta":      "x=1",
+		"status":        float64(http.StatusInternalServerError),
+		"bytes":         float64(gravador.Body.Len()),
+		"ip":            "192.0.2.7:51000",
+		"user_agent":    "teste/1.0",
+	} {
+		if acesso[campo] != esperado {
+			t.Errorf("log de acesso: %s = %v, esperava %v", campo, acesso[campo], esperado)
+		}
+	}
+	if duracao, ok := acesso["duracao_ms"].(float64); !ok || duracao < 0 {
+		t.Errorf("log de acesso: duracao_ms = %v", acesso["duracao_ms"])
+	}
+
+	// Com a resposta já começada, não dá para trocar o status: o que foi enviado fica.
+	saida.Reset()
+	gravador = httptest.NewRecorder()
+	handler.ServeHTTP(gravador, httptest.NewRequest(http.MethodGet, "/api/quebra-no-meio", nil))
+	if gravador.Code != http.StatusOK || gravador.Body.String() != `{"parcial":` {
+		t.Fatalf("status %d, corpo %q", gravador.Code, gravador.Body.String())
+	}
+	if acesso := linhasDoLog(t, &saida)[1]; acesso["status"] != float64(http.StatusOK) || acesso["bytes"] != float64(11) {
+		t.Errorf("log de acesso = %v", acesso)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Nomes dos notificadores, usados nas regras de alerta.
+const (
+	notificadorWebhook  = "webhook"
+	notificadorSMTP     = "smtp"
+	notificadorTelegram = "telegram"
+)
+
+// ConfiguracaoWebhook define a URL que recebe os alertas em JSON (POST).
+type ConfiguracaoWebhook struct {
+	URL string `mapstructure:"url" yaml:"url"`
+}
+
+// ConfiguracaoSMTP define o servidor e os endereços do e-mail de alerta.
+type ConfiguracaoSMTP struct {
+	Endereco string   `mapstructure:"endereco" yaml:"endereco"` // Ex: smtp.gmail.com:587
+	Usuario  string   `mapstructure:"usuario" yaml:"usuario"`   // Vazio: envia sem autenticação.
+	Senha    string   `mapstructure:"senha" yaml:"senha"`
+	De       string   `mapstructure:"de" yaml:"de"`
+	Para     []string `mapstructure:"para" yaml:"para"`
+}
+
+// ConfiguracaoTelegram define o bot e o chat que recebem os alertas.
+type ConfiguracaoTelegram struct {
+	URLBase string `mapstructure:"url_base" yaml:"url_base"`
+	Token   string `mapstructure:"token" yaml:"token"`
+	ChatID  string `mapstructure:"chat_id" yaml:"chat_id"`
+}
+
+// Notificador entrega um alerta por algum canal (webhook, e-mail, Telegram...).
+type Notificador interface {
+	Nome() string
+	Notificar(ctx context.Context, alerta Alerta) error
+}
+
+// montarNotificadores cria os notificadores que estão configurados no config.yaml.
+func montarNotificadores(config ConfiguracaoAlertas, cliente *http.Client) map[string]Notificador {
+	notificadores := map[string]Notificador{}
+	if config.Webhook.URL != "" {
+		notificadores[notificadorWebhook] = &NotificadorWebhook{url: config.Webhook.URL, cliente: cliente}
+	}
+	if config.SMTP.Endereco != "" && len(config.SMTP.Para) > 0 {
+		notificadores[notificadorSMTP] = &NotificadorSMTP{config: config.SMTP}
+	}
+	if config.Telegram.Token != "" && config.Telegram.ChatID != "" {
+		notificadores[notificadorTelegram] = &NotificadorTelegram{config: config.Telegram, cliente: cliente}
+	}
+	return notificadores
+}
+
+// semURL tira a URL das mensagens de erro do http.Client, para não expor tokens nos logs.
+func semURL(err error) error {
+	var erroURL *url.Error
+	if errors.As(err, &erroURL) {
+		return erroURL.Err
+	}
+	return err
+}
+
+// ============== WEBHOOK ==============
+
+// NotificadorWebhook envia o alerta em JSON para uma URL.
+type NotificadorWebhook struct {
+	url     string
+	cliente *http.Client
+}
+
+func (n *NotificadorWebhook) Nome() string { return notificadorWebhook }
+
+func (n *NotificadorWebhook) Notificar(ctx context.Context, alerta Alerta) error {
+	corpo, err := json.Marshal(alerta)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(corpo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.cliente.Do(req)
+	if err != nil {
+		return semURL(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook respondeu com status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ============== E-MAIL (SMTP) ==============
+
+// NotificadorSMTP envia o alerta por e-mail.
+type NotificadorSMTP struct {
+	config ConfiguracaoSMTP
+}
+
+func (n *NotificadorSMTP) Nome() string { return notificadorSMTP }
+
+// Notificar faz o mesmo que o smtp.SendMail (STARTTLS quando o servidor oferece e
+// autenticação quando há usuário), mas respeitando o prazo e o cancelamento do contexto,
+// que o net/smtp não recebe.
+func (n *NotificadorSMTP) Notificar(ctx context.Context, alerta Alerta) error {
+	var discador net.Dialer
+	conexao, err := discador.DialContext(ctx, "tcp", n.config.Endereco)
+	if err != nil {
+		return err
+	}
+	defer conexao.Close()
+	if prazo, ok := ctx.Deadline(); ok {
+		conexao.SetDeadline(prazo)
+	}
+	pararDeVigiar := context.AfterFunc(ctx, func() { conexao.SetDeadline(time.Now()) })
+	defer pararDeVigiar()
+
+	host, _, err := net.SplitHostPort(n.config.Endereco)
+	if err != nil {
+		return err
+	}
+	cliente, err := smtp.NewClient(conexao, host)
+	if err != nil {
+		return err
+	}
+	defer cliente.Close()
+	if ok, _ := cliente.Extension("STARTTLS"); ok {
+		if err := cliente.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.config.Usuario != "" {
+		if err := cliente.Auth(smtp.PlainAuth("", n.config.Usuario, n.config.Senha, host)); err != nil {
+			return err
+		}
+	}
+	if err := cliente.Mail(n.config.De); err != nil {
+		return err
+	}
+	for _, para := range n.config.Para {
+		if err := cliente.Rcpt(para); err != nil {
+			return err
+		}
+	}
+	escritor, err := cliente.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := escritor.Write(montarEmail(n.config, alerta)); err != nil {
+		return err
+	}
+	if err := escritor.Close(); err != nil {
+		return err
+	}
+	return cliente.Quit()
+}
+
+// montarEmail monta a mensagem no formato RFC 5322, com assunto e corpo em UTF-8.
+func montarEmail(config ConfiguracaoSMTP, alerta Alerta) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", config.De)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(config.Para, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[Alerta] "+alerta.Nome))
+	fmt.Fprintf(&b, "Date: %s\r\n", alerta.Momento.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(alerta.Mensagem, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// ============== TELEGRAM ==============
+
+// NotificadorTelegram envia o alerta como mensagem de um bot do Telegram (ou de uma API compatível).
+type NotificadorTelegram struct {
+	config  ConfiguracaoTelegram
+	cliente *http.Client
+}
+
+func (n *NotificadorTelegram) Nome() string { return notificadorTelegram }
+
+func (n *NotificadorTelegram) Notificar(ctx context.Context, alerta Alerta) error {
+	corpo, err := json.Marshal(map[string]string{"chat_id": n.config.ChatID, "text": "[Alerta] " + alerta.Nome + "\n" + alerta.Mensagem})
+	if err != nil {
+		return err
+	}
+	endereco := strings.TrimSuffix(n.config.URLBase, "/") + "/bot" + n.config.Token + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endereco, bytes.NewReader(corpo))
+	if err != nil {
+		return semURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.cliente.Do(req)
+	if err != nil {
+		return semURL(err)
+	}
+	defer resp.Body.Close()
+
+	// A API do Telegram responde {"ok": true} ou {"ok": false, "description": "..."}.
+	var resposta struct {
+		OK        bool   `json:"ok"`
+		Descricao string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&resposta); err != nil {
+		return fmt.Errorf("resposta inválida do Telegram (status %d): %w", resp.StatusCode, err)
+	}
+	if !resposta.OK {
+		return fmt.Errorf("telegram recusou a mensagem: %s", resposta.Descricao)
+	}
+	return nil
+}
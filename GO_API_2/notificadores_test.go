@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// alertaDeTeste é o alerta entregue nos testes dos notificadores.
+var alertaDeTeste = Alerta{
+	RegraID:  7,
+	Nome:     "Bitcoin acima de 350 mil",
+	Fonte:    "bitcoin:brl",
+	Operador: operadorMaior,
+	Limite:   350000,
+	Valor:    351234.5,
+	Mensagem: "bitcoin:brl está em 351234.50 (> 350000)",
+	Momento:  time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+}
+
+func TestNotificadorWebhook(t *testing.T) {
+	var recebido Alerta
+	var tipo string
+	status := http.StatusNoContent
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tipo = r.Header.Get("Content-Type")
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&recebido) != nil {
+			http.Error(w, "pedido inválido", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer servidor.Close()
+	notificador := &NotificadorWebhook{url: servidor.URL, cliente: servidor.Client()}
+
+	if err := notificador.Notificar(context.Background(), alertaDeTeste); err != nil {
+		t.Fatal(err)
+	}
+	if recebido != alertaDeTeste || tipo != "application/json" {
+		t.Fatalf("recebido %+v (%s), esperava %+v", recebido, tipo, alertaDeTeste)
+	}
+
+	status = http.StatusInternalServerError
+	if err := notificador.Notificar(context.Background(), alertaDeTeste); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Fatalf("esperava erro com o status 500, veio %v", err)
+	}
+}
+
+func TestNotificadorTelegram(t *testing.T) {
+	var caminho string
+	var corpo map[string]string
+	resposta := `{"ok": true, "result": {}}`
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caminho = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&corpo)
+		if !strings.Contains(resposta, `"ok": true`) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(resposta))
+	}))
+	defer servidor.Close()
+	config := ConfiguracaoTelegram{URLBase: servidor.URL + "/", Token: "123:segredo", ChatID: "-100200"}
+	notificador := &NotificadorTelegram{config: config, cliente: servidor.Client()}
+
+	if err := notificador.Notificar(context.Background(), alertaDeTeste); err != nil {
+		t.Fatal(err)
+	}
+	if caminho != "/bot123:segredo/sendMessage" {
+		t.Fatalf("caminho = %s", caminho)
+	}
+	if corpo["chat_id"] != "-100200" || corpo["text"] != "[Alerta] "+alertaDeTeste.Nome+"\n"+alertaDeTeste.Mensagem {
+		t.Fatalf("corpo = %v", corpo)
+	}
+
+	resposta = `{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`
+	if err := notificador.Notificar(context.Background(), alertaDeTeste); err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("esperava a recusa do Telegram, veio %v", err)
+	}
+
+	// Os erros de conexão não levam a URL, que contém o token do bot.
+	servidor.Close()
+	if err := notificador.Notificar(context.Background(), alertaDeTeste); err == nil || strings.Contains(err.Error(), "segredo") {
+		t.Fatalf("esperava erro de conexão sem o token, veio %v", err)
+	}
+}
+
+// servidorSMTPFalso aceita uma conversa SMTP por conexão, sem TLS, e guarda os comandos
+// recebidos e o conteúdo do DATA.
+type servidorSMTPFalso struct {
+	escuta   net.Listener
+	mu       sync.Mutex
+	comandos []string
+	mensagem string
+}
+
+func iniciarServidorSMTPFalso(t *testing.T) *servidorSMTPFalso {
+	t.Helper()
+	escuta, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { escuta.Close() })
+	s := &servidorSMTPFalso{escuta: escuta}
+	go func() {
+		for {
+			conexao, err := escuta.Accept()
+			if err != nil {
+				return
+			}
+			go s.atender(conexao)
+		}
+	}()
+	return s
+}
+
+func (s *servidorSMTPFalso) atender(conexao net.Conn) {
+	defer conexao.Close()
+	leitor := bufio.NewReader(conexao)
+	responder := func(linhas ...string) {
+		for _, linha := range linhas {
+			conexao.Write([]byte(linha + "\r\n"))
+		}
+	}
+	responder("220 smtp.teste ESMTP")
+	for {
+		linha, err := leitor.ReadString('\n')
+		if err != nil {
+			return
+		}
+		comando := strings.TrimRight(linha, "\r\n")
+		s.mu.Lock()
+		s.comandos = append(s.comandos, comando)
+		s.mu.Unlock()
+
+		switch verbo, _, _ := strings.Cut(comando, " "); strings.ToUpper(verbo) {
+		case "EHLO":
+			responder("250-smtp.teste", "250 AUTH PLAIN")
+		case "AUTH":
+			responder("235 2.7.0 autenticado")
+		case "MAIL", "RCPT":
+			responder("250 2.1.0 ok")
+		case "DATA":
+			responder("354 pode enviar")
+			var mensagem strings.Builder
+			for {
+				linha, err := leitor.ReadString('\n')
+				if err != nil || linha == ".\r\n" {
+					break
+				}
+				mensagem.WriteString(linha)
+			}
+			s.mu.Lock()
+			s.mensagem = mensagem.String()
+			s.mu.Unlock()
+			responder("250 2.0.0 enfileirada")
+		case "QUIT":
+			responder("221 2.0.0 tchau")
+			return
+		default:
+			responder("502 5.5.2 comando desconhecido")
+		}
+	}
+}
+
+func TestNotificadorSMTP(t *testing.T) {
+	servidor := iniciarServidorSMTPFalso(t)
+	notificador := &NotificadorSMTP{config: ConfiguracaoSMTP{
+		Endereco: servidor.escuta.Addr().String(),
+		Usuario:  "alertas",
+		Senha:    "senha",
+		De:       "alertas@empresa.com",
+		Para:     []string{"ana@empresa.com", "noc@empresa.com"},
+	}}
+
+	if err := notificador.Notificar(context.Background(), alertaDeTeste); err != nil {
+		t.Fatal(err)
+	}
+	servidor.mu.Lock()
+	defer servidor.mu.Unlock()
+	credenciais := base64.StdEncoding.EncodeToString([]byte("\x00alertas\x00senha"))
+	esperados := []string{
+		"EHLO localhost",
+		"AUTH PLAIN " + credenciais,
+		"MAIL FROM:<alertas@empresa.com>",
+		"RCPT TO:<ana@empresa.com>",
+		"RCPT TO:<noc@empresa.com>",
+		"DATA",
+		"QUIT",
+	}
+	if strings.Join(servidor.comandos, "\n") != strings.Join(esperados, "\n") {
+		t.Fatalf("comandos:\n%s\nesperava:\n%s", strings.Join(servidor.comandos, "\n"), strings.Join(esperados, "\n"))
+	}
+	for _, trecho := range []string{
+		"To: ana@empresa.com, noc@empresa.com\r\n",
+		"Subject: [Alerta] Bitcoin acima de 350 mil\r\n",
+		"\r\n\r\n" + alertaDeTeste.Mensagem + "\r\n",
+	} {
+		if !strings.Contains(servidor.mensagem, trecho) {
+			t.Errorf("a mensagem não contém %q:\n%s", trecho, servidor.mensagem)
+		}
+	}
+}
+
+func TestNotificadorSMTPRespeitaPrazo(t *testing.T) {
+	// Um servidor que aceita a conexão e nunca responde.
+	escuta, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer escuta.Close()
+	notificador := &NotificadorSMTP{config: ConfiguracaoSMTP{Endereco: escuta.Addr().String(), De: "a@b.com", Para: []string{"c@d.com"}}}
+
+	ctx, cancelar := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelar()
+	inicio := time.Now()
+	if err := notificador.Notificar(ctx, alertaDeTeste); err == nil {
+		t.Fatal("esperava erro de tempo esgotado")
+	}
+	if decorrido := time.Since(inicio); decorrido > 2*time.Second {
+		t.Fatalf("o envio levou %s, além do prazo do contexto", decorrido)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Limites dos parâmetros aceitos nas rotas, para que uma URL maliciosa não vire
+// uma chamada enorme (ou muitas chamadas diferentes) às APIs externas.
+const (
+	tamanhoMaximoCidade = 80
+	maximoItensLista    = 10
+)
+
+// erroValidacao marca os erros causados por dados inválidos enviados pelo navegador,
+// respondidos com 400.
+type erroValidacao struct{ error }
+
+// cidadeValida aceita letras (já sem acento), espaços, hífens, apóstrofos e pontos.
+var cidadeValida = regexp.MustCompile(`^[A-Za-z][A-Za-z .'\-]*$`)
+
+// identificadorValido é o formato dos ids de ativos e moedas da CoinGecko (ex: "bitcoin", "usd").
+var identificadorValido = regexp.MustCompile(`^[a-z0-9][a-z0-9\-]*$`)
+
+// removerAcentos troca letras acentuadas pelas equivalentes sem acento ("João" -> "Joao").
+func removerAcentos(texto string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(texto) {
+		// Na forma NFD o acento vira um caractere separado (categoria Mn), que descartamos.
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizarCidade valida o nome da cidade e o converte para o formato do wttr.in:
+// sem acentos, com '+' no lugar dos espaços ("São Paulo" -> "Sao+Paulo").
+func normalizarCidade(cidade string) (string, error) {
+	cidade = strings.Join(strings.Fields(removerAcentos(cidade)), " ")
+	if cidade == "" {
+		return "", fmt.Errorf("informe a cidade")
+	}
+	if len(cidade) > tamanhoMaximoCidade {
+		return "", fmt.Errorf("nome de cidade muito longo (máximo de %d caracteres)", tamanhoMaximoCidade)
+	}
+	if !cidadeValida.MatchString(cidade) {
+		return "", fmt.Errorf("nome de cidade inválido: '%s'", cidade)
+	}
+
+	partes := strings.Split(cidade, " ")
+	for i, parte := range partes {
+		partes[i] = url.PathEscape(parte)
+	}
+	return strings.Join(partes, "+"), nil
+}
+
+// listaIdentificadores interpreta um parâmetro separado por vírgulas ("usd,eur"),
+// usando o padrão quando ele não for informado. O resultado vem em minúsculas,
+// sem repetições e ordenado, para que a mesma consulta sempre use a mesma chave de cache.
+func listaIdentificadores(valor string, padrao []string, nome string) ([]string, error) {
+	itens := padrao
+	if strings.TrimSpace(valor) != "" {
+		itens = strings.Split(valor, ",")
+	}
+
+	vistos := map[string]bool{}
+	var lista []string
+	for _, item := range itens {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" || vistos[item] {
+			continue
+		}
+		if !identificadorValido.MatchString(item) {
+			return nil, fmt.Errorf("valor inválido em '%s': '%s'", nome, item)
+		}
+		vistos[item] = true
+		lista = append(lista, item)
+	}
+
+	if len(lista) == 0 {
+		return nil, fmt.Errorf("informe ao menos um valor em '%s'", nome)
+	}
+	if len(lista) > maximoItensLista {
+		return nil, fmt.Errorf("no máximo %d valores em '%s'", maximoItensLista, nome)
+	}
+	sort.Strings(lista)
+	return lista, nil
+}
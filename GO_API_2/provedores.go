@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nomes dos provedores aceitos em 'provedores.clima' e 'provedores.mercado' do config.yaml.
+const (
+	provedorWttr          = "wttr"
+	provedorOpenMeteo     = "openmeteo"
+	provedorCoinGecko     = "coingecko"
+	provedorCryptoCompare = "cryptocompare"
+)
+
+// ConfiguracaoProvedores define quais serviços fornecem o clima e as cotações, na ordem
+// em que são tentados: se o primeiro falhar, o seguinte responde no lugar dele.
+type ConfiguracaoProvedores struct {
+	Clima   []string `mapstructure:"clima" yaml:"clima"`
+	Mercado []string `mapstructure:"mercado" yaml:"mercado"`
+}
+
+// Provedor é um serviço externo que fornece dados ao painel.
+type Provedor interface {
+	Nome() string
+	// Upstreams devolve as APIs chamadas pelo provedor, para o /readyz.
+	Upstreams() []*ClienteUpstream
+}
+
+// ProvedorClima busca o clima atual de uma cidade, já normalizada ("Sao+Paulo").
+type ProvedorClima interface {
+	Provedor
+	BuscarClima(ctx context.Context, cidade string) (Clima, error)
+}
+
+// ProvedorMercado busca o preço de cada ativo em cada moeda, com os ids da CoinGecko
+// ("bitcoin", "brl"). Pares que o provedor não conhece ficam de fora da lista.
+type ProvedorMercado interface {
+	Provedor
+	BuscarCotacoes(ctx context.Context, ativos, moedas []string) ([]Cotacao, error)
+}
+
+// Provedores usados pelos handlers, na ordem de tentativa, criados em 'configurarProvedores'.
+var (
+	provedoresClima   []ProvedorClima
+	provedoresMercado []ProvedorMercado
+)
+
+// configurarProvedores cria os provedores listados no config.yaml, cada um com o seu
+// cliente de API externa (e o seu disjuntor).
+func configurarProvedores(config Configuracao) error {
+	provedoresClima, provedoresMercado = nil, nil
+	for _, nome := range config.Provedores.Clima {
+		switch strings.ToLower(strings.TrimSpace(nome)) {
+		case provedorWttr:
+			provedoresClima = append(provedoresClima, &ProvedorWttr{
+				cliente: novoClienteUpstream(provedorWttr, config.APIs.Wttr, clienteHTTP),
+			})
+		case provedorOpenMeteo:
+			provedoresClima = append(provedoresClima, &ProvedorOpenMeteo{
+				previsao:       novoClienteUpstream(provedorOpenMeteo, config.APIs.OpenMeteo, clienteHTTP),
+				geocodificacao: novoClienteUpstream("openmeteo_geocodificacao", config.APIs.OpenMeteoGeocodificacao, clienteHTTP),
+			})
+		default:
+			return fmt.Errorf("provedor de clima desconhecido em 'provedores.clima': '%s' (use wttr ou openmeteo)", nome)
+		}
+	}
+	for _, nome := range config.Provedores.Mercado {
+		switch strings.ToLower(strings.TrimSpace(nome)) {
+		case provedorCoinGecko:
+			provedoresMercado = append(provedoresMercado, &ProvedorCoinGecko{
+				cliente: novoClienteUpstream(provedorCoinGecko, config.APIs.CoinGecko, clienteHTTP),
+			})
+		case provedorCryptoCompare:
+			provedoresMercado = append(provedoresMercado, &ProvedorCryptoCompare{
+				cliente: novoClienteUpstream(provedorCryptoCompare, config.APIs.CryptoCompare, clienteHTTP),
+			})
+		default:
+			return fmt.Errorf("provedor de mercado desconhecido em 'provedores.mercado': '%s' (use coingecko ou cryptocompare)", nome)
+		}
+	}
+	if len(provedoresClima) == 0 || len(provedoresMercado) == 0 {
+		return fmt.Errorf("informe ao menos um provedor em 'provedores.clima' e em 'provedores.mercado'")
+	}
+	return nil
+}
+
+// disponivel informa se o provedor pode ser chamado: nenhuma das suas APIs está com o circuito aberto.
+func disponivel(provedor Provedor) bool {
+	for _, cliente := range provedor.Upstreams() {
+		if cliente.Estado().Circuito == "aberto" {
+			return false
+		}
+	}
+	return true
+}
+
+// ============== CADEIAS COM FALLBACK ==============
+
+// buscarCotacoesComFallback tenta cada provedor de mercado, na ordem do config.yaml, e
+// devolve as cotações do primeiro que responder, ordenadas por ativo e moeda.
+func buscarCotacoesComFallback(ctx context.Context, ativos, moedas []string) ([]Cotacao, string, error) {
+	var falhas []error
+	for _, provedor := range provedoresMercado {
+		cotacoes, err := provedor.BuscarCotacoes(ctx, ativos, moedas)
+		if err == nil && len(cotacoes) == 0 {
+			err = errSemCotacoes
+		}
+		if err != nil {
+			falhas = append(falhas, err)
+			avisarFallback(provedor.Nome(), len(falhas) < len(provedoresMercado), err)
+			continue
+		}
+		sort.Slice(cotacoes, func(i, j int) bool {
+			if cotacoes[i].Ativo != cotacoes[j].Ativo {
+				return cotacoes[i].Ativo < cotacoes[j].Ativo
+			}
+			return cotacoes[i].Moeda < cotacoes[j].Moeda
+		})
+		return cotacoes, provedor.Nome(), nil
+	}
+	return nil, "", juntarFalhas(falhas)
+}
+
+// buscarClimaComFallback tenta cada provedor de clima, na ordem do config.yaml, e devolve
+// o clima do primeiro que responder.
+func buscarClimaComFallback(ctx context.Context, cidade string) (Clima, string, error) {
+	var falhas []error
+	for _, provedor := range provedoresClima {
+		clima, err := provedor.BuscarClima(ctx, cidade)
+		if err != nil {
+			falhas = append(falhas, err)
+			avisarFallback(provedor.Nome(), len(falhas) < len(provedoresClima), err)
+			continue
+		}
+		return clima, provedor.Nome(), nil
+	}
+	return Clima{}, "", juntarFalhas(falhas)
+}
+
+// avisarFallback registra no log a falha de um provedor que será substituído pelo próximo.
+func avisarFallback(provedor string, temProximo bool, err error) {
+	if temProximo {
+		log.Printf("AVISO: provedor '%s' falhou, tentando o próximo: %v", provedor, err)
+	}
+}
+
+// juntarFalhas resume as falhas de todos os provedores. Se nenhum deles conhecia os
+// ativos e moedas pedidos, o resultado é errSemCotacoes (404); senão, vale a primeira
+// falha de API externa, que define o status (502/504) e o provedor da resposta de erro.
+func juntarFalhas(falhas []error) error {
+	var outras []error
+	for _, err := range falhas {
+		if !errors.Is(err, errSemCotacoes) {
+			outras = append(outras, err)
+		}
+	}
+	if len(outras) == 0 && len(falhas) > 0 {
+		return errSemCotacoes
+	}
+	return errors.Join(outras...)
+}
+
+// ============== WTTR.IN ==============
+
+// ClimaWttr é o formato da resposta do wttr.in (?format=j1). Com '&lang=pt', a descrição
+// em português vem em 'lang_pt'.
+type ClimaWttr struct {
+	CondicoesTempo []struct {
+		TempC    string `json:"temp_C"`
+		DescTemp []struct {
+			Valor string `json:"value"`
+		} `json:"weatherDesc"`
+		DescPortugues []struct {
+			Valor string `json:"value"`
+		} `json:"lang_pt"`
+	} `json:"current_condition"`
+}
+
+// ProvedorWttr busca o clima no wttr.in.
+type ProvedorWttr struct {
+	cliente *ClienteUpstream
+}
+
+func (p *ProvedorWttr) Nome() string                  { return provedorWttr }
+func (p *ProvedorWttr) Upstreams() []*ClienteUpstream { return []*ClienteUpstream{p.cliente} }
+
+func (p *ProvedorWttr) BuscarClima(ctx context.Context, cidade string) (Clima, error) {
+	var resposta ClimaWttr
+	if err := p.cliente.ObterJSON(ctx, fmt.Sprintf("/%s?format=j1&lang=pt", cidade), &resposta); err != nil {
+		return Clima{}, err
+	}
+
+	// Uma resposta sem condição atual não é aceita (nem guardada no cache).
+	if len(resposta.CondicoesTempo) == 0 {
+		return Clima{}, &ErroUpstream{Provedor: provedorWttr, Status: http.StatusBadGateway,
+			Causa: fmt.Errorf("o JSON foi decodificado, mas o array 'current_condition' está vazio")}
+	}
+	condicao := resposta.CondicoesTempo[0]
+	temperatura, err := strconv.ParseFloat(condicao.TempC, 64)
+	if err != nil {
+		return Clima{}, &ErroUpstream{Provedor: provedorWttr, Status: http.StatusBadGateway,
+			Causa: fmt.Errorf("temperatura inválida: '%s'", condicao.TempC)}
+	}
+
+	clima := Clima{TemperaturaC: temperatura}
+	switch {
+	case len(condicao.DescPortugues) > 0:
+		clima.Descricao = strings.TrimSpace(condicao.DescPortugues[0].Valor)
+	case len(condicao.DescTemp) > 0:
+		clima.Descricao = strings.TrimSpace(condicao.DescTemp[0].Valor)
+	}
+	return clima, nil
+}
+
+// ============== OPEN-METEO ==============
+
+// ProvedorOpenMeteo busca o clima na Open-Meteo, que pede latitude e longitude: a cidade
+// é convertida em coordenadas pela API de geocodificação, e o resultado fica no cache.
+type ProvedorOpenMeteo struct {
+	previsao       *ClienteUpstream
+	geocodificacao *ClienteUpstream
+}
+
+// Coordenadas de uma cidade, segundo a geocodificação da Open-Meteo.
+type coordenadas struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// As coordenadas de uma cidade não mudam: ficam um dia no cache (e uma semana como reserva).
+var politicaGeocodificacao = PoliticaCache{TTL: 24 * time.Hour, Obsoleto: 7 * 24 * time.Hour}
+
+// descricoesWMO traduz os códigos de tempo da WMO usados pela Open-Meteo.
+var descricoesWMO = map[int]string{
+	0: "Céu limpo", 1: "Predominantemente limpo", 2: "Parcialmente nublado", 3: "Nublado",
+	45: "Nevoeiro", 48: "Nevoeiro com geada",
+	51: "Garoa fraca", 53: "Garoa moderada", 55: "Garoa intensa",
+	56: "Garoa congelante fraca", 57: "Garoa congelante intensa",
+	61: "Chuva fraca", 63: "Chuva moderada", 65: "Chuva forte",
+	66: "Chuva congelante fraca", 67: "Chuva congelante forte",
+	71: "Neve fraca", 73: "Neve moderada", 75: "Neve forte", 77: "Grãos de neve",
+	80: "Pancadas de chuva fracas", 81: "Pancadas de chuva moderadas", 82: "Pancadas de chuva fortes",
+	85: "Pancadas de neve fracas", 86: "Pancadas de neve fortes",
+	95: "Trovoada", 96: "Trovoada com granizo fraco", 99: "Trovoada com granizo forte",
+}
+
+func (p *ProvedorOpenMeteo) Nome() string { return provedorOpenMeteo }
+func (p *ProvedorOpenMeteo) Upstreams() []*ClienteUpstream {
+	return []*ClienteUpstream{p.previsao, p.geocodificacao}
+}
+
+func (p *ProvedorOpenMeteo) BuscarClima(ctx context.Context, cidade string) (Clima, error) {
+	local, err := p.localizar(ctx, cidade)
+	if err != nil {
+		return Clima{}, err
+	}
+
+	consulta := url.Values{}
+	consulta.Set("latitude", strconv.FormatFloat(local.Latitude, 'f', 4, 64))
+	consulta.Set("longitude", strconv.FormatFloat(local.Longitude, 'f', 4, 64))
+	consulta.Set("current", "temperature_2m,weather_code")
+	var resposta struct {
+		Atual *struct {
+			Temperatura float64 `json:"temperature_2m"`
+			Codigo      int     `json:"weather_code"`
+		} `json:"current"`
+	}
+	if err := p.previsao.ObterJSON(ctx, "/forecast?"+consulta.Encode(), &resposta); err != nil {
+		return Clima{}, err
+	}
+	if resposta.Atual == nil {
+		return Clima{}, &ErroUpstream{Provedor: provedorOpenMeteo, Status: http.StatusBadGateway,
+			Causa: fmt.Errorf("o JSON foi decodificado, mas o objeto 'current' está ausente")}
+	}
+
+	descricao, ok := descricoesWMO[resposta.Atual.Codigo]
+	if !ok {
+		descricao = fmt.Sprintf("Código de tempo %d", resposta.Atual.Codigo)
+	}
+	return Clima{TemperaturaC: resposta.Atual.Temperatura, Descricao: descricao}, nil
+}
+
+// localizar devolve as coordenadas da cidade, do cache ou da API de geocodificação.
+func (p *ProvedorOpenMeteo) localizar(ctx context.Context, cidade string) (coordenadas, error) {
+	corpo, _, err := cacheAPIs.Obter("geocodificacao:"+strings.ToLower(cidade), politicaGeocodificacao, func() ([]byte, error) {
+		consulta := url.Values{}
+		consulta.Set("name", nomeDaCidade(cidade))
+		consulta.Set("count", "1")
+		consulta.Set("language", "pt")
+		var resposta struct {
+			Resultados []coordenadas `json:"results"`
+		}
+		if err := p.geocodificacao.ObterJSON(ctx, "/search?"+consulta.Encode(), &resposta); err != nil {
+			return nil, err
+		}
+		if len(resposta.Resultados) == 0 {
+			return nil, &ErroUpstream{Provedor: provedorOpenMeteo, Status: http.StatusNotFound,
+				Causa: fmt.Errorf("cidade não encontrada: '%s'", nomeDaCidade(cidade))}
+		}
+		return json.Marshal(resposta.Resultados[0])
+	})
+	if err != nil {
+		return coordenadas{}, err
+	}
+	var local coordenadas
+	err = json.Unmarshal(corpo, &local)
+	return local, err
+}
+
+// nomeDaCidade desfaz a normalização do wttr.in ("Sao+Paulo" -> "Sao Paulo").
+func nomeDaCidade(cidade string) string {
+	nome := strings.ReplaceAll(cidade, "+", " ")
+	if semEscape, err := url.PathUnescape(nome); err == nil {
+		return semEscape
+	}
+	return nome
+}
+
+// ============== COINGECKO ==============
+
+// PrecosCoinGecko é o formato da resposta da CoinGecko: ativo -> moeda -> preço.
+// Ex: {"bitcoin": {"brl": 350000.0, "usd": 65000.0}}
+type PrecosCoinGecko map[string]map[string]float64
+
+// ProvedorCoinGecko busca as cotações na CoinGecko, que usa os mesmos ids da nossa API.
+type ProvedorCoinGecko struct {
+	cliente *ClienteUpstream
+}
+
+func (p *ProvedorCoinGecko) Nome() string                  { return provedorCoinGecko }
+func (p *ProvedorCoinGecko) Upstreams() []*ClienteUpstream { return []*ClienteUpstream{p.cliente} }
+
+func (p *ProvedorCoinGecko) BuscarCotacoes(ctx context.Context, ativos, moedas []string) ([]Cotacao, error) {
+	consulta := url.Values{}
+	consulta.Set("ids", strings.Join(ativos, ","))
+	consulta.Set("vs_currencies", strings.Join(moedas, ","))
+	var precos PrecosCoinGecko
+	if err := p.cliente.ObterJSON(ctx, "/simple/price?"+consulta.Encode(), &precos); err != nil {
+		return nil, err
+	}
+
+	var cotacoes []Cotacao
+	for _, ativo := range ativos {
+		for _, moeda := range moedas {
+			if preco, ok := precos[ativo][moeda]; ok {
+				cotacoes = append(cotacoes, Cotacao{Ativo: ativo, Moeda: moeda, Preco: preco})
+			}
+		}
+	}
+	return cotacoes, nil
+}
+
+// ============== CRYPTOCOMPARE ==============
+
+// simbolosCryptoCompare traduz os ids da CoinGecko para os símbolos da CryptoCompare.
+// Ativos fora da lista não são buscados nela.
+var simbolosCryptoCompare = map[string]string{
+	"bitcoin":     "BTC",
+	"ethereum":    "ETH",
+	"tether":      "USDT",
+	"binancecoin": "BNB",
+	"solana":      "SOL",
+	"ripple":      "XRP",
+	"cardano":     "ADA",
+	"dogecoin":    "DOGE",
+	"litecoin":    "LTC",
+	"polkadot":    "DOT",
+}
+
+// ProvedorCryptoCompare busca as cotações na CryptoCompare (min-api).
+type ProvedorCryptoCompare struct {
+	cliente *ClienteUpstream
+}
+
+func (p *ProvedorCryptoCompare) Nome() string                  { return provedorCryptoCompare }
+func (p *ProvedorCryptoCompare) Upstreams() []*ClienteUpstream { return []*ClienteUpstream{p.cliente} }
+
+func (p *ProvedorCryptoCompare) BuscarCotacoes(ctx context.Context, ativos, moedas []string) ([]Cotacao, error) {
+	var simbolos []string
+	for _, ativo := range ativos {
+		if simbolo, ok := simbolosCryptoCompare[ativo]; ok {
+			simbolos = append(simbolos, simbolo)
+		}
+	}
+	if len(simbolos) == 0 {
+		return nil, errSemCotacoes
+	}
+
+	consulta := url.Values{}
+	consulta.Set("fsyms", strings.Join(simbolos, ","))
+	consulta.Set("tsyms", strings.ToUpper(strings.Join(moedas, ",")))
+	// A resposta é símbolo -> MOEDA -> preço, ou {"Response": "Error", "Message": "..."}
+	// com status 200 quando a consulta é recusada.
+	var resposta map[string]json.RawMessage
+	if err := p.cliente.ObterJSON(ctx, "/data/pricemulti?"+consulta.Encode(), &resposta); err != nil {
+		return nil, err
+	}
+	if _, recusada := resposta["Response"]; recusada {
+		var mensagem string
+		json.Unmarshal(resposta["Message"], &mensagem)
+		return nil, &ErroUpstream{Provedor: provedorCryptoCompare, Status: http.StatusBadGateway,
+			Causa: fmt.Errorf("consulta recusada: %s", mensagem)}
+	}
+
+	var cotacoes []Cotacao
+	for _, ativo := range ativos {
+		var precos map[string]float64
+		if json.Unmarshal(resposta[simbolosCryptoCompare[ativo]], &precos) != nil {
+			continue
+		}
+		for _, moeda := range moedas {
+			if preco, ok := precos[strings.ToUpper(moeda)]; ok {
+				cotacoes = append(cotacoes, Cotacao{Ativo: ativo, Moeda: moeda, Preco: preco})
+			}
+		}
+	}
+	return cotacoes, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// encerrando passa a ser verdadeiro quando o servidor recebe SIGINT/SIGTERM. A partir
+// daí o /readyz responde 503, para que o balanceador pare de enviar tráfego.
+var encerrando atomic.Bool
+
+// RespostaSaude é o corpo JSON do /healthz e do /readyz.
+type RespostaSaude struct {
+	Status    string                    `json:"status"`
+	Upstreams map[string]EstadoUpstream `json:"upstreams,omitempty"`
+}
+
+// Handler do /healthz: o processo está de pé e respondendo.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RespostaSaude{Status: "ok"})
+}
+
+// Handler do /readyz: o servidor pode receber tráfego se não estiver encerrando e se
+// houver, para o clima e para as cotações, ao menos um provedor sem circuito aberto
+// (fora do ar há várias tentativas).
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	resposta := RespostaSaude{Status: "pronto", Upstreams: map[string]EstadoUpstream{}}
+	climaDisponivel, mercadoDisponivel := false, false
+	for _, provedor := range provedoresClima {
+		climaDisponivel = climaDisponivel || disponivel(provedor)
+		registrarUpstreams(resposta.Upstreams, provedor)
+	}
+	for _, provedor := range provedoresMercado {
+		mercadoDisponivel = mercadoDisponivel || disponivel(provedor)
+		registrarUpstreams(resposta.Upstreams, provedor)
+	}
+
+	status := http.StatusOK
+	switch {
+	case encerrando.Load():
+		resposta.Status = "encerrando"
+		status = http.StatusServiceUnavailable
+	case !climaDisponivel || !mercadoDisponivel:
+		resposta.Status = "indisponivel"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resposta)
+}
+
+// registrarUpstreams acrescenta o estado das APIs chamadas pelo provedor.
+func registrarUpstreams(estados map[string]EstadoUpstream, provedor Provedor) {
+	for _, cliente := range provedor.Upstreams() {
+		estados[cliente.nome] = cliente.Estado()
+	}
+}
+
+// novoServidor cria o http.Server com os tempos máximos do config.yaml. O JoinHostPort
+// põe os colchetes de um host IPv6 (ex: [::1]:8080).
+func novoServidor(config Configuracao, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              net.JoinHostPort(config.Servidor.Host, config.Servidor.Porta),
+		Handler:           handler,
+		ReadHeaderTimeout: config.Servidor.TimeoutCabecalho,
+		ReadTimeout:       config.Servidor.TimeoutLeitura,
+		WriteTimeout:      config.Servidor.TimeoutEscrita,
+		IdleTimeout:       config.Servidor.TimeoutOcioso,
+	}
+}
+
+// iniciarServidor atende em HTTPS se o servidor tiver configuração TLS, senão em HTTP.
+func iniciarServidor(servidor *http.Server) error {
+	if servidor.TLSConfig != nil {
+		return servidor.ListenAndServeTLS("", "")
+	}
+	return servidor.ListenAndServe()
+}
+
+// executarServidores atende as requisições até o contexto ser cancelado (SIGINT/SIGTERM) e,
+// então, espera as requisições em andamento terminarem, por no máximo 'prazo'. Os servidores
+// (ex: o HTTPS e o redirecionamento HTTP) sobem e descem juntos.
+func executarServidores(ctx context.Context, prazo time.Duration, servidores ...*http.Server) error {
+	erros := make(chan error, len(servidores))
+	for _, servidor := range servidores {
+		go func() {
+			erros <- iniciarServidor(servidor)
+		}()
+	}
+
+	var falha error
+	select {
+	case falha = <-erros:
+		// Um dos servidores nem chegou a subir (ex: porta em uso); os demais são encerrados.
+		log.Printf("ERRO: %v", falha)
+	case <-ctx.Done():
+		log.Printf("Sinal de encerramento recebido; aguardando as requisições em andamento (até %s)...", prazo)
+	}
+	encerrando.Store(true)
+
+	ctxEncerramento, cancelar := context.WithTimeout(context.Background(), prazo)
+	defer cancelar()
+	// Todos os servidores são encerrados, mesmo que o encerramento de algum deles falhe.
+	problemas := []error{falha}
+	for _, servidor := range servidores {
+		if err := servidor.Shutdown(ctxEncerramento); err != nil {
+			// Prazo esgotado: fecha as conexões que restaram.
+			servidor.Close()
+			problemas = append(problemas, fmt.Errorf("encerramento forçado de %s após %s: %w", servidor.Addr, prazo, err))
+		}
+	}
+	if falha == nil {
+		for range servidores {
+			if err := <-erros; !errors.Is(err, http.ErrServerClosed) {
+				problemas = append(problemas, err)
+			}
+		}
+	}
+	if err := errors.Join(problemas...); err != nil {
+		return err
+	}
+	log.Println("Servidor encerrado.")
+	return nil
+}
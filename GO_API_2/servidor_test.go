@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// enderecoLivre devolve um endereço local com uma porta que estava livre.
+func enderecoLivre(t *testing.T) string {
+	t.Helper()
+	escuta, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer escuta.Close()
+	return escuta.Addr().String()
+}
+
+// esperarServidor espera o servidor começar a aceitar conexões.
+func esperarServidor(t *testing.T, endereco string) {
+	t.Helper()
+	for limite := time.Now().Add(2 * time.Second); time.Now().Before(limite); time.Sleep(10 * time.Millisecond) {
+		if conexao, err := net.Dial("tcp", endereco); err == nil {
+			conexao.Close()
+			return
+		}
+	}
+	t.Fatalf("o servidor em %s não subiu", endereco)
+}
+
+func TestExecutarServidoresEncerraTodos(t *testing.T) {
+	t.Cleanup(func() { encerrando.Store(false) })
+
+	// O primeiro servidor tem uma requisição que não termina dentro do prazo; o segundo
+	// precisa ser encerrado mesmo assim.
+	liberar := make(chan struct{})
+	defer close(liberar)
+	iniciada := make(chan struct{})
+	lento := &http.Server{Addr: enderecoLivre(t), Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(iniciada)
+		<-liberar
+	})}
+	rapido := &http.Server{Addr: enderecoLivre(t), Handler: http.HandlerFunc(healthzHandler)}
+
+	ctx, cancelar := context.WithCancel(context.Background())
+	resultado := make(chan error, 1)
+	go func() { resultado <- executarServidores(ctx, 100*time.Millisecond, lento, rapido) }()
+	esperarServidor(t, lento.Addr)
+	esperarServidor(t, rapido.Addr)
+	go http.Get("http://" + lento.Addr + "/")
+	<-iniciada
+
+	cancelar()
+	select {
+	case err := <-resultado:
+		if err == nil || !strings.Contains(err.Error(), "encerramento forçado de "+lento.Addr) {
+			t.Fatalf("esperava o encerramento forçado do servidor lento, veio %v", err)
+		}
+		if strings.Contains(err.Error(), rapido.Addr) {
+			t.Fatalf("o servidor rápido não deveria falhar: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("executarServidores não retornou")
+	}
+	if conexao, err := net.Dial("tcp", rapido.Addr); err == nil {
+		conexao.Close()
+		t.Fatal("o segundo servidor continuou aceitando conexões")
+	}
+	if !encerrando.Load() {
+		t.Fatal("o /readyz não foi avisado do encerramento")
+	}
+}
+
+func TestRedirecionadorHTTPS(t *testing.T) {
+	casos := []struct {
+		porta, host, destino string
+	}{
+		{"443", "exemplo.com", "https://exemplo.com/api/clima?cidade=recife"},
+		{"443", "exemplo.com:80", "https://exemplo.com/api/clima?cidade=recife"},
+		{"8443", "exemplo.com:8080", "https://exemplo.com:8443/api/clima?cidade=recife"},
+		{"443", "192.168.0.10:80", "https://192.168.0.10/api/clima?cidade=recife"},
+		// IPv6 precisa dos colchetes na URL, com ou sem a porta.
+		{"443", "[::1]:80", "https://[::1]/api/clima?cidade=recife"},
+		{"443", "[::1]", "https://[::1]/api/clima?cidade=recife"},
+		{"8443", "[2001:db8::1]:8080", "https://[2001:db8::1]:8443/api/clima?cidade=recife"},
+		{"8443", "[2001:db8::1]", "https://[2001:db8::1]:8443/api/clima?cidade=recife"},
+	}
+	for _, caso := range casos {
+		var config Configuracao
+		config.Servidor.Porta = caso.porta
+		redirecionador := novoRedirecionador(config)
+
+		pedido := httptest.NewRequest(http.MethodPost, "/api/clima?cidade=recife", nil)
+		pedido.Host = caso.host
+		gravador := httptest.NewRecorder()
+		redirecionador.Handler.ServeHTTP(gravador, pedido)
+		if gravador.Code != http.StatusPermanentRedirect || gravador.Header().Get("Location") != caso.destino {
+			t.Errorf("porta %s, Host %s: %d para %q, esperava 308 para %q",
+				caso.porta, caso.host, gravador.Code, gravador.Header().Get("Location"), caso.destino)
+		}
+	}
+}
+
+func TestNovoServidorEndereco(t *testing.T) {
+	casos := []struct{ host, porta, endereco string }{
+		{"0.0.0.0", "8080", "0.0.0.0:8080"},
+		{"localhost", "8080", "localhost:8080"},
+		{"", "8080", ":8080"},
+		// IPv6 precisa dos colchetes, senão a porta se confunde com o endereço.
+		{"::1", "8080", "[::1]:8080"},
+		{"::", "443", "[::]:443"},
+	}
+	for _, caso := range casos {
+		var config Configuracao
+		config.Servidor.Host, config.Servidor.Porta = caso.host, caso.porta
+		if endereco := novoServidor(config, nil).Addr; endereco != caso.endereco {
+			t.Errorf("host %q e porta %s: %q, esperava %q", caso.host, caso.porta, endereco, caso.endereco)
+		}
+	}
+}
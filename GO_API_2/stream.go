@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tipos de evento enviados pelo /api/stream (campo "event:" do SSE).
+const (
+	eventoBitcoin  = "bitcoin"
+	eventoClima    = "clima"
+	eventoMensagem = "mensagem"
+)
+
+// Tempo que o navegador espera antes de reconectar (campo "retry:" do SSE).
+const esperaReconexaoSSE = 5 * time.Second
+
+// ConfiguracaoStream define o ritmo do atualizador e os limites do /api/stream.
+type ConfiguracaoStream struct {
+	Intervalo   time.Duration `mapstructure:"intervalo" yaml:"intervalo"`       // De quanto em quanto tempo os dados são atualizados.
+	Heartbeat   time.Duration `mapstructure:"heartbeat" yaml:"heartbeat"`       // Comentário enviado para manter a conexão aberta.
+	MaxClientes int           `mapstructure:"max_clientes" yaml:"max_clientes"` // Conexões simultâneas aceitas.
+	Historico   int           `mapstructure:"historico" yaml:"historico"`       // Eventos guardados para quem reconectar.
+}
+
+// Evento é uma atualização enviada aos navegadores conectados.
+type Evento struct {
+	ID    uint64
+	Tipo  string
+	Dados []byte
+}
+
+// errLimiteClientes indica que o /api/stream já atingiu o número máximo de conexões.
+var errLimiteClientes = errors.New("limite de clientes conectados atingido; tente novamente em instantes")
+
+// ============== DIFUSOR DE EVENTOS ==============
+
+// Difusor distribui os eventos para os clientes conectados e guarda os mais recentes,
+// para que quem reconectar com Last-Event-ID receba o que perdeu.
+type Difusor struct {
+	mu               sync.Mutex
+	clientes         map[chan Evento]struct{}
+	historico        []Evento
+	ultimos          map[string]Evento // Último evento de cada tipo.
+	proximoID        uint64
+	maxClientes      int
+	tamanhoHistorico int
+}
+
+// difusorEventos é o difusor usado pelo /api/stream, criado no main.
+var difusorEventos *Difusor
+
+// novoDifusor cria um difusor sem clientes.
+func novoDifusor(maxClientes, tamanhoHistorico int) *Difusor {
+	return &Difusor{
+		clientes:         map[chan Evento]struct{}{},
+		ultimos:          map[string]Evento{},
+		proximoID:        1,
+		maxClientes:      maxClientes,
+		tamanhoHistorico: tamanhoHistorico,
+	}
+}
+
+// Inscrever registra um cliente e devolve, além do canal, os eventos que ele deve
+// receber primeiro: os posteriores a 'ultimoID', se ainda estiverem no histórico,
+// ou o último evento de cada tipo (conexão nova ou ausência longa demais).
+func (d *Difusor) Inscrever(ultimoID uint64, reconectando bool) (chan Evento, []Evento, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.clientes) >= d.maxClientes {
+		return nil, nil, errLimiteClientes
+	}
+	canal := make(chan Evento, 16)
+	d.clientes[canal] = struct{}{}
+
+	// O histórico só serve se ainda contém o evento seguinte ao último recebido.
+	// Um ID maior que o atual indica que o servidor foi reiniciado.
+	if reconectando && ultimoID < d.proximoID &&
+		(len(d.historico) == 0 || d.historico[0].ID <= ultimoID+1) {
+		var perdidos []Evento
+		for _, evento := range d.historico {
+			if evento.ID > ultimoID {
+				perdidos = append(perdidos, evento)
+			}
+		}
+		return canal, perdidos, nil
+	}
+
+	var iniciais []Evento
+	for _, evento := range d.ultimos {
+		iniciais = append(iniciais, evento)
+	}
+	sort.Slice(iniciais, func(i, j int) bool { return iniciais[i].ID < iniciais[j].ID })
+	return canal, iniciais, nil
+}
+
+// Cancelar remove o cliente. Pode ser chamada mais de uma vez.
+func (d *Difusor) Cancelar(canal chan Evento) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.clientes[canal]; ok {
+		delete(d.clientes, canal)
+		close(canal)
+	}
+}
+
+// Publicar envia os dados a todos os clientes, se forem diferentes dos últimos
+// publicados para o mesmo tipo. Informa se o evento foi publicado.
+func (d *Difusor) Publicar(tipo string, dados []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if anterior, ok := d.ultimos[tipo]; ok && string(anterior.Dados) == string(dados) {
+		return false
+	}
+
+	evento := Evento{ID: d.proximoID, Tipo: tipo, Dados: dados}
+	d.proximoID++
+	d.ultimos[tipo] = evento
+	d.historico = append(d.historico, evento)
+	if len(d.historico) > d.tamanhoHistorico {
+		d.historico = d.historico[len(d.historico)-d.tamanhoHistorico:]
+	}
+
+	for canal := range d.clientes {
+		select {
+		case canal <- evento:
+		default:
+			// Cliente lento: desconectamos. Ele reconecta e recupera o que perdeu pelo Last-Event-ID.
+			delete(d.clientes, canal)
+			close(canal)
+		}
+	}
+	return true
+}
+
+// Encerrar desconecta todos os clientes. Usada no encerramento do servidor, para que as
+// conexões abertas não segurem o desligamento até o prazo final.
+func (d *Difusor) Encerrar() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for canal := range d.clientes {
+		delete(d.clientes, canal)
+		close(canal)
+	}
+}
+
+// ============== ATUALIZADOR PERIÓDICO ==============
+
+// iniciarAtualizador executa as funções de atualização a cada 'intervalo', até o
+// contexto ser cancelado. Cada uma busca os dados do painel e publica o que mudou.
+func iniciarAtualizador(ctx context.Context, intervalo time.Duration, atualizacoes ...func()) {
+	go func() {
+		ticker := time.NewTicker(intervalo)
+		defer ticker.Stop()
+		for {
+			for _, atualizar := range atualizacoes {
+				atualizar()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// atualizarPainel busca cotação, clima e mensagem (com os valores padrão do config.yaml)
+// e publica cada um deles. As APIs externas só são chamadas quando o cache vence.
+func atualizarPainel(d *Difusor) {
+	topicos := map[string]string{eventoBitcoin: eventoBitcoin, eventoMensagem: eventoMensagem}
+	if cidade, err := normalizarCidade(configuracao().Padroes.Cidade); err != nil {
+		log.Printf("AVISO: cidade padrão inválida no config.yaml: %v", err)
+	} else {
+		topicos[eventoClima] = eventoClima + ":" + strings.ToLower(cidade)
+	}
+
+	for _, evento := range []string{eventoBitcoin, eventoClima, eventoMensagem} {
+		topico, ok := topicos[evento]
+		if !ok {
+			continue
+		}
+		corpo, err := dadosDoTopico(topico)
+		if err != nil {
+			log.Printf("AVISO: stream sem dados atualizados de '%s': %v", topico, err)
+			continue
+		}
+		d.Publicar(evento, corpo)
+	}
+}
+
+// dadosDoTopico busca os dados atuais de um tópico do painel: "bitcoin" (ativos e
+// moedas padrão), "mensagem" ou "clima:<cidade>", com a cidade já normalizada.
+func dadosDoTopico(topico string) ([]byte, error) {
+	switch {
+	case topico == eventoBitcoin:
+		ativos, err := listaIdentificadores("", configuracao().Padroes.Ativos, "ativos")
+		if err != nil {
+			return nil, err
+		}
+		moedas, err := listaIdentificadores("", configuracao().Padroes.Moedas, "moeda")
+		if err != nil {
+			return nil, err
+		}
+		corpo, _, err := obterCotacoes(ativos, moedas)
+		return corpo, err
+	case topico == eventoMensagem:
+		mensagem, err := novaMensagem(FiltroFrases{}, modoAleatorio)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(mensagem)
+	case strings.HasPrefix(topico, eventoClima+":"):
+		corpo, _, err := obterClima(strings.TrimPrefix(topico, eventoClima+":"))
+		return corpo, err
+	}
+	return nil, fmt.Errorf("tópico desconhecido: '%s'", topico)
+}
+
+// ============== HANDLER SSE ==============
+
+// Handler do /api/stream: mantém a conexão aberta e envia os eventos do painel
+// no formato Server-Sent Events, com comentários de heartbeat entre eles.
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		responderErro(w, http.StatusInternalServerError, RespostaErro{Erro: "o servidor não suporta streaming"})
+		return
+	}
+
+	// O navegador envia o Last-Event-ID sozinho ao reconectar.
+	ultimoID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	canal, iniciais, errInscricao := difusorEventos.Inscrever(ultimoID, err == nil)
+	if errInscricao != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(esperaReconexaoSSE.Seconds())))
+		responderErro(w, http.StatusServiceUnavailable, RespostaErro{Erro: errInscricao.Error()})
+		return
+	}
+	defer difusorEventos.Cancelar(canal)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// A conexão fica aberta indefinidamente: o timeout de escrita do servidor não vale aqui.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	fmt.Fprintf(w, "retry: %d\n\n", esperaReconexaoSSE.Milliseconds())
+	for _, evento := range iniciais {
+		if err := escreverEvento(w, evento); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(configuracao().Stream.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case evento, aberto := <-canal:
+			if !aberto {
+				return // Desconectado por ser lento demais ou pelo encerramento do servidor.
+			}
+			if err := escreverEvento(w, evento); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// escreverEvento grava um evento no formato SSE. Os dados são JSON compacto, sem quebras de linha.
+func escreverEvento(w http.ResponseWriter, evento Evento) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evento.ID, evento.Tipo, evento.Dados)
+	return err
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// idsDosEventos descreve os eventos como "ID:tipo", na ordem recebida.
+func idsDosEventos(eventos []Evento) string {
+	var ids []string
+	for _, evento := range eventos {
+		ids = append(ids, fmt.Sprintf("%d:%s", evento.ID, evento.Tipo))
+	}
+	return strings.Join(ids, " ")
+}
+
+// clientesDoDifusor devolve quantos clientes estão inscritos.
+func clientesDoDifusor(d *Difusor) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.clientes)
+}
+
+func TestDifusorLastEventID(t *testing.T) {
+	d := novoDifusor(10, 3)
+	d.Publicar(eventoBitcoin, []byte(`{"preco":1}`))
+	d.Publicar(eventoClima, []byte(`{"temperatura_c":30}`))
+	d.Publicar(eventoBitcoin, []byte(`{"preco":2}`))
+	d.Publicar(eventoMensagem, []byte(`{"frase":"oi"}`))
+	// Dados iguais aos últimos do mesmo tipo não geram evento.
+	if d.Publicar(eventoMensagem, []byte(`{"frase":"oi"}`)) {
+		t.Fatal("publicou dados repetidos")
+	}
+
+	// O histórico guarda os 3 últimos eventos (IDs 2 a 4).
+	ultimosDeCadaTipo := "2:clima 3:bitcoin 4:mensagem"
+	casos := []struct {
+		nome         string
+		ultimoID     uint64
+		reconectando bool
+		esperados    string
+	}{
+		{"conexão nova", 0, false, ultimosDeCadaTipo},
+		{"perdeu os eventos 2 a 4", 1, true, "2:clima 3:bitcoin 4:mensagem"},
+		{"perdeu o evento 4", 3, true, "4:mensagem"},
+		{"não perdeu nada", 4, true, ""},
+		// O evento 1 já saiu do histórico: não há como repor tudo o que foi perdido.
+		{"ausência longa demais", 0, true, ultimosDeCadaTipo},
+		// Um ID à frente do atual indica que o servidor foi reiniciado.
+		{"servidor reiniciado", 99, true, ultimosDeCadaTipo},
+	}
+	for _, caso := range casos {
+		canal, iniciais, err := d.Inscrever(caso.ultimoID, caso.reconectando)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obtidos := idsDosEventos(iniciais); obtidos != caso.esperados {
+			t.Errorf("%s: recebeu %q, esperava %q", caso.nome, obtidos, caso.esperados)
+		}
+		d.Cancelar(canal)
+	}
+}
+
+func TestDifusorLimiteClientes(t *testing.T) {
+	d := novoDifusor(2, 10)
+	primeiro, _, err := d.Inscrever(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.Inscrever(0, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.Inscrever(0, false); !errors.Is(err, errLimiteClientes) {
+		t.Fatalf("esperava errLimiteClientes, veio %v", err)
+	}
+
+	// Cancelar libera a vaga, e cancelar de novo não faz nada.
+	d.Cancelar(primeiro)
+	d.Cancelar(primeiro)
+	if _, _, err := d.Inscrever(0, false); err != nil {
+		t.Fatalf("a vaga não foi liberada: %v", err)
+	}
+}
+
+func TestDifusorDesconectaClienteLento(t *testing.T) {
+	d := novoDifusor(10, 100)
+	lento, _, _ := d.Inscrever(0, false)
+	rapido, _, _ := d.Inscrever(0, false)
+
+	// O canal de cada cliente guarda 16 eventos; no 17º sem leitura, o cliente lento sai.
+	for i := range 17 {
+		d.Publicar(eventoBitcoin, fmt.Appendf(nil, `{"preco":%d}`, i))
+		if evento := <-rapido; evento.ID != uint64(i+1) {
+			t.Fatalf("o cliente rápido recebeu o evento %d, esperava %d", evento.ID, i+1)
+		}
+	}
+	if n := clientesDoDifusor(d); n != 1 {
+		t.Fatalf("%d clientes inscritos, esperava só o rápido", n)
+	}
+	// O lento ainda recebe o que estava no canal, que depois é fechado.
+	recebidos := 0
+	for range lento {
+		recebidos++
+	}
+	if recebidos != 16 {
+		t.Fatalf("o cliente lento recebeu %d eventos antes de sair, esperava 16", recebidos)
+	}
+}
+
+// prepararStreamDeTeste troca o difusor global por um novo, com o heartbeat informado.
+func prepararStreamDeTeste(t *testing.T, maxClientes int, heartbeat time.Duration) *Difusor {
+	t.Helper()
+	var config Configuracao
+	config.aplicarPadroes()
+	config.Stream.Heartbeat = heartbeat
+	configAnterior, difusorAnterior := configuracaoAtual.Load(), difusorEventos
+	configuracaoAtual.Store(&config)
+	difusorEventos = novoDifusor(maxClientes, 10)
+	t.Cleanup(func() {
+		configuracaoAtual.Store(configAnterior)
+		difusorEventos = difusorAnterior
+	})
+	return difusorEventos
+}
+
+// esperarClientes espera o difusor ter a quantidade de clientes informada.
+func esperarClientes(t *testing.T, d *Difusor, quantidade int) {
+	t.Helper()
+	for limite := time.Now().Add(2 * time.Second); time.Now().Before(limite); time.Sleep(time.Millisecond) {
+		if clientesDoDifusor(d) == quantidade {
+			return
+		}
+	}
+	t.Fatalf("o difusor não chegou a %d clientes", quantidade)
+}
+
+func TestStreamHandlerFormatoSSE(t *testing.T) {
+	d := prepararStreamDeTeste(t, 10, time.Hour)
+	d.Publicar(eventoBitcoin, []byte(`{"preco":1}`))
+	d.Publicar(eventoClima, []byte(`{"temperatura_c":30}`))
+
+	// Reconectando depois do evento 1: recebe o 2 do histórico e depois o que for publicado.
+	pedido := httptest.NewRequest(http.MethodGet, "/api/stream", nil)
+	pedido.Header.Set("Last-Event-ID", "1")
+	gravador := httptest.NewRecorder()
+	terminou := make(chan struct{})
+	go func() {
+		streamHandler(gravador, pedido)
+		close(terminou)
+	}()
+	esperarClientes(t, d, 1)
+	d.Publicar(eventoMensagem, []byte(`{"frase":"oi"}`))
+	d.Encerrar()
+	select {
+	case <-terminou:
+	case <-time.After(2 * time.Second):
+		t.Fatal("o handler não terminou com o encerramento do difusor")
+	}
+
+	if tipo := gravador.Header().Get("Content-Type"); tipo != "text/event-stream" || gravador.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("cabeçalhos = %v", gravador.Header())
+	}
+	esperado := "retry: 5000\n\n" +
+		"id: 2\nevent: clima\ndata: {\"temperatura_c\":30}\n\n" +
+		"id: 3\nevent: mensagem\ndata: {\"frase\":\"oi\"}\n\n"
+	if gravador.Body.String() != esperado {
+		t.Fatalf("corpo:\n%q\nesperava:\n%q", gravador.Body.String(), esperado)
+	}
+}
+
+func TestStreamHandlerHeartbeatELimite(t *testing.T) {
+	d := prepararStreamDeTeste(t, 1, 10*time.Millisecond)
+
+	ctx, cancelar := context.WithCancel(context.Background())
+	gravador := httptest.NewRecorder()
+	terminou := make(chan struct{})
+	go func() {
+		streamHandler(gravador, httptest.NewRequest(http.MethodGet, "/api/stream", nil).WithContext(ctx))
+		close(terminou)
+	}()
+	esperarClientes(t, d, 1)
+
+	// Com a única vaga ocupada, o próximo navegador recebe 503 com o Retry-After.
+	recusado := httptest.NewRecorder()
+	streamHandler(recusado, httptest.NewRequest(http.MethodGet, "/api/stream", nil))
+	if recusado.Code != http.StatusServiceUnavailable || recusado.Header().Get("Retry-After") != "5" {
+		t.Fatalf("segundo cliente: status %d, Retry-After %q", recusado.Code, recusado.Header().Get("Retry-After"))
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	cancelar()
+	<-terminou
+	if !strings.Contains(gravador.Body.String(), ": heartbeat\n\n") {
+		t.Fatalf("sem heartbeat no corpo: %q", gravador.Body.String())
+	}
+	// Ao sair, o handler devolve a vaga.
+	if n := clientesDoDifusor(d); n != 0 {
+		t.Fatalf("%d clientes depois da desconexão, esperava 0", n)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfiguracaoTLS define o HTTPS do servidor. Sem certificado e chave, um certificado
+// autoassinado é gerado na primeira execução, válido para o nome e os IPs desta máquina.
+type ConfiguracaoTLS struct {
+	Ativo       bool     `mapstructure:"ativo" yaml:"ativo"`
+	Certificado string   `mapstructure:"certificado" yaml:"certificado"` // Arquivo PEM (ex: de uma CA interna ou do Let's Encrypt).
+	Chave       string   `mapstructure:"chave" yaml:"chave"`             // Arquivo PEM da chave privada.
+	Hosts       []string `mapstructure:"hosts" yaml:"hosts"`             // Nomes e IPs extras do certificado autoassinado.
+	// Porta que só redireciona para o HTTPS (ex: "80"). Vazio: sem redirecionamento.
+	PortaRedirecionamento string `mapstructure:"porta_redirecionamento" yaml:"porta_redirecionamento"`
+	// Tempo em que o navegador só aceita HTTPS neste endereço (HSTS). 0 desliga.
+	// Com certificado autoassinado, o navegador deixa de permitir "continuar assim mesmo".
+	HSTS time.Duration `mapstructure:"hsts" yaml:"hsts"`
+}
+
+// Arquivos do certificado autoassinado e por quanto tempo ele vale.
+const (
+	certificadoAutoassinado = "tls/autoassinado.pem"
+	chaveAutoassinada       = "tls/autoassinado-chave.pem"
+	validadeAutoassinado    = 365 * 24 * time.Hour
+	renovacaoAutoassinado   = 7 * 24 * time.Hour // Gera um novo quando faltar menos que isso.
+)
+
+// configurarTLS devolve a configuração TLS do servidor, com o certificado informado ou,
+// se nenhum for informado, com o autoassinado (gerado quando não existe ou está vencendo).
+func configurarTLS(config ConfiguracaoTLS) (*tls.Config, error) {
+	certificado, chave := config.Certificado, config.Chave
+	if certificado == "" && chave == "" {
+		certificado, chave = certificadoAutoassinado, chaveAutoassinada
+		if err := garantirAutoassinado(certificado, chave, config.Hosts, time.Now()); err != nil {
+			return nil, fmt.Errorf("falha ao gerar o certificado autoassinado: %w", err)
+		}
+	} else if certificado == "" || chave == "" {
+		return nil, fmt.Errorf("informe 'tls.certificado' e 'tls.chave' juntos (ou nenhum dos dois, para usar um autoassinado)")
+	}
+
+	par, err := tls.LoadX509KeyPair(certificado, chave)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar o certificado '%s': %w", certificado, err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{par}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// garantirAutoassinado gera o certificado autoassinado se ele ainda não existir ou estiver
+// perto de vencer.
+func garantirAutoassinado(arquivoCertificado, arquivoChave string, extras []string, agora time.Time) error {
+	if par, err := tls.LoadX509KeyPair(arquivoCertificado, arquivoChave); err == nil {
+		if folha, err := x509.ParseCertificate(par.Certificate[0]); err == nil && folha.NotAfter.Sub(agora) > renovacaoAutoassinado {
+			return nil
+		}
+	}
+
+	nomes, ips := hostsDestaMaquina(extras)
+	chave, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serie, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	modelo := &x509.Certificate{
+		SerialNumber:          serie,
+		Subject:               pkix.Name{CommonName: nomes[0], Organization: []string{"Painel Go + Svelte (autoassinado)"}},
+		NotBefore:             agora.Add(-time.Hour),
+		NotAfter:              agora.Add(validadeAutoassinado),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              nomes,
+		IPAddresses:           ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, modelo, modelo, &chave.PublicKey, chave)
+	if err != nil {
+		return err
+	}
+	derChave, err := x509.MarshalPKCS8PrivateKey(chave)
+	if err != nil {
+		return err
+	}
+
+	if err := gravarPEM(arquivoChave, "PRIVATE KEY", derChave, 0o600); err != nil {
+		return err
+	}
+	if err := gravarPEM(arquivoCertificado, "CERTIFICATE", der, 0o644); err != nil {
+		return err
+	}
+	log.Printf("AVISO: certificado autoassinado gerado em '%s' para %s; o navegador pedirá confirmação no primeiro acesso.",
+		arquivoCertificado, strings.Join(append(append([]string{}, nomes...), textosIPs(ips)...), ", "))
+	return nil
+}
+
+// hostsDestaMaquina devolve os nomes (o primeiro é o nome da máquina) e os IPs que o
+// certificado autoassinado deve cobrir: localhost, o nome na rede local e os IPs das
+// interfaces, além dos extras do config.yaml.
+func hostsDestaMaquina(extras []string) ([]string, []net.IP) {
+	var nomes []string
+	if nome, err := os.Hostname(); err == nil && nome != "" {
+		nomes = append(nomes, nome)
+		if !strings.Contains(nome, ".") {
+			nomes = append(nomes, nome+".local") // Nome anunciado via mDNS/Bonjour.
+		}
+	}
+	nomes = append(nomes, "localhost")
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+
+	if enderecos, err := net.InterfaceAddrs(); err == nil {
+		for _, endereco := range enderecos {
+			if rede, ok := endereco.(*net.IPNet); ok && rede.IP.IsGlobalUnicast() {
+				ips = append(ips, rede.IP)
+			}
+		}
+	}
+	for _, extra := range extras {
+		extra = strings.TrimSpace(extra)
+		if ip := net.ParseIP(extra); ip != nil {
+			ips = append(ips, ip)
+		} else if extra != "" && !slices.Contains(nomes, extra) {
+			nomes = append(nomes, extra)
+		}
+	}
+	return nomes, ips
+}
+
+// textosIPs converte os IPs para texto, para o log.
+func textosIPs(ips []net.IP) []string {
+	textos := make([]string, len(ips))
+	for i, ip := range ips {
+		textos[i] = ip.String()
+	}
+	return textos
+}
+
+// gravarPEM grava o bloco PEM no arquivo, criando a pasta se necessário.
+func gravarPEM(arquivo, tipo string, der []byte, permissao os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(arquivo), 0o700); err != nil {
+		return err
+	}
+	conteudo := pem.EncodeToMemory(&pem.Block{Type: tipo, Bytes: der})
+	if err := os.WriteFile(arquivo, conteudo, permissao); err != nil {
+		return err
+	}
+	// O WriteFile não muda a permissão de um arquivo que já existia.
+	return os.Chmod(arquivo, permissao)
+}
+
+// ============== REDIRECIONAMENTO E HSTS ==============
+
+// novoRedirecionador cria o servidor HTTP que só redireciona para o mesmo endereço em HTTPS.
+func novoRedirecionador(config Configuracao) *http.Server {
+	portaHTTPS := config.Servidor.Porta
+	return &http.Server{
+		Addr:              net.JoinHostPort(config.Servidor.Host, config.TLS.PortaRedirecionamento),
+		ReadHeaderTimeout: config.Servidor.TimeoutCabecalho,
+		IdleTimeout:       config.Servidor.TimeoutOcioso,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if semPorta, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = semPorta
+			} else {
+				host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]") // Ex: "[::1]" sem porta.
+			}
+			if host == "" {
+				http.Error(w, "cabeçalho Host ausente", http.StatusBadRequest)
+				return
+			}
+			// O JoinHostPort põe os colchetes dos IPv6 ("[::1]:8443"); na porta padrão, ela é
+			// tirada e os colchetes ficam.
+			destino := strings.TrimSuffix(net.JoinHostPort(host, portaHTTPS), ":443")
+			// 308 mantém o método e o corpo (ex: um POST continua POST).
+			http.Redirect(w, r, "https://"+destino+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+	}
+}
+
+// comHSTS pede ao navegador que, por 'duracao', só acesse este endereço via HTTPS.
+// O cabeçalho só vale (e só é enviado) nas respostas em HTTPS.
+func comHSTS(duracao time.Duration) Middleware {
+	valor := "max-age=" + strconv.Itoa(int(duracao.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if duracao > 0 && r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", valor)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
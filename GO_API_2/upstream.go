@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfiguracaoUpstream reúne o endereço e as regras de resiliência de uma API externa.
+// Tentativas e LimiteFalhas são ponteiros para separar o zero escrito no config.yaml,
+// que desliga o recurso, da opção não informada, que recebe o padrão.
+type ConfiguracaoUpstream struct {
+	URLBase       string        `mapstructure:"url_base" yaml:"url_base"`
+	Timeout       time.Duration `mapstructure:"timeout" yaml:"timeout"`               // Tempo máximo de cada tentativa.
+	Tentativas    *int          `mapstructure:"tentativas" yaml:"tentativas"`         // Novas tentativas após a primeira falha (0 = nenhuma).
+	EsperaInicial time.Duration `mapstructure:"espera_inicial" yaml:"espera_inicial"` // Dobra a cada nova tentativa.
+	LimiteFalhas  *int          `mapstructure:"limite_falhas" yaml:"limite_falhas"`   // Falhas seguidas que abrem o circuito (0 = sem disjuntor).
+	TempoAberto   time.Duration `mapstructure:"tempo_aberto" yaml:"tempo_aberto"`     // Quanto tempo o circuito fica aberto.
+}
+
+// valorOuZero lê uma opção numérica que pode não ter sido informada.
+func valorOuZero(valor *int) int {
+	if valor == nil {
+		return 0
+	}
+	return *valor
+}
+
+// clienteHTTP é o único http.Client usado para falar com as APIs externas. O tempo
+// máximo de cada chamada é definido por upstream, via contexto.
+var clienteHTTP = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+// ============== ERROS ==============
+
+// ErroUpstream descreve a falha de uma API externa e o status HTTP que devolvemos ao navegador:
+// 504 quando a API não respondeu a tempo, 404 quando ela não conhece o que foi pedido
+// (ex: uma cidade inexistente) e 502 nos demais casos.
+type ErroUpstream struct {
+	Provedor string
+	Status   int
+	Causa    error
+}
+
+func (e *ErroUpstream) Error() string {
+	return fmt.Sprintf("%s: %v", e.Provedor, e.Causa)
+}
+
+func (e *ErroUpstream) Unwrap() error {
+	return e.Causa
+}
+
+// errCircuitoAberto indica que a API foi desligada temporariamente após falhas seguidas.
+var errCircuitoAberto = errors.New("circuito aberto após falhas seguidas; nova tentativa em instantes")
+
+// RespostaErro é o corpo JSON devolvido quando algo dá errado.
+type RespostaErro struct {
+	Erro     string `json:"erro"`
+	Provedor string `json:"provedor,omitempty"`
+}
+
+// responderErro envia um erro em JSON com o status informado.
+func responderErro(w http.ResponseWriter, status int, resposta RespostaErro) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resposta)
+}
+
+// responderErroUpstream traduz a falha de uma API externa em 502/504 com corpo JSON.
+func responderErroUpstream(w http.ResponseWriter, err error, mensagem string) {
+	log.Printf("ERRO: %v", err)
+
+	var erroUpstream *ErroUpstream
+	if errors.As(err, &erroUpstream) {
+		responderErro(w, erroUpstream.Status, RespostaErro{Erro: mensagem, Provedor: erroUpstream.Provedor})
+		return
+	}
+	responderErro(w, http.StatusBadGateway, RespostaErro{Erro: mensagem})
+}
+
+// ============== DISJUNTOR (CIRCUIT BREAKER) ==============
+
+// Disjuntor deixa de chamar uma API depois de 'limite' falhas seguidas e só volta a
+// tentar quando 'tempoAberto' passar. Se a tentativa seguinte falhar, abre de novo.
+type Disjuntor struct {
+	mu          sync.Mutex
+	limite      int
+	tempoAberto time.Duration
+	falhas      int
+	abertoAte   time.Time
+	agora       func() time.Time
+}
+
+// novoDisjuntor cria um disjuntor fechado. Um limite zero desliga o disjuntor.
+func novoDisjuntor(limite int, tempoAberto time.Duration, agora func() time.Time) *Disjuntor {
+	return &Disjuntor{limite: limite, tempoAberto: tempoAberto, agora: agora}
+}
+
+// Permitir informa se a chamada pode ser feita agora.
+func (d *Disjuntor) Permitir() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.limite <= 0 || !d.agora().Before(d.abertoAte)
+}
+
+// Estado informa se o disjuntor está aberto e quantas falhas seguidas já houve.
+func (d *Disjuntor) Estado() (aberto bool, falhas int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.limite > 0 && d.agora().Before(d.abertoAte), d.falhas
+}
+
+// RegistrarSucesso fecha o disjuntor.
+func (d *Disjuntor) RegistrarSucesso() {
+	d.mu.Lock()
+	d.falhas = 0
+	d.mu.Unlock()
+}
+
+// RegistrarFalha conta a falha e abre o disjuntor ao atingir o limite.
+func (d *Disjuntor) RegistrarFalha() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.falhas++
+	if d.limite > 0 && d.falhas >= d.limite {
+		d.abertoAte = d.agora().Add(d.tempoAberto)
+	}
+}
+
+// ============== CLIENTE DE UMA API EXTERNA ==============
+
+// ClienteUpstream chama uma API externa com tempo máximo, novas tentativas com espera
+// exponencial e disjuntor.
+type ClienteUpstream struct {
+	nome      string
+	config    ConfiguracaoUpstream
+	http      *http.Client
+	disjuntor *Disjuntor
+	dormir    func(ctx context.Context, d time.Duration) error // Substituível nos testes.
+}
+
+// novoClienteUpstream cria o cliente de uma API externa.
+func novoClienteUpstream(nome string, config ConfiguracaoUpstream, cliente *http.Client) *ClienteUpstream {
+	return &ClienteUpstream{
+		nome:      nome,
+		config:    config,
+		http:      cliente,
+		disjuntor: novoDisjuntor(valorOuZero(config.LimiteFalhas), config.TempoAberto, time.Now),
+		dormir:    dormirComContexto,
+	}
+}
+
+// EstadoUpstream resume a saúde de uma API externa, para o /readyz.
+type EstadoUpstream struct {
+	Circuito       string `json:"circuito"` // "fechado" (chamadas liberadas) ou "aberto".
+	FalhasSeguidas int    `json:"falhas_seguidas"`
+}
+
+// Estado informa se a API externa está sendo chamada ou foi desligada pelo disjuntor.
+func (c *ClienteUpstream) Estado() EstadoUpstream {
+	aberto, falhas := c.disjuntor.Estado()
+	estado := EstadoUpstream{Circuito: "fechado", FalhasSeguidas: falhas}
+	if aberto {
+		estado.Circuito = "aberto"
+	}
+	return estado
+}
+
+// dormirComContexto espera o tempo informado, desistindo se o contexto for cancelado.
+func dormirComContexto(ctx context.Context, d time.Duration) error {
+	temporizador := time.NewTimer(d)
+	defer temporizador.Stop()
+	select {
+	case <-temporizador.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// erroTemporario marca as falhas que valem uma nova tentativa (rede, 5xx e 429).
+type erroTemporario struct{ error }
+
+func (e erroTemporario) Unwrap() error {
+	return e.error
+}
+
+// erroStatus é a resposta da API externa com um status HTTP diferente de 200.
+type erroStatus struct{ codigo int }
+
+func (e erroStatus) Error() string {
+	return fmt.Sprintf("status %d", e.codigo)
+}
+
+// ObterJSON faz um GET em URLBase+caminho e decodifica o corpo JSON em 'destino'.
+func (c *ClienteUpstream) ObterJSON(ctx context.Context, caminho string, destino any) error {
+	if !c.disjuntor.Permitir() {
+		metricas.RegistrarErroUpstream(c.nome, "circuito_aberto")
+		return &ErroUpstream{Provedor: c.nome, Status: http.StatusBadGateway, Causa: errCircuitoAberto}
+	}
+
+	var err error
+	espera := c.config.EsperaInicial
+	novasTentativas := valorOuZero(c.config.Tentativas)
+	for tentativa := 0; tentativa <= novasTentativas; tentativa++ {
+		if tentativa > 0 {
+			if errDormir := c.dormir(ctx, espera); errDormir != nil {
+				break
+			}
+			espera *= 2
+		}
+
+		var corpo []byte
+		inicio := time.Now()
+		corpo, err = c.tentar(ctx, caminho)
+		metricas.RegistrarUpstream(c.nome, time.Since(inicio), err)
+		if err == nil {
+			if err = json.Unmarshal(corpo, destino); err != nil {
+				// Um JSON inválido não melhora com novas tentativas.
+				metricas.RegistrarErroUpstream(c.nome, "json_invalido")
+				return &ErroUpstream{Provedor: c.nome, Status: http.StatusBadGateway, Causa: fmt.Errorf("resposta inválida: %w", err)}
+			}
+			c.disjuntor.RegistrarSucesso()
+			return nil
+		}
+
+		var temporario erroTemporario
+		if !errors.As(err, &temporario) {
+			break
+		}
+		log.Printf("AVISO: %s falhou (tentativa %d de %d): %v", c.nome, tentativa+1, novasTentativas+1, err)
+	}
+
+	if falhaDaAPI(err) {
+		c.disjuntor.RegistrarFalha()
+	}
+	status := http.StatusBadGateway
+	var erroHTTP erroStatus
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || ehTimeout(err):
+		status = http.StatusGatewayTimeout
+	case errors.As(err, &erroHTTP) && erroHTTP.codigo == http.StatusNotFound:
+		status = http.StatusNotFound
+	}
+	return &ErroUpstream{Provedor: c.nome, Status: status, Causa: err}
+}
+
+// falhaDaAPI informa se o erro mostra um problema na própria API (5xx, tempo esgotado ou
+// rede), o único tipo que conta para o disjuntor. Respostas como 404 dependem do que foi
+// pedido: contá-las deixaria quem pede uma cidade inexistente desligar a API para todos.
+func falhaDaAPI(err error) bool {
+	var erroHTTP erroStatus
+	if errors.As(err, &erroHTTP) {
+		return erroHTTP.codigo >= 500
+	}
+	return true
+}
+
+// tentar faz uma única chamada, respeitando o tempo máximo configurado.
+func (c *ClienteUpstream) tentar(ctx context.Context, caminho string) ([]byte, error) {
+	if c.config.Timeout > 0 {
+		var cancelar context.CancelFunc
+		ctx, cancelar = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancelar()
+	}
+
+	url := strings.TrimSuffix(c.config.URLBase, "/") + caminho
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, erroTemporario{err}
+	}
+	defer resp.Body.Close()
+
+	corpo, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, erroTemporario{err}
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, erroTemporario{erroStatus{resp.StatusCode}}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, erroStatus{resp.StatusCode}
+	}
+	return corpo, nil
+}
+
+// ehTimeout identifica erros de rede causados por tempo esgotado.
+func ehTimeout(err error) bool {
+	var erroRede net.Error
+	return errors.As(err, &erroRede) && erroRede.Timeout()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// novoClienteDeTeste cria um cliente para a API falsa que não dorme de verdade entre as
+// tentativas: as esperas pedidas ficam anotadas em 'esperas'.
+func novoClienteDeTeste(api *httptest.Server, config ConfiguracaoUpstream) (*ClienteUpstream, *[]time.Duration) {
+	config.URLBase = api.URL
+	cliente := novoClienteUpstream("teste", config, api.Client())
+	var esperas []time.Duration
+	cliente.dormir = func(ctx context.Context, d time.Duration) error {
+		esperas = append(esperas, d)
+		return ctx.Err()
+	}
+	return cliente, &esperas
+}
+
+// novaAPIFalsa responde com os status informados, um por chamada (o último se repete).
+func novaAPIFalsa(t *testing.T, status ...int) (*httptest.Server, *atomic.Int32) {
+	var chamadas atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(chamadas.Add(1))
+		codigo := status[min(n, len(status))-1]
+		w.WriteHeader(codigo)
+		if codigo == http.StatusOK {
+			w.Write([]byte(`{"valor": 42}`))
+		}
+	}))
+	t.Cleanup(api.Close)
+	return api, &chamadas
+}
+
+func TestObterJSONNovasTentativas(t *testing.T) {
+	api, chamadas := novaAPIFalsa(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	cliente, esperas := novoClienteDeTeste(api, ConfiguracaoUpstream{Tentativas: ponteiro(2), EsperaInicial: 100 * time.Millisecond, LimiteFalhas: ponteiro(5)})
+
+	var destino struct{ Valor int }
+	if err := cliente.ObterJSON(context.Background(), "/", &destino); err != nil {
+		t.Fatal(err)
+	}
+	if destino.Valor != 42 || chamadas.Load() != 3 {
+		t.Fatalf("valor %d com %d chamadas, esperava 42 com 3", destino.Valor, chamadas.Load())
+	}
+	// A espera dobra a cada nova tentativa.
+	if len(*esperas) != 2 || (*esperas)[0] != 100*time.Millisecond || (*esperas)[1] != 200*time.Millisecond {
+		t.Fatalf("esperas = %v", *esperas)
+	}
+	if estado := cliente.Estado(); estado.FalhasSeguidas != 0 {
+		t.Fatalf("o sucesso não zerou as falhas: %+v", estado)
+	}
+}
+
+func TestObterJSONErroDefinitivo(t *testing.T) {
+	// Um 404 não melhora com novas tentativas e chega ao navegador como 404.
+	api, chamadas := novaAPIFalsa(t, http.StatusNotFound)
+	cliente, esperas := novoClienteDeTeste(api, ConfiguracaoUpstream{Tentativas: ponteiro(3), EsperaInicial: time.Second})
+
+	var erroUpstream *ErroUpstream
+	err := cliente.ObterJSON(context.Background(), "/", &struct{}{})
+	if !errors.As(err, &erroUpstream) || erroUpstream.Status != http.StatusNotFound || erroUpstream.Provedor != "teste" {
+		t.Fatalf("erro = %v", err)
+	}
+	if chamadas.Load() != 1 || len(*esperas) != 0 {
+		t.Fatalf("%d chamadas e esperas %v, esperava uma chamada sem esperas", chamadas.Load(), *esperas)
+	}
+}
+
+func TestObterJSONFalhasDoDisjuntor(t *testing.T) {
+	// Só os problemas da própria API abrem o circuito; os erros que dependem do pedido
+	// (como uma cidade inexistente) não podem desligá-la para todos.
+	casos := []struct {
+		status int
+		abre   bool
+		http   int
+	}{
+		{http.StatusNotFound, false, http.StatusNotFound},
+		{http.StatusBadRequest, false, http.StatusBadGateway},
+		{http.StatusTooManyRequests, false, http.StatusBadGateway},
+		{http.StatusInternalServerError, true, http.StatusBadGateway},
+		{http.StatusServiceUnavailable, true, http.StatusBadGateway},
+	}
+	for _, caso := range casos {
+		api, _ := novaAPIFalsa(t, caso.status)
+		cliente, _ := novoClienteDeTeste(api, ConfiguracaoUpstream{LimiteFalhas: ponteiro(2), TempoAberto: time.Minute})
+
+		var erroUpstream *ErroUpstream
+		for range 3 {
+			if err := cliente.ObterJSON(context.Background(), "/", &struct{}{}); !errors.As(err, &erroUpstream) || erroUpstream.Status != caso.http {
+				t.Fatalf("status %d: erro %v, esperava %d", caso.status, err, caso.http)
+			}
+		}
+		if aberto := cliente.Estado().Circuito == "aberto"; aberto != caso.abre {
+			t.Errorf("status %d: circuito aberto = %v, esperava %v", caso.status, aberto, caso.abre)
+		}
+	}
+
+	// Uma API que não responde também abre o circuito.
+	api, _ := novaAPIFalsa(t, http.StatusOK)
+	api.Close()
+	cliente, _ := novoClienteDeTeste(api, ConfiguracaoUpstream{LimiteFalhas: ponteiro(2), TempoAberto: time.Minute})
+	for range 2 {
+		cliente.ObterJSON(context.Background(), "/", &struct{}{})
+	}
+	if estado := cliente.Estado(); estado.Circuito != "aberto" {
+		t.Fatalf("API fora do ar: estado = %+v, esperava o circuito aberto", estado)
+	}
+}
+
+func TestObterJSONTempoEsgotado(t *testing.T) {
+	liberar := make(chan struct{})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-liberar:
+		case <-r.Context().Done():
+		}
+	}))
+	defer api.Close()
+	defer close(liberar)
+	cliente, _ := novoClienteDeTeste(api, ConfiguracaoUpstream{Timeout: 50 * time.Millisecond})
+
+	var erroUpstream *ErroUpstream
+	if err := cliente.ObterJSON(context.Background(), "/", &struct{}{}); !errors.As(err, &erroUpstream) || erroUpstream.Status != http.StatusGatewayTimeout {
+		t.Fatalf("esperava 504, veio %v", err)
+	}
+}
+
+func TestObterJSONDisjuntor(t *testing.T) {
+	api, chamadas := novaAPIFalsa(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+	cliente, _ := novoClienteDeTeste(api, ConfiguracaoUpstream{LimiteFalhas: ponteiro(2), TempoAberto: 30 * time.Second})
+	relogio := novoRelogioFalso()
+	cliente.disjuntor.agora = relogio.agora
+
+	for range 2 {
+		if err := cliente.ObterJSON(context.Background(), "/", &struct{}{}); err == nil {
+			t.Fatal("esperava erro 500")
+		}
+	}
+	if estado := cliente.Estado(); estado.Circuito != "aberto" || estado.FalhasSeguidas != 2 {
+		t.Fatalf("estado = %+v, esperava o circuito aberto", estado)
+	}
+
+	// Com o circuito aberto, a API nem é chamada.
+	if err := cliente.ObterJSON(context.Background(), "/", &struct{}{}); !errors.Is(err, errCircuitoAberto) {
+		t.Fatalf("esperava circuito aberto, veio %v", err)
+	}
+	if chamadas.Load() != 2 {
+		t.Fatalf("%d chamadas, esperava 2", chamadas.Load())
+	}
+
+	// Passado o tempo aberto, uma nova tentativa é liberada e o sucesso fecha o circuito.
+	relogio.avancar(30 * time.Second)
+	if err := cliente.ObterJSON(context.Background(), "/", &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if estado := cliente.Estado(); estado.Circuito != "fechado" || estado.FalhasSeguidas != 0 {
+		t.Fatalf("estado = %+v, esperava o circuito fechado", estado)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConfiguracaoWebSocket define o keepalive e os limites do /ws.
+type ConfiguracaoWebSocket struct {
+	Ping        time.Duration `mapstructure:"ping" yaml:"ping"`                 // Intervalo entre os pings enviados ao navegador.
+	MaxClientes int           `mapstructure:"max_clientes" yaml:"max_clientes"` // Conexões simultâneas aceitas.
+	MaxTopicos  int           `mapstructure:"max_topicos" yaml:"max_topicos"`   // Tópicos que cada conexão pode assinar.
+}
+
+// Limites de cada conexão WebSocket.
+const (
+	esperaEscritaWS    = 10 * time.Second // Tempo máximo para enviar uma mensagem.
+	tamanhoMaximoMsgWS = 512              // Mensagens do navegador são pequenas (inscrever/cancelar).
+	tamanhoFilaWS      = 32               // Mensagens pendentes antes de o cliente ser considerado lento.
+)
+
+// Ações que o navegador pode enviar.
+const (
+	acaoInscrever = "inscrever"
+	acaoCancelar  = "cancelar"
+)
+
+// MensagemCliente é o que o navegador envia. Ex: {"acao": "inscrever", "topico": "clima:Natal"}
+type MensagemCliente struct {
+	Acao   string `json:"acao"`
+	Topico string `json:"topico"`
+}
+
+// MensagemServidor é o que o servidor envia. 'Tipo' é "atualizacao", "inscrito", "cancelado" ou "erro".
+type MensagemServidor struct {
+	Tipo   string          `json:"tipo"`
+	Topico string          `json:"topico,omitempty"`
+	Dados  json.RawMessage `json:"dados,omitempty"`
+	Erro   string          `json:"erro,omitempty"`
+}
+
+// O /ws aceita as mesmas origens liberadas pela política de CORS do config.yaml.
+var conversorWS = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return origemPermitida(configuracao().CORS, r) },
+}
+
+// normalizarTopico valida o tópico pedido pelo navegador e devolve a forma usada no hub
+// ("clima:São Paulo" -> "clima:sao+paulo"), para que a mesma cidade tenha um único tópico.
+func normalizarTopico(topico string) (string, error) {
+	topico = strings.TrimSpace(topico)
+	switch strings.ToLower(topico) {
+	case eventoBitcoin, eventoMensagem:
+		return strings.ToLower(topico), nil
+	}
+
+	nome, cidade, temCidade := strings.Cut(topico, ":")
+	if temCidade && strings.ToLower(nome) == eventoClima {
+		normalizada, err := normalizarCidade(cidade)
+		if err != nil {
+			return "", err
+		}
+		return eventoClima + ":" + strings.ToLower(normalizada), nil
+	}
+	return "", fmt.Errorf("tópico desconhecido: '%s' (use bitcoin, mensagem ou clima:<cidade>)", topico)
+}
+
+// ============== HUB ==============
+
+// ClienteWS é uma conexão WebSocket e a fila de mensagens que ainda serão enviadas a ela.
+type ClienteWS struct {
+	conexao    *websocket.Conn
+	envio      chan []byte
+	topicos    map[string]bool
+	encerrado  bool   // Fila fechada; protegido pelo mutex do hub.
+	fechamento []byte // Mensagem de fechamento enviada ao navegador quando o servidor encerra a conexão.
+}
+
+// Hub guarda quem assina cada tópico e distribui as atualizações entre eles.
+type Hub struct {
+	mu       sync.Mutex
+	config   ConfiguracaoWebSocket
+	clientes map[*ClienteWS]bool
+	topicos  map[string]map[*ClienteWS]bool
+	ultimos  map[string][]byte // Últimos dados publicados em cada tópico.
+}
+
+// hubWS é o hub usado pelo /ws, criado no main.
+var hubWS *Hub
+
+// novoHub cria um hub vazio.
+func novoHub(config ConfiguracaoWebSocket) *Hub {
+	return &Hub{
+		config:   config,
+		clientes: map[*ClienteWS]bool{},
+		topicos:  map[string]map[*ClienteWS]bool{},
+		ultimos:  map[string][]byte{},
+	}
+}
+
+// Registrar adiciona uma conexão ao hub, respeitando o limite de clientes.
+func (h *Hub) Registrar(conexao *websocket.Conn) (*ClienteWS, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.clientes) >= h.config.MaxClientes {
+		return nil, errLimiteClientes
+	}
+	cliente := &ClienteWS{conexao: conexao, envio: make(chan []byte, tamanhoFilaWS), topicos: map[string]bool{}}
+	h.clientes[cliente] = true
+	return cliente, nil
+}
+
+// Remover tira o cliente de todos os tópicos e fecha a sua fila. Pode ser chamada mais de uma vez.
+func (h *Hub) Remover(cliente *ClienteWS) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remover(cliente)
+}
+
+// remover faz o trabalho de 'Remover'. Deve ser chamada com o mutex travado.
+func (h *Hub) remover(cliente *ClienteWS) {
+	if cliente.encerrado {
+		return
+	}
+	for topico := range cliente.topicos {
+		delete(h.topicos[topico], cliente)
+		if len(h.topicos[topico]) == 0 {
+			delete(h.topicos, topico)
+			delete(h.ultimos, topico)
+		}
+	}
+	delete(h.clientes, cliente)
+	cliente.encerrado = true
+	close(cliente.envio)
+}
+
+// enviar coloca a mensagem na fila do cliente. Se a fila estiver cheia, o cliente é
+// lento demais e é desconectado. Deve ser chamada com o mutex travado.
+func (h *Hub) enviar(cliente *ClienteWS, mensagem MensagemServidor) {
+	if cliente.encerrado {
+		return
+	}
+	dados, err := json.Marshal(mensagem)
+	if err != nil {
+		log.Printf("Erro ao codificar JSON: %v", err)
+		return
+	}
+	select {
+	case cliente.envio <- dados:
+	default:
+		log.Printf("AVISO: cliente WebSocket %s desconectado por não acompanhar as atualizações", cliente.conexao.RemoteAddr())
+		cliente.fechamento = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "cliente lento demais")
+		h.remover(cliente)
+	}
+}
+
+// Encerrar desconecta todos os clientes, avisando que o servidor está sendo desligado.
+func (h *Hub) Encerrar() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for cliente := range h.clientes {
+		cliente.fechamento = websocket.FormatCloseMessage(websocket.CloseGoingAway, "servidor em manutenção")
+		h.remover(cliente)
+	}
+}
+
+// Inscrever assina o tópico e envia ao cliente os últimos dados conhecidos dele.
+// Informa se o tópico ainda não tinha dados (e precisa ser buscado).
+func (h *Hub) Inscrever(cliente *ClienteWS, topico string) (semDados bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cliente.encerrado {
+		return false, nil
+	}
+	if !cliente.topicos[topico] && len(cliente.topicos) >= h.config.MaxTopicos {
+		return false, fmt.Errorf("limite de %d tópicos por conexão atingido", h.config.MaxTopicos)
+	}
+
+	cliente.topicos[topico] = true
+	if h.topicos[topico] == nil {
+		h.topicos[topico] = map[*ClienteWS]bool{}
+	}
+	h.topicos[topico][cliente] = true
+	h.enviar(cliente, MensagemServidor{Tipo: "inscrito", Topico: topico})
+
+	dados, ok := h.ultimos[topico]
+	if ok {
+		h.enviar(cliente, MensagemServidor{Tipo: "atualizacao", Topico: topico, Dados: dados})
+	}
+	return !ok, nil
+}
+
+// Cancelar deixa de assinar o tópico.
+func (h *Hub) Cancelar(cliente *ClienteWS, topico string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !cliente.topicos[topico] {
+		return
+	}
+	delete(cliente.topicos, topico)
+	delete(h.topicos[topico], cliente)
+	if len(h.topicos[topico]) == 0 {
+		// Sem assinantes, o tópico deixa de ser atualizado e os dados guardados são descartados.
+		delete(h.topicos, topico)
+		delete(h.ultimos, topico)
+	}
+	h.enviar(cliente, MensagemServidor{Tipo: "cancelado", Topico: topico})
+}
+
+// Publicar envia os dados aos assinantes do tópico, se forem diferentes dos últimos publicados.
+func (h *Hub) Publicar(topico string, dados []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.topicos[topico]) == 0 || string(h.ultimos[topico]) == string(dados) {
+		return
+	}
+	h.ultimos[topico] = dados
+	for cliente := range h.topicos[topico] {
+		h.enviar(cliente, MensagemServidor{Tipo: "atualizacao", Topico: topico, Dados: dados})
+	}
+}
+
+// AtualizarTopicos busca os dados de todos os tópicos com assinantes e publica o que mudou.
+func (h *Hub) AtualizarTopicos() {
+	h.mu.Lock()
+	topicos := make([]string, 0, len(h.topicos))
+	for topico := range h.topicos {
+		topicos = append(topicos, topico)
+	}
+	h.mu.Unlock()
+
+	sort.Strings(topicos)
+	for _, topico := range topicos {
+		h.atualizarTopico(topico)
+	}
+}
+
+// atualizarTopico busca os dados de um tópico e os publica.
+func (h *Hub) atualizarTopico(topico string) {
+	dados, err := dadosDoTopico(topico)
+	if err != nil {
+		log.Printf("AVISO: WebSocket sem dados atualizados de '%s': %v", topico, err)
+		return
+	}
+	h.Publicar(topico, dados)
+}
+
+// ============== HANDLER WEBSOCKET ==============
+
+// Handler do /ws: o navegador assina tópicos (bitcoin, mensagem, clima:<cidade>) e
+// recebe as atualizações de cada um deles pela mesma conexão.
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	conexao, err := conversorWS.Upgrade(w, r, nil)
+	if err != nil {
+		// O Upgrade já respondeu ao navegador com o erro.
+		log.Printf("AVISO: falha ao abrir WebSocket: %v", err)
+		return
+	}
+
+	h := hubWS
+	cliente, err := h.Registrar(conexao)
+	if err != nil {
+		conexao.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()), time.Now().Add(esperaEscritaWS))
+		conexao.Close()
+		return
+	}
+
+	go escreverWS(cliente, h.config.Ping)
+	lerWS(h, cliente)
+}
+
+// lerWS trata as mensagens do navegador até a conexão cair ou deixar de responder aos pings.
+func lerWS(h *Hub, cliente *ClienteWS) {
+	defer h.Remover(cliente)
+
+	// Sem resposta (pong) ao ping em até dois intervalos, a conexão é considerada perdida.
+	esperaPong := 2 * h.config.Ping
+	cliente.conexao.SetReadLimit(tamanhoMaximoMsgWS)
+	cliente.conexao.SetReadDeadline(time.Now().Add(esperaPong))
+	cliente.conexao.SetPongHandler(func(string) error {
+		return cliente.conexao.SetReadDeadline(time.Now().Add(esperaPong))
+	})
+
+	for {
+		var mensagem MensagemCliente
+		if err := cliente.conexao.ReadJSON(&mensagem); err != nil {
+			var erroFechamento *websocket.CloseError
+			// Fechamentos normais (pelo navegador ou pelo próprio servidor) não são registrados.
+			if !errors.As(err, &erroFechamento) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("AVISO: WebSocket %s encerrado: %v", cliente.conexao.RemoteAddr(), err)
+			}
+			return
+		}
+		if err := tratarMensagemWS(h, cliente, mensagem); err != nil {
+			h.mu.Lock()
+			h.enviar(cliente, MensagemServidor{Tipo: "erro", Topico: mensagem.Topico, Erro: err.Error()})
+			h.mu.Unlock()
+		}
+	}
+}
+
+// tratarMensagemWS executa a ação pedida pelo navegador.
+func tratarMensagemWS(h *Hub, cliente *ClienteWS, mensagem MensagemCliente) error {
+	topico, err := normalizarTopico(mensagem.Topico)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(mensagem.Acao) {
+	case acaoInscrever:
+		semDados, err := h.Inscrever(cliente, topico)
+		if err != nil {
+			return err
+		}
+		if semDados {
+			// Busca fora da leitura, para não travar a conexão enquanto a API externa responde.
+			go h.atualizarTopico(topico)
+		}
+	case acaoCancelar:
+		h.Cancelar(cliente, topico)
+	default:
+		return fmt.Errorf("ação desconhecida: '%s' (use inscrever ou cancelar)", mensagem.Acao)
+	}
+	return nil
+}
+
+// escreverWS envia as mensagens da fila do cliente e os pings de keepalive.
+// Termina quando a fila é fechada (cliente removido) ou a escrita falha.
+func escreverWS(cliente *ClienteWS, intervaloPing time.Duration) {
+	ping := time.NewTicker(intervaloPing)
+	defer func() {
+		ping.Stop()
+		cliente.conexao.Close()
+	}()
+
+	for {
+		select {
+		case dados, aberta := <-cliente.envio:
+			cliente.conexao.SetWriteDeadline(time.Now().Add(esperaEscritaWS))
+			if !aberta {
+				// Fila fechada pelo hub. Se foi decisão do servidor, avisa o navegador antes de encerrar.
+				if cliente.fechamento != nil {
+					cliente.conexao.WriteMessage(websocket.CloseMessage, cliente.fechamento)
+				}
+				return
+			}
+			if err := cliente.conexao.WriteMessage(websocket.TextMessage, dados); err != nil {
+				return
+			}
+		case <-ping.C:
+			cliente.conexao.SetWriteDeadline(time.Now().Add(esperaEscritaWS))
+			if err := cliente.conexao.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
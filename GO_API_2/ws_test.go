@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// configuracaoWSDeTeste permite 3 conexões com até 2 tópicos cada.
+var configuracaoWSDeTeste = ConfiguracaoWebSocket{Ping: time.Minute, MaxClientes: 3, MaxTopicos: 2}
+
+// filaDoCliente esvazia a fila do cliente e descreve cada mensagem como "tipo tópico dados".
+func filaDoCliente(t *testing.T, cliente *ClienteWS) []string {
+	t.Helper()
+	var mensagens []string
+	for {
+		select {
+		case dados, aberta := <-cliente.envio:
+			if !aberta {
+				return append(mensagens, "(fila fechada)")
+			}
+			var mensagem MensagemServidor
+			if err := json.Unmarshal(dados, &mensagem); err != nil {
+				t.Fatal(err)
+			}
+			mensagens = append(mensagens, strings.TrimSpace(fmt.Sprintf("%s %s %s%s", mensagem.Tipo, mensagem.Topico, string(mensagem.Dados), mensagem.Erro)))
+		default:
+			return mensagens
+		}
+	}
+}
+
+// conferirFila compara as mensagens pendentes do cliente com as esperadas.
+func conferirFila(t *testing.T, nome string, cliente *ClienteWS, esperadas ...string) {
+	t.Helper()
+	if obtidas := filaDoCliente(t, cliente); strings.Join(obtidas, "\n") != strings.Join(esperadas, "\n") {
+		t.Fatalf("%s recebeu:\n%s\nesperava:\n%s", nome, strings.Join(obtidas, "\n"), strings.Join(esperadas, "\n"))
+	}
+}
+
+func TestHubInscreverECancelar(t *testing.T) {
+	h := novoHub(configuracaoWSDeTeste)
+	ana, _ := h.Registrar(nil)
+	bia, _ := h.Registrar(nil)
+
+	// O primeiro assinante ainda não tem dados: o tópico precisa ser buscado.
+	if semDados, err := h.Inscrever(ana, eventoBitcoin); err != nil || !semDados {
+		t.Fatalf("Inscrever = (%v, %v), esperava um tópico sem dados", semDados, err)
+	}
+	h.Publicar(eventoBitcoin, []byte(`{"preco":1}`))
+	conferirFila(t, "ana", ana, `inscrito bitcoin`, `atualizacao bitcoin {"preco":1}`)
+
+	// Quem chega depois recebe os últimos dados na hora.
+	if semDados, err := h.Inscrever(bia, eventoBitcoin); err != nil || semDados {
+		t.Fatalf("Inscrever = (%v, %v), esperava os dados guardados", semDados, err)
+	}
+	conferirFila(t, "bia", bia, `inscrito bitcoin`, `atualizacao bitcoin {"preco":1}`)
+
+	// Cancelada a assinatura, as atualizações seguintes não chegam mais.
+	h.Cancelar(ana, eventoBitcoin)
+	h.Publicar(eventoBitcoin, []byte(`{"preco":2}`))
+	conferirFila(t, "ana", ana, `cancelado bitcoin`)
+	conferirFila(t, "bia", bia, `atualizacao bitcoin {"preco":2}`)
+
+	// Sem assinantes, o tópico e os dados guardados são descartados.
+	h.Cancelar(bia, eventoBitcoin)
+	conferirFila(t, "bia", bia, `cancelado bitcoin`)
+	if semDados, _ := h.Inscrever(ana, eventoBitcoin); !semDados {
+		t.Fatal("os dados de um tópico sem assinantes continuaram guardados")
+	}
+
+	// Remover fecha a fila e tira o cliente dos tópicos; remover de novo não faz nada.
+	h.Remover(ana)
+	h.Remover(ana)
+	conferirFila(t, "ana", ana, `inscrito bitcoin`, `(fila fechada)`)
+	if len(h.topicos) != 0 || len(h.clientes) != 1 {
+		t.Fatalf("depois de remover: %d tópicos e %d clientes, esperava 0 e 1", len(h.topicos), len(h.clientes))
+	}
+}
+
+func TestHubLimites(t *testing.T) {
+	h := novoHub(configuracaoWSDeTeste)
+	cliente, _ := h.Registrar(nil)
+	h.Registrar(nil)
+	h.Registrar(nil)
+	if _, err := h.Registrar(nil); !errors.Is(err, errLimiteClientes) {
+		t.Fatalf("quarta conexão: esperava errLimiteClientes, veio %v", err)
+	}
+
+	for _, topico := range []string{eventoBitcoin, eventoMensagem} {
+		if _, err := h.Inscrever(cliente, topico); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Inscrever(cliente, "clima:natal"); err == nil || err.Error() != "limite de 2 tópicos por conexão atingido" {
+		t.Fatalf("terceiro tópico: erro %v", err)
+	}
+	// Repetir um tópico já assinado não conta para o limite.
+	if _, err := h.Inscrever(cliente, eventoBitcoin); err != nil {
+		t.Fatalf("repetir o tópico: %v", err)
+	}
+}
+
+func TestHubSoEnviaDadosAlterados(t *testing.T) {
+	h := novoHub(configuracaoWSDeTeste)
+	ana, _ := h.Registrar(nil)
+	bia, _ := h.Registrar(nil)
+	h.Inscrever(ana, "clima:natal")
+	h.Inscrever(bia, "clima:recife")
+	filaDoCliente(t, ana)
+	filaDoCliente(t, bia)
+
+	// Cada tópico compara com os próprios dados: o mesmo clima nas duas cidades chega às duas.
+	h.Publicar("clima:natal", []byte(`{"temperatura_c":30}`))
+	h.Publicar("clima:recife", []byte(`{"temperatura_c":30}`))
+	h.Publicar("clima:natal", []byte(`{"temperatura_c":30}`))
+	h.Publicar("clima:natal", []byte(`{"temperatura_c":31}`))
+	// Tópicos sem assinantes não guardam nada.
+	h.Publicar(eventoMensagem, []byte(`{"frase":"oi"}`))
+
+	conferirFila(t, "ana", ana, `atualizacao clima:natal {"temperatura_c":30}`, `atualizacao clima:natal {"temperatura_c":31}`)
+	conferirFila(t, "bia", bia, `atualizacao clima:recife {"temperatura_c":30}`)
+	if _, guardado := h.ultimos[eventoMensagem]; guardado {
+		t.Fatal("guardou os dados de um tópico sem assinantes")
+	}
+}
+
+// servidorWSDeTeste sobe um /ws com o hub informado no lugar do global.
+func servidorWSDeTeste(t *testing.T, h *Hub) string {
+	t.Helper()
+	hubAnterior := hubWS
+	hubWS = h
+	servidor := httptest.NewServer(http.HandlerFunc(wsHandler))
+	t.Cleanup(func() {
+		servidor.Close()
+		hubWS = hubAnterior
+	})
+	return "ws" + strings.TrimPrefix(servidor.URL, "http")
+}
+
+// esperarClientesWS espera o hub ter a quantidade de clientes informada.
+func esperarClientesWS(t *testing.T, h *Hub, quantidade int) {
+	t.Helper()
+	for limite := time.Now().Add(2 * time.Second); time.Now().Before(limite); time.Sleep(time.Millisecond) {
+		h.mu.Lock()
+		n := len(h.clientes)
+		h.mu.Unlock()
+		if n == quantidade {
+			return
+		}
+	}
+	t.Fatalf("o hub não chegou a %d clientes", quantidade)
+}
+
+func TestHubDesconectaClienteLento(t *testing.T) {
+	var config Configuracao
+	config.aplicarPadroes()
+	configAnterior := configuracaoAtual.Load()
+	configuracaoAtual.Store(&config)
+	t.Cleanup(func() { configuracaoAtual.Store(configAnterior) })
+
+	// O cliente lento precisa de uma conexão de verdade para receber o aviso de fechamento.
+	h := novoHub(configuracaoWSDeTeste)
+	conexao, _, err := websocket.DefaultDialer.Dial(servidorWSDeTeste(t, h), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conexao.Close()
+	esperarClientesWS(t, h, 1)
+
+	// Um cliente que nunca lê a fila, ao lado do que chegou pela conexão.
+	lento, _ := h.Registrar(nil)
+	lento.conexao = conexao
+	h.Inscrever(lento, eventoBitcoin)
+	for i := range tamanhoFilaWS {
+		h.Publicar(eventoBitcoin, fmt.Appendf(nil, `{"preco":%d}`, i))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !lento.encerrado || h.clientes[lento] || len(h.topicos[eventoBitcoin]) != 0 {
+		t.Fatal("o cliente com a fila cheia não foi desconectado")
+	}
+	if fechamento := string(lento.fechamento); !strings.Contains(fechamento, "cliente lento demais") {
+		t.Fatalf("mensagem de fechamento = %q", fechamento)
+	}
+	if len(h.clientes) != 1 {
+		t.Fatalf("%d clientes, esperava só o da conexão", len(h.clientes))
+	}
+}
+
+func TestWSIdaEVolta(t *testing.T) {
+	// O tópico bitcoin é buscado na CoinGecko falsa assim que o primeiro navegador o assina.
+	prepararBitcoinDeTeste(t, novoRelogioFalso(), nil)
+	h := novoHub(configuracaoWSDeTeste)
+	conexao, _, err := websocket.DefaultDialer.Dial(servidorWSDeTeste(t, h), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conexao.Close()
+	conexao.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	receber := func() MensagemServidor {
+		t.Helper()
+		var mensagem MensagemServidor
+		if err := conexao.ReadJSON(&mensagem); err != nil {
+			t.Fatal(err)
+		}
+		return mensagem
+	}
+
+	conexao.WriteJSON(MensagemCliente{Acao: "inscrever", Topico: "Bitcoin"})
+	if mensagem := receber(); mensagem.Tipo != "inscrito" || mensagem.Topico != eventoBitcoin {
+		t.Fatalf("esperava a confirmação da inscrição, veio %+v", mensagem)
+	}
+	mensagem := receber()
+	var cotacoes RespostaCotacoes
+	if mensagem.Tipo != "atualizacao" || json.Unmarshal(mensagem.Dados, &cotacoes) != nil || len(cotacoes.Cotacoes) != 1 || cotacoes.Cotacoes[0].Preco != 350001 {
+		t.Fatalf("esperava a cotação da API falsa, veio %+v", mensagem)
+	}
+
+	conexao.WriteJSON(MensagemCliente{Acao: "inscrever", Topico: "clima"})
+	if mensagem := receber(); mensagem.Tipo != "erro" || !strings.Contains(mensagem.Erro, "tópico desconhecido") {
+		t.Fatalf("esperava erro de tópico, veio %+v", mensagem)
+	}
+
+	// No encerramento do servidor, o navegador recebe o fechamento com o motivo.
+	h.Encerrar()
+	var erroFechamento *websocket.CloseError
+	if _, _, err := conexao.ReadMessage(); !errors.As(err, &erroFechamento) || erroFechamento.Code != websocket.CloseGoingAway {
+		t.Fatalf("esperava o fechamento 1001, veio %v", err)
+	}
+}
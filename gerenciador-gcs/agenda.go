@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"        // Cancelamento do daemon ao receber SIGINT/SIGTERM.
+	"database/sql"   // Acesso às tabelas de agendamentos.
+	"encoding/json"  // O filtro de equipamentos é gravado como JSON.
+	"fmt"            // Formatação das mensagens.
+	"log"            // Registro das atividades do daemon.
+	"os"             // Saída padrão das tabelas.
+	"os/signal"      // Captura dos sinais de encerramento.
+	"strconv"        // Conversão do ID informado na linha de comando.
+	"syscall"        // Sinal SIGTERM.
+	"text/tabwriter" // Tabelas alinhadas no console.
+	"time"           // Horários previstos e intervalos do daemon.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== ESTRUTURAS ==============
+
+// Políticas para os disparos perdidos (ex: o daemon estava parado no horário previsto).
+const (
+	perdidasExecutar = "executar" // Executa uma única vez, assim que o daemon voltar.
+	perdidasIgnorar  = "ignorar"  // Apenas registra que o disparo foi perdido.
+)
+
+// Agendamento representa uma linha da tabela 'agendamentos'.
+type Agendamento struct {
+	ID             int
+	Nome           string
+	GrupoID        int
+	Filtro         FiltroEquipamentos
+	Cron           string
+	Perdidas       string
+	Ativo          bool
+	UltimaPrevista time.Time // Último horário previsto já tratado (zero se nunca disparou).
+	CriadoEm       time.Time
+}
+
+// Relogio fornece a hora atual e a espera entre as verificações. Em produção usamos
+// o relógio do sistema; para testar os agendamentos basta injetar um relógio controlado.
+type Relogio interface {
+	Agora() time.Time
+	Apos(d time.Duration) <-chan time.Time
+}
+
+// relogioSistema implementa Relogio com o pacote 'time'.
+type relogioSistema struct{}
+
+func (relogioSistema) Agora() time.Time                      { return time.Now() }
+func (relogioSistema) Apos(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// ============== LÓGICA DE CRUD - AGENDAMENTOS ==============
+
+// adicionarAgendamento valida e grava um novo agendamento.
+func adicionarAgendamento(a Agendamento, agora time.Time) error {
+	if _, err := interpretarCron(a.Cron); err != nil {
+		return err
+	}
+	if a.Perdidas != perdidasExecutar && a.Perdidas != perdidasIgnorar {
+		return fmt.Errorf("política de disparos perdidos '%s' inválida (use %s ou %s)", a.Perdidas, perdidasExecutar, perdidasIgnorar)
+	}
+	grupo, err := buscarGrupoComandos(a.GrupoID)
+	if err != nil {
+		return err
+	}
+	// Agendar um grupo de configuração equivale a executá-lo, então exige o mesmo papel.
+	if grupo.EhConfiguracao() {
+		if err := exigirPapel(PapelAdmin, "agenda ad (grupo de configuração)"); err != nil {
+			return err
+		}
+	}
+
+	filtro, err := json.Marshal(a.Filtro)
+	if err != nil {
+		return err
+	}
+	_, err = bancoDeDados.Exec("INSERT INTO agendamentos(nome, grupo_id, filtro, cron, perdidas, ativo, criado_em) VALUES(?, ?, ?, ?, ?, 1, ?)",
+		a.Nome, a.GrupoID, string(filtro), a.Cron, a.Perdidas, agora.Format(time.RFC3339))
+	return err
+}
+
+// buscarAgendamentos devolve os agendamentos (apenas os ativos, se pedido).
+func buscarAgendamentos(somenteAtivos bool) ([]Agendamento, error) {
+	consulta := "SELECT id, nome, grupo_id, filtro, cron, perdidas, ativo, ultima_prevista, criado_em FROM agendamentos"
+	if somenteAtivos {
+		consulta += " WHERE ativo = 1"
+	}
+	rows, err := bancoDeDados.Query(consulta + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agendamentos []Agendamento
+	for rows.Next() {
+		var a Agendamento
+		var filtro, criadoEm string
+		var ultima sql.NullString
+		if err := rows.Scan(&a.ID, &a.Nome, &a.GrupoID, &filtro, &a.Cron, &a.Perdidas, &a.Ativo, &ultima, &criadoEm); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(filtro), &a.Filtro); err != nil {
+			return nil, fmt.Errorf("filtro do agendamento %d inválido: %w", a.ID, err)
+		}
+		a.CriadoEm, _ = time.Parse(time.RFC3339, criadoEm)
+		if ultima.Valid {
+			a.UltimaPrevista, _ = time.Parse(time.RFC3339, ultima.String)
+		}
+		agendamentos = append(agendamentos, a)
+	}
+	return agendamentos, rows.Err()
+}
+
+// alterarAtivoAgendamento ativa ou desativa um agendamento.
+func alterarAtivoAgendamento(id int, ativo bool) error {
+	res, err := bancoDeDados.Exec("UPDATE agendamentos SET ativo = ? WHERE id = ?", ativo, id)
+	if err != nil {
+		return err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if linhasAfetadas == 0 {
+		return fmt.Errorf("nenhum agendamento encontrado com o ID %d", id)
+	}
+	return nil
+}
+
+// deletarAgendamento remove um agendamento e o seu histórico.
+func deletarAgendamento(id int) error {
+	res, err := bancoDeDados.Exec("DELETE FROM agendamentos WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if linhasAfetadas == 0 {
+		return fmt.Errorf("nenhum agendamento encontrado com o ID %d", id)
+	}
+	_, err = bancoDeDados.Exec("DELETE FROM agendamentos_execucoes WHERE agendamento_id = ?", id)
+	return err
+}
+
+// listarAgendamentos exibe os agendamentos com o próximo disparo previsto.
+func listarAgendamentos(agora time.Time) error {
+	agendamentos, err := buscarAgendamentos(false)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNOME\tGRUPO\tCRON\tPERDIDAS\tATIVO\tPRÓXIMO")
+	fmt.Fprintln(w, "--\t----\t-----\t----\t--------\t-----\t-------")
+	for _, a := range agendamentos {
+		proximo := "-"
+		if expressao, err := interpretarCron(a.Cron); err == nil && a.Ativo {
+			if p := expressao.Proxima(agora); !p.IsZero() {
+				proximo = p.Format("2006-01-02 15:04")
+			}
+		}
+		ativo := "não"
+		if a.Ativo {
+			ativo = "sim"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", a.ID, a.Nome, a.GrupoID, a.Cron, a.Perdidas, ativo, proximo)
+	}
+	return w.Flush()
+}
+
+// listarHistoricoAgendamento exibe os disparos mais recentes de um agendamento.
+func listarHistoricoAgendamento(id, limite int) error {
+	rows, err := bancoDeDados.Query(`SELECT id, previsto_para, COALESCE(iniciado_em, ''), COALESCE(finalizado_em, ''), status, COALESCE(mensagem, '')
+		FROM agendamentos_execucoes WHERE agendamento_id = ? ORDER BY id DESC LIMIT ?`, id, limite)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tPREVISTO\tINÍCIO\tFIM\tSTATUS\tMENSAGEM")
+	fmt.Fprintln(w, "--\t--------\t------\t---\t------\t--------")
+	for rows.Next() {
+		var idExec int
+		var previsto, inicio, fim, status, mensagem string
+		if err := rows.Scan(&idExec, &previsto, &inicio, &fim, &status, &mensagem); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", idExec, previsto, inicio, fim, status, mensagem)
+	}
+	return w.Flush()
+}
+
+// registrarDisparo grava uma linha no histórico de um agendamento.
+func registrarDisparo(agendamentoID int, previsto, inicio, fim time.Time, status, mensagem string) error {
+	formatar := func(t time.Time) any {
+		if t.IsZero() {
+			return nil
+		}
+		return t.Format(time.RFC3339)
+	}
+	_, err := bancoDeDados.Exec("INSERT INTO agendamentos_execucoes(agendamento_id, previsto_para, iniciado_em, finalizado_em, status, mensagem) VALUES(?, ?, ?, ?, ?, ?)",
+		agendamentoID, previsto.Format(time.RFC3339), formatar(inicio), formatar(fim), status, mensagem)
+	return err
+}
+
+// ============== AGENDADOR (DAEMON) ==============
+
+// Agendador verifica periodicamente quais agendamentos venceram e os executa.
+type Agendador struct {
+	relogio Relogio
+	// intervalo entre as verificações.
+	intervalo time.Duration
+	// tolerancia é o atraso máximo para um disparo ainda ser considerado "no horário".
+	// Acima disso (ex: o daemon estava parado), o disparo é tratado como perdido.
+	tolerancia time.Duration
+	// executar roda o grupo do agendamento; é uma variável para poder ser substituída.
+	executar func(a Agendamento) error
+}
+
+// novoAgendador cria um agendador que executa os grupos com 'rodarGrupo'.
+func novoAgendador(relogio Relogio, intervalo time.Duration) *Agendador {
+	return &Agendador{
+		relogio:    relogio,
+		intervalo:  intervalo,
+		tolerancia: 2 * intervalo,
+		// Os agendamentos nunca forçam a janela de manutenção.
+		executar: func(a Agendamento) error {
+			return rodarGrupo(a.GrupoID, a.Filtro, OpcoesExecucao{})
+		},
+	}
+}
+
+// vencimentos calcula, para um agendamento, o último horário previsto que já passou
+// e quantos horários anteriores a ele também passaram sem disparo.
+func vencimentos(a Agendamento, expressao *ExpressaoCron, agora time.Time) (ultimo time.Time, anteriores int) {
+	base := a.UltimaPrevista
+	if base.IsZero() {
+		base = a.CriadoEm
+	}
+	for p := expressao.Proxima(base); !p.IsZero() && !p.After(agora); p = expressao.Proxima(p) {
+		if !ultimo.IsZero() {
+			anteriores++
+		}
+		ultimo = p
+	}
+	return ultimo, anteriores
+}
+
+// verificar trata todos os agendamentos ativos que venceram até agora.
+func (ag *Agendador) verificar() error {
+	agendamentos, err := buscarAgendamentos(true)
+	if err != nil {
+		return err
+	}
+
+	agora := ag.relogio.Agora()
+	for _, a := range agendamentos {
+		expressao, err := interpretarCron(a.Cron)
+		if err != nil {
+			log.Printf("Agendamento %d (%s) ignorado: %v", a.ID, a.Nome, err)
+			continue
+		}
+
+		previsto, anteriores := vencimentos(a, expressao, agora)
+		if previsto.IsZero() {
+			continue
+		}
+
+		// Marca o horário como tratado antes de executar, para que uma queda
+		// durante a execução não faça o mesmo disparo rodar duas vezes.
+		if _, err := bancoDeDados.Exec("UPDATE agendamentos SET ultima_prevista = ? WHERE id = ?", previsto.Format(time.RFC3339), a.ID); err != nil {
+			return err
+		}
+
+		atrasado := agora.Sub(previsto) > ag.tolerancia
+		if anteriores > 0 {
+			mensagem := fmt.Sprintf("%d disparo(s) anterior(es) perdido(s) enquanto o daemon estava parado", anteriores)
+			if err := registrarDisparo(a.ID, previsto, time.Time{}, time.Time{}, "perdida", mensagem); err != nil {
+				return err
+			}
+		}
+		if atrasado && a.Perdidas == perdidasIgnorar {
+			log.Printf("Agendamento %d (%s): disparo de %s perdido e ignorado.", a.ID, a.Nome, previsto.Format(time.RFC3339))
+			if err := registrarDisparo(a.ID, previsto, time.Time{}, time.Time{}, "perdida", "disparo ignorado pela política 'ignorar'"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		log.Printf("Agendamento %d (%s): executando disparo de %s.", a.ID, a.Nome, previsto.Format(time.RFC3339))
+		inicio := ag.relogio.Agora()
+		status, mensagem := "sucesso", ""
+		if atrasado {
+			mensagem = "executado com atraso"
+		}
+		if err := ag.executar(a); err != nil {
+			status, mensagem = "falha", err.Error()
+			log.Printf("Agendamento %d (%s) falhou: %v", a.ID, a.Nome, err)
+		}
+		if err := registrarDisparo(a.ID, previsto, inicio, ag.relogio.Agora(), status, mensagem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rodar verifica os agendamentos a cada intervalo, até o contexto ser cancelado.
+func (ag *Agendador) rodar(ctx context.Context) error {
+	for {
+		if err := ag.verificar(); err != nil {
+			log.Printf("Erro ao verificar agendamentos: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ag.relogio.Apos(ag.intervalo):
+		}
+	}
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoAgenda = &cobra.Command{
+	Use:     "agenda",
+	Short:   "Gerencia as execuções agendadas de grupos de comandos.",
+	Aliases: []string{"ag"},
+}
+
+var comandoAddAgenda = &cobra.Command{
+	Use:         "ad",
+	Short:       "Agenda a execução recorrente de um grupo de comandos.",
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		var a Agendamento
+		a.Nome, _ = cmd.Flags().GetString("nome")
+		a.GrupoID, _ = cmd.Flags().GetInt("grupo")
+		a.Cron, _ = cmd.Flags().GetString("cron")
+		a.Perdidas, _ = cmd.Flags().GetString("perdidas")
+		a.Filtro.IDs, _ = cmd.Flags().GetIntSlice("equip")
+		a.Filtro.Cidade, _ = cmd.Flags().GetString("cidade")
+		a.Filtro.Tipo, _ = cmd.Flags().GetString("tipo")
+		a.Filtro.Vendor, _ = cmd.Flags().GetString("vendor")
+		a.Filtro.IncluirInativos, _ = cmd.Flags().GetBool("incluir-inativos")
+
+		if err := adicionarAgendamento(a, time.Now()); err != nil {
+			log.Fatalf("Erro ao adicionar agendamento: %v", err)
+		}
+		fmt.Println("Agendamento adicionado com sucesso!")
+	},
+}
+
+var comandoListAgenda = &cobra.Command{
+	Use:         "list",
+	Short:       "Lista os agendamentos e o próximo disparo de cada um.",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listarAgendamentos(time.Now()); err != nil {
+			log.Fatalf("Erro ao listar agendamentos: %v", err)
+		}
+	},
+}
+
+var comandoDeleteAgenda = &cobra.Command{
+	Use:         "del [ID]",
+	Short:       "Deleta um agendamento pelo seu ID.",
+	Aliases:     []string{"rm"},
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		if err := deletarAgendamento(id); err != nil {
+			log.Fatalf("Erro ao deletar agendamento: %v", err)
+		}
+		fmt.Printf("Agendamento com ID %d deletado com sucesso!\n", id)
+	},
+}
+
+// novoComandoAtivoAgenda cria os comandos 'ativar' e 'desativar', que só diferem no valor gravado.
+func novoComandoAtivoAgenda(uso, descricao string, ativo bool) *cobra.Command {
+	return &cobra.Command{
+		Use:         uso + " [ID]",
+		Short:       descricao,
+		Args:        cobra.ExactArgs(1),
+		Annotations: exigir(PapelOperador),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+			}
+			if err := alterarAtivoAgendamento(id, ativo); err != nil {
+				log.Fatalf("Erro ao alterar agendamento: %v", err)
+			}
+			fmt.Printf("Agendamento com ID %d atualizado com sucesso!\n", id)
+		},
+	}
+}
+
+var comandoHistoricoAgenda = &cobra.Command{
+	Use:         "historico [ID]",
+	Short:       "Exibe os últimos disparos de um agendamento.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		limite, _ := cmd.Flags().GetInt("limite")
+		if err := listarHistoricoAgendamento(id, limite); err != nil {
+			log.Fatalf("Erro ao listar histórico: %v", err)
+		}
+	},
+}
+
+var comandoDaemon = &cobra.Command{
+	Use:         "daemon",
+	Short:       "Executa os agendamentos continuamente, até receber SIGINT/SIGTERM.",
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		intervalo, _ := cmd.Flags().GetDuration("intervalo")
+		if intervalo <= 0 {
+			log.Fatal("O intervalo deve ser maior que zero.")
+		}
+
+		ctx, cancelar := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancelar()
+
+		log.Printf("Daemon iniciado. Verificando agendamentos a cada %s.", intervalo)
+		if err := novoAgendador(relogioSistema{}, intervalo).rodar(ctx); err != nil {
+			log.Fatalf("Erro no daemon: %v", err)
+		}
+		log.Println("Daemon encerrado.")
+	},
+}
+
+func init() {
+	comandoRaiz.AddCommand(comandoAgenda, comandoDaemon)
+	comandoAgenda.AddCommand(
+		comandoAddAgenda,
+		comandoListAgenda,
+		comandoDeleteAgenda,
+		novoComandoAtivoAgenda("ativar", "Ativa um agendamento.", true),
+		novoComandoAtivoAgenda("desativar", "Desativa um agendamento sem apagá-lo.", false),
+		comandoHistoricoAgenda,
+	)
+
+	comandoAddAgenda.Flags().String("nome", "", "Nome do agendamento")
+	comandoAddAgenda.Flags().Int("grupo", 0, "ID do grupo de comandos")
+	comandoAddAgenda.Flags().String("cron", "", "Expressão cron (ex: \"0 7 * * 1-5\")")
+	comandoAddAgenda.Flags().String("perdidas", perdidasExecutar, "O que fazer com disparos perdidos: executar ou ignorar")
+	comandoAddAgenda.Flags().IntSlice("equip", nil, "IDs dos equipamentos (ex: 1,2,3)")
+	comandoAddAgenda.Flags().String("cidade", "", "Executa em todos os equipamentos da cidade")
+	comandoAddAgenda.Flags().String("tipo", "", "Executa em todos os equipamentos do tipo")
+	comandoAddAgenda.Flags().String("vendor", "", "Executa em todos os equipamentos do fabricante")
+	comandoAddAgenda.Flags().Bool("incluir-inativos", false, "Executa também nos equipamentos em manutenção ou desativados")
+	comandoAddAgenda.MarkFlagRequired("nome")
+	comandoAddAgenda.MarkFlagRequired("grupo")
+	comandoAddAgenda.MarkFlagRequired("cron")
+
+	comandoHistoricoAgenda.Flags().Int("limite", 20, "Quantidade máxima de disparos exibidos")
+	comandoDaemon.Flags().Duration("intervalo", 30*time.Second, "Intervalo entre as verificações dos agendamentos")
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// relogioFalso implementa Relogio sem esperar de verdade: cada 'Apos' avança a hora
+// simulada e dispara na hora, o que permite rodar horas de daemon em milissegundos.
+type relogioFalso struct {
+	mu    sync.Mutex
+	agora time.Time
+	// aoEsperar é chamada a cada espera, já com a hora avançada (ex: para parar o daemon).
+	aoEsperar func(agora time.Time)
+}
+
+func (r *relogioFalso) Agora() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.agora
+}
+
+func (r *relogioFalso) Apos(d time.Duration) <-chan time.Time {
+	r.mu.Lock()
+	r.agora = r.agora.Add(d)
+	agora := r.agora
+	r.mu.Unlock()
+
+	if r.aoEsperar != nil {
+		r.aoEsperar(agora)
+	}
+	canal := make(chan time.Time, 1)
+	canal <- agora
+	return canal
+}
+
+// definir muda a hora simulada (ex: o daemon "volta" depois de ficar parado).
+func (r *relogioFalso) definir(agora time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.agora = agora
+}
+
+// horario monta um horário UTC de 19/10/2026.
+func horario(hora, minuto, segundo int) time.Time {
+	return time.Date(2026, 10, 19, hora, minuto, segundo, 0, time.UTC)
+}
+
+// criarAgendamentoDeTeste grava um agendamento diretamente na tabela, sem exigir grupo.
+func criarAgendamentoDeTeste(t *testing.T, cron, perdidas string, criadoEm time.Time) int {
+	t.Helper()
+	filtro, _ := json.Marshal(FiltroEquipamentos{Cidade: "JPA"})
+	res, err := bancoDeDados.Exec("INSERT INTO agendamentos(nome, grupo_id, filtro, cron, perdidas, ativo, criado_em) VALUES(?, 1, ?, ?, ?, 1, ?)",
+		"backup", string(filtro), cron, perdidas, criadoEm.Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// disparoRegistrado é uma linha de 'agendamentos_execucoes'.
+type disparoRegistrado struct {
+	previsto, status, mensagem string
+}
+
+// disparosRegistrados lê o histórico do agendamento, do mais antigo para o mais novo.
+func disparosRegistrados(t *testing.T, id int) []disparoRegistrado {
+	t.Helper()
+	rows, err := bancoDeDados.Query("SELECT previsto_para, status, COALESCE(mensagem, '') FROM agendamentos_execucoes WHERE agendamento_id = ? ORDER BY id", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var disparos []disparoRegistrado
+	for rows.Next() {
+		var d disparoRegistrado
+		if err := rows.Scan(&d.previsto, &d.status, &d.mensagem); err != nil {
+			t.Fatal(err)
+		}
+		disparos = append(disparos, d)
+	}
+	return disparos
+}
+
+// novoAgendadorDeTeste cria um agendador com o relógio falso que só anota as execuções.
+func novoAgendadorDeTeste(relogio Relogio) (*Agendador, *[]time.Time) {
+	ag := novoAgendador(relogio, 30*time.Second)
+	var execucoes []time.Time
+	ag.executar = func(Agendamento) error {
+		execucoes = append(execucoes, relogio.Agora())
+		return nil
+	}
+	return ag, &execucoes
+}
+
+func TestVencimentos(t *testing.T) {
+	expressao, err := interpretarCron("*/5 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	casos := []struct {
+		nome       string
+		ultima     time.Time
+		agora      time.Time
+		ultimo     time.Time
+		anteriores int
+	}{
+		{"ainda não venceu", time.Time{}, horario(10, 4, 59), time.Time{}, 0},
+		{"venceu agora", time.Time{}, horario(10, 5, 0), horario(10, 5, 0), 0},
+		{"daemon parado por meia hora", time.Time{}, horario(10, 31, 0), horario(10, 30, 0), 5},
+		{"já tratado", horario(10, 5, 0), horario(10, 9, 0), time.Time{}, 0},
+		{"conta a partir do último tratado", horario(10, 20, 0), horario(10, 31, 0), horario(10, 30, 0), 1},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			a := Agendamento{CriadoEm: horario(10, 0, 0), UltimaPrevista: caso.ultima}
+			ultimo, anteriores := vencimentos(a, expressao, caso.agora)
+			if !ultimo.Equal(caso.ultimo) || anteriores != caso.anteriores {
+				t.Fatalf("vencimentos = (%s, %d), esperava (%s, %d)", ultimo, anteriores, caso.ultimo, caso.anteriores)
+			}
+		})
+	}
+}
+
+func TestAgendadorExecutaNoHorario(t *testing.T) {
+	abrirBancoDeTeste(t)
+	id := criarAgendamentoDeTeste(t, "*/5 * * * *", perdidasExecutar, horario(10, 0, 0))
+	relogio := &relogioFalso{agora: horario(10, 4, 0)}
+	ag, execucoes := novoAgendadorDeTeste(relogio)
+
+	// Antes do horário, nada acontece.
+	if err := ag.verificar(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*execucoes) != 0 {
+		t.Fatalf("executou antes do horário: %v", *execucoes)
+	}
+
+	// No horário, executa uma vez; uma nova verificação no mesmo minuto não repete.
+	relogio.definir(horario(10, 5, 10))
+	for range 2 {
+		if err := ag.verificar(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*execucoes) != 1 {
+		t.Fatalf("execuções = %v, esperava uma", *execucoes)
+	}
+	disparos := disparosRegistrados(t, id)
+	if len(disparos) != 1 || disparos[0].status != "sucesso" || disparos[0].previsto != "2026-10-19T10:05:00Z" || disparos[0].mensagem != "" {
+		t.Fatalf("histórico inesperado: %+v", disparos)
+	}
+}
+
+func TestAgendadorDisparosPerdidos(t *testing.T) {
+	casos := []struct {
+		perdidas   string
+		execucoes  int
+		ultimo     disparoRegistrado
+		historicos int
+	}{
+		// Executa uma única vez (e não seis), registrando os disparos perdidos.
+		{perdidasExecutar, 1, disparoRegistrado{"2026-10-19T10:30:00Z", "sucesso", "executado com atraso"}, 2},
+		{perdidasIgnorar, 0, disparoRegistrado{"2026-10-19T10:30:00Z", "perdida", "disparo ignorado pela política 'ignorar'"}, 2},
+	}
+	for _, caso := range casos {
+		t.Run(caso.perdidas, func(t *testing.T) {
+			abrirBancoDeTeste(t)
+			id := criarAgendamentoDeTeste(t, "*/5 * * * *", caso.perdidas, horario(10, 0, 0))
+			// O daemon volta às 10:33: o disparo das 10:30 está além da tolerância (1 min).
+			relogio := &relogioFalso{agora: horario(10, 33, 0)}
+			ag, execucoes := novoAgendadorDeTeste(relogio)
+
+			if err := ag.verificar(); err != nil {
+				t.Fatal(err)
+			}
+			if len(*execucoes) != caso.execucoes {
+				t.Fatalf("execuções = %d, esperava %d", len(*execucoes), caso.execucoes)
+			}
+			disparos := disparosRegistrados(t, id)
+			if len(disparos) != caso.historicos {
+				t.Fatalf("histórico = %+v", disparos)
+			}
+			perdidos := disparos[0]
+			if perdidos.status != "perdida" || perdidos.mensagem != "5 disparo(s) anterior(es) perdido(s) enquanto o daemon estava parado" {
+				t.Errorf("registro dos disparos perdidos: %+v", perdidos)
+			}
+			if disparos[len(disparos)-1] != caso.ultimo {
+				t.Errorf("último registro = %+v, esperava %+v", disparos[len(disparos)-1], caso.ultimo)
+			}
+		})
+	}
+}
+
+func TestAgendadorDentroDaTolerancia(t *testing.T) {
+	abrirBancoDeTeste(t)
+	id := criarAgendamentoDeTeste(t, "*/5 * * * *", perdidasIgnorar, horario(10, 0, 0))
+	// 50s de atraso estão dentro da tolerância: mesmo com 'ignorar', executa normalmente.
+	ag, execucoes := novoAgendadorDeTeste(&relogioFalso{agora: horario(10, 5, 50)})
+	if err := ag.verificar(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*execucoes) != 1 {
+		t.Fatalf("execuções = %d, esperava 1", len(*execucoes))
+	}
+	if disparos := disparosRegistrados(t, id); len(disparos) != 1 || disparos[0].status != "sucesso" {
+		t.Fatalf("histórico inesperado: %+v", disparos)
+	}
+}
+
+func TestAgendadorRodar(t *testing.T) {
+	abrirBancoDeTeste(t)
+	criarAgendamentoDeTeste(t, "*/5 * * * *", perdidasExecutar, horario(10, 0, 0))
+	ctx, cancelar := context.WithCancel(context.Background())
+	defer cancelar()
+
+	// Simula o daemon das 10:00 às 10:16, verificando a cada 30 segundos.
+	relogio := &relogioFalso{agora: horario(10, 0, 0)}
+	relogio.aoEsperar = func(agora time.Time) {
+		if agora.After(horario(10, 16, 0)) {
+			cancelar()
+		}
+	}
+	ag, execucoes := novoAgendadorDeTeste(relogio)
+	if err := ag.rodar(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	esperadas := []time.Time{horario(10, 5, 0), horario(10, 10, 0), horario(10, 15, 0)}
+	if len(*execucoes) != len(esperadas) {
+		t.Fatalf("execuções = %v, esperava %v", *execucoes, esperadas)
+	}
+	for i, execucao := range *execucoes {
+		if !execucao.Equal(esperadas[i]) {
+			t.Errorf("execução %d às %s, esperava %s", i, execucao.Format(time.TimeOnly), esperadas[i].Format(time.TimeOnly))
+		}
+	}
+}
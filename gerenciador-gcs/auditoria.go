@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"   // Gravações dentro de transações.
+	"fmt"            // Impressão da trilha de auditoria.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"os"             // Saída padrão e variáveis de ambiente.
+	"os/user"        // Identificação do usuário do sistema operacional.
+	"text/tabwriter" // Tabela alinhada no console.
+	"time"           // Data/hora de cada evento.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== TRILHA DE AUDITORIA ==============
+
+// usuarioSistema devolve o login do usuário do sistema operacional que está rodando a CLI.
+func usuarioSistema() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if nome := os.Getenv("USER"); nome != "" {
+		return nome
+	}
+	return os.Getenv("USERNAME")
+}
+
+// usuarioAtual devolve o nome do operador identificado ou, se ainda não houver
+// operadores, o usuário do sistema.
+func usuarioAtual() string {
+	if operadorAtual != nil {
+		return operadorAtual.Nome
+	}
+	return usuarioSistema()
+}
+
+// executorSQL é o que o banco e uma transação têm em comum, para que uma gravação possa
+// fazer parte de uma operação maior.
+type executorSQL interface {
+	Exec(consulta string, args ...any) (sql.Result, error)
+}
+
+// registrarAuditoria grava um evento na trilha de auditoria, em nome do usuário atual.
+func registrarAuditoria(acao, detalhes string) error {
+	return registrarAuditoriaEm(bancoDeDados, acao, detalhes)
+}
+
+// registrarAuditoriaEm grava o evento pelo executor informado (o banco ou uma transação).
+func registrarAuditoriaEm(executor executorSQL, acao, detalhes string) error {
+	_, err := executor.Exec("INSERT INTO auditoria(momento, usuario, acao, detalhes) VALUES(?, ?, ?, ?)",
+		time.Now().Format(time.RFC3339), usuarioAtual(), acao, detalhes)
+	return err
+}
+
+// listarAuditoria exibe os eventos mais recentes da trilha de auditoria.
+func listarAuditoria(limite int) error {
+	rows, err := bancoDeDados.Query("SELECT id, momento, usuario, acao, detalhes FROM auditoria ORDER BY id DESC LIMIT ?", limite)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tMOMENTO\tUSUÁRIO\tAÇÃO\tDETALHES")
+	fmt.Fprintln(w, "--\t-------\t-------\t----\t--------")
+	for rows.Next() {
+		var id int
+		var momento, usuario, acao, detalhes string
+		if err := rows.Scan(&id, &momento, &usuario, &acao, &detalhes); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", id, momento, usuario, acao, detalhes)
+	}
+	return w.Flush()
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoAuditoria = &cobra.Command{
+	Use:         "auditoria",
+	Short:       "Exibe a trilha de auditoria.",
+	Aliases:     []string{"audit"},
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		limite, _ := cmd.Flags().GetInt("limite")
+		if err := listarAuditoria(limite); err != nil {
+			log.Fatalf("Erro ao listar auditoria: %v", err)
+		}
+	},
+}
+
+func init() {
+	comandoRaiz.AddCommand(comandoAuditoria)
+	comandoAuditoria.Flags().Int("limite", 50, "Quantidade máxima de eventos exibidos")
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"        // Conexão dedicada ao índice temporário.
+	"errors"         // Identificação da falta do módulo FTS5.
+	"fmt"            // Formatação dos resultados.
+	"io"             // Destino da listagem dos resultados.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"os"             // Saída padrão.
+	"sort"           // Ordenação dos resultados do modo sem FTS5.
+	"strings"        // Normalização dos termos e destaque dos trechos.
+	"text/tabwriter" // Tabela com os resultados de cada tipo.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== DOCUMENTOS PESQUISÁVEIS ==============
+
+// Tipos de entidade que aparecem na busca, na ordem em que são exibidos.
+const (
+	tipoBuscaEquipamento = "equipamento"
+	tipoBuscaGrupo       = "grupo"
+	tipoBuscaNota        = "nota"
+)
+
+// titulosTiposBusca são os cabeçalhos de cada tipo na saída da busca.
+var titulosTiposBusca = map[string]string{
+	tipoBuscaEquipamento: "Equipamentos",
+	tipoBuscaGrupo:       "Grupos de comandos",
+	tipoBuscaNota:        "Notas",
+}
+
+// Marcadores que destacam o termo encontrado nos trechos exibidos.
+const (
+	marcadorInicio = "«"
+	marcadorFim    = "»"
+)
+
+// DocumentoBusca é o texto pesquisável de uma entidade: o título (nome) e o conteúdo.
+type DocumentoBusca struct {
+	Tipo     string
+	RefID    int
+	Titulo   string
+	Conteudo string
+}
+
+// ResultadoBusca é um documento encontrado, com os trechos já destacados.
+type ResultadoBusca struct {
+	Tipo       string
+	RefID      int
+	Titulo     string
+	Trecho     string
+	Relevancia float64 // Quanto menor, mais relevante (mesma convenção do bm25 do SQLite).
+}
+
+// carregarDocumentosBusca monta os documentos de equipamentos (com as tags),
+// grupos de comandos e notas.
+func carregarDocumentosBusca() ([]DocumentoBusca, error) {
+	var documentos []DocumentoBusca
+
+	consultas := []struct {
+		tipo, sql string
+	}{
+		{tipoBuscaEquipamento, `SELECT e.id, COALESCE(e.nome, ''),
+			TRIM(COALESCE(e.ip, '') || ' ' || COALESCE(e.cidade, '') || ' ' || COALESCE(e.tipo, '') || ' ' ||
+			COALESCE(e.vendor, '') || ' ' || COALESCE(e.dev_tipo, '') || ' ' || e.status || ' ' ||
+			COALESCE((SELECT GROUP_CONCAT(t.tag, ' ') FROM equipamentos_tags t WHERE t.equipamento_id = e.id), ''))
+			FROM equipamentos e`},
+		{tipoBuscaGrupo, `SELECT id, COALESCE(nome, ''), REPLACE(COALESCE(comandos, ''), ';', '; ') || ' ' || COALESCE(tipo_comando, '')
+			FROM grupos_comandos`},
+		{tipoBuscaNota, `SELECT n.id, COALESCE(e.nome, 'equipamento ' || n.equipamento_id), n.texto
+			FROM equipamentos_notas n LEFT JOIN equipamentos e ON e.id = n.equipamento_id`},
+	}
+
+	for _, c := range consultas {
+		rows, err := bancoDeDados.Query(c.sql)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			d := DocumentoBusca{Tipo: c.tipo}
+			if err := rows.Scan(&d.RefID, &d.Titulo, &d.Conteudo); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			// Campos vazios deixam espaços repetidos no conteúdo.
+			d.Conteudo = strings.Join(strings.Fields(d.Conteudo), " ")
+			documentos = append(documentos, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return documentos, nil
+}
+
+// ============== BUSCA COM FTS5 ==============
+
+// errSemFTS5 indica que o SQLite foi compilado sem o módulo FTS5.
+// O go-sqlite3 só inclui o FTS5 quando compilado com '-tags sqlite_fts5' (veja o Makefile).
+var errSemFTS5 = errors.New("módulo FTS5 indisponível")
+
+// tamanhoMinimoTermoFTS é o menor termo que o tokenizador 'trigram' consegue encontrar.
+const tamanhoMinimoTermoFTS = 3
+
+// buscarComFTS5 indexa os documentos em uma tabela FTS5 temporária e devolve os que contêm
+// todos os termos, do mais relevante (bm25) para o menos relevante.
+//
+// O índice é temporário de propósito: um índice permanente mantido por triggers quebraria
+// as gravações feitas por um binário compilado sem FTS5. Como a CLI roda um comando por
+// processo, reconstruir o índice a cada busca custa pouco para o tamanho do inventário.
+func buscarComFTS5(documentos []DocumentoBusca, termos []string) ([]ResultadoBusca, error) {
+	ctx := context.Background()
+	// Tabelas temporárias pertencem a uma conexão; por isso usamos sempre a mesma.
+	conexao, err := bancoDeDados.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conexao.Close()
+
+	// O tokenizador 'trigram' permite achar pedaços de palavras (ex: "core" em "sw-core-01")
+	// e de endereços IP, o que os tokenizadores por palavra não fazem.
+	_, err = conexao.ExecContext(ctx, `CREATE VIRTUAL TABLE temp.busca USING fts5(
+		tipo UNINDEXED, ref_id UNINDEXED, titulo, conteudo, tokenize = 'trigram')`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			return nil, errSemFTS5
+		}
+		return nil, err
+	}
+	defer conexao.ExecContext(ctx, "DROP TABLE temp.busca")
+
+	tx, err := conexao.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range documentos {
+		if _, err := tx.Exec("INSERT INTO temp.busca(tipo, ref_id, titulo, conteudo) VALUES(?, ?, ?, ?)",
+			d.Tipo, d.RefID, d.Titulo, d.Conteudo); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Cada termo vira uma frase entre aspas; frases separadas por espaço precisam aparecer todas.
+	frases := make([]string, len(termos))
+	for i, termo := range termos {
+		frases[i] = `"` + strings.ReplaceAll(termo, `"`, `""`) + `"`
+	}
+
+	// O título pesa mais que o conteúdo: achar o termo no nome é mais relevante.
+	rows, err := conexao.QueryContext(ctx, `SELECT tipo, ref_id,
+			highlight(busca, 2, ?, ?),
+			snippet(busca, 3, ?, ?, '…', 64),
+			bm25(busca, 0, 0, 5.0, 1.0) AS relevancia
+		FROM temp.busca WHERE busca MATCH ? ORDER BY relevancia`,
+		marcadorInicio, marcadorFim, marcadorInicio, marcadorFim, strings.Join(frases, " "))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resultados []ResultadoBusca
+	for rows.Next() {
+		var r ResultadoBusca
+		if err := rows.Scan(&r.Tipo, &r.RefID, &r.Titulo, &r.Trecho, &r.Relevancia); err != nil {
+			return nil, err
+		}
+		resultados = append(resultados, r)
+	}
+	return resultados, rows.Err()
+}
+
+// ============== BUSCA SEM FTS5 ==============
+
+// buscarSemFTS5 é a alternativa usada quando o FTS5 não está disponível ou o termo é curto
+// demais para o trigram: procura os termos como substrings (como um LIKE '%termo%') e
+// ordena pela quantidade de ocorrências, valendo mais as do título.
+func buscarSemFTS5(documentos []DocumentoBusca, termos []string) []ResultadoBusca {
+	var resultados []ResultadoBusca
+	for _, d := range documentos {
+		titulo, conteudo := strings.ToLower(d.Titulo), strings.ToLower(d.Conteudo)
+		pontos := 0
+		for _, termo := range termos {
+			noTitulo, noConteudo := strings.Count(titulo, termo), strings.Count(conteudo, termo)
+			if noTitulo+noConteudo == 0 {
+				pontos = 0
+				break
+			}
+			pontos += 5*noTitulo + noConteudo
+		}
+		if pontos == 0 {
+			continue
+		}
+		resultados = append(resultados, ResultadoBusca{
+			Tipo:       d.Tipo,
+			RefID:      d.RefID,
+			Titulo:     destacar(d.Titulo, termos),
+			Trecho:     destacar(d.Conteudo, termos),
+			Relevancia: -float64(pontos),
+		})
+	}
+	sort.SliceStable(resultados, func(i, j int) bool { return resultados[i].Relevancia < resultados[j].Relevancia })
+	return resultados
+}
+
+// destacar envolve as ocorrências dos termos (sem diferenciar maiúsculas) com os marcadores.
+func destacar(texto string, termos []string) string {
+	minusculo := strings.ToLower(texto)
+	if len(minusculo) != len(texto) {
+		return texto // Alguns caracteres mudam de tamanho ao ir para minúsculo; nesse caso não destacamos.
+	}
+	marcado := make([]bool, len(texto))
+	for _, termo := range termos {
+		for inicio := 0; ; {
+			i := strings.Index(minusculo[inicio:], termo)
+			if i < 0 {
+				break
+			}
+			for j := inicio + i; j < inicio+i+len(termo); j++ {
+				marcado[j] = true
+			}
+			inicio += i + len(termo)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(texto); i++ {
+		if marcado[i] && (i == 0 || !marcado[i-1]) {
+			b.WriteString(marcadorInicio)
+		}
+		b.WriteByte(texto[i])
+		if marcado[i] && (i == len(texto)-1 || !marcado[i+1]) {
+			b.WriteString(marcadorFim)
+		}
+	}
+	return b.String()
+}
+
+// ============== BUSCA ==============
+
+// buscar procura os termos em todas as entidades. Usa o FTS5 quando possível e avisa
+// quando precisa recorrer à busca simples.
+func buscar(texto string) ([]ResultadoBusca, error) {
+	termos := strings.Fields(strings.ToLower(texto))
+	if len(termos) == 0 {
+		return nil, fmt.Errorf("informe o que deseja buscar")
+	}
+	documentos, err := carregarDocumentosBusca()
+	if err != nil {
+		return nil, err
+	}
+
+	usarFTS5 := true
+	for _, termo := range termos {
+		if len([]rune(termo)) < tamanhoMinimoTermoFTS {
+			usarFTS5 = false
+		}
+	}
+	if usarFTS5 {
+		resultados, err := buscarComFTS5(documentos, termos)
+		if !errors.Is(err, errSemFTS5) {
+			return resultados, err
+		}
+		fmt.Fprintln(os.Stderr, "AVISO: SQLite sem FTS5 (compile com 'make build' ou '-tags sqlite_fts5'); usando a busca simples.")
+	}
+	return buscarSemFTS5(documentos, termos), nil
+}
+
+// exibirResultadosBusca escreve os resultados agrupados por tipo, respeitando o limite de cada tipo.
+func exibirResultadosBusca(saida io.Writer, resultados []ResultadoBusca, limite int) {
+	porTipo := map[string][]ResultadoBusca{}
+	for _, r := range resultados {
+		porTipo[r.Tipo] = append(porTipo[r.Tipo], r)
+	}
+	if len(resultados) == 0 {
+		fmt.Fprintln(saida, "Nenhum resultado encontrado.")
+		return
+	}
+
+	for _, tipo := range []string{tipoBuscaEquipamento, tipoBuscaGrupo, tipoBuscaNota} {
+		lista := porTipo[tipo]
+		if len(lista) == 0 {
+			continue
+		}
+		fmt.Fprintf(saida, "== %s (%d) ==\n", titulosTiposBusca[tipo], len(lista))
+		if len(lista) > limite {
+			lista = lista[:limite]
+		}
+
+		w := tabwriter.NewWriter(saida, 0, 0, 3, ' ', 0)
+		cabecalho := "NOME"
+		if tipo == tipoBuscaNota {
+			cabecalho = "EQUIPAMENTO"
+		}
+		fmt.Fprintf(w, "ID\t%s\tTRECHO\n", cabecalho)
+		fmt.Fprintf(w, "--\t%s\t------\n", strings.Repeat("-", len(cabecalho)))
+		for _, r := range lista {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", r.RefID, r.Titulo, r.Trecho)
+		}
+		w.Flush()
+		fmt.Fprintln(saida)
+	}
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoBusca = &cobra.Command{
+	Use:   "busca [TERMO...]",
+	Short: "Busca equipamentos, grupos de comandos e notas por qualquer trecho de texto.",
+	Long: `Busca equipamentos (nome, IP, cidade, tipo, vendor, modelo, status e tags),
+grupos de comandos (nome e comandos) e notas. Todos os termos precisam aparecer.
+
+A busca usa o FTS5 do SQLite, com resultados ordenados por relevância. O FTS5
+só existe no binário compilado com '-tags sqlite_fts5', como fazem o 'make build'
+e o 'make test'; sem ele (ou com termos de menos de 3 letras) é usada uma busca
+simples por substring.`,
+	Aliases:     []string{"search"},
+	Args:        cobra.MinimumNArgs(1),
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		limite, _ := cmd.Flags().GetInt("limite")
+
+		resultados, err := buscar(strings.Join(args, " "))
+		if err != nil {
+			log.Fatalf("Erro na busca: %v", err)
+		}
+		exibirResultadosBusca(os.Stdout, resultados, limite)
+	},
+}
+
+func init() {
+	comandoRaiz.AddCommand(comandoBusca)
+	comandoBusca.Flags().Int("limite", 10, "Quantidade máxima de resultados por tipo")
+}
@@ -0,0 +1,58 @@
+//go:build sqlite_fts5
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Estes testes só rodam no binário com FTS5: 'make test' (ou go test -tags sqlite_fts5).
+
+func TestBuscaComFTS5(t *testing.T) {
+	documentos := prepararBuscaDeTeste(t)
+
+	resultados, err := buscarComFTS5(documentos, []string{"core"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// O bm25 com peso 5 no título põe os achados no nome à frente dos achados no conteúdo.
+	if len(resultados) != 4 {
+		t.Fatalf("resultados:\n%s\nesperava 4", strings.Join(resumoResultados(resultados), "\n"))
+	}
+	for i, r := range resultados {
+		noTitulo := strings.Contains(r.Titulo, marcadorInicio)
+		if esperado := i < 2; noTitulo != esperado {
+			t.Fatalf("posição %d (%s): achado no título = %v, esperava %v", i+1, resumoResultados(resultados)[i], noTitulo, esperado)
+		}
+		if i > 0 && r.Relevancia < resultados[i-1].Relevancia {
+			t.Fatalf("resultados fora da ordem de relevância:\n%s", strings.Join(resumoResultados(resultados), "\n"))
+		}
+	}
+
+	// O título e o trecho do conteúdo vêm com o termo destacado.
+	encontrados := map[string]bool{}
+	for _, linha := range resumoResultados(resultados) {
+		encontrados[linha] = true
+	}
+	for _, esperado := range []string{
+		"equipamento: SW-«CORE»-01 / 10.0.0.1 JPA roteador cisco ativo",
+		"grupo: backup-«core» / show running-config; show version show",
+		"equipamento: RT-BORDA-01 / 10.0.0.9 JPA roteador huawei ativo «core»",
+		"nota: OLT-JPA / Uplink migrado para o SW-«CORE»-01.",
+	} {
+		if !encontrados[esperado] {
+			t.Errorf("faltou %q em:\n%s", esperado, strings.Join(resumoResultados(resultados), "\n"))
+		}
+	}
+
+	// Todos os termos precisam aparecer, e o índice temporário da busca anterior já foi
+	// removido (senão o CREATE falharia).
+	resultados, err = buscarComFTS5(documentos, []string{"core", "huawei"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumo := resumoResultados(resultados); len(resumo) != 1 || resumo[0] != "equipamento: RT-BORDA-01 / 10.0.0.9 JPA roteador «huawei» ativo «core»" {
+		t.Fatalf("resultados:\n%s", strings.Join(resumo, "\n"))
+	}
+}
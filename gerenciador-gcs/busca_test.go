@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// prepararBuscaDeTeste cadastra equipamentos, um grupo de comandos e uma nota que mencionam
+// "core" no título ou só no conteúdo, e devolve os documentos pesquisáveis.
+func prepararBuscaDeTeste(t *testing.T) []DocumentoBusca {
+	t.Helper()
+	abrirBancoDeTeste(t)
+	for _, e := range []Equipamento{
+		{Nome: "SW-CORE-01", IP: "10.0.0.1", Vendor: "cisco"},
+		{Nome: "RT-BORDA-01", IP: "10.0.0.9", Vendor: "huawei"},
+		{Nome: "OLT-JPA", IP: "10.0.0.5", Vendor: "zte"},
+	} {
+		if err := adicionarEquipamento(e.Nome, e.IP, "JPA", "roteador", e.Vendor, "", StatusAtivo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	equipamentos, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int{}
+	for _, e := range equipamentos {
+		ids[e.Nome] = e.ID
+	}
+	if err := adicionarTags(ids["RT-BORDA-01"], []string{"core"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := adicionarGrupoComandos("backup-core", "show running-config;show version", "show"); err != nil {
+		t.Fatal(err)
+	}
+	if err := adicionarNota(ids["OLT-JPA"], "Uplink migrado para o SW-CORE-01."); err != nil {
+		t.Fatal(err)
+	}
+
+	documentos, err := carregarDocumentosBusca()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return documentos
+}
+
+// resumoResultados descreve cada resultado como "tipo: título / trecho".
+func resumoResultados(resultados []ResultadoBusca) []string {
+	var resumo []string
+	for _, r := range resultados {
+		resumo = append(resumo, fmt.Sprintf("%s: %s / %s", r.Tipo, r.Titulo, r.Trecho))
+	}
+	return resumo
+}
+
+// semAlinhamento troca as sequências de espaços de cada linha por um só espaço.
+func semAlinhamento(texto string) string {
+	linhas := strings.Split(texto, "\n")
+	for i, linha := range linhas {
+		linhas[i] = strings.Join(strings.Fields(linha), " ")
+	}
+	return strings.Join(linhas, "\n")
+}
+
+func TestBuscaSemFTS5(t *testing.T) {
+	documentos := prepararBuscaDeTeste(t)
+
+	// Os achados no título vêm antes dos achados só no conteúdo.
+	esperados := []string{
+		"equipamento: SW-«CORE»-01 / 10.0.0.1 JPA roteador cisco ativo",
+		"grupo: backup-«core» / show running-config; show version show",
+		"equipamento: RT-BORDA-01 / 10.0.0.9 JPA roteador huawei ativo «core»",
+		"nota: OLT-JPA / Uplink migrado para o SW-«CORE»-01.",
+	}
+	if obtidos := resumoResultados(buscarSemFTS5(documentos, []string{"core"})); strings.Join(obtidos, "\n") != strings.Join(esperados, "\n") {
+		t.Fatalf("resultados:\n%s\nesperava:\n%s", strings.Join(obtidos, "\n"), strings.Join(esperados, "\n"))
+	}
+
+	// Todos os termos precisam aparecer.
+	esperados = []string{"equipamento: RT-BORDA-01 / 10.0.0.9 JPA roteador «huawei» ativo «core»"}
+	if obtidos := resumoResultados(buscarSemFTS5(documentos, []string{"core", "huawei"})); strings.Join(obtidos, "\n") != strings.Join(esperados, "\n") {
+		t.Fatalf("resultados:\n%s\nesperava:\n%s", strings.Join(obtidos, "\n"), strings.Join(esperados, "\n"))
+	}
+}
+
+func TestExibirResultadosBuscaPorTipo(t *testing.T) {
+	documentos := prepararBuscaDeTeste(t)
+	resultados := buscarSemFTS5(documentos, []string{"core"})
+
+	// Os tipos aparecem sempre na mesma ordem, com o total de cada um, mesmo quando o
+	// limite corta a lista. Os espaços do alinhamento das colunas não importam aqui.
+	var saida strings.Builder
+	exibirResultadosBusca(&saida, resultados, 1)
+	esperada := `== Equipamentos (2) ==
+ID   NOME           TRECHO
+--   ----           ------
+1    SW-«CORE»-01   10.0.0.1 JPA roteador cisco ativo
+
+== Grupos de comandos (1) ==
+ID   NOME            TRECHO
+--   ----            ------
+1    backup-«core»   show running-config; show version show
+
+== Notas (1) ==
+ID   EQUIPAMENTO   TRECHO
+--   -----------   ------
+1    OLT-JPA       Uplink migrado para o SW-«CORE»-01.
+
+`
+	if semAlinhamento(saida.String()) != semAlinhamento(esperada) {
+		t.Fatalf("saída:\n%s\nesperava:\n%s", saida.String(), esperada)
+	}
+
+	saida.Reset()
+	exibirResultadosBusca(&saida, nil, 1)
+	if saida.String() != "Nenhum resultado encontrado.\n" {
+		t.Fatalf("saída sem resultados = %q", saida.String())
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"            // Formatação das mensagens.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"os"             // Saída padrão para a tabela de notas.
+	"strconv"        // Conversão do ID informado na linha de comando.
+	"strings"        // Montagem das mensagens e das notas.
+	"text/tabwriter" // Tabela alinhada com as notas.
+	"time"           // Data/hora de cada nota.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== STATUS DO EQUIPAMENTO ==============
+
+// Etapas do ciclo de vida de um equipamento.
+const (
+	StatusPlanejado  = "planejado"
+	StatusAtivo      = "ativo"
+	StatusManutencao = "manutencao"
+	StatusDesativado = "desativado"
+)
+
+// condicaoEquipamentosAtivos é a cláusula SQL que exclui os equipamentos em manutenção
+// ou desativados das listagens e execuções.
+const condicaoEquipamentosAtivos = "status NOT IN ('manutencao', 'desativado')"
+
+// transicoesStatus lista, para cada status, os status para os quais o equipamento pode ir.
+// Um equipamento desativado só volta ao ciclo como planejado (ex: reaproveitado em outro site).
+var transicoesStatus = map[string][]string{
+	StatusPlanejado:  {StatusAtivo, StatusDesativado},
+	StatusAtivo:      {StatusManutencao, StatusDesativado},
+	StatusManutencao: {StatusAtivo, StatusDesativado},
+	StatusDesativado: {StatusPlanejado},
+}
+
+// validarStatus confere se o status é um dos conhecidos.
+func validarStatus(status string) error {
+	if _, ok := transicoesStatus[status]; !ok {
+		return fmt.Errorf("status '%s' inválido (use planejado, ativo, manutencao ou desativado)", status)
+	}
+	return nil
+}
+
+// validarTransicao confere se o equipamento pode passar de um status para outro.
+func validarTransicao(atual, novo string) error {
+	if err := validarStatus(novo); err != nil {
+		return err
+	}
+	if atual == novo {
+		return fmt.Errorf("o equipamento já está com o status '%s'", novo)
+	}
+	for _, permitido := range transicoesStatus[atual] {
+		if permitido == novo {
+			return nil
+		}
+	}
+	return fmt.Errorf("transição de '%s' para '%s' não permitida (permitidas: %s)",
+		atual, novo, strings.Join(transicoesStatus[atual], ", "))
+}
+
+// mudarStatus altera o status do equipamento e registra a mudança como nota e na auditoria.
+// As três gravações acontecem em uma única transação: ou tudo fica registrado, ou nada muda.
+func mudarStatus(id int, novo, motivo string) error {
+	equip, err := buscarEquipamento(id)
+	if err != nil {
+		return err
+	}
+	if err := validarTransicao(equip.Status, novo); err != nil {
+		return err
+	}
+
+	tx, err := bancoDeDados.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A condição sobre o status atual impede que duas mudanças simultâneas partam do
+	// mesmo status e uma delas pule a validação da transição.
+	resultado, err := tx.Exec("UPDATE equipamentos SET status = ? WHERE id = ? AND status = ?", novo, id, equip.Status)
+	if err != nil {
+		return err
+	}
+	if alterados, err := resultado.RowsAffected(); err != nil {
+		return err
+	} else if alterados == 0 {
+		return fmt.Errorf("o status do equipamento %d mudou durante a operação; tente novamente", id)
+	}
+
+	texto := fmt.Sprintf("Status alterado de '%s' para '%s'.", equip.Status, novo)
+	if motivo = strings.TrimSpace(motivo); motivo != "" {
+		texto += " Motivo: " + motivo
+	}
+	if err := adicionarNotaEm(tx, id, texto); err != nil {
+		return err
+	}
+	if err := registrarAuditoriaEm(tx, "equip_status", fmt.Sprintf("equipamento %d (%s): %s", id, equip.Nome, texto)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ============== LÓGICA DE CRUD - NOTAS ==============
+
+// adicionarNota grava uma nota livre no histórico do equipamento.
+func adicionarNota(equipamentoID int, texto string) error {
+	return adicionarNotaEm(bancoDeDados, equipamentoID, texto)
+}
+
+// adicionarNotaEm grava a nota pelo executor informado (o banco ou uma transação).
+func adicionarNotaEm(executor executorSQL, equipamentoID int, texto string) error {
+	if strings.TrimSpace(texto) == "" {
+		return fmt.Errorf("a nota não pode ser vazia")
+	}
+	_, err := executor.Exec("INSERT INTO equipamentos_notas(equipamento_id, momento, autor, texto) VALUES(?, ?, ?, ?)",
+		equipamentoID, time.Now().Format(time.RFC3339), usuarioAtual(), strings.TrimSpace(texto))
+	return err
+}
+
+// listarNotas exibe as notas de um equipamento, das mais recentes para as mais antigas.
+func listarNotas(equipamentoID, limite int) error {
+	rows, err := bancoDeDados.Query("SELECT id, momento, autor, texto FROM equipamentos_notas WHERE equipamento_id = ? ORDER BY id DESC LIMIT ?",
+		equipamentoID, limite)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tMOMENTO\tAUTOR\tNOTA")
+	fmt.Fprintln(w, "--\t-------\t-----\t----")
+	for rows.Next() {
+		var id int
+		var momento, autor, texto string
+		if err := rows.Scan(&id, &momento, &autor, &texto); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", id, momento, autor, texto)
+	}
+	return w.Flush()
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoStatusEquip = &cobra.Command{
+	Use:         "status [ID] [STATUS]",
+	Short:       "Altera o status do equipamento (planejado, ativo, manutencao, desativado).",
+	Args:        cobra.ExactArgs(2),
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		novo := strings.ToLower(strings.TrimSpace(args[1]))
+		motivo, _ := cmd.Flags().GetString("motivo")
+
+		if err := mudarStatus(id, novo, motivo); err != nil {
+			log.Fatalf("Erro ao alterar o status: %v", err)
+		}
+		fmt.Printf("Equipamento com ID %d agora está '%s'.\n", id, novo)
+	},
+}
+
+var comandoNotaEquip = &cobra.Command{
+	Use:   "nota",
+	Short: "Gerencia as notas de manutenção dos equipamentos.",
+}
+
+var comandoAddNota = &cobra.Command{
+	Use:         "add [ID] [TEXTO]",
+	Short:       "Adiciona uma nota ao equipamento.",
+	Args:        cobra.MinimumNArgs(2),
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		if _, err := buscarEquipamento(id); err != nil {
+			log.Fatalf("Erro ao adicionar nota: %v", err)
+		}
+		// Aceita o texto com ou sem aspas.
+		if err := adicionarNota(id, strings.Join(args[1:], " ")); err != nil {
+			log.Fatalf("Erro ao adicionar nota: %v", err)
+		}
+		fmt.Println("Nota adicionada com sucesso!")
+	},
+}
+
+var comandoListNota = &cobra.Command{
+	Use:         "list [ID]",
+	Short:       "Lista as notas do equipamento.",
+	Aliases:     []string{"ls"},
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		limite, _ := cmd.Flags().GetInt("limite")
+		if err := listarNotas(id, limite); err != nil {
+			log.Fatalf("Erro ao listar notas: %v", err)
+		}
+	},
+}
+
+func init() {
+	comandoEquip.AddCommand(comandoStatusEquip, comandoNotaEquip)
+	comandoNotaEquip.AddCommand(comandoAddNota, comandoListNota)
+
+	comandoStatusEquip.Flags().String("motivo", "", "Motivo da mudança (fica registrado nas notas)")
+	comandoListNota.Flags().Int("limite", 50, "Quantidade máxima de notas exibidas")
+}
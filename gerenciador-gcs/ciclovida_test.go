@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidarTransicao(t *testing.T) {
+	casos := []struct {
+		atual, novo, erro string
+	}{
+		{StatusPlanejado, StatusAtivo, ""},
+		{StatusPlanejado, StatusDesativado, ""},
+		{StatusPlanejado, StatusManutencao, "transição de 'planejado' para 'manutencao' não permitida (permitidas: ativo, desativado)"},
+		{StatusAtivo, StatusManutencao, ""},
+		{StatusAtivo, StatusDesativado, ""},
+		{StatusAtivo, StatusPlanejado, "transição de 'ativo' para 'planejado' não permitida (permitidas: manutencao, desativado)"},
+		{StatusManutencao, StatusAtivo, ""},
+		{StatusManutencao, StatusDesativado, ""},
+		{StatusDesativado, StatusPlanejado, ""},
+		{StatusDesativado, StatusAtivo, "transição de 'desativado' para 'ativo' não permitida (permitidas: planejado)"},
+		{StatusAtivo, StatusAtivo, "o equipamento já está com o status 'ativo'"},
+		{StatusAtivo, "quebrado", "status 'quebrado' inválido (use planejado, ativo, manutencao ou desativado)"},
+	}
+	for _, caso := range casos {
+		obtido := ""
+		if err := validarTransicao(caso.atual, caso.novo); err != nil {
+			obtido = err.Error()
+		}
+		if obtido != caso.erro {
+			t.Errorf("%s -> %s: erro %q, esperava %q", caso.atual, caso.novo, obtido, caso.erro)
+		}
+	}
+}
+
+// contarLinhas devolve a quantidade de linhas da tabela.
+func contarLinhas(t *testing.T, tabela string) int {
+	t.Helper()
+	var total int
+	if err := bancoDeDados.QueryRow("SELECT COUNT(*) FROM " + tabela).Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestMudarStatus(t *testing.T) {
+	abrirBancoDeTeste(t)
+	if err := adicionarEquipamento("SW-ACESSO-01", "10.0.0.2", "JPA", "switch", "cisco", "", StatusAtivo); err != nil {
+		t.Fatal(err)
+	}
+	equipamentos, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := equipamentos[0].ID
+	notas, eventos := contarLinhas(t, "equipamentos_notas"), contarLinhas(t, "auditoria")
+
+	if err := mudarStatus(id, StatusManutencao, " troca da fonte "); err != nil {
+		t.Fatal(err)
+	}
+	if equip, _ := buscarEquipamento(id); equip.Status != StatusManutencao {
+		t.Fatalf("status = %s, esperava manutencao", equip.Status)
+	}
+	var texto string
+	if err := bancoDeDados.QueryRow("SELECT texto FROM equipamentos_notas ORDER BY id DESC LIMIT 1").Scan(&texto); err != nil {
+		t.Fatal(err)
+	}
+	if texto != "Status alterado de 'ativo' para 'manutencao'. Motivo: troca da fonte" {
+		t.Fatalf("nota = %q", texto)
+	}
+	if contarLinhas(t, "equipamentos_notas") != notas+1 || contarLinhas(t, "auditoria") != eventos+1 {
+		t.Fatal("a mudança deveria gravar uma nota e um evento de auditoria")
+	}
+
+	// Se a auditoria falha, nem o status nem a nota ficam gravados.
+	if _, err := bancoDeDados.Exec("DROP TABLE auditoria"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mudarStatus(id, StatusAtivo, ""); err == nil || !strings.Contains(err.Error(), "auditoria") {
+		t.Fatalf("esperava a falha da auditoria, veio %v", err)
+	}
+	if equip, _ := buscarEquipamento(id); equip.Status != StatusManutencao {
+		t.Fatalf("status = %s depois da falha, esperava manutencao", equip.Status)
+	}
+	if contarLinhas(t, "equipamentos_notas") != notas+1 {
+		t.Fatal("a nota da mudança que falhou ficou gravada")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"     // Formatação das mensagens de erro.
+	"strconv" // Conversão dos números da expressão.
+	"strings" // Separação dos campos da expressão.
+	"time"    // Cálculo das próximas datas.
+)
+
+// ============== EXPRESSÕES CRON ==============
+//
+// Formato com 5 campos: minuto hora dia-do-mês mês dia-da-semana.
+// Cada campo aceita '*', números, intervalos (1-5), listas (1,3,5) e passos (*/15, 8-18/2).
+// Também são aceitos os atalhos @hourly, @daily, @weekly, @monthly e @yearly.
+
+// ExpressaoCron é uma expressão cron já interpretada.
+type ExpressaoCron struct {
+	texto          string
+	minutos        [60]bool
+	horas          [24]bool
+	diasMes        [32]bool // Índice 0 não é usado.
+	meses          [13]bool // Índice 0 não é usado.
+	diasSemana     [7]bool  // 0 = domingo.
+	diaMesLivre    bool     // O campo dia-do-mês é '*'.
+	diaSemanaLivre bool     // O campo dia-da-semana é '*'.
+}
+
+// atalhosCron traduz os atalhos para a forma com 5 campos.
+var atalhosCron = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// interpretarCron valida e interpreta uma expressão cron.
+func interpretarCron(texto string) (*ExpressaoCron, error) {
+	expressao := strings.TrimSpace(texto)
+	if atalho, ok := atalhosCron[strings.ToLower(expressao)]; ok {
+		expressao = atalho
+	}
+
+	campos := strings.Fields(expressao)
+	if len(campos) != 5 {
+		return nil, fmt.Errorf("expressão cron '%s' deve ter 5 campos (minuto hora dia mês dia-da-semana)", texto)
+	}
+
+	c := &ExpressaoCron{texto: texto}
+	if err := preencherCampoCron(campos[0], 0, 59, c.minutos[:]); err != nil {
+		return nil, fmt.Errorf("minuto: %w", err)
+	}
+	if err := preencherCampoCron(campos[1], 0, 23, c.horas[:]); err != nil {
+		return nil, fmt.Errorf("hora: %w", err)
+	}
+	if err := preencherCampoCron(campos[2], 1, 31, c.diasMes[:]); err != nil {
+		return nil, fmt.Errorf("dia do mês: %w", err)
+	}
+	if err := preencherCampoCron(campos[3], 1, 12, c.meses[:]); err != nil {
+		return nil, fmt.Errorf("mês: %w", err)
+	}
+	// O dia da semana aceita 7 como domingo, então usamos um vetor temporário de 8 posições.
+	var diasSemana [8]bool
+	if err := preencherCampoCron(campos[4], 0, 7, diasSemana[:]); err != nil {
+		return nil, fmt.Errorf("dia da semana: %w", err)
+	}
+	copy(c.diasSemana[:], diasSemana[:7])
+	c.diasSemana[0] = c.diasSemana[0] || diasSemana[7]
+
+	c.diaMesLivre = campos[2] == "*"
+	c.diaSemanaLivre = campos[4] == "*"
+	return c, nil
+}
+
+// preencherCampoCron marca no vetor 'permitidos' os valores aceitos por um campo.
+func preencherCampoCron(campo string, minimo, maximo int, permitidos []bool) error {
+	for _, parte := range strings.Split(campo, ",") {
+		passo := 1
+		if i := strings.Index(parte, "/"); i >= 0 {
+			var err error
+			if passo, err = strconv.Atoi(parte[i+1:]); err != nil || passo <= 0 {
+				return fmt.Errorf("passo inválido em '%s'", parte)
+			}
+			parte = parte[:i]
+		}
+
+		inicio, fim := minimo, maximo
+		switch {
+		case parte == "*":
+			// Mantém o intervalo completo.
+		case strings.Contains(parte, "-"):
+			limites := strings.SplitN(parte, "-", 2)
+			var err1, err2 error
+			inicio, err1 = strconv.Atoi(limites[0])
+			fim, err2 = strconv.Atoi(limites[1])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("intervalo inválido '%s'", parte)
+			}
+		default:
+			valor, err := strconv.Atoi(parte)
+			if err != nil {
+				return fmt.Errorf("valor inválido '%s'", parte)
+			}
+			inicio = valor
+			// "5/10" significa "de 5 até o máximo, de 10 em 10".
+			if passo == 1 {
+				fim = valor
+			}
+		}
+
+		if inicio < minimo || fim > maximo || inicio > fim {
+			return fmt.Errorf("'%s' fora do intervalo %d-%d", parte, minimo, maximo)
+		}
+		for v := inicio; v <= fim; v += passo {
+			permitidos[v] = true
+		}
+	}
+	return nil
+}
+
+// String devolve a expressão original.
+func (c *ExpressaoCron) String() string {
+	return c.texto
+}
+
+// diaPermitido aplica a regra do cron para dia do mês e dia da semana: se os dois
+// estiverem restritos, basta um deles coincidir.
+func (c *ExpressaoCron) diaPermitido(t time.Time) bool {
+	diaMes := c.diasMes[t.Day()]
+	diaSemana := c.diasSemana[int(t.Weekday())]
+	switch {
+	case c.diaMesLivre && c.diaSemanaLivre:
+		return true
+	case c.diaMesLivre:
+		return diaSemana
+	case c.diaSemanaLivre:
+		return diaMes
+	default:
+		return diaMes || diaSemana
+	}
+}
+
+// Proxima devolve o primeiro horário depois de 'apos' que atende à expressão.
+// Devolve o tempo zero se nenhum horário for encontrado nos próximos 5 anos
+// (ex: "0 0 31 2 *").
+func (c *ExpressaoCron) Proxima(apos time.Time) time.Time {
+	t := apos.Truncate(time.Minute).Add(time.Minute)
+	limite := apos.AddDate(5, 0, 0)
+
+	// Avança pulando meses, dias e horas inteiros que não coincidem, para não
+	// precisar testar minuto a minuto.
+	for t.Before(limite) {
+		if !c.meses[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.diaPermitido(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.horas[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutos[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
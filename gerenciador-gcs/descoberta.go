@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"          // Leitura da confirmação do operador.
+	"fmt"            // Formatação das mensagens.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"net"            // Interpretação da faixa CIDR.
+	"os"             // Entrada/saída padrão.
+	"regexp"         // Extração do modelo a partir do sysDescr.
+	"sort"           // Ordenação das propostas por IP.
+	"strings"        // Manipulação dos textos retornados pelo SNMP.
+	"sync"           // Consulta paralela dos endereços.
+	"text/tabwriter" // Tabela com as propostas.
+	"time"           // Timeout das consultas.
+
+	"github.com/gosnmp/gosnmp" // Cliente SNMP v2c/v3.
+	"github.com/spf13/cobra"
+)
+
+// ============== CONSULTA SNMP ==============
+
+// OIDs do grupo 'system' (SNMPv2-MIB) usados na descoberta.
+const (
+	oidSysDescr    = ".1.3.6.1.2.1.1.1.0"
+	oidSysObjectID = ".1.3.6.1.2.1.1.2.0"
+	oidSysName     = ".1.3.6.1.2.1.1.5.0"
+)
+
+// InfoSNMP reúne o que o equipamento informa sobre si mesmo.
+type InfoSNMP struct {
+	IP          string
+	SysDescr    string
+	SysObjectID string
+	SysName     string
+}
+
+// ConsultorSNMP consulta o grupo 'system' de um endereço. A implementação real usa
+// o gosnmp; nos testes pode ser trocada por um agente falso.
+type ConsultorSNMP interface {
+	Consultar(ip string) (InfoSNMP, error)
+}
+
+// ParametrosSNMP são as credenciais e opções de transporte das consultas.
+type ParametrosSNMP struct {
+	Versao     string // "2c" ou "3".
+	Porta      uint16
+	Community  string
+	Timeout    time.Duration
+	Tentativas int
+	// Campos usados apenas no SNMPv3.
+	Usuario   string
+	AuthProto string // MD5, SHA, SHA256, SHA512.
+	AuthSenha string
+	PrivProto string // DES, AES, AES256.
+	PrivSenha string
+}
+
+// consultorGoSNMP implementa ConsultorSNMP com a biblioteca gosnmp.
+type consultorGoSNMP struct {
+	parametros ParametrosSNMP
+}
+
+// protocolosAuth e protocolosPriv traduzem os nomes aceitos na linha de comando.
+var protocolosAuth = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+}
+var protocolosPriv = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192, "AES256": gosnmp.AES256,
+}
+
+// novoCliente monta o cliente gosnmp para um endereço.
+func (c consultorGoSNMP) novoCliente(ip string) (*gosnmp.GoSNMP, error) {
+	p := c.parametros
+	cliente := &gosnmp.GoSNMP{
+		Target:  ip,
+		Port:    p.Porta,
+		Timeout: p.Timeout,
+		Retries: p.Tentativas,
+	}
+
+	switch p.Versao {
+	case "2c":
+		cliente.Version = gosnmp.Version2c
+		cliente.Community = p.Community
+	case "3":
+		cliente.Version = gosnmp.Version3
+		cliente.SecurityModel = gosnmp.UserSecurityModel
+		usm := &gosnmp.UsmSecurityParameters{UserName: p.Usuario}
+		cliente.MsgFlags = gosnmp.NoAuthNoPriv
+
+		if p.AuthProto != "" {
+			proto, ok := protocolosAuth[strings.ToUpper(p.AuthProto)]
+			if !ok {
+				return nil, fmt.Errorf("protocolo de autenticação '%s' inválido", p.AuthProto)
+			}
+			usm.AuthenticationProtocol = proto
+			usm.AuthenticationPassphrase = p.AuthSenha
+			cliente.MsgFlags = gosnmp.AuthNoPriv
+		}
+		if p.PrivProto != "" {
+			if p.AuthProto == "" {
+				return nil, fmt.Errorf("privacidade (--priv-proto) exige autenticação (--auth-proto)")
+			}
+			proto, ok := protocolosPriv[strings.ToUpper(p.PrivProto)]
+			if !ok {
+				return nil, fmt.Errorf("protocolo de privacidade '%s' inválido", p.PrivProto)
+			}
+			usm.PrivacyProtocol = proto
+			usm.PrivacyPassphrase = p.PrivSenha
+			cliente.MsgFlags = gosnmp.AuthPriv
+		}
+		cliente.SecurityParameters = usm
+	default:
+		return nil, fmt.Errorf("versão SNMP '%s' inválida (use 2c ou 3)", p.Versao)
+	}
+	return cliente, nil
+}
+
+// Consultar implementa ConsultorSNMP.
+func (c consultorGoSNMP) Consultar(ip string) (InfoSNMP, error) {
+	info := InfoSNMP{IP: ip}
+	cliente, err := c.novoCliente(ip)
+	if err != nil {
+		return info, err
+	}
+	if err := cliente.Connect(); err != nil {
+		return info, err
+	}
+	defer cliente.Conn.Close()
+
+	resposta, err := cliente.Get([]string{oidSysDescr, oidSysObjectID, oidSysName})
+	if err != nil {
+		return info, err
+	}
+	for _, variavel := range resposta.Variables {
+		switch variavel.Name {
+		case oidSysDescr:
+			info.SysDescr = textoSNMP(variavel.Value)
+		case oidSysObjectID:
+			info.SysObjectID = textoSNMP(variavel.Value)
+		case oidSysName:
+			info.SysName = textoSNMP(variavel.Value)
+		}
+	}
+	if info.SysObjectID == "" && info.SysDescr == "" {
+		return info, fmt.Errorf("o agente não respondeu ao grupo 'system'")
+	}
+	return info, nil
+}
+
+// textoSNMP converte o valor de uma variável SNMP para texto.
+func textoSNMP(valor any) string {
+	switch v := valor.(type) {
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case string:
+		return strings.TrimSpace(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ============== IDENTIFICAÇÃO DO FABRICANTE E DO MODELO ==============
+
+// fabricanteSNMP associa um prefixo de sysObjectID (número da empresa na IANA) a um vendor
+// e às expressões que extraem o modelo do sysDescr.
+type fabricanteSNMP struct {
+	prefixo string
+	vendor  string
+	modelos []*regexp.Regexp // O primeiro grupo de captura é o modelo.
+}
+
+// fabricantesSNMP lista os fabricantes reconhecidos. Os nomes de vendor seguem os usados
+// nos drivers, para que o equipamento descoberto já caia no driver certo.
+var fabricantesSNMP = []fabricanteSNMP{
+	{".1.3.6.1.4.1.2011.", "Huawei", []*regexp.Regexp{
+		regexp.MustCompile(`\b(MA5\d{3}[A-Z]?(?:-X\d+)?|EA5\d{3})\b`),
+		regexp.MustCompile(`\(([A-Z][\w\-]+)\s+V\d{3}R`),
+		regexp.MustCompile(`HUAWEI\s+([\w\-]+)`),
+	}},
+	{".1.3.6.1.4.1.9.", "Cisco", []*regexp.Regexp{
+		regexp.MustCompile(`Cisco IOS Software,\s+(?:IOS-XE Software,\s+)?(\S+)\s+Software`),
+		regexp.MustCompile(`Cisco (?:NX-OS|IOS XR)[^,]*,?\s.*?\b(N\dK|ASR\d+|NCS\d+)`),
+	}},
+	{".1.3.6.1.4.1.3902.", "ZTE", []*regexp.Regexp{
+		regexp.MustCompile(`\b(C[36]\d{2})\b`),
+		regexp.MustCompile(`\b(ZX[A-Z0-9]+(?:\s+[A-Z0-9\-]+)?)`),
+	}},
+	{".1.3.6.1.4.1.6527.", "Nokia", []*regexp.Regexp{
+		regexp.MustCompile(`TiMOS-\S+\s+\S+\s+Nokia\s+([\w\-]+(?:\s+[\w\-]+)?)`),
+		regexp.MustCompile(`Nokia\s+(7\d{3}[\w\- ]*?)\s+Copyright`),
+	}},
+	{".1.3.6.1.4.1.637.", "Nokia", []*regexp.Regexp{
+		regexp.MustCompile(`\b(ISAM\s+\S+|7\d{3}\s+\S+)`),
+	}},
+	{".1.3.6.1.4.1.3709.", "Datacom", []*regexp.Regexp{
+		regexp.MustCompile(`\b(DM\d{4}[\w\-]*)`),
+	}},
+	{".1.3.6.1.4.1.2636.", "Juniper", []*regexp.Regexp{
+		regexp.MustCompile(`Inc\.\s+(\S+)\s+internet router`),
+	}},
+	{".1.3.6.1.4.1.14988.", "MikroTik", []*regexp.Regexp{
+		regexp.MustCompile(`RouterOS\s+(\S+)`),
+	}},
+}
+
+// identificarFabricante devolve o vendor e o modelo a partir do sysObjectID e do sysDescr.
+func identificarFabricante(info InfoSNMP) (vendor, modelo string) {
+	oid := info.SysObjectID
+	if !strings.HasPrefix(oid, ".") {
+		oid = "." + oid
+	}
+	for _, f := range fabricantesSNMP {
+		if !strings.HasPrefix(oid+".", f.prefixo) {
+			continue
+		}
+		for _, re := range f.modelos {
+			if m := re.FindStringSubmatch(info.SysDescr); m != nil {
+				return f.vendor, strings.TrimSpace(m[1])
+			}
+		}
+		return f.vendor, ""
+	}
+	return "", ""
+}
+
+// ============== PROPOSTAS DE ALTERAÇÃO ==============
+
+// PropostaInventario é uma inclusão ou atualização sugerida pela descoberta.
+type PropostaInventario struct {
+	Acao   string // "incluir" ou "atualizar".
+	Atual  Equipamento
+	Nova   Equipamento
+	Campos []string // Campos alterados (apenas para "atualizar").
+}
+
+// enderecosCIDR lista os endereços de host de uma faixa IPv4.
+func enderecosCIDR(cidr string) ([]string, error) {
+	ip, rede, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("faixa '%s' inválida: %w", cidr, err)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("apenas faixas IPv4 são suportadas")
+	}
+	tamanho, bits := rede.Mask.Size()
+	if bits-tamanho > 16 {
+		return nil, fmt.Errorf("faixa '%s' muito grande (máximo /16)", cidr)
+	}
+
+	var enderecos []string
+	for atual := append(net.IP{}, rede.IP.To4()...); rede.Contains(atual); incrementarIP(atual) {
+		enderecos = append(enderecos, atual.String())
+	}
+	// Em faixas maiores que /31, remove o endereço de rede e o de broadcast.
+	if tamanho < 31 && len(enderecos) > 2 {
+		enderecos = enderecos[1 : len(enderecos)-1]
+	}
+	return enderecos, nil
+}
+
+// incrementarIP soma 1 ao endereço, em seu próprio slice.
+func incrementarIP(ip net.IP) {
+	for i := len(ip) - 1; i >= 0; i-- {
+		ip[i]++
+		if ip[i] != 0 {
+			return
+		}
+	}
+}
+
+// descobrirEquipamentos consulta todos os endereços em paralelo e devolve quem respondeu.
+func descobrirEquipamentos(consultor ConsultorSNMP, enderecos []string, paralelismo int) []InfoSNMP {
+	var (
+		mu          sync.Mutex
+		encontrados []InfoSNMP
+		wg          sync.WaitGroup
+	)
+	fila := make(chan string)
+
+	for i := 0; i < paralelismo; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range fila {
+				info, err := consultor.Consultar(ip)
+				if err != nil {
+					continue // Sem resposta: não há agente SNMP ou a community está errada.
+				}
+				mu.Lock()
+				encontrados = append(encontrados, info)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, ip := range enderecos {
+		fila <- ip
+	}
+	close(fila)
+	wg.Wait()
+	return encontrados
+}
+
+// montarPropostas compara o que foi descoberto com o inventário, usando o IP como chave.
+func montarPropostas(encontrados []InfoSNMP, inventario []Equipamento) []PropostaInventario {
+	porIP := map[string]Equipamento{}
+	for _, e := range inventario {
+		porIP[e.IP] = e
+	}
+
+	var propostas []PropostaInventario
+	for _, info := range encontrados {
+		vendor, modelo := identificarFabricante(info)
+		atual, existe := porIP[info.IP]
+
+		if !existe {
+			nova := Equipamento{Nome: info.SysName, IP: info.IP, Vendor: vendor, DevTipo: modelo}
+			propostas = append(propostas, PropostaInventario{Acao: "incluir", Nova: nova})
+			continue
+		}
+
+		// Só propõe alterar os campos para os quais a descoberta trouxe um valor.
+		nova := atual
+		var campos []string
+		if info.SysName != "" && info.SysName != atual.Nome {
+			nova.Nome = info.SysName
+			campos = append(campos, "nome")
+		}
+		if vendor != "" && !strings.EqualFold(vendor, atual.Vendor) {
+			nova.Vendor = vendor
+			campos = append(campos, "vendor")
+		}
+		if modelo != "" && !strings.EqualFold(modelo, atual.DevTipo) {
+			nova.DevTipo = modelo
+			campos = append(campos, "dev_tipo")
+		}
+		if len(campos) > 0 {
+			propostas = append(propostas, PropostaInventario{Acao: "atualizar", Atual: atual, Nova: nova, Campos: campos})
+		}
+	}
+
+	sort.Slice(propostas, func(i, j int) bool {
+		return compararIPs(propostas[i].Nova.IP, propostas[j].Nova.IP)
+	})
+	return propostas
+}
+
+// compararIPs ordena os endereços numericamente.
+func compararIPs(a, b string) bool {
+	ipA, ipB := net.ParseIP(a).To4(), net.ParseIP(b).To4()
+	if ipA == nil || ipB == nil {
+		return a < b
+	}
+	return string(ipA) < string(ipB)
+}
+
+// exibirPropostas mostra as propostas numeradas, para a revisão do operador.
+func exibirPropostas(propostas []PropostaInventario) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "#\tAÇÃO\tIP\tNOME\tVENDOR\tDEV_TIPO\tALTERAÇÕES")
+	fmt.Fprintln(w, "-\t----\t--\t----\t------\t--------\t----------")
+	for i, p := range propostas {
+		alteracoes := "-"
+		if p.Acao == "atualizar" {
+			var partes []string
+			for _, campo := range p.Campos {
+				switch campo {
+				case "nome":
+					partes = append(partes, fmt.Sprintf("nome: %s -> %s", p.Atual.Nome, p.Nova.Nome))
+				case "vendor":
+					partes = append(partes, fmt.Sprintf("vendor: %s -> %s", p.Atual.Vendor, p.Nova.Vendor))
+				case "dev_tipo":
+					partes = append(partes, fmt.Sprintf("dev_tipo: %s -> %s", p.Atual.DevTipo, p.Nova.DevTipo))
+				}
+			}
+			alteracoes = strings.Join(partes, "; ")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, p.Acao, p.Nova.IP, p.Nova.Nome, p.Nova.Vendor, p.Nova.DevTipo, alteracoes)
+	}
+	w.Flush()
+}
+
+// selecionarPropostas pergunta ao operador quais propostas aplicar ("todas", "nenhuma" ou "1,3,5").
+func selecionarPropostas(propostas []PropostaInventario, leitor *bufio.Reader) ([]PropostaInventario, error) {
+	fmt.Print("Aplicar quais propostas? [todas/nenhuma/números separados por vírgula] (nenhuma): ")
+	resposta, _ := leitor.ReadString('\n')
+	resposta = strings.ToLower(strings.TrimSpace(resposta))
+
+	switch resposta {
+	case "", "nenhuma", "n":
+		return nil, nil
+	case "todas", "t", "s":
+		return propostas, nil
+	}
+
+	var escolhidas []PropostaInventario
+	for _, parte := range strings.Split(resposta, ",") {
+		var numero int
+		if _, err := fmt.Sscanf(strings.TrimSpace(parte), "%d", &numero); err != nil || numero < 1 || numero > len(propostas) {
+			return nil, fmt.Errorf("opção '%s' inválida", parte)
+		}
+		escolhidas = append(escolhidas, propostas[numero-1])
+	}
+	return escolhidas, nil
+}
+
+// aplicarPropostas grava no inventário as propostas aprovadas.
+func aplicarPropostas(propostas []PropostaInventario) error {
+	for _, p := range propostas {
+		var err error
+		switch p.Acao {
+		case "incluir":
+			err = adicionarEquipamento(p.Nova.Nome, p.Nova.IP, p.Nova.Cidade, p.Nova.Tipo, p.Nova.Vendor, p.Nova.DevTipo, StatusAtivo)
+		case "atualizar":
+			err = atualizarEquipamento(p.Nova)
+		}
+		if err != nil {
+			return fmt.Errorf("falha ao aplicar a proposta para %s: %w", p.Nova.IP, err)
+		}
+	}
+	if len(propostas) > 0 {
+		return registrarAuditoria("equip_discover", fmt.Sprintf("%d proposta(s) da descoberta SNMP aplicada(s)", len(propostas)))
+	}
+	return nil
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoDiscoverEquip = &cobra.Command{
+	Use:         "discover",
+	Short:       "Descobre equipamentos via SNMP e propõe inclusões/atualizações no inventário.",
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		cidr, _ := cmd.Flags().GetString("cidr")
+		paralelismo, _ := cmd.Flags().GetInt("paralelismo")
+		aplicarTudo, _ := cmd.Flags().GetBool("sim")
+
+		var p ParametrosSNMP
+		p.Versao, _ = cmd.Flags().GetString("versao")
+		p.Porta, _ = cmd.Flags().GetUint16("porta")
+		p.Community, _ = cmd.Flags().GetString("community")
+		p.Timeout, _ = cmd.Flags().GetDuration("timeout")
+		p.Tentativas, _ = cmd.Flags().GetInt("tentativas")
+		p.Usuario, _ = cmd.Flags().GetString("usuario")
+		p.AuthProto, _ = cmd.Flags().GetString("auth-proto")
+		p.AuthSenha, _ = cmd.Flags().GetString("auth-senha")
+		p.PrivProto, _ = cmd.Flags().GetString("priv-proto")
+		p.PrivSenha, _ = cmd.Flags().GetString("priv-senha")
+
+		consultor := consultorGoSNMP{parametros: p}
+		// Valida as credenciais antes de varrer a faixa inteira.
+		if _, err := consultor.novoCliente("127.0.0.1"); err != nil {
+			log.Fatal(err)
+		}
+
+		enderecos, err := enderecosCIDR(cidr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if paralelismo < 1 {
+			paralelismo = 1
+		}
+
+		fmt.Printf("Consultando %d endereço(s) via SNMPv%s...\n", len(enderecos), p.Versao)
+		encontrados := descobrirEquipamentos(consultor, enderecos, paralelismo)
+		fmt.Printf("%d equipamento(s) responderam.\n\n", len(encontrados))
+
+		inventario, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+		if err != nil {
+			log.Fatalf("Erro ao ler o inventário: %v", err)
+		}
+		propostas := montarPropostas(encontrados, inventario)
+		if len(propostas) == 0 {
+			fmt.Println("O inventário já está de acordo com a descoberta. Nada a fazer.")
+			return
+		}
+		exibirPropostas(propostas)
+		fmt.Println()
+
+		// Etapa de revisão: sem --sim, o operador escolhe o que aplicar.
+		escolhidas := propostas
+		if !aplicarTudo {
+			if escolhidas, err = selecionarPropostas(propostas, bufio.NewReader(os.Stdin)); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := aplicarPropostas(escolhidas); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d proposta(s) aplicada(s).\n", len(escolhidas))
+	},
+}
+
+func init() {
+	comandoEquip.AddCommand(comandoDiscoverEquip)
+	f := comandoDiscoverEquip.Flags()
+	f.String("cidr", "", "Faixa a varrer (ex: 10.0.0.0/24)")
+	f.String("versao", "2c", "Versão do SNMP: 2c ou 3")
+	f.Uint16("porta", 161, "Porta UDP do agente SNMP")
+	f.String("community", "public", "Community SNMPv2c")
+	f.Duration("timeout", 2*time.Second, "Tempo máximo de espera por endereço")
+	f.Int("tentativas", 1, "Quantidade de novas tentativas por endereço")
+	f.Int("paralelismo", 32, "Quantidade de endereços consultados ao mesmo tempo")
+	f.String("usuario", "", "Usuário SNMPv3")
+	f.String("auth-proto", "", "Autenticação SNMPv3: MD5, SHA, SHA256, SHA512")
+	f.String("auth-senha", "", "Senha de autenticação SNMPv3")
+	f.String("priv-proto", "", "Privacidade SNMPv3: DES, AES, AES256")
+	f.String("priv-senha", "", "Senha de privacidade SNMPv3")
+	f.Bool("sim", false, "Aplica todas as propostas sem perguntar")
+	comandoDiscoverEquip.MarkFlagRequired("cidr")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// agenteSNMPFalso é um agente SNMPv2c mínimo escutando em UDP local: responde aos GETs
+// do grupo 'system' com os dados informados e ignora pedidos com a community errada,
+// como um agente real.
+type agenteSNMPFalso struct {
+	conexao   *net.UDPConn
+	community string
+	info      InfoSNMP
+}
+
+// iniciarAgenteSNMPFalso escuta em ip:porta (porta 0 escolhe uma livre) até o fim do teste.
+func iniciarAgenteSNMPFalso(t *testing.T, ip string, porta int, community string, info InfoSNMP) *agenteSNMPFalso {
+	t.Helper()
+	conexao, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip), Port: porta})
+	if err != nil {
+		t.Skipf("não foi possível escutar em %s:%d: %v", ip, porta, err)
+	}
+	t.Cleanup(func() { conexao.Close() })
+	agente := &agenteSNMPFalso{conexao: conexao, community: community, info: info}
+	go agente.atender()
+	return agente
+}
+
+// porta devolve a porta UDP em que o agente escuta.
+func (a *agenteSNMPFalso) porta() int {
+	return a.conexao.LocalAddr().(*net.UDPAddr).Port
+}
+
+// atender responde aos pedidos até a conexão ser fechada.
+func (a *agenteSNMPFalso) atender() {
+	decodificador := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: a.community}
+	buffer := make([]byte, 65535)
+	for {
+		n, origem, err := a.conexao.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		pedido, err := decodificador.SnmpDecodePacket(buffer[:n])
+		if err != nil || pedido.PDUType != gosnmp.GetRequest || pedido.Community != a.community {
+			continue
+		}
+
+		resposta := &gosnmp.SnmpPacket{
+			Version:   gosnmp.Version2c,
+			Community: a.community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: pedido.RequestID,
+		}
+		for _, variavel := range pedido.Variables {
+			resposta.Variables = append(resposta.Variables, a.valor(variavel.Name))
+		}
+		if dados, err := resposta.MarshalMsg(); err == nil {
+			a.conexao.WriteToUDP(dados, origem)
+		}
+	}
+}
+
+// valor monta a variável de resposta para um OID do grupo 'system'.
+func (a *agenteSNMPFalso) valor(oid string) gosnmp.SnmpPDU {
+	switch oid {
+	case oidSysDescr:
+		return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.OctetString, Value: []byte(a.info.SysDescr)}
+	case oidSysObjectID:
+		return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.ObjectIdentifier, Value: a.info.SysObjectID}
+	case oidSysName:
+		return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.OctetString, Value: []byte(a.info.SysName)}
+	}
+	return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.NoSuchObject}
+}
+
+// sysDescr reais (resumidos) dos fabricantes reconhecidos.
+const (
+	descrMA5800 = "Huawei Integrated Access Software MA5800-X7 V100R019C10"
+	descrNE40   = "Huawei Versatile Routing Platform Software\r\nVRP (R) software, Version 8.180 (NE40E V800R011C00SPC607B607)\r\nCopyright (C) 2012-2018 Huawei Technologies Co., Ltd.\r\nHUAWEI NE40E-X8A"
+	descrCisco  = "Cisco IOS Software, C2960X Software (C2960X-UNIVERSALK9-M), Version 15.2(7)E4, RELEASE SOFTWARE (fc2)"
+	descrNexus  = "Cisco NX-OS(tm) n9000, Software (n9000-dk9), Version 9.3(8), RELEASE SOFTWARE Copyright (c) 2002-2021 by Cisco Systems, Inc. Compiled 4/22/2021 N9K"
+	descrZTE    = "ZXR10 ROS Version V4.08.23 ZXR10 C300 Software"
+	descrNokia  = "TiMOS-B-20.10.R12 both/x86_64 Nokia 7750 SR Copyright (c) 2000-2021 Nokia."
+	descrDM4100 = "DmOS DM4100 4.4.0"
+	descrMikrot = "RouterOS CCR1036-8G-2S+"
+)
+
+func TestIdentificarFabricante(t *testing.T) {
+	casos := []struct {
+		oid, descr     string
+		vendor, modelo string
+	}{
+		{".1.3.6.1.4.1.2011.2.248", descrMA5800, "Huawei", "MA5800-X7"},
+		{"1.3.6.1.4.1.2011.2.224.279", descrNE40, "Huawei", "NE40E"}, // OID sem o ponto inicial.
+		{".1.3.6.1.4.1.9.1.1208", descrCisco, "Cisco", "C2960X"},
+		{".1.3.6.1.4.1.9.12.3.1.3.1812", descrNexus, "Cisco", "N9K"},
+		{".1.3.6.1.4.1.3902.1082.1001", descrZTE, "ZTE", "C300"},
+		{".1.3.6.1.4.1.6527.1.3.17", descrNokia, "Nokia", "7750 SR"},
+		{".1.3.6.1.4.1.3709.1.2.51", descrDM4100, "Datacom", "DM4100"},
+		{".1.3.6.1.4.1.14988.1", descrMikrot, "MikroTik", "CCR1036-8G-2S+"},
+		{".1.3.6.1.4.1.2011.2.1", "texto sem modelo", "Huawei", ""},
+		// O prefixo precisa casar até o fim do número da empresa: 9 não é 99.
+		{".1.3.6.1.4.1.99.1", descrCisco, "", ""},
+		{"", "", "", ""},
+	}
+	for _, caso := range casos {
+		vendor, modelo := identificarFabricante(InfoSNMP{SysObjectID: caso.oid, SysDescr: caso.descr})
+		if vendor != caso.vendor || modelo != caso.modelo {
+			t.Errorf("identificarFabricante(%s) = (%q, %q), esperava (%q, %q)", caso.oid, vendor, modelo, caso.vendor, caso.modelo)
+		}
+	}
+}
+
+func TestDescobertaComAgenteFalso(t *testing.T) {
+	abrirBancoDeTeste(t)
+
+	// Três agentes no loopback, todos na mesma porta, como equipamentos de uma faixa real:
+	// .2 é uma OLT nova, .3 um switch já cadastrado com dados velhos e .4 um equipamento
+	// de fabricante desconhecido já em dia. Os demais endereços da faixa não respondem.
+	olt := iniciarAgenteSNMPFalso(t, "127.0.0.2", 0, "gcs", InfoSNMP{SysDescr: descrMA5800, SysObjectID: ".1.3.6.1.4.1.2011.2.248", SysName: "OLT-JPA-01"})
+	porta := olt.porta()
+	iniciarAgenteSNMPFalso(t, "127.0.0.3", porta, "gcs", InfoSNMP{SysDescr: descrCisco, SysObjectID: ".1.3.6.1.4.1.9.1.1208", SysName: "SW-JPA-02"})
+	iniciarAgenteSNMPFalso(t, "127.0.0.4", porta, "gcs", InfoSNMP{SysDescr: "Firewall 1.0", SysObjectID: ".1.3.6.1.4.1.99999.1", SysName: "FW-JPA"})
+
+	for _, e := range []Equipamento{
+		{Nome: "sw-jpa-antigo", IP: "127.0.0.3", Cidade: "JPA", Tipo: "switch", Vendor: "cisco"},
+		{Nome: "FW-JPA", IP: "127.0.0.4", Cidade: "JPA", Tipo: "firewall", Vendor: "Fortinet", DevTipo: "FG-100F"},
+	} {
+		if err := adicionarEquipamento(e.Nome, e.IP, e.Cidade, e.Tipo, e.Vendor, e.DevTipo, StatusAtivo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enderecos, err := enderecosCIDR("127.0.0.0/29")
+	if err != nil {
+		t.Fatal(err)
+	}
+	consultor := consultorGoSNMP{parametros: ParametrosSNMP{Versao: "2c", Porta: uint16(porta), Community: "gcs", Timeout: 300 * time.Millisecond}}
+	encontrados := descobrirEquipamentos(consultor, enderecos, 4)
+	if len(encontrados) != 3 {
+		t.Fatalf("encontrados = %+v, esperava os 3 agentes", encontrados)
+	}
+
+	inventario, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	propostas := montarPropostas(encontrados, inventario)
+	esperadas := []string{
+		"incluir 127.0.0.2 OLT-JPA-01 Huawei MA5800-X7 []",
+		// O vendor só muda de caixa: não é proposto.
+		"atualizar 127.0.0.3 SW-JPA-02 cisco C2960X [nome dev_tipo]",
+	}
+	if len(propostas) != len(esperadas) {
+		t.Fatalf("propostas = %+v", propostas)
+	}
+	for i, p := range propostas {
+		obtida := fmt.Sprintf("%s %s %s %s %s %v", p.Acao, p.Nova.IP, p.Nova.Nome, p.Nova.Vendor, p.Nova.DevTipo, p.Campos)
+		if obtida != esperadas[i] {
+			t.Errorf("proposta %d = %q, esperava %q", i+1, obtida, esperadas[i])
+		}
+	}
+	if atual := propostas[1].Atual; atual.Nome != "sw-jpa-antigo" || atual.Cidade != "JPA" {
+		t.Errorf("a atualização perdeu os dados atuais: %+v", atual)
+	}
+
+	// Depois de aplicadas, uma nova descoberta não tem mais nada a propor.
+	if err := aplicarPropostas(propostas); err != nil {
+		t.Fatal(err)
+	}
+	inventario, err = buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inventario) != 3 {
+		t.Fatalf("inventário = %+v", inventario)
+	}
+	if propostas := montarPropostas(descobrirEquipamentos(consultor, enderecos, 4), inventario); len(propostas) != 0 {
+		t.Fatalf("propostas depois de aplicar: %+v", propostas)
+	}
+}
+
+func TestDescobertaCommunityErrada(t *testing.T) {
+	agente := iniciarAgenteSNMPFalso(t, "127.0.0.1", 0, "gcs", InfoSNMP{SysDescr: descrCisco, SysObjectID: ".1.3.6.1.4.1.9.1.1208"})
+	consultor := consultorGoSNMP{parametros: ParametrosSNMP{Versao: "2c", Porta: uint16(agente.porta()), Community: "public", Timeout: 200 * time.Millisecond}}
+
+	// O agente ignora o pedido: a consulta termina por tempo esgotado.
+	if info, err := consultor.Consultar("127.0.0.1"); err == nil {
+		t.Fatalf("esperava erro com a community errada, veio %+v", info)
+	}
+}
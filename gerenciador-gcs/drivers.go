@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"     // Formatação das mensagens de erro.
+	"os"      // Leitura do arquivo YAML com os drivers personalizados.
+	"regexp"  // Expressões regulares usadas para reconhecer os prompts dos equipamentos.
+	"strings" // Normalização dos nomes de vendor e dev_tipo.
+
+	"gopkg.in/yaml.v3" // Interpretação do arquivo de drivers personalizados.
+)
+
+// ============== INTERFACE DOS DRIVERS ==============
+
+// Driver descreve como conversar com um equipamento de um determinado fabricante:
+// qual é o prompt, como desligar a paginação e como entrar/sair do modo de configuração.
+// Qualquer tipo que implemente esses métodos pode ser registrado no registro de drivers.
+type Driver interface {
+	// Nome identifica o driver (ex: "huawei", "cisco").
+	Nome() string
+	// Preparar aguarda o primeiro prompt, entra no modo privilegiado (se houver)
+	// e desabilita a paginação da saída.
+	Preparar(s Sessao, senhaEnable string) error
+	// Executar envia um comando e devolve apenas a saída dele, sem o eco e sem o prompt.
+	Executar(s Sessao, comando string) (string, error)
+	// EntrarModoConfiguracao entra no modo de configuração (system-view, configure terminal...).
+	EntrarModoConfiguracao(s Sessao) error
+	// SairModoConfiguracao volta ao modo privilegiado ao final de um grupo de configuração.
+	SairModoConfiguracao(s Sessao) error
+}
+
+// DriverVizinhos é implementado pelos drivers que sabem listar os vizinhos LLDP/CDP
+// do equipamento. É opcional: drivers sem suporte são ignorados pelo comando 'topologia'.
+type DriverVizinhos interface {
+	// ConsultaVizinhos devolve o comando, o template que interpreta sua saída e o protocolo.
+	// Um comando vazio indica que o driver não sabe consultar os vizinhos.
+	ConsultaVizinhos() (comando, template, protocolo string)
+}
+
+// DefinicaoDriver é a implementação de Driver orientada a dados. Os drivers embutidos e
+// os drivers definidos em YAML usam a mesma estrutura, mudando apenas os valores.
+type DefinicaoDriver struct {
+	NomeDriver           string   `yaml:"nome"`
+	Vendors              []string `yaml:"vendors"`               // Valores da coluna 'vendor' atendidos pelo driver.
+	DevTipos             []string `yaml:"dev_tipos"`             // Valores da coluna 'dev_tipo' (têm prioridade sobre o vendor).
+	Prompt               string   `yaml:"prompt"`                // Regex que reconhece qualquer prompt do equipamento.
+	PromptSenha          string   `yaml:"prompt_senha"`          // Regex do pedido de senha do modo privilegiado.
+	ComandoPrivilegiado  string   `yaml:"comando_privilegiado"`  // Ex: "enable". Vazio se não existir.
+	PromptPrivilegiado   string   `yaml:"prompt_privilegiado"`   // Regex que indica que já estamos no modo privilegiado.
+	DesabilitarPaginacao []string `yaml:"desabilitar_paginacao"` // Ex: "terminal length 0".
+	ComandoConfiguracao  string   `yaml:"comando_configuracao"`  // Ex: "system-view", "configure terminal".
+	SairConfiguracao     string   `yaml:"sair_configuracao"`     // Ex: "return", "end".
+	ComandoVizinhos      string   `yaml:"comando_vizinhos"`      // Ex: "show cdp neighbors detail". Vazio se não houver.
+	TemplateVizinhos     string   `yaml:"template_vizinhos"`     // Template que interpreta a saída do comando de vizinhos.
+	ProtocoloVizinhos    string   `yaml:"protocolo_vizinhos"`    // "lldp" ou "cdp".
+
+	// Versões compiladas das expressões regulares, preenchidas por 'compilar'.
+	rePrompt             *regexp.Regexp
+	rePromptSenha        *regexp.Regexp
+	rePromptPrivilegiado *regexp.Regexp
+}
+
+// compilar valida e compila as expressões regulares da definição.
+func (d *DefinicaoDriver) compilar() error {
+	if d.NomeDriver == "" {
+		return fmt.Errorf("driver sem nome")
+	}
+	if d.Prompt == "" {
+		return fmt.Errorf("driver '%s' sem regex de prompt", d.NomeDriver)
+	}
+
+	var err error
+	if d.rePrompt, err = regexp.Compile(d.Prompt); err != nil {
+		return fmt.Errorf("driver '%s': regex de prompt inválida: %w", d.NomeDriver, err)
+	}
+
+	// Se não for informado, usamos o pedido de senha mais comum.
+	if d.PromptSenha == "" {
+		d.PromptSenha = `(?i)password:\s*$`
+	}
+	if d.rePromptSenha, err = regexp.Compile(d.PromptSenha); err != nil {
+		return fmt.Errorf("driver '%s': regex de prompt de senha inválida: %w", d.NomeDriver, err)
+	}
+
+	if d.PromptPrivilegiado != "" {
+		if d.rePromptPrivilegiado, err = regexp.Compile(d.PromptPrivilegiado); err != nil {
+			return fmt.Errorf("driver '%s': regex de prompt privilegiado inválida: %w", d.NomeDriver, err)
+		}
+	}
+	return nil
+}
+
+// Nome devolve o nome do driver.
+func (d *DefinicaoDriver) Nome() string {
+	return d.NomeDriver
+}
+
+// Preparar implementa Driver.
+func (d *DefinicaoDriver) Preparar(s Sessao, senhaEnable string) error {
+	// Aguarda o prompt inicial, descartando o banner de login.
+	saida, err := s.LerAte(d.rePrompt)
+	if err != nil {
+		return fmt.Errorf("prompt inicial não reconhecido: %w", err)
+	}
+
+	// Só entra no modo privilegiado se o driver tiver um comando para isso
+	// e o prompt atual ainda não for o privilegiado.
+	if d.ComandoPrivilegiado != "" && (d.rePromptPrivilegiado == nil || !d.rePromptPrivilegiado.MatchString(saida)) {
+		if err := d.entrarModoPrivilegiado(s, senhaEnable); err != nil {
+			return err
+		}
+	}
+
+	for _, comando := range d.DesabilitarPaginacao {
+		if _, err := d.Executar(s, comando); err != nil {
+			return fmt.Errorf("falha ao desabilitar paginação com '%s': %w", comando, err)
+		}
+	}
+	return nil
+}
+
+// entrarModoPrivilegiado envia o comando privilegiado e responde ao pedido de senha, se houver.
+func (d *DefinicaoDriver) entrarModoPrivilegiado(s Sessao, senhaEnable string) error {
+	if err := s.Enviar(d.ComandoPrivilegiado); err != nil {
+		return err
+	}
+
+	// O equipamento pode responder com o prompt ou com um pedido de senha.
+	qualquer := regexp.MustCompile(d.rePrompt.String() + "|" + d.rePromptSenha.String())
+	saida, err := s.LerAte(qualquer)
+	if err != nil {
+		return fmt.Errorf("falha ao entrar no modo privilegiado: %w", err)
+	}
+
+	if d.rePromptSenha.MatchString(saida) {
+		if senhaEnable == "" {
+			return fmt.Errorf("o equipamento pediu senha de enable, mas 'senha_enable' não está configurada")
+		}
+		if err := s.Enviar(senhaEnable); err != nil {
+			return err
+		}
+		if saida, err = s.LerAte(d.rePrompt); err != nil {
+			return fmt.Errorf("falha ao entrar no modo privilegiado: %w", err)
+		}
+	}
+
+	if d.rePromptPrivilegiado != nil && !d.rePromptPrivilegiado.MatchString(saida) {
+		return fmt.Errorf("modo privilegiado recusado pelo equipamento")
+	}
+	return nil
+}
+
+// Executar implementa Driver.
+func (d *DefinicaoDriver) Executar(s Sessao, comando string) (string, error) {
+	if err := s.Enviar(comando); err != nil {
+		return "", err
+	}
+	saida, err := s.LerAte(d.rePrompt)
+	if err != nil {
+		return "", err
+	}
+	return limparSaida(saida, comando, d.rePrompt), nil
+}
+
+// EntrarModoConfiguracao implementa Driver.
+func (d *DefinicaoDriver) EntrarModoConfiguracao(s Sessao) error {
+	if d.ComandoConfiguracao == "" {
+		return nil
+	}
+	_, err := d.Executar(s, d.ComandoConfiguracao)
+	return err
+}
+
+// SairModoConfiguracao implementa Driver.
+func (d *DefinicaoDriver) SairModoConfiguracao(s Sessao) error {
+	if d.SairConfiguracao == "" {
+		return nil
+	}
+	_, err := d.Executar(s, d.SairConfiguracao)
+	return err
+}
+
+// ConsultaVizinhos implementa DriverVizinhos.
+func (d *DefinicaoDriver) ConsultaVizinhos() (comando, template, protocolo string) {
+	return d.ComandoVizinhos, d.TemplateVizinhos, d.ProtocoloVizinhos
+}
+
+// limparSaida remove o eco do comando (primeira linha) e o prompt (última linha) da saída bruta.
+func limparSaida(saida, comando string, prompt *regexp.Regexp) string {
+	saida = strings.ReplaceAll(saida, "\r\n", "\n")
+	saida = strings.ReplaceAll(saida, "\r", "")
+
+	// Remove o prompt que fica no final da saída.
+	if loc := prompt.FindAllStringIndex(saida, -1); len(loc) > 0 {
+		saida = saida[:loc[len(loc)-1][0]]
+	}
+
+	// Remove o eco do comando, que o equipamento devolve na primeira linha.
+	linhas := strings.Split(saida, "\n")
+	if len(linhas) > 0 && strings.Contains(linhas[0], strings.TrimSpace(comando)) {
+		linhas = linhas[1:]
+	}
+	return strings.TrimRight(strings.Join(linhas, "\n"), "\n ")
+}
+
+// ============== DRIVERS EMBUTIDOS ==============
+
+// driversEmbutidos são os drivers para os fabricantes mais comuns do Datacenter.
+// Os drivers de 'dev_tipo' (ex: OLTs) vêm antes para terem prioridade sobre os de vendor.
+var driversEmbutidos = []*DefinicaoDriver{
+	{
+		// OLTs Huawei (MA5600T/MA5800) usam prompt no estilo "MA5800>" / "MA5800(config)#".
+		NomeDriver:           "huawei-olt",
+		DevTipos:             []string{"MA5600", "MA5600T", "MA5608T", "MA5680T", "MA5800", "MA5800-X7", "MA5800-X15", "MA5800-X17", "EA5800"},
+		Prompt:               `(?m)^[\w.\-]+(\([\w\-/:]+\))?[>#]\s*$`,
+		ComandoPrivilegiado:  "enable",
+		PromptPrivilegiado:   `(?m)#\s*$`,
+		DesabilitarPaginacao: []string{"scroll 512", "undo smart"},
+		ComandoConfiguracao:  "config",
+		SairConfiguracao:     "quit",
+	},
+	{
+		// Roteadores e switches Huawei (VRP) usam "<HOST>" e "[HOST]" no modo system-view.
+		NomeDriver:           "huawei",
+		Vendors:              []string{"huawei"},
+		Prompt:               `(?m)^[<\[][~*]?[\w.\-/:]+[>\]]\s*$`,
+		DesabilitarPaginacao: []string{"screen-length 0 temporary"},
+		ComandoConfiguracao:  "system-view",
+		SairConfiguracao:     "return",
+		ComandoVizinhos:      "display lldp neighbor brief",
+		TemplateVizinhos:     "huawei_display_lldp_neighbor_brief",
+		ProtocoloVizinhos:    "lldp",
+	},
+	{
+		NomeDriver:           "cisco",
+		Vendors:              []string{"cisco"},
+		Prompt:               `(?m)^[\w.\-/:]+(\([\w\-/:]+\))?[>#]\s*$`,
+		ComandoPrivilegiado:  "enable",
+		PromptPrivilegiado:   `(?m)#\s*$`,
+		DesabilitarPaginacao: []string{"terminal length 0"},
+		ComandoConfiguracao:  "configure terminal",
+		SairConfiguracao:     "end",
+		ComandoVizinhos:      "show cdp neighbors detail",
+		TemplateVizinhos:     "cisco_show_cdp_neighbors_detail",
+		ProtocoloVizinhos:    "cdp",
+	},
+	{
+		NomeDriver:           "zte",
+		Vendors:              []string{"zte"},
+		Prompt:               `(?m)^[\w.\-/:]+(\([\w\-/:]+\))?[>#]\s*$`,
+		ComandoPrivilegiado:  "enable",
+		PromptPrivilegiado:   `(?m)#\s*$`,
+		DesabilitarPaginacao: []string{"terminal length 0"},
+		ComandoConfiguracao:  "configure terminal",
+		SairConfiguracao:     "end",
+	},
+	{
+		// Nokia SR OS (CLI clássica): prompts como "A:ROUTER#" ou "*A:ROUTER>config#".
+		NomeDriver:           "nokia",
+		Vendors:              []string{"nokia", "alcatel", "alcatel-lucent"},
+		Prompt:               `(?m)^\*?[AB]:[\w.\-/:>]+[#$]\s*$`,
+		DesabilitarPaginacao: []string{"environment no more"},
+		ComandoConfiguracao:  "configure",
+		SairConfiguracao:     "exit all",
+		ComandoVizinhos:      "show system lldp neighbor",
+		TemplateVizinhos:     "nokia_show_system_lldp_neighbor",
+		ProtocoloVizinhos:    "lldp",
+	},
+	{
+		// Driver genérico, usado quando nenhum outro atende ao equipamento.
+		NomeDriver: nomeDriverGenerico,
+		Prompt:     `(?m)^[\w.\-/:@()\[\]<~*]+[>#$%\]]\s*$`,
+	},
+}
+
+// nomeDriverGenerico é o driver usado como último recurso.
+const nomeDriverGenerico = "generico"
+
+// ============== REGISTRO DE DRIVERS ==============
+
+// registroDrivers guarda os drivers disponíveis, indexados por nome, dev_tipo e vendor.
+type registroDrivers struct {
+	porNome    map[string]Driver
+	porDevTipo map[string]Driver
+	porVendor  map[string]Driver
+}
+
+// drivers é o registro global, preenchido com os embutidos e, depois, com os personalizados.
+var drivers = novoRegistroDrivers()
+
+// novoRegistroDrivers cria um registro já contendo os drivers embutidos.
+func novoRegistroDrivers() *registroDrivers {
+	r := &registroDrivers{
+		porNome:    map[string]Driver{},
+		porDevTipo: map[string]Driver{},
+		porVendor:  map[string]Driver{},
+	}
+	for _, d := range driversEmbutidos {
+		if err := d.compilar(); err != nil {
+			// Os drivers embutidos são fixos no código; um erro aqui é um bug.
+			panic(err)
+		}
+		r.registrar(d, d.Vendors, d.DevTipos)
+	}
+	return r
+}
+
+// normalizarChave deixa vendor e dev_tipo comparáveis independentemente de maiúsculas e espaços.
+func normalizarChave(valor string) string {
+	return strings.ToLower(strings.TrimSpace(valor))
+}
+
+// registrar adiciona (ou substitui) um driver no registro.
+func (r *registroDrivers) registrar(d Driver, vendors, devTipos []string) {
+	r.porNome[d.Nome()] = d
+	for _, v := range vendors {
+		r.porVendor[normalizarChave(v)] = d
+	}
+	for _, t := range devTipos {
+		r.porDevTipo[normalizarChave(t)] = d
+	}
+}
+
+// resolver escolhe o driver de um equipamento: primeiro pelo dev_tipo, depois pelo vendor
+// e, por fim, o driver genérico.
+func (r *registroDrivers) resolver(vendor, devTipo string) Driver {
+	if d, ok := r.porDevTipo[normalizarChave(devTipo)]; ok {
+		return d
+	}
+	if d, ok := r.porVendor[normalizarChave(vendor)]; ok {
+		return d
+	}
+	return r.porNome[nomeDriverGenerico]
+}
+
+// carregarDriversPersonalizados lê um arquivo YAML com uma lista de drivers e os registra.
+// Um driver personalizado com o mesmo vendor/dev_tipo de um embutido o substitui.
+//
+// Exemplo de arquivo:
+//
+//	drivers:
+//	  - nome: datacom
+//	    vendors: [datacom]
+//	    prompt: '(?m)^[\w.\-]+(\(config[\w\-]*\))?#\s*$'
+//	    desabilitar_paginacao: ["paginate false"]
+//	    comando_configuracao: config
+//	    sair_configuracao: end
+//	    comando_vizinhos: show lldp neighbors
+//	    template_vizinhos: datacom_show_lldp_neighbors
+//	    protocolo_vizinhos: lldp
+func (r *registroDrivers) carregarDriversPersonalizados(caminho string) error {
+	dados, err := os.ReadFile(caminho)
+	if err != nil {
+		return err
+	}
+
+	var arquivo struct {
+		Drivers []*DefinicaoDriver `yaml:"drivers"`
+	}
+	if err := yaml.Unmarshal(dados, &arquivo); err != nil {
+		return fmt.Errorf("arquivo de drivers '%s' inválido: %w", caminho, err)
+	}
+
+	for _, d := range arquivo.Drivers {
+		if err := d.compilar(); err != nil {
+			return err
+		}
+		r.registrar(d, d.Vendors, d.DevTipos)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Cada driver embutido é exercitado do login à saída do modo de configuração: o roteiro
+// só aceita a sequência exata de comandos (enable, paginação, system-view...).
+func TestDriversEmbutidos(t *testing.T) {
+	casos := []struct {
+		driver      string
+		senhaEnable string
+		inicial     string
+		passos      []passoRoteiro
+		comando     string
+		saida       string
+	}{
+		{
+			driver:  "huawei-olt",
+			inicial: "\r\nUser name:admin\r\nUser password:\r\n\r\n  Huawei Integrated Access Software (MA5800).\r\nOLT-JPA>",
+			passos: []passoRoteiro{
+				{"enable", "OLT-JPA#"},
+				{"scroll 512", "OLT-JPA#"},
+				{"undo smart", "OLT-JPA#"},
+				{"config", "OLT-JPA(config)#"},
+				{"display ont info 0 1 0 all", "  F/S/P   ONT  SN\r\n  0/1/0   1    485754430A1B2C3D\r\nOLT-JPA(config)#"},
+				{"quit", "OLT-JPA#"},
+			},
+			comando: "display ont info 0 1 0 all",
+			saida:   "  F/S/P   ONT  SN\n  0/1/0   1    485754430A1B2C3D",
+		},
+		{
+			driver:  "huawei",
+			inicial: "Info: The max number of VTY users is 10.\r\n<CORE-01>",
+			passos: []passoRoteiro{
+				{"screen-length 0 temporary", "Info: The configuration takes effect on the current user terminal interface only.\r\n<CORE-01>"},
+				{"system-view", "Enter system view, return user view with return command.\r\n[~CORE-01]"},
+				{"display current-configuration | include sysname", " sysname CORE-01\r\n[~CORE-01]"},
+				{"return", "<CORE-01>"},
+			},
+			comando: "display current-configuration | include sysname",
+			saida:   " sysname CORE-01",
+		},
+		{
+			driver:      "cisco",
+			senhaEnable: "segredo",
+			inicial:     "\r\nUser Access Verification\r\n\r\nSW-ACESSO>",
+			passos: []passoRoteiro{
+				{"enable", "Password: "},
+				{"segredo", "SW-ACESSO#"},
+				{"terminal length 0", "SW-ACESSO#"},
+				{"configure terminal", "Enter configuration commands, one per line.  End with CNTL/Z.\r\nSW-ACESSO(config)#"},
+				{"do show clock", "*10:15:00.123 BRT Mon Oct 19 2026\r\nSW-ACESSO(config)#"},
+				{"end", "SW-ACESSO#"},
+			},
+			comando: "do show clock",
+			saida:   "*10:15:00.123 BRT Mon Oct 19 2026",
+		},
+		{
+			// Usuário que já entra privilegiado: o driver não deve mandar 'enable'.
+			driver:  "zte",
+			inicial: "ZXR10#",
+			passos: []passoRoteiro{
+				{"terminal length 0", "ZXR10#"},
+				{"configure terminal", "ZXR10(config)#"},
+				{"hostname ZXR10", "ZXR10(config)#"},
+				{"end", "ZXR10#"},
+			},
+			comando: "hostname ZXR10",
+			saida:   "",
+		},
+		{
+			driver:  "nokia",
+			inicial: "SR OS Software\r\nA:PE-01#",
+			passos: []passoRoteiro{
+				{"environment no more", "A:PE-01#"},
+				{"configure", "*A:PE-01>config#"},
+				{"info", "    system\r\n        name \"PE-01\"\r\n    exit\r\n*A:PE-01>config#"},
+				{"exit all", "*A:PE-01#"},
+			},
+			comando: "info",
+			saida:   "    system\n        name \"PE-01\"\n    exit",
+		},
+		{
+			// O genérico não tem paginação nem modo de configuração: só executa.
+			driver:  nomeDriverGenerico,
+			inicial: "Last login: Mon Oct 19\r\nadmin@firewall:~$",
+			passos: []passoRoteiro{
+				{"uptime", " 10:15:00 up 42 days\r\nadmin@firewall:~$"},
+			},
+			comando: "uptime",
+			saida:   " 10:15:00 up 42 days",
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.driver, func(t *testing.T) {
+			sessao := novaSessaoRoteirizada(caso.inicial, caso.passos...)
+			resultados, err := executarComandos(sessao, drivers.porNome[caso.driver], []string{caso.comando}, true, caso.senhaEnable)
+			if err != nil {
+				t.Fatalf("executarComandos: %v (enviados: %q)", err, sessao.enviados)
+			}
+			sessao.conferirRoteiro(t)
+			if len(resultados) != 1 || resultados[0].Saida != caso.saida {
+				t.Fatalf("saída de %q = %+v, esperava %q", caso.comando, resultados, caso.saida)
+			}
+		})
+	}
+}
+
+func TestDriverSemSenhaEnable(t *testing.T) {
+	sessao := novaSessaoRoteirizada("SW-ACESSO>", passoRoteiro{"enable", "Password: "})
+	err := drivers.porNome["cisco"].Preparar(sessao, "")
+	if err == nil || !strings.Contains(err.Error(), "senha_enable") {
+		t.Fatalf("esperava erro pedindo 'senha_enable', veio %v", err)
+	}
+}
+
+func TestDriverEnableRecusado(t *testing.T) {
+	sessao := novaSessaoRoteirizada("SW-ACESSO>",
+		passoRoteiro{"enable", "Password: "},
+		passoRoteiro{"errada", "% Access denied\r\nSW-ACESSO>"},
+	)
+	err := drivers.porNome["cisco"].Preparar(sessao, "errada")
+	if err == nil || !strings.Contains(err.Error(), "recusado") {
+		t.Fatalf("esperava modo privilegiado recusado, veio %v", err)
+	}
+}
+
+func TestResolverDriver(t *testing.T) {
+	casos := []struct{ vendor, devTipo, esperado string }{
+		{"Huawei", "MA5800-X7", "huawei-olt"}, // dev_tipo tem prioridade sobre o vendor.
+		{" HUAWEI ", "NE40E", "huawei"},
+		{"Cisco", "", "cisco"},
+		{"Alcatel-Lucent", "", "nokia"},
+		{"Mikrotik", "", nomeDriverGenerico},
+	}
+	for _, caso := range casos {
+		if d := drivers.resolver(caso.vendor, caso.devTipo); d.Nome() != caso.esperado {
+			t.Errorf("resolver(%q, %q) = %s, esperava %s", caso.vendor, caso.devTipo, d.Nome(), caso.esperado)
+		}
+	}
+}
+
+func TestDriversPersonalizados(t *testing.T) {
+	arquivo := filepath.Join(t.TempDir(), "drivers.yaml")
+	conteudo := `drivers:
+  - nome: datacom
+    vendors: [datacom]
+    prompt: '(?m)^[\w.\-]+(\(config[\w\-]*\))?#\s*$'
+    desabilitar_paginacao: ["paginate false"]
+    comando_configuracao: config
+    sair_configuracao: end
+  - nome: cisco-nexus
+    vendors: [cisco]
+    prompt: '(?m)^[\w.\-]+(\(config[\w\-]*\))?#\s*$'
+    desabilitar_paginacao: ["terminal length 0"]
+`
+	if err := os.WriteFile(arquivo, []byte(conteudo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registro := novoRegistroDrivers()
+	if err := registro.carregarDriversPersonalizados(arquivo); err != nil {
+		t.Fatalf("carregarDriversPersonalizados: %v", err)
+	}
+
+	// Um driver personalizado com o vendor de um embutido o substitui.
+	if d := registro.resolver("cisco", ""); d.Nome() != "cisco-nexus" {
+		t.Errorf("vendor cisco resolveu para %s", d.Nome())
+	}
+
+	sessao := novaSessaoRoteirizada("DM4100#",
+		passoRoteiro{"paginate false", "DM4100#"},
+		passoRoteiro{"config", "DM4100(config)#"},
+		passoRoteiro{"hostname DM4100", "DM4100(config)#"},
+		passoRoteiro{"end", "DM4100#"},
+	)
+	if _, err := executarComandos(sessao, registro.resolver("Datacom", ""), []string{"hostname DM4100"}, true, ""); err != nil {
+		t.Fatalf("executarComandos: %v (enviados: %q)", err, sessao.enviados)
+	}
+	sessao.conferirRoteiro(t)
+}
+
+func TestDriversPersonalizadosInvalidos(t *testing.T) {
+	casos := map[string]string{
+		"sem nome":      "drivers:\n  - prompt: '#$'\n",
+		"sem prompt":    "drivers:\n  - nome: x\n",
+		"regex errada":  "drivers:\n  - nome: x\n    prompt: '(['\n",
+		"yaml quebrado": "drivers: [",
+	}
+	for nome, conteudo := range casos {
+		t.Run(nome, func(t *testing.T) {
+			arquivo := filepath.Join(t.TempDir(), "drivers.yaml")
+			os.WriteFile(arquivo, []byte(conteudo), 0o644)
+			if err := novoRegistroDrivers().carregarDriversPersonalizados(arquivo); err == nil {
+				t.Fatal("esperava erro")
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"   // Acesso à tabela 'execucoes'.
+	"fmt"            // Impressão dos resultados.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"os"             // Saída padrão para a tabela e as exportações.
+	"strconv"        // Conversão do ID informado na linha de comando.
+	"strings"        // Montagem da consulta com filtros.
+	"text/tabwriter" // Tabela alinhada com a lista de execuções.
+	"time"           // Data/hora de cada execução.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== ESTRUTURAS ==============
+
+// Execucao representa uma linha da tabela 'execucoes': a saída de um comando em um equipamento.
+type Execucao struct {
+	ID            int
+	GrupoID       int
+	EquipamentoID int
+	Comando       string
+	Saida         string
+	Erro          string
+	ExecutadoEm   string
+}
+
+// FiltroExecucoes restringe a listagem das execuções.
+type FiltroExecucoes struct {
+	GrupoID       int
+	EquipamentoID int
+	Limite        int
+}
+
+// ============== LÓGICA DE CRUD - EXECUÇÕES ==============
+
+// registrarExecucoes grava a saída de cada comando executado e devolve os IDs gerados,
+// na mesma ordem dos resultados. Se a execução falhou, o erro também é gravado.
+func registrarExecucoes(grupoID, equipamentoID int, resultados []ResultadoComando, erroExecucao error) ([]int64, error) {
+	stmt, err := bancoDeDados.Prepare("INSERT INTO execucoes(grupo_id, equipamento_id, comando, saida, erro, executado_em) VALUES(?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	agora := time.Now().Format(time.RFC3339)
+	var ids []int64
+	for _, r := range resultados {
+		res, err := stmt.Exec(grupoID, equipamentoID, r.Comando, r.Saida, "", agora)
+		if err != nil {
+			return ids, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	// A falha fica registrada em uma linha própria, sem comando.
+	if erroExecucao != nil {
+		if _, err := stmt.Exec(grupoID, equipamentoID, "", "", erroExecucao.Error(), agora); err != nil {
+			return ids, err
+		}
+	}
+	return ids, nil
+}
+
+// buscarExecucao carrega uma execução pelo seu ID.
+func buscarExecucao(id int) (Execucao, error) {
+	var e Execucao
+	var saida, erro sql.NullString
+	err := bancoDeDados.QueryRow("SELECT id, grupo_id, equipamento_id, comando, saida, erro, executado_em FROM execucoes WHERE id = ?", id).
+		Scan(&e.ID, &e.GrupoID, &e.EquipamentoID, &e.Comando, &saida, &erro, &e.ExecutadoEm)
+	if err == sql.ErrNoRows {
+		return e, fmt.Errorf("nenhuma execução encontrada com o ID %d", id)
+	}
+	e.Saida, e.Erro = saida.String, erro.String
+	return e, err
+}
+
+// listarExecucoes exibe as execuções mais recentes, com o nome do equipamento e do grupo.
+func listarExecucoes(filtro FiltroExecucoes) error {
+	consulta := `SELECT x.id, x.executado_em, COALESCE(e.nome, ''), COALESCE(g.nome, ''), x.comando, COALESCE(x.erro, '')
+		FROM execucoes x
+		LEFT JOIN equipamentos e ON e.id = x.equipamento_id
+		LEFT JOIN grupos_comandos g ON g.id = x.grupo_id
+		WHERE 1=1`
+	var argumentos []any
+	if filtro.GrupoID != 0 {
+		consulta += " AND x.grupo_id = ?"
+		argumentos = append(argumentos, filtro.GrupoID)
+	}
+	if filtro.EquipamentoID != 0 {
+		consulta += " AND x.equipamento_id = ?"
+		argumentos = append(argumentos, filtro.EquipamentoID)
+	}
+	consulta += " ORDER BY x.id DESC LIMIT ?"
+	argumentos = append(argumentos, filtro.Limite)
+
+	rows, err := bancoDeDados.Query(consulta, argumentos...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tDATA\tEQUIPAMENTO\tGRUPO\tCOMANDO\tERRO")
+	fmt.Fprintln(w, "--\t----\t-----------\t-----\t-------\t----")
+	for rows.Next() {
+		var id int
+		var data, equipamento, grupo, comando, erro string
+		if err := rows.Scan(&id, &data, &equipamento, &grupo, &comando, &erro); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", id, data, equipamento, grupo, comando, erro)
+	}
+	return w.Flush()
+}
+
+// parsearExecucao aplica à saída da execução o template informado ou,
+// se nenhum for informado, o template associado ao comando no grupo.
+func parsearExecucao(e Execucao, nomeTemplate string) (*TabelaParseada, error) {
+	if nomeTemplate == "" {
+		var err error
+		nomeTemplate, err = templateDoComando(e.GrupoID, e.Comando)
+		if err != nil {
+			return nil, err
+		}
+		if nomeTemplate == "" {
+			return nil, fmt.Errorf("o comando '%s' não possui template associado no grupo %d (use 'grupo template' ou --template)", e.Comando, e.GrupoID)
+		}
+	}
+
+	template, err := carregarTemplate(nomeTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return template.Processar(e.Saida)
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoExec = &cobra.Command{
+	Use:     "exec",
+	Short:   "Consulta as saídas dos grupos de comandos já executados.",
+	Aliases: []string{"execucao"},
+}
+
+var comandoListExec = &cobra.Command{
+	Use:         "list",
+	Short:       "Lista as execuções mais recentes.",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		var filtro FiltroExecucoes
+		filtro.GrupoID, _ = cmd.Flags().GetInt("grupo")
+		filtro.EquipamentoID, _ = cmd.Flags().GetInt("equip")
+		filtro.Limite, _ = cmd.Flags().GetInt("limite")
+
+		if err := listarExecucoes(filtro); err != nil {
+			log.Fatalf("Erro ao listar execuções: %v", err)
+		}
+	},
+}
+
+var comandoShowExec = &cobra.Command{
+	Use:         "show [ID]",
+	Short:       "Exibe a saída de uma execução, bruta ou parseada.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		execucao, err := buscarExecucao(id)
+		if err != nil {
+			log.Fatalf("Erro ao buscar execução: %v", err)
+		}
+
+		parseado, _ := cmd.Flags().GetBool("parsed")
+		nomeTemplate, _ := cmd.Flags().GetString("template")
+		formato, _ := cmd.Flags().GetString("formato")
+
+		// Sem --parsed, exibe a saída bruta como foi recebida do equipamento.
+		if !parseado && nomeTemplate == "" {
+			if execucao.Erro != "" {
+				fmt.Printf("ERRO: %s\n", execucao.Erro)
+			}
+			fmt.Println(execucao.Saida)
+			return
+		}
+
+		tabela, err := parsearExecucao(execucao, nomeTemplate)
+		if err != nil {
+			log.Fatalf("Erro ao parsear a saída: %v", err)
+		}
+		if err := tabela.escrever(os.Stdout, strings.TrimSpace(formato)); err != nil {
+			log.Fatalf("Erro ao exportar a saída: %v", err)
+		}
+	},
+}
+
+func init() {
+	comandoRaiz.AddCommand(comandoExec)
+	comandoExec.AddCommand(comandoListExec, comandoShowExec)
+
+	comandoListExec.Flags().Int("grupo", 0, "Filtra pelo ID do grupo de comandos")
+	comandoListExec.Flags().Int("equip", 0, "Filtra pelo ID do equipamento")
+	comandoListExec.Flags().Int("limite", 20, "Quantidade máxima de execuções exibidas")
+
+	comandoShowExec.Flags().Bool("parsed", false, "Aplica o template associado e exibe a saída como tabela")
+	comandoShowExec.Flags().String("template", "", "Usa este template em vez do associado ao comando")
+	comandoShowExec.Flags().String("formato", "tabela", "Formato da saída parseada: tabela, json ou csv")
+}
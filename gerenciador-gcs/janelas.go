@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"            // Formatação das mensagens.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"os"             // Saída padrão das tabelas.
+	"strconv"        // Conversão do ID informado na linha de comando.
+	"strings"        // Comparação de cidades e tags.
+	"text/tabwriter" // Tabelas alinhadas no console.
+	"time"           // Verificação do horário das janelas.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== ESTRUTURAS ==============
+
+// JanelaManutencao é um período recorrente em que grupos de configuração podem ser executados.
+// A janela vale para os equipamentos da cidade e/ou da tag informadas; sem nenhuma das duas,
+// vale para todos os equipamentos.
+type JanelaManutencao struct {
+	ID         int
+	Nome       string
+	Cidade     string
+	Tag        string
+	DiasSemana string // No formato do cron: "1-5", "0,6", "*" (0 = domingo).
+	Inicio     string // "HH:MM"
+	Fim        string // "HH:MM". Se for menor que o início, a janela atravessa a meia-noite; se for igual, dura 24h.
+}
+
+// minutosDoDia converte "HH:MM" para minutos desde a meia-noite.
+func minutosDoDia(horario string) (int, error) {
+	t, err := time.Parse("15:04", horario)
+	if err != nil {
+		return 0, fmt.Errorf("horário '%s' inválido (use HH:MM)", horario)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validar confere os dias da semana e os horários da janela.
+func (j JanelaManutencao) validar() error {
+	var dias [8]bool
+	if err := preencherCampoCron(j.DiasSemana, 0, 7, dias[:]); err != nil {
+		return fmt.Errorf("dias da semana: %w", err)
+	}
+	if _, err := minutosDoDia(j.Inicio); err != nil {
+		return err
+	}
+	if _, err := minutosDoDia(j.Fim); err != nil {
+		return err
+	}
+	return nil
+}
+
+// permiteDia indica se a janela vale no dia da semana informado.
+func (j JanelaManutencao) permiteDia(dia time.Weekday) bool {
+	var dias [8]bool
+	if err := preencherCampoCron(j.DiasSemana, 0, 7, dias[:]); err != nil {
+		return false
+	}
+	return dias[int(dia)] || (dia == time.Sunday && dias[7])
+}
+
+// Aberta indica se a janela está aberta no momento informado. O início é incluído e o fim
+// não. Início igual ao fim (ex: 00:00-00:00) é uma janela de 24 horas a partir do início.
+func (j JanelaManutencao) Aberta(agora time.Time) bool {
+	inicio, err1 := minutosDoDia(j.Inicio)
+	fim, err2 := minutosDoDia(j.Fim)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minuto := agora.Hour()*60 + agora.Minute()
+
+	if inicio < fim {
+		return j.permiteDia(agora.Weekday()) && minuto >= inicio && minuto < fim
+	}
+	// Janela que atravessa a meia-noite (ex: 23:00-04:00, ou 08:00-08:00): a parte depois
+	// da meia-noite pertence à janela que começou no dia anterior.
+	if minuto >= inicio {
+		return j.permiteDia(agora.Weekday())
+	}
+	if minuto < fim {
+		return j.permiteDia(agora.AddDate(0, 0, -1).Weekday())
+	}
+	return false
+}
+
+// ValeParaEquipamento indica se a janela se aplica ao equipamento (pela cidade ou pela tag).
+func (j JanelaManutencao) ValeParaEquipamento(equip Equipamento, tags []string) bool {
+	if j.Cidade == "" && j.Tag == "" {
+		return true
+	}
+	if j.Cidade != "" && strings.EqualFold(j.Cidade, equip.Cidade) {
+		return true
+	}
+	for _, tag := range tags {
+		if j.Tag != "" && strings.EqualFold(j.Tag, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ============== LÓGICA DE CRUD - TAGS DE EQUIPAMENTOS ==============
+
+// adicionarTags associa tags a um equipamento (tags repetidas são ignoradas).
+func adicionarTags(equipamentoID int, tags []string) error {
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		if _, err := bancoDeDados.Exec("INSERT OR IGNORE INTO equipamentos_tags(equipamento_id, tag) VALUES(?, ?)", equipamentoID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removerTags desassocia tags de um equipamento.
+func removerTags(equipamentoID int, tags []string) error {
+	for _, tag := range tags {
+		if _, err := bancoDeDados.Exec("DELETE FROM equipamentos_tags WHERE equipamento_id = ? AND tag = ?", equipamentoID, strings.TrimSpace(tag)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagsDoEquipamento devolve as tags de um equipamento.
+func tagsDoEquipamento(equipamentoID int) ([]string, error) {
+	rows, err := bancoDeDados.Query("SELECT tag FROM equipamentos_tags WHERE equipamento_id = ? ORDER BY tag", equipamentoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ============== LÓGICA DE CRUD - JANELAS DE MANUTENÇÃO ==============
+
+// adicionarJanela valida e grava uma nova janela de manutenção.
+func adicionarJanela(j JanelaManutencao) error {
+	if err := j.validar(); err != nil {
+		return err
+	}
+	_, err := bancoDeDados.Exec("INSERT INTO janelas_manutencao(nome, cidade, tag, dias_semana, inicio, fim) VALUES(?, ?, ?, ?, ?, ?)",
+		j.Nome, j.Cidade, j.Tag, j.DiasSemana, j.Inicio, j.Fim)
+	return err
+}
+
+// buscarJanelas devolve todas as janelas de manutenção cadastradas.
+func buscarJanelas() ([]JanelaManutencao, error) {
+	rows, err := bancoDeDados.Query("SELECT id, nome, cidade, tag, dias_semana, inicio, fim FROM janelas_manutencao ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var janelas []JanelaManutencao
+	for rows.Next() {
+		var j JanelaManutencao
+		if err := rows.Scan(&j.ID, &j.Nome, &j.Cidade, &j.Tag, &j.DiasSemana, &j.Inicio, &j.Fim); err != nil {
+			return nil, err
+		}
+		janelas = append(janelas, j)
+	}
+	return janelas, rows.Err()
+}
+
+// listarJanelas exibe as janelas de manutenção e se estão abertas agora.
+func listarJanelas(agora time.Time) error {
+	janelas, err := buscarJanelas()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNOME\tCIDADE\tTAG\tDIAS\tHORÁRIO\tABERTA")
+	fmt.Fprintln(w, "--\t----\t------\t---\t----\t-------\t------")
+	for _, j := range janelas {
+		aberta := "não"
+		if j.Aberta(agora) {
+			aberta = "sim"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s-%s\t%s\n", j.ID, j.Nome, j.Cidade, j.Tag, j.DiasSemana, j.Inicio, j.Fim, aberta)
+	}
+	return w.Flush()
+}
+
+// deletarJanela remove uma janela de manutenção pelo seu ID.
+func deletarJanela(id int) error {
+	res, err := bancoDeDados.Exec("DELETE FROM janelas_manutencao WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if linhasAfetadas == 0 {
+		return fmt.Errorf("nenhuma janela encontrada com o ID %d", id)
+	}
+	return nil
+}
+
+// verificarJanela confere se o equipamento está dentro de alguma janela de manutenção aberta.
+// Equipamentos sem nenhuma janela aplicável ficam bloqueados para grupos de configuração.
+func verificarJanela(equip Equipamento, janelas []JanelaManutencao, agora time.Time) error {
+	tags, err := tagsDoEquipamento(equip.ID)
+	if err != nil {
+		return err
+	}
+
+	aplicaveis := 0
+	for _, j := range janelas {
+		if !j.ValeParaEquipamento(equip, tags) {
+			continue
+		}
+		aplicaveis++
+		if j.Aberta(agora) {
+			return nil
+		}
+	}
+
+	if aplicaveis == 0 {
+		return fmt.Errorf("nenhuma janela de manutenção cadastrada para o equipamento '%s' (cidade '%s')", equip.Nome, equip.Cidade)
+	}
+	return fmt.Errorf("o equipamento '%s' está fora da janela de manutenção", equip.Nome)
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoJanela = &cobra.Command{
+	Use:     "janela",
+	Short:   "Gerencia as janelas de manutenção dos grupos de configuração.",
+	Aliases: []string{"jm"},
+}
+
+var comandoAddJanela = &cobra.Command{
+	Use:         "ad",
+	Short:       "Adiciona uma nova janela de manutenção.",
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		var j JanelaManutencao
+		j.Nome, _ = cmd.Flags().GetString("nome")
+		j.Cidade, _ = cmd.Flags().GetString("cidade")
+		j.Tag, _ = cmd.Flags().GetString("tag")
+		j.DiasSemana, _ = cmd.Flags().GetString("dias")
+		j.Inicio, _ = cmd.Flags().GetString("inicio")
+		j.Fim, _ = cmd.Flags().GetString("fim")
+
+		if err := adicionarJanela(j); err != nil {
+			log.Fatalf("Erro ao adicionar janela: %v", err)
+		}
+		fmt.Println("Janela de manutenção adicionada com sucesso!")
+	},
+}
+
+var comandoListJanela = &cobra.Command{
+	Use:         "list",
+	Short:       "Lista as janelas de manutenção.",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listarJanelas(time.Now()); err != nil {
+			log.Fatalf("Erro ao listar janelas: %v", err)
+		}
+	},
+}
+
+var comandoDeleteJanela = &cobra.Command{
+	Use:         "del [ID]",
+	Short:       "Deleta uma janela de manutenção pelo seu ID.",
+	Aliases:     []string{"rm"},
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		if err := deletarJanela(id); err != nil {
+			log.Fatalf("Erro ao deletar janela: %v", err)
+		}
+		fmt.Printf("Janela com ID %d deletada com sucesso!\n", id)
+	},
+}
+
+var comandoTagEquip = &cobra.Command{
+	Use:         "tag [ID]",
+	Short:       "Adiciona, remove ou lista as tags de um equipamento.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		adicionar, _ := cmd.Flags().GetStringSlice("add")
+		remover, _ := cmd.Flags().GetStringSlice("rm")
+
+		if err := adicionarTags(id, adicionar); err != nil {
+			log.Fatalf("Erro ao adicionar tags: %v", err)
+		}
+		if err := removerTags(id, remover); err != nil {
+			log.Fatalf("Erro ao remover tags: %v", err)
+		}
+		tags, err := tagsDoEquipamento(id)
+		if err != nil {
+			log.Fatalf("Erro ao listar tags: %v", err)
+		}
+		fmt.Printf("Tags do equipamento %d: %s\n", id, strings.Join(tags, ", "))
+	},
+}
+
+func init() {
+	comandoRaiz.AddCommand(comandoJanela)
+	comandoJanela.AddCommand(comandoAddJanela, comandoListJanela, comandoDeleteJanela)
+	comandoAddJanela.Flags().String("nome", "", "Nome da janela")
+	comandoAddJanela.Flags().String("cidade", "", "Cidade dos equipamentos atendidos pela janela")
+	comandoAddJanela.Flags().String("tag", "", "Tag dos equipamentos atendidos pela janela")
+	comandoAddJanela.Flags().String("dias", "*", "Dias da semana no formato do cron (ex: 1-5; 0 = domingo)")
+	comandoAddJanela.Flags().String("inicio", "", "Horário de início (HH:MM)")
+	comandoAddJanela.Flags().String("fim", "", "Horário de fim (HH:MM; igual ao início = 24 horas)")
+	comandoAddJanela.MarkFlagRequired("nome")
+	comandoAddJanela.MarkFlagRequired("inicio")
+	comandoAddJanela.MarkFlagRequired("fim")
+
+	comandoEquip.AddCommand(comandoTagEquip)
+	comandoTagEquip.Flags().StringSlice("add", nil, "Tags a adicionar (ex: core,backbone)")
+	comandoTagEquip.Flags().StringSlice("rm", nil, "Tags a remover")
+}
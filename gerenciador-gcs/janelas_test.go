@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJanelaAberta(t *testing.T) {
+	// 19/10/2026 é uma segunda-feira.
+	segunda := func(hora, minuto int) time.Time { return time.Date(2026, 10, 19, hora, minuto, 0, 0, time.UTC) }
+	terca := func(hora, minuto int) time.Time { return time.Date(2026, 10, 20, hora, minuto, 0, 0, time.UTC) }
+
+	casos := []struct {
+		nome          string
+		dias          string
+		inicio, fim   string
+		agora         time.Time
+		esperadoAbrir bool
+	}{
+		{"dentro", "1-5", "01:00", "05:00", segunda(3, 0), true},
+		{"no início", "1-5", "01:00", "05:00", segunda(1, 0), true},
+		{"no fim", "1-5", "01:00", "05:00", segunda(5, 0), false},
+		{"dia não permitido", "0,6", "01:00", "05:00", segunda(3, 0), false},
+		{"meia-noite, antes", "1", "23:00", "04:00", segunda(23, 30), true},
+		{"meia-noite, depois", "1", "23:00", "04:00", terca(2, 0), true},
+		{"meia-noite, dia seguinte não conta", "2", "23:00", "04:00", terca(2, 0), false},
+		{"24 horas, depois do início", "1", "08:00", "08:00", segunda(8, 0), true},
+		{"24 horas, dia seguinte", "1", "08:00", "08:00", terca(7, 59), true},
+		{"24 horas, terminou", "1", "08:00", "08:00", terca(8, 0), false},
+		{"24 horas, antes do início", "1", "08:00", "08:00", segunda(7, 59), false},
+		{"dia todo", "*", "00:00", "00:00", segunda(23, 59), true},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			j := JanelaManutencao{DiasSemana: caso.dias, Inicio: caso.inicio, Fim: caso.fim}
+			if err := j.validar(); err != nil {
+				t.Fatalf("validar: %v", err)
+			}
+			if aberta := j.Aberta(caso.agora); aberta != caso.esperadoAbrir {
+				t.Fatalf("Aberta(%s) = %v, esperava %v", caso.agora.Format("Mon 15:04"), aberta, caso.esperadoAbrir)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"        // Para conversão entre strings e outros tipos (ex: string para inteiro).
 	"strings"        // Funções para manipulação de strings.
 	"text/tabwriter" // Pacote para criar tabelas bem alinhadas no console.
+	"time"           // Usado para os timeouts das sessões com os equipamentos.
 
 	// O '_' significa que estamos importando o pacote por seus efeitos colaterais,
 	// que neste caso é registrar o driver do SQLite. Não usamos o pacote diretamente.
@@ -23,6 +24,64 @@ import (
 // A tag `yaml:"..."` mapeia o campo da struct para a chave no arquivo YAML.
 type Configuracao struct {
 	CaminhoBancoDados string `yaml:"database_path"`
+	// Caminho opcional para um arquivo YAML com drivers de fabricantes personalizados.
+	ArquivoDrivers string `yaml:"drivers_path,omitempty"`
+	// Pasta opcional com templates de parsing (.textfsm) que complementam os embutidos.
+	DiretorioTemplates string `yaml:"templates_path,omitempty"`
+	// Credenciais usadas para acessar os equipamentos. A senha também pode vir
+	// da variável de ambiente GCS_SSH_SENHA, para não ficar gravada no arquivo.
+	UsuarioSSH      string `yaml:"ssh_usuario,omitempty"`
+	SenhaSSH        string `yaml:"ssh_senha,omitempty"`
+	SenhaEnable     string `yaml:"senha_enable,omitempty"`
+	PortaSSH        int    `yaml:"ssh_porta,omitempty"`
+	TimeoutSegundos int    `yaml:"timeout_segundos,omitempty"`
+}
+
+// Equipamento representa uma linha da tabela 'equipamentos'.
+type Equipamento struct {
+	ID      int
+	Nome    string
+	IP      string
+	Cidade  string
+	Tipo    string
+	Vendor  string
+	DevTipo string
+	Status  string // planejado, ativo, manutencao ou desativado.
+}
+
+// GrupoComandos representa uma linha da tabela 'grupos_comandos'.
+type GrupoComandos struct {
+	ID          int
+	Nome        string
+	Comandos    string
+	TipoComando string
+}
+
+// ListaComandos separa os comandos do grupo (gravados separados por ';').
+func (g GrupoComandos) ListaComandos() []string {
+	var lista []string
+	for _, comando := range strings.Split(g.Comandos, ";") {
+		if comando = strings.TrimSpace(comando); comando != "" {
+			lista = append(lista, comando)
+		}
+	}
+	return lista
+}
+
+// EhConfiguracao indica se o grupo altera a configuração dos equipamentos.
+func (g GrupoComandos) EhConfiguracao() bool {
+	return strings.EqualFold(strings.TrimSpace(g.TipoComando), "configuracao")
+}
+
+// FiltroEquipamentos seleciona os equipamentos que participarão de uma execução.
+// Campos vazios não filtram nada.
+type FiltroEquipamentos struct {
+	IDs    []int  `json:"ids,omitempty"`
+	Cidade string `json:"cidade,omitempty"`
+	Tipo   string `json:"tipo,omitempty"`
+	Vendor string `json:"vendor,omitempty"`
+	// IncluirInativos inclui os equipamentos em manutenção ou desativados, que por padrão ficam de fora.
+	IncluirInativos bool `json:"incluir_inativos,omitempty"`
 }
 
 // ============== VARIÁVEIS GLOBAIS ==============
@@ -32,6 +91,8 @@ var (
 	arquivoConfig = "config.yml"
 	// bancoDeDados é a variável global que manterá a conexão com o banco de dados ativa.
 	bancoDeDados *sql.DB
+	// configuracao guarda a configuração carregada na inicialização.
+	configuracao Configuracao
 )
 
 // ============== LÓGICA DE CONFIGURAÇÃO (config.yml) ==============
@@ -86,6 +147,34 @@ func carregarConfiguracao() (Configuracao, error) {
 	return config, nil
 }
 
+// credenciaisSSH monta as credenciais de acesso a partir da configuração,
+// aplicando os valores padrão e as variáveis de ambiente.
+func (c Configuracao) credenciaisSSH() CredenciaisSSH {
+	cred := CredenciaisSSH{
+		Usuario:     c.UsuarioSSH,
+		Senha:       c.SenhaSSH,
+		SenhaEnable: c.SenhaEnable,
+		Porta:       c.PortaSSH,
+		Timeout:     time.Duration(c.TimeoutSegundos) * time.Second,
+	}
+	if usuario := os.Getenv("GCS_SSH_USUARIO"); usuario != "" {
+		cred.Usuario = usuario
+	}
+	if senha := os.Getenv("GCS_SSH_SENHA"); senha != "" {
+		cred.Senha = senha
+	}
+	if senha := os.Getenv("GCS_SENHA_ENABLE"); senha != "" {
+		cred.SenhaEnable = senha
+	}
+	if cred.Porta == 0 {
+		cred.Porta = 22
+	}
+	if cred.Timeout == 0 {
+		cred.Timeout = 30 * time.Second
+	}
+	return cred
+}
+
 // ============== LÓGICA DO BANCO DE DADOS ==============
 
 // inicializarBancoDeDados abre a conexão com o banco de dados SQLite no caminho especificado.
@@ -105,25 +194,172 @@ func inicializarBancoDeDados(caminho string) (*sql.DB, error) {
 	return bancoDeDados, nil
 }
 
+// esquemaBancoDeDados contém as instruções que criam as tabelas usadas pela aplicação.
+// O 'IF NOT EXISTS' permite executá-las sempre, sem afetar bancos que já existem.
+var esquemaBancoDeDados = []string{
+	`CREATE TABLE IF NOT EXISTS equipamentos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nome TEXT, ip TEXT, cidade TEXT, tipo TEXT, vendor TEXT, dev_tipo TEXT,
+		status TEXT NOT NULL DEFAULT 'ativo'
+	)`,
+	`CREATE TABLE IF NOT EXISTS grupos_comandos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nome TEXT, comandos TEXT, tipo_comando TEXT
+	)`,
+	// Saída de cada comando executado em cada equipamento.
+	`CREATE TABLE IF NOT EXISTS execucoes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		grupo_id INTEGER NOT NULL,
+		equipamento_id INTEGER NOT NULL,
+		comando TEXT NOT NULL,
+		saida TEXT,
+		erro TEXT,
+		executado_em TEXT NOT NULL
+	)`,
+	// Template de parsing associado a um comando de um grupo.
+	`CREATE TABLE IF NOT EXISTS grupos_templates (
+		grupo_id INTEGER NOT NULL,
+		comando TEXT NOT NULL,
+		template TEXT NOT NULL,
+		PRIMARY KEY (grupo_id, comando)
+	)`,
+	// Execuções recorrentes de um grupo, definidas por uma expressão cron.
+	`CREATE TABLE IF NOT EXISTS agendamentos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nome TEXT NOT NULL,
+		grupo_id INTEGER NOT NULL,
+		filtro TEXT NOT NULL,
+		cron TEXT NOT NULL,
+		perdidas TEXT NOT NULL DEFAULT 'executar',
+		ativo INTEGER NOT NULL DEFAULT 1,
+		ultima_prevista TEXT,
+		criado_em TEXT NOT NULL
+	)`,
+	// Histórico de cada disparo de um agendamento.
+	`CREATE TABLE IF NOT EXISTS agendamentos_execucoes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		agendamento_id INTEGER NOT NULL,
+		previsto_para TEXT NOT NULL,
+		iniciado_em TEXT,
+		finalizado_em TEXT,
+		status TEXT NOT NULL,
+		mensagem TEXT
+	)`,
+	// Tags livres dos equipamentos (ex: "core", "backbone").
+	`CREATE TABLE IF NOT EXISTS equipamentos_tags (
+		equipamento_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (equipamento_id, tag)
+	)`,
+	// Períodos em que grupos de configuração podem ser executados.
+	`CREATE TABLE IF NOT EXISTS janelas_manutencao (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nome TEXT NOT NULL,
+		cidade TEXT NOT NULL DEFAULT '',
+		tag TEXT NOT NULL DEFAULT '',
+		dias_semana TEXT NOT NULL DEFAULT '*',
+		inicio TEXT NOT NULL,
+		fim TEXT NOT NULL
+	)`,
+	// Operadores da CLI e seus papéis. O token de API é gravado apenas como hash.
+	`CREATE TABLE IF NOT EXISTS operadores (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nome TEXT NOT NULL UNIQUE,
+		papel TEXT NOT NULL,
+		token_hash TEXT UNIQUE,
+		criado_em TEXT NOT NULL
+	)`,
+	// Trilha de auditoria das ações sensíveis.
+	`CREATE TABLE IF NOT EXISTS auditoria (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		momento TEXT NOT NULL,
+		usuario TEXT NOT NULL,
+		acao TEXT NOT NULL,
+		detalhes TEXT NOT NULL
+	)`,
+	// Enlaces descobertos via LLDP/CDP. 'vizinho_id' fica nulo quando o vizinho
+	// ainda não está no inventário.
+	`CREATE TABLE IF NOT EXISTS enlaces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		equipamento_id INTEGER NOT NULL,
+		interface_local TEXT NOT NULL,
+		vizinho_id INTEGER,
+		vizinho_nome TEXT NOT NULL,
+		vizinho_interface TEXT NOT NULL,
+		vizinho_ip TEXT NOT NULL DEFAULT '',
+		vizinho_plataforma TEXT NOT NULL DEFAULT '',
+		protocolo TEXT NOT NULL,
+		descoberto_em TEXT NOT NULL
+	)`,
+	// Notas livres de cada equipamento, incluindo as mudanças de status.
+	`CREATE TABLE IF NOT EXISTS equipamentos_notas (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		equipamento_id INTEGER NOT NULL,
+		momento TEXT NOT NULL,
+		autor TEXT NOT NULL,
+		texto TEXT NOT NULL
+	)`,
+}
+
+// colunasAdicionadas são as colunas criadas depois da primeira versão das tabelas.
+// Bancos antigos recebem essas colunas via ALTER TABLE em 'garantirEsquema'.
+var colunasAdicionadas = []struct {
+	tabela, coluna, definicao string
+}{
+	{"equipamentos", "status", "TEXT NOT NULL DEFAULT 'ativo'"},
+}
+
+// garantirEsquema cria as tabelas que ainda não existirem no banco.
+func garantirEsquema() error {
+	for _, instrucao := range esquemaBancoDeDados {
+		if _, err := bancoDeDados.Exec(instrucao); err != nil {
+			return fmt.Errorf("falha ao preparar o esquema do banco de dados: %w", err)
+		}
+	}
+
+	for _, c := range colunasAdicionadas {
+		var existe int
+		err := bancoDeDados.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.tabela, c.coluna).Scan(&existe)
+		if err != nil {
+			return fmt.Errorf("falha ao verificar a coluna %s.%s: %w", c.tabela, c.coluna, err)
+		}
+		if existe == 0 {
+			if _, err := bancoDeDados.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.tabela, c.coluna, c.definicao)); err != nil {
+				return fmt.Errorf("falha ao adicionar a coluna %s.%s: %w", c.tabela, c.coluna, err)
+			}
+		}
+	}
+	return nil
+}
+
 // ============== LÓGICA DE CRUD - EQUIPAMENTOS ==============
 
 // adicionarEquipamento insere um novo registro na tabela 'equipamentos'.
-func adicionarEquipamento(nome, ip, cidade, tipo, vendor, devTipo string) error {
+func adicionarEquipamento(nome, ip, cidade, tipo, vendor, devTipo, status string) error {
+	if err := validarStatus(status); err != nil {
+		return err
+	}
+
 	// Prepara a instrução SQL para evitar SQL Injection.
-	stmt, err := bancoDeDados.Prepare("INSERT INTO equipamentos(nome, ip, cidade, tipo, vendor, dev_tipo) VALUES(?, ?, ?, ?, ?, ?)")
+	stmt, err := bancoDeDados.Prepare("INSERT INTO equipamentos(nome, ip, cidade, tipo, vendor, dev_tipo, status) VALUES(?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
 	defer stmt.Close() // Garante que o statement será fechado ao final da função.
 
 	// Executa a instrução preparada com os valores fornecidos.
-	_, err = stmt.Exec(nome, ip, cidade, tipo, vendor, devTipo)
+	_, err = stmt.Exec(nome, ip, cidade, tipo, vendor, devTipo, status)
 	return err
 }
 
-// listarEquipamentos consulta e exibe todos os registros da tabela 'equipamentos'.
-func listarEquipamentos() error {
-	rows, err := bancoDeDados.Query("SELECT id, nome, ip, cidade, tipo, vendor, dev_tipo FROM equipamentos")
+// listarEquipamentos consulta e exibe os registros da tabela 'equipamentos'.
+// Equipamentos em manutenção ou desativados só aparecem se 'todos' for verdadeiro.
+func listarEquipamentos(todos bool) error {
+	consulta := "SELECT id, nome, ip, cidade, tipo, vendor, dev_tipo, status FROM equipamentos"
+	if !todos {
+		consulta += " WHERE " + condicaoEquipamentosAtivos
+	}
+	rows, err := bancoDeDados.Query(consulta)
 	if err != nil {
 		return err
 	}
@@ -131,23 +367,101 @@ func listarEquipamentos() error {
 
 	// Usa tabwriter para formatar a saída em uma tabela alinhada.
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintln(w, "ID\tNOME\tIP\tCIDADE\tTIPO\tVENDOR\tDEV_TIPO")
-	fmt.Fprintln(w, "--\t----\t--\t------\t----\t------\t--------")
+	fmt.Fprintln(w, "ID\tNOME\tIP\tCIDADE\tTIPO\tVENDOR\tDEV_TIPO\tSTATUS")
+	fmt.Fprintln(w, "--\t----\t--\t------\t----\t------\t--------\t------")
 
 	for rows.Next() {
 		var id int
 		// Usa sql.NullString para tratar colunas que podem ser nulas no banco.
 		var nome, ip, cidade, tipo, vendor, devTipo sql.NullString
-		if err := rows.Scan(&id, &nome, &ip, &cidade, &tipo, &vendor, &devTipo); err != nil {
+		var status string
+		if err := rows.Scan(&id, &nome, &ip, &cidade, &tipo, &vendor, &devTipo, &status); err != nil {
 			return err
 		}
 		// Imprime a linha formatada. .String converte NullString para string (vazia se for nulo).
-		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", id, nome.String, ip.String, cidade.String, tipo.String, vendor.String, devTipo.String)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", id, nome.String, ip.String, cidade.String, tipo.String, vendor.String, devTipo.String, status)
 	}
 	// 'Flush' escreve o conteúdo do buffer (a tabela) no console.
 	return w.Flush()
 }
 
+// buscarEquipamentos devolve os equipamentos que atendem ao filtro.
+func buscarEquipamentos(filtro FiltroEquipamentos) ([]Equipamento, error) {
+	consulta := "SELECT id, nome, ip, cidade, tipo, vendor, dev_tipo, status FROM equipamentos WHERE 1=1"
+	var argumentos []any
+
+	// Monta a cláusula WHERE apenas com os filtros informados.
+	if len(filtro.IDs) > 0 {
+		marcadores := strings.TrimSuffix(strings.Repeat("?,", len(filtro.IDs)), ",")
+		consulta += " AND id IN (" + marcadores + ")"
+		for _, id := range filtro.IDs {
+			argumentos = append(argumentos, id)
+		}
+	}
+	if filtro.Cidade != "" {
+		consulta += " AND cidade = ? COLLATE NOCASE"
+		argumentos = append(argumentos, filtro.Cidade)
+	}
+	if filtro.Tipo != "" {
+		consulta += " AND tipo = ? COLLATE NOCASE"
+		argumentos = append(argumentos, filtro.Tipo)
+	}
+	if filtro.Vendor != "" {
+		consulta += " AND vendor = ? COLLATE NOCASE"
+		argumentos = append(argumentos, filtro.Vendor)
+	}
+	if !filtro.IncluirInativos {
+		consulta += " AND " + condicaoEquipamentosAtivos
+	}
+
+	rows, err := bancoDeDados.Query(consulta+" ORDER BY id", argumentos...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var equipamentos []Equipamento
+	for rows.Next() {
+		var e Equipamento
+		var nome, ip, cidade, tipo, vendor, devTipo sql.NullString
+		if err := rows.Scan(&e.ID, &nome, &ip, &cidade, &tipo, &vendor, &devTipo, &e.Status); err != nil {
+			return nil, err
+		}
+		e.Nome, e.IP, e.Cidade, e.Tipo, e.Vendor, e.DevTipo = nome.String, ip.String, cidade.String, tipo.String, vendor.String, devTipo.String
+		equipamentos = append(equipamentos, e)
+	}
+	return equipamentos, rows.Err()
+}
+
+// buscarEquipamento carrega um equipamento pelo seu ID, qualquer que seja o status.
+func buscarEquipamento(id int) (Equipamento, error) {
+	equipamentos, err := buscarEquipamentos(FiltroEquipamentos{IDs: []int{id}, IncluirInativos: true})
+	if err != nil {
+		return Equipamento{}, err
+	}
+	if len(equipamentos) == 0 {
+		return Equipamento{}, fmt.Errorf("nenhum equipamento encontrado com o ID %d", id)
+	}
+	return equipamentos[0], nil
+}
+
+// atualizarEquipamento grava os dados de um equipamento existente.
+func atualizarEquipamento(e Equipamento) error {
+	res, err := bancoDeDados.Exec("UPDATE equipamentos SET nome = ?, ip = ?, cidade = ?, tipo = ?, vendor = ?, dev_tipo = ? WHERE id = ?",
+		e.Nome, e.IP, e.Cidade, e.Tipo, e.Vendor, e.DevTipo, e.ID)
+	if err != nil {
+		return err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if linhasAfetadas == 0 {
+		return fmt.Errorf("nenhum equipamento encontrado com o ID %d", e.ID)
+	}
+	return nil
+}
+
 // deletarEquipamento remove um equipamento da tabela pelo seu ID.
 func deletarEquipamento(id int) error {
 	stmt, err := bancoDeDados.Prepare("DELETE FROM equipamentos WHERE id = ?")
@@ -214,6 +528,22 @@ func listarGruposComandos() error {
 	return w.Flush()
 }
 
+// buscarGrupoComandos carrega um grupo de comandos pelo seu ID.
+func buscarGrupoComandos(id int) (GrupoComandos, error) {
+	var g GrupoComandos
+	var nome, comandos, tipoComando sql.NullString
+	err := bancoDeDados.QueryRow("SELECT id, nome, comandos, tipo_comando FROM grupos_comandos WHERE id = ?", id).
+		Scan(&g.ID, &nome, &comandos, &tipoComando)
+	if err == sql.ErrNoRows {
+		return g, fmt.Errorf("nenhum grupo de comandos encontrado com o ID %d", id)
+	}
+	if err != nil {
+		return g, err
+	}
+	g.Nome, g.Comandos, g.TipoComando = nome.String, comandos.String, tipoComando.String
+	return g, nil
+}
+
 // deletarGrupoComandos remove um grupo de comandos da tabela pelo seu ID.
 func deletarGrupoComandos(id int) error {
 	stmt, err := bancoDeDados.Prepare("DELETE FROM grupos_comandos WHERE id = ?")
@@ -236,6 +566,102 @@ func deletarGrupoComandos(id int) error {
 	return nil
 }
 
+// ============== LÓGICA DE EXECUÇÃO ==============
+
+// executarGrupoNoEquipamento abre uma sessão SSH com o equipamento, escolhe o driver
+// pelo vendor/dev_tipo e executa os comandos do grupo.
+func executarGrupoNoEquipamento(grupo GrupoComandos, equip Equipamento) ([]ResultadoComando, error) {
+	cred := configuracao.credenciaisSSH()
+	driver := drivers.resolver(equip.Vendor, equip.DevTipo)
+
+	sessao, err := abrirSessaoSSH(equip.IP, cred)
+	if err != nil {
+		return nil, err
+	}
+	defer sessao.Fechar()
+
+	return executarComandos(sessao, driver, grupo.ListaComandos(), grupo.EhConfiguracao(), cred.SenhaEnable)
+}
+
+// OpcoesExecucao controla como um grupo é executado.
+type OpcoesExecucao struct {
+	// Forcar permite executar grupos de configuração fora da janela de manutenção.
+	Forcar bool
+	// Justificativa é obrigatória quando Forcar é usado e fica registrada na auditoria.
+	Justificativa string
+}
+
+// rodarGrupo executa um grupo em todos os equipamentos selecionados e imprime as saídas.
+// Uma falha em um equipamento não interrompe os demais.
+func rodarGrupo(grupoID int, filtro FiltroEquipamentos, opcoes OpcoesExecucao) error {
+	grupo, err := buscarGrupoComandos(grupoID)
+	if err != nil {
+		return err
+	}
+	equipamentos, err := buscarEquipamentos(filtro)
+	if err != nil {
+		return err
+	}
+	if len(equipamentos) == 0 {
+		if !filtro.IncluirInativos {
+			return fmt.Errorf("nenhum equipamento ativo atende ao filtro informado (equipamentos em manutenção ou desativados são ignorados)")
+		}
+		return fmt.Errorf("nenhum equipamento atende ao filtro informado")
+	}
+	if opcoes.Forcar && strings.TrimSpace(opcoes.Justificativa) == "" {
+		return fmt.Errorf("--forcar exige uma justificativa (--justificativa)")
+	}
+
+	// Grupos de configuração só podem rodar dentro das janelas de manutenção.
+	var janelas []JanelaManutencao
+	if grupo.EhConfiguracao() {
+		if janelas, err = buscarJanelas(); err != nil {
+			return err
+		}
+	}
+
+	falhas := 0
+	for _, equip := range equipamentos {
+		driver := drivers.resolver(equip.Vendor, equip.DevTipo)
+		fmt.Printf("===== %s (%s) - driver %s =====\n", equip.Nome, equip.IP, driver.Nome())
+
+		if grupo.EhConfiguracao() {
+			if errJanela := verificarJanela(equip, janelas, time.Now()); errJanela != nil {
+				if !opcoes.Forcar {
+					falhas++
+					fmt.Printf("BLOQUEADO: %v. Use --forcar com --justificativa para executar mesmo assim.\n\n", errJanela)
+					continue
+				}
+				detalhes := fmt.Sprintf("grupo %d (%s) no equipamento %d (%s): %v; justificativa: %s",
+					grupo.ID, grupo.Nome, equip.ID, equip.Nome, errJanela, opcoes.Justificativa)
+				if err := registrarAuditoria("forcar_janela", detalhes); err != nil {
+					return err
+				}
+				fmt.Printf("AVISO: %v. Execução forçada e registrada na auditoria.\n", errJanela)
+			}
+		}
+
+		resultados, err := executarGrupoNoEquipamento(grupo, equip)
+		ids, errRegistro := registrarExecucoes(grupo.ID, equip.ID, resultados, err)
+		if errRegistro != nil {
+			return errRegistro
+		}
+		for i, r := range resultados {
+			fmt.Printf("--- %s (exec #%d) ---\n%s\n", r.Comando, ids[i], r.Saida)
+		}
+		if err != nil {
+			falhas++
+			fmt.Printf("ERRO: %v\n", err)
+		}
+		fmt.Println()
+	}
+
+	if falhas > 0 {
+		return fmt.Errorf("%d de %d equipamento(s) falharam", falhas, len(equipamentos))
+	}
+	return nil
+}
+
 // ============== DEFINIÇÃO DOS COMANDOS CLI (COBRA) ==============
 
 // comandoRaiz é o comando principal da nossa aplicação. Os outros comandos serão "filhos" dele.
@@ -252,11 +678,14 @@ var comandoConfig = &cobra.Command{
 }
 
 var comandoSetPath = &cobra.Command{
-	Use:   "dir-db [novo-caminho]",
-	Short: "Define um novo caminho para o banco de dados.",
-	Args:  cobra.ExactArgs(1), // Exige exatamente um argumento.
+	Use:         "dir-db [novo-caminho]",
+	Short:       "Define um novo caminho para o banco de dados.",
+	Args:        cobra.ExactArgs(1), // Exige exatamente um argumento.
+	Annotations: exigir(PapelAdmin),
 	Run: func(cmd *cobra.Command, args []string) {
-		config := Configuracao{CaminhoBancoDados: args[0]}
+		// Mantém as demais opções já configuradas e troca apenas o caminho do banco.
+		config := configuracao
+		config.CaminhoBancoDados = args[0]
 		if err := salvarConfiguracao(config); err != nil {
 			log.Fatalf("Erro ao salvar nova configuração: %v", err)
 		}
@@ -272,8 +701,9 @@ var comandoEquip = &cobra.Command{
 }
 
 var comandoAddEquip = &cobra.Command{
-	Use:   "ad",
-	Short: "Adiciona um novo equipamento.",
+	Use:         "ad",
+	Short:       "Adiciona um novo equipamento.",
+	Annotations: exigir(PapelOperador),
 	Run: func(cmd *cobra.Command, args []string) {
 		// Obtém os valores das flags passadas na linha de comando.
 		nome, _ := cmd.Flags().GetString("nome")
@@ -282,8 +712,9 @@ var comandoAddEquip = &cobra.Command{
 		tipo, _ := cmd.Flags().GetString("tipo")
 		vendor, _ := cmd.Flags().GetString("vendor")
 		devTipo, _ := cmd.Flags().GetString("dev_tipo")
+		status, _ := cmd.Flags().GetString("status")
 
-		if err := adicionarEquipamento(nome, ip, cidade, tipo, vendor, devTipo); err != nil {
+		if err := adicionarEquipamento(nome, ip, cidade, tipo, vendor, devTipo, status); err != nil {
 			log.Fatalf("Erro ao adicionar equipamento: %v", err)
 		}
 		fmt.Println("Equipamento adicionado com sucesso!")
@@ -291,21 +722,24 @@ var comandoAddEquip = &cobra.Command{
 }
 
 var comandoListEquip = &cobra.Command{
-	Use:     "list",
-	Short:   "Lista todos os equipamentos.",
-	Aliases: []string{"ls"},
+	Use:         "list",
+	Short:       "Lista os equipamentos (os em manutenção ou desativados só com --todos).",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelLeitura),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := listarEquipamentos(); err != nil {
+		todos, _ := cmd.Flags().GetBool("todos")
+		if err := listarEquipamentos(todos); err != nil {
 			log.Fatalf("Erro ao listar equipamentos: %v", err)
 		}
 	},
 }
 
 var comandoDeleteEquip = &cobra.Command{
-	Use:     "del [ID]",
-	Short:   "Deleta um equipamento pelo seu ID.",
-	Aliases: []string{"rm"},
-	Args:    cobra.ExactArgs(1),
+	Use:         "del [ID]",
+	Short:       "Deleta um equipamento pelo seu ID.",
+	Aliases:     []string{"rm"},
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelAdmin),
 	Run: func(cmd *cobra.Command, args []string) {
 		// Converte o argumento (string) para um inteiro.
 		id, err := strconv.Atoi(args[0])
@@ -327,8 +761,9 @@ var comandoGrupo = &cobra.Command{
 }
 
 var comandoAddGrupo = &cobra.Command{
-	Use:   "ad",
-	Short: "Adiciona um novo grupo de comandos.",
+	Use:         "ad",
+	Short:       "Adiciona um novo grupo de comandos.",
+	Annotations: exigir(PapelOperador),
 	Run: func(cmd *cobra.Command, args []string) {
 		nome, _ := cmd.Flags().GetString("nome")
 		comandos, _ := cmd.Flags().GetString("comandos")
@@ -342,9 +777,10 @@ var comandoAddGrupo = &cobra.Command{
 }
 
 var comandoListGrupo = &cobra.Command{
-	Use:     "list",
-	Short:   "Lista todos os grupos de comandos.",
-	Aliases: []string{"ls"},
+	Use:         "list",
+	Short:       "Lista todos os grupos de comandos.",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelLeitura),
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := listarGruposComandos(); err != nil {
 			log.Fatalf("Erro ao listar grupos: %v", err)
@@ -353,10 +789,11 @@ var comandoListGrupo = &cobra.Command{
 }
 
 var comandoDeleteGrupo = &cobra.Command{
-	Use:     "del [ID]",
-	Short:   "Deleta um grupo de comandos pelo seu ID.",
-	Aliases: []string{"rm"},
-	Args:    cobra.ExactArgs(1),
+	Use:         "del [ID]",
+	Short:       "Deleta um grupo de comandos pelo seu ID.",
+	Aliases:     []string{"rm"},
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelAdmin),
 	Run: func(cmd *cobra.Command, args []string) {
 		id, err := strconv.Atoi(args[0])
 		if err != nil {
@@ -369,6 +806,45 @@ var comandoDeleteGrupo = &cobra.Command{
 	},
 }
 
+var comandoRunGrupo = &cobra.Command{
+	Use:         "run [ID]",
+	Short:       "Executa um grupo de comandos nos equipamentos selecionados.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+
+		var filtro FiltroEquipamentos
+		filtro.IDs, _ = cmd.Flags().GetIntSlice("equip")
+		filtro.Cidade, _ = cmd.Flags().GetString("cidade")
+		filtro.Tipo, _ = cmd.Flags().GetString("tipo")
+		filtro.Vendor, _ = cmd.Flags().GetString("vendor")
+		filtro.IncluirInativos, _ = cmd.Flags().GetBool("incluir-inativos")
+
+		var opcoes OpcoesExecucao
+		opcoes.Forcar, _ = cmd.Flags().GetBool("forcar")
+		opcoes.Justificativa, _ = cmd.Flags().GetString("justificativa")
+
+		// Grupos de configuração exigem o papel admin.
+		grupo, err := buscarGrupoComandos(id)
+		if err != nil {
+			log.Fatalf("Erro ao executar grupo: %v", err)
+		}
+		if grupo.EhConfiguracao() {
+			if err := exigirPapel(PapelAdmin, "grupo run (grupo de configuração)"); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if err := rodarGrupo(id, filtro, opcoes); err != nil {
+			log.Fatalf("Erro ao executar grupo: %v", err)
+		}
+	},
+}
+
 // ============== FUNÇÃO DE INICIALIZAÇÃO E FUNÇÃO PRINCIPAL ==============
 
 // A função init() é executada automaticamente pelo Go antes da função main().
@@ -382,6 +858,7 @@ func init() {
 		if err != nil {
 			log.Fatalf("Erro ao carregar configuração: %v", err)
 		}
+		configuracao = cfg
 		if cfg.CaminhoBancoDados == "" {
 			log.Fatal("O caminho do banco de dados não pode ser vazio. Use 'config set-path' para definir.")
 		}
@@ -389,6 +866,15 @@ func init() {
 		if err != nil {
 			log.Fatalf("Erro ao inicializar banco de dados: %v", err)
 		}
+		if err := garantirEsquema(); err != nil {
+			log.Fatal(err)
+		}
+		// Os drivers personalizados complementam (ou substituem) os embutidos.
+		if cfg.ArquivoDrivers != "" {
+			if err := drivers.carregarDriversPersonalizados(cfg.ArquivoDrivers); err != nil {
+				log.Fatalf("Erro ao carregar drivers personalizados: %v", err)
+			}
+		}
 	})
 
 	// Monta a hierarquia de comandos. Adicionamos os subcomandos ao comando raiz.
@@ -405,16 +891,25 @@ func init() {
 	comandoAddEquip.Flags().String("tipo", "", "Tipo do equipamento (ex: OLT, Switch)")
 	comandoAddEquip.Flags().String("vendor", "", "Fabricante (ex: Huawei, Cisco)")
 	comandoAddEquip.Flags().String("dev_tipo", "", "Modelo específico do equipamento")
+	comandoAddEquip.Flags().String("status", StatusAtivo, "Status inicial: planejado, ativo, manutencao ou desativado")
 	comandoAddEquip.MarkFlagRequired("nome") // Torna a flag --nome obrigatória.
 	comandoAddEquip.MarkFlagRequired("ip")   // Torna a flag --ip obrigatória.
+	comandoListEquip.Flags().Bool("todos", false, "Inclui os equipamentos em manutenção ou desativados")
 
 	// Adiciona subcomandos de 'grupo' e define suas flags.
-	comandoGrupo.AddCommand(comandoAddGrupo, comandoListGrupo, comandoDeleteGrupo)
+	comandoGrupo.AddCommand(comandoAddGrupo, comandoListGrupo, comandoDeleteGrupo, comandoRunGrupo)
 	comandoAddGrupo.Flags().String("nome", "", "Nome do grupo de comandos")
 	comandoAddGrupo.Flags().String("comandos", "", "Comandos a serem executados, separados por ';'")
 	comandoAddGrupo.Flags().String("tipo", "", "Tipo de comando (ex: consulta, configuracao)")
 	comandoAddGrupo.MarkFlagRequired("nome")
 	comandoAddGrupo.MarkFlagRequired("comandos")
+	comandoRunGrupo.Flags().IntSlice("equip", nil, "IDs dos equipamentos (ex: 1,2,3)")
+	comandoRunGrupo.Flags().String("cidade", "", "Executa em todos os equipamentos da cidade")
+	comandoRunGrupo.Flags().String("tipo", "", "Executa em todos os equipamentos do tipo")
+	comandoRunGrupo.Flags().String("vendor", "", "Executa em todos os equipamentos do fabricante")
+	comandoRunGrupo.Flags().Bool("incluir-inativos", false, "Executa também nos equipamentos em manutenção ou desativados")
+	comandoRunGrupo.Flags().Bool("forcar", false, "Executa grupos de configuração fora da janela de manutenção")
+	comandoRunGrupo.Flags().String("justificativa", "", "Motivo da execução forçada (registrado na auditoria)")
 }
 
 // A função main() é o ponto de entrada de qualquer programa Go.
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// abrirBancoDeTeste cria um banco SQLite vazio, com o esquema completo, em uma pasta
+// temporária, e o coloca na variável global usada pelas funções de CRUD.
+func abrirBancoDeTeste(t *testing.T) {
+	t.Helper()
+	anterior := bancoDeDados
+	if _, err := inicializarBancoDeDados(filepath.Join(t.TempDir(), "teste.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := garantirEsquema(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bancoDeDados.Close()
+		bancoDeDados = anterior
+	})
+}
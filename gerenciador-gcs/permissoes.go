@@ -0,0 +1,379 @@
+package main
+
+import (
+	"crypto/rand"    // Geração dos tokens de API.
+	"crypto/sha256"  // Os tokens são gravados apenas como hash.
+	"database/sql"   // Acesso à tabela 'operadores'.
+	"encoding/hex"   // Representação textual dos tokens e hashes.
+	"fmt"            // Formatação das mensagens.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"os"             // Variável de ambiente com o token e saída padrão.
+	"strconv"        // Conversão do ID informado na linha de comando.
+	"strings"        // Normalização dos nomes dos papéis.
+	"text/tabwriter" // Tabela alinhada no console.
+	"time"           // Data de criação dos operadores.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== PAPÉIS ==============
+
+// Papel define o que um operador pode fazer. Cada papel inclui as permissões dos anteriores.
+type Papel int
+
+const (
+	PapelLeitura  Papel = iota + 1 // Apenas consultas.
+	PapelOperador                  // Cadastros e execução de grupos de consulta.
+	PapelAdmin                     // Remoções, grupos de configuração e administração.
+)
+
+// nomesPapeis é o nome gravado no banco e usado na linha de comando para cada papel.
+var nomesPapeis = map[Papel]string{
+	PapelLeitura:  "leitura",
+	PapelOperador: "operador",
+	PapelAdmin:    "admin",
+}
+
+// String devolve o nome do papel.
+func (p Papel) String() string {
+	return nomesPapeis[p]
+}
+
+// papelPorNome converte o nome de um papel para o tipo Papel.
+func papelPorNome(nome string) (Papel, error) {
+	for papel, n := range nomesPapeis {
+		if strings.EqualFold(strings.TrimSpace(nome), n) {
+			return papel, nil
+		}
+	}
+	return 0, fmt.Errorf("papel '%s' inválido (use leitura, operador ou admin)", nome)
+}
+
+// anotacaoPapel é a chave usada em cobra.Command.Annotations para indicar o papel mínimo.
+const anotacaoPapel = "papel"
+
+// exigir cria as anotações de um comando que exige o papel informado.
+func exigir(papel Papel) map[string]string {
+	return map[string]string{anotacaoPapel: papel.String()}
+}
+
+// ============== IDENTIFICAÇÃO DO OPERADOR ==============
+
+// Operador representa uma linha da tabela 'operadores'.
+type Operador struct {
+	ID    int
+	Nome  string
+	Papel Papel
+}
+
+// operadorAtual é quem está executando a CLI, identificado antes de cada comando.
+var operadorAtual *Operador
+
+// hashToken calcula o hash SHA-256 de um token; só o hash é gravado no banco.
+func hashToken(token string) string {
+	soma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(soma[:])
+}
+
+// gerarToken cria um token aleatório de 32 bytes.
+func gerarToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// identificarOperador descobre quem está executando a CLI: pelo token de API, se informado,
+// ou pelo usuário do sistema operacional. Devolve nil se o banco ainda não tem operadores.
+func identificarOperador(token string) (*Operador, error) {
+	var total int
+	if err := bancoDeDados.QueryRow("SELECT COUNT(*) FROM operadores").Scan(&total); err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	var o Operador
+	var papel string
+	var err error
+	if token != "" {
+		err = bancoDeDados.QueryRow("SELECT id, nome, papel FROM operadores WHERE token_hash = ?", hashToken(token)).Scan(&o.ID, &o.Nome, &papel)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("token de API inválido")
+		}
+	} else {
+		nome := usuarioSistema()
+		err = bancoDeDados.QueryRow("SELECT id, nome, papel FROM operadores WHERE nome = ?", nome).Scan(&o.ID, &o.Nome, &papel)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("o usuário '%s' não está cadastrado como operador (peça a um admin: 'operador ad --nome %s')", nome, nome)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Papel, err = papelPorNome(papel); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// exigirPapel confere se o operador atual tem pelo menos o papel informado.
+// Enquanto não houver operadores cadastrados, tudo é permitido (modo de implantação).
+func exigirPapel(minimo Papel, acao string) error {
+	if operadorAtual == nil {
+		return nil
+	}
+	if operadorAtual.Papel < minimo {
+		return fmt.Errorf("permissão negada: '%s' exige o papel '%s', mas o operador '%s' tem o papel '%s'",
+			acao, minimo, operadorAtual.Nome, operadorAtual.Papel)
+	}
+	return nil
+}
+
+// verificarPermissao roda antes de cada comando: identifica o operador e confere
+// o papel exigido pela anotação do comando. Comandos sem anotação (ex: help) são livres.
+func verificarPermissao(cmd *cobra.Command, args []string) error {
+	nomePapel, anotado := cmd.Annotations[anotacaoPapel]
+	if !anotado {
+		return nil
+	}
+	minimo, err := papelPorNome(nomePapel)
+	if err != nil {
+		return err
+	}
+	// Uma negação não é erro de uso, então não exibimos a ajuda do comando.
+	cmd.SilenceUsage = true
+
+	token, _ := cmd.Flags().GetString("token")
+	if token == "" {
+		token = os.Getenv("GCS_TOKEN")
+	}
+	if operadorAtual, err = identificarOperador(token); err != nil {
+		return err
+	}
+	if operadorAtual == nil {
+		fmt.Fprintln(os.Stderr, "AVISO: nenhum operador cadastrado; todas as ações estão liberadas. Cadastre um admin com 'operador ad'.")
+	}
+
+	// Remove o nome do programa ("gerenciador-gcs equip del" -> "equip del").
+	acao := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	return exigirPapel(minimo, acao)
+}
+
+// ============== LÓGICA DE CRUD - OPERADORES ==============
+
+// adicionarOperador grava um operador e devolve o token gerado (vazio se não foi pedido).
+func adicionarOperador(nome string, papel Papel, comToken bool) (string, error) {
+	var token string
+	var tokenHash any // NULL quando o operador só se identifica pelo usuário do sistema.
+	if comToken {
+		var err error
+		if token, err = gerarToken(); err != nil {
+			return "", err
+		}
+		tokenHash = hashToken(token)
+	}
+
+	_, err := bancoDeDados.Exec("INSERT INTO operadores(nome, papel, token_hash, criado_em) VALUES(?, ?, ?, ?)",
+		nome, papel.String(), tokenHash, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return "", err
+	}
+	return token, registrarAuditoria("operador_ad", fmt.Sprintf("operador '%s' criado com o papel '%s'", nome, papel))
+}
+
+// listarOperadores exibe os operadores cadastrados.
+func listarOperadores() error {
+	rows, err := bancoDeDados.Query("SELECT id, nome, papel, token_hash IS NOT NULL, criado_em FROM operadores ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNOME\tPAPEL\tTOKEN\tCRIADO_EM")
+	fmt.Fprintln(w, "--\t----\t-----\t-----\t---------")
+	for rows.Next() {
+		var id int
+		var nome, papel, criadoEm string
+		var temToken bool
+		if err := rows.Scan(&id, &nome, &papel, &temToken, &criadoEm); err != nil {
+			return err
+		}
+		token := "não"
+		if temToken {
+			token = "sim"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", id, nome, papel, token, criadoEm)
+	}
+	return w.Flush()
+}
+
+// alterarPapelOperador troca o papel de um operador.
+func alterarPapelOperador(id int, papel Papel) error {
+	res, err := bancoDeDados.Exec("UPDATE operadores SET papel = ? WHERE id = ?", papel.String(), id)
+	if err != nil {
+		return err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if linhasAfetadas == 0 {
+		return fmt.Errorf("nenhum operador encontrado com o ID %d", id)
+	}
+	return registrarAuditoria("operador_papel", fmt.Sprintf("operador %d passou para o papel '%s'", id, papel))
+}
+
+// renovarTokenOperador gera um novo token para o operador, invalidando o anterior.
+func renovarTokenOperador(id int) (string, error) {
+	token, err := gerarToken()
+	if err != nil {
+		return "", err
+	}
+	res, err := bancoDeDados.Exec("UPDATE operadores SET token_hash = ? WHERE id = ?", hashToken(token), id)
+	if err != nil {
+		return "", err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if linhasAfetadas == 0 {
+		return "", fmt.Errorf("nenhum operador encontrado com o ID %d", id)
+	}
+	return token, registrarAuditoria("operador_token", fmt.Sprintf("token do operador %d renovado", id))
+}
+
+// deletarOperador remove um operador pelo seu ID.
+func deletarOperador(id int) error {
+	res, err := bancoDeDados.Exec("DELETE FROM operadores WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if linhasAfetadas == 0 {
+		return fmt.Errorf("nenhum operador encontrado com o ID %d", id)
+	}
+	return registrarAuditoria("operador_del", fmt.Sprintf("operador %d removido", id))
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoOperador = &cobra.Command{
+	Use:     "operador",
+	Short:   "Gerencia os operadores e seus papéis (leitura, operador, admin).",
+	Aliases: []string{"op"},
+}
+
+var comandoAddOperador = &cobra.Command{
+	Use:         "ad",
+	Short:       "Cadastra um operador.",
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		nome, _ := cmd.Flags().GetString("nome")
+		nomePapel, _ := cmd.Flags().GetString("papel")
+		comToken, _ := cmd.Flags().GetBool("token-api")
+
+		papel, err := papelPorNome(nomePapel)
+		if err != nil {
+			log.Fatal(err)
+		}
+		token, err := adicionarOperador(nome, papel, comToken)
+		if err != nil {
+			log.Fatalf("Erro ao adicionar operador: %v", err)
+		}
+		fmt.Println("Operador adicionado com sucesso!")
+		if token != "" {
+			fmt.Printf("Token de API (guarde agora, ele não será exibido de novo): %s\n", token)
+		}
+	},
+}
+
+var comandoListOperador = &cobra.Command{
+	Use:         "list",
+	Short:       "Lista os operadores.",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listarOperadores(); err != nil {
+			log.Fatalf("Erro ao listar operadores: %v", err)
+		}
+	},
+}
+
+var comandoPapelOperador = &cobra.Command{
+	Use:         "papel [ID] [papel]",
+	Short:       "Altera o papel de um operador.",
+	Args:        cobra.ExactArgs(2),
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		papel, err := papelPorNome(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := alterarPapelOperador(id, papel); err != nil {
+			log.Fatalf("Erro ao alterar papel: %v", err)
+		}
+		fmt.Printf("Operador com ID %d agora tem o papel '%s'.\n", id, papel)
+	},
+}
+
+var comandoTokenOperador = &cobra.Command{
+	Use:         "token [ID]",
+	Short:       "Gera um novo token de API para o operador.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		token, err := renovarTokenOperador(id)
+		if err != nil {
+			log.Fatalf("Erro ao gerar token: %v", err)
+		}
+		fmt.Printf("Novo token de API (guarde agora, ele não será exibido de novo): %s\n", token)
+	},
+}
+
+var comandoDeleteOperador = &cobra.Command{
+	Use:         "del [ID]",
+	Short:       "Deleta um operador pelo seu ID.",
+	Aliases:     []string{"rm"},
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelAdmin),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		if err := deletarOperador(id); err != nil {
+			log.Fatalf("Erro ao deletar operador: %v", err)
+		}
+		fmt.Printf("Operador com ID %d deletado com sucesso!\n", id)
+	},
+}
+
+func init() {
+	// A verificação de permissão roda antes de todos os comandos.
+	comandoRaiz.PersistentPreRunE = verificarPermissao
+	comandoRaiz.PersistentFlags().String("token", "", "Token de API do operador (ou variável GCS_TOKEN)")
+
+	comandoRaiz.AddCommand(comandoOperador)
+	comandoOperador.AddCommand(comandoAddOperador, comandoListOperador, comandoPapelOperador, comandoTokenOperador, comandoDeleteOperador)
+	comandoAddOperador.Flags().String("nome", "", "Login do operador no sistema operacional (ou um nome, se usar token)")
+	comandoAddOperador.Flags().String("papel", "leitura", "Papel do operador: leitura, operador ou admin")
+	comandoAddOperador.Flags().Bool("token-api", false, "Gera um token de API para o operador")
+	comandoAddOperador.MarkFlagRequired("nome")
+}
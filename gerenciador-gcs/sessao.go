@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"   // Buffer onde acumulamos a saída recebida do equipamento.
+	"fmt"     // Formatação das mensagens de erro.
+	"io"      // Interfaces de leitura/escrita da sessão SSH.
+	"net"     // Montagem do endereço host:porta.
+	"regexp"  // Reconhecimento dos prompts.
+	"strconv" // Conversão da porta para string.
+	"sync"    // Garante que o canal de encerramento seja fechado uma única vez.
+	"time"    // Timeouts de conexão e de leitura.
+
+	"golang.org/x/crypto/ssh" // Cliente SSH usado para acessar os equipamentos.
+)
+
+// ============== SESSÃO COM O EQUIPAMENTO ==============
+
+// Sessao é o canal de texto com o equipamento. Os drivers só conversam com o equipamento
+// através desta interface, o que permite trocar o SSH por uma sessão roteirizada (falsa).
+type Sessao interface {
+	// Enviar escreve uma linha (o "Enter" é adicionado automaticamente).
+	Enviar(linha string) error
+	// LerAte lê a saída até que o padrão seja encontrado e devolve tudo o que foi lido.
+	LerAte(padrao *regexp.Regexp) (string, error)
+	// Fechar encerra a sessão.
+	Fechar() error
+}
+
+// CredenciaisSSH reúne os dados necessários para abrir uma sessão SSH.
+type CredenciaisSSH struct {
+	Usuario     string
+	Senha       string
+	SenhaEnable string
+	Porta       int
+	Timeout     time.Duration
+}
+
+// sessaoSSH implementa Sessao sobre um shell interativo SSH.
+type sessaoSSH struct {
+	cliente *ssh.Client
+	sessao  *ssh.Session
+	entrada io.WriteCloser
+	saida   chan []byte   // Pedaços da saída, enviados pela goroutine de leitura.
+	erros   chan error    // Erro de leitura (ex: conexão fechada pelo equipamento).
+	fim     chan struct{} // Fechado pelo Fechar, para a goroutine de leitura não ficar presa.
+	fechar  sync.Once
+	buffer  bytes.Buffer
+	timeout time.Duration
+}
+
+// abrirSessaoSSH conecta ao equipamento e abre um shell interativo com pseudo-terminal.
+func abrirSessaoSSH(ip string, cred CredenciaisSSH) (Sessao, error) {
+	configSSH := &ssh.ClientConfig{
+		User: cred.Usuario,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(cred.Senha),
+			// Muitos equipamentos pedem a senha via "keyboard-interactive".
+			ssh.KeyboardInteractive(func(_, _ string, perguntas []string, _ []bool) ([]string, error) {
+				respostas := make([]string, len(perguntas))
+				for i := range respostas {
+					respostas[i] = cred.Senha
+				}
+				return respostas, nil
+			}),
+		},
+		// Os equipamentos de rede não têm as chaves cadastradas em known_hosts.
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         cred.Timeout,
+	}
+	// Equipamentos antigos ainda só falam algoritmos legados, então os habilitamos também.
+	configSSH.KeyExchanges = append(ssh.SupportedAlgorithms().KeyExchanges, "diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1")
+	configSSH.Ciphers = append(ssh.SupportedAlgorithms().Ciphers, "aes128-cbc", "3des-cbc")
+
+	endereco := net.JoinHostPort(ip, strconv.Itoa(cred.Porta))
+	cliente, err := ssh.Dial("tcp", endereco, configSSH)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao conectar em %s: %w", endereco, err)
+	}
+
+	sessao, err := cliente.NewSession()
+	if err != nil {
+		cliente.Close()
+		return nil, err
+	}
+
+	entrada, err := sessao.StdinPipe()
+	if err != nil {
+		cliente.Close()
+		return nil, err
+	}
+	saida, err := sessao.StdoutPipe()
+	if err != nil {
+		cliente.Close()
+		return nil, err
+	}
+
+	// Pede um terminal "largo" para que os equipamentos não quebrem as linhas da saída.
+	if err := sessao.RequestPty("vt100", 0, 512, ssh.TerminalModes{ssh.ECHO: 1}); err != nil {
+		cliente.Close()
+		return nil, err
+	}
+	if err := sessao.Shell(); err != nil {
+		cliente.Close()
+		return nil, err
+	}
+
+	s := &sessaoSSH{
+		cliente: cliente,
+		sessao:  sessao,
+		entrada: entrada,
+		saida:   make(chan []byte, 64),
+		erros:   make(chan error, 1),
+		fim:     make(chan struct{}),
+		timeout: cred.Timeout,
+	}
+	go s.lerContinuamente(saida)
+	return s, nil
+}
+
+// lerContinuamente copia a saída do SSH para o canal até a conexão ser encerrada ou a
+// sessão ser fechada (quando ninguém mais vai ler o canal).
+func (s *sessaoSSH) lerContinuamente(r io.Reader) {
+	pedaco := make([]byte, 4096)
+	for {
+		n, err := r.Read(pedaco)
+		if n > 0 {
+			copia := make([]byte, n)
+			copy(copia, pedaco[:n])
+			select {
+			case s.saida <- copia:
+			case <-s.fim:
+				return
+			}
+		}
+		if err != nil {
+			select {
+			case s.erros <- err:
+			case <-s.fim:
+			}
+			return
+		}
+	}
+}
+
+// Enviar implementa Sessao.
+func (s *sessaoSSH) Enviar(linha string) error {
+	_, err := io.WriteString(s.entrada, linha+"\n")
+	return err
+}
+
+// LerAte implementa Sessao.
+func (s *sessaoSSH) LerAte(padrao *regexp.Regexp) (string, error) {
+	limite := time.NewTimer(s.timeout)
+	defer limite.Stop()
+
+	for {
+		if padrao.Match(s.buffer.Bytes()) {
+			lido := s.buffer.String()
+			s.buffer.Reset()
+			return lido, nil
+		}
+
+		select {
+		case pedaco := <-s.saida:
+			s.buffer.Write(pedaco)
+		case err := <-s.erros:
+			return s.buffer.String(), fmt.Errorf("conexão encerrada pelo equipamento: %w", err)
+		case <-limite.C:
+			return s.buffer.String(), fmt.Errorf("tempo esgotado aguardando o prompt (%s)", s.timeout)
+		}
+	}
+}
+
+// Fechar implementa Sessao.
+func (s *sessaoSSH) Fechar() error {
+	s.fechar.Do(func() { close(s.fim) })
+	s.sessao.Close()
+	return s.cliente.Close()
+}
+
+// ============== EXECUÇÃO DE GRUPOS DE COMANDOS ==============
+
+// ResultadoComando guarda a saída de um comando executado em um equipamento.
+type ResultadoComando struct {
+	Comando string
+	Saida   string
+}
+
+// executarComandos prepara a sessão com o driver e executa os comandos em sequência.
+// Para grupos do tipo "configuracao", entra no modo de configuração antes e sai ao final.
+func executarComandos(s Sessao, d Driver, comandos []string, modoConfiguracao bool, senhaEnable string) ([]ResultadoComando, error) {
+	if err := d.Preparar(s, senhaEnable); err != nil {
+		return nil, err
+	}
+
+	if modoConfiguracao {
+		if err := d.EntrarModoConfiguracao(s); err != nil {
+			return nil, fmt.Errorf("falha ao entrar no modo de configuração: %w", err)
+		}
+	}
+
+	var resultados []ResultadoComando
+	for _, comando := range comandos {
+		saida, err := d.Executar(s, comando)
+		if err != nil {
+			return resultados, fmt.Errorf("falha ao executar '%s': %w", comando, err)
+		}
+		resultados = append(resultados, ResultadoComando{Comando: comando, Saida: saida})
+	}
+
+	if modoConfiguracao {
+		if err := d.SairModoConfiguracao(s); err != nil {
+			return resultados, fmt.Errorf("falha ao sair do modo de configuração: %w", err)
+		}
+	}
+	return resultados, nil
+}
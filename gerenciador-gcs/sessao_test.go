@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// passoRoteiro é uma troca esperada com o equipamento: o que deve ser enviado e o que o
+// equipamento responde (sem o eco, que a sessão roteirizada acrescenta sozinha).
+type passoRoteiro struct {
+	envio    string
+	resposta string
+}
+
+// sessaoRoteirizada implementa Sessao seguindo um roteiro fixo, como um equipamento falso.
+// Um envio fora do roteiro ou um prompt que nunca aparece vira erro, como no SSH real.
+type sessaoRoteirizada struct {
+	pendente string // Saída ainda não lida (começa com o banner e o primeiro prompt).
+	passos   []passoRoteiro
+	enviados []string
+	fechada  bool
+}
+
+// novaSessaoRoteirizada cria a sessão com a saída inicial (banner + prompt) e o roteiro.
+func novaSessaoRoteirizada(inicial string, passos ...passoRoteiro) *sessaoRoteirizada {
+	return &sessaoRoteirizada{pendente: inicial, passos: passos}
+}
+
+// Enviar implementa Sessao.
+func (s *sessaoRoteirizada) Enviar(linha string) error {
+	s.enviados = append(s.enviados, linha)
+	if len(s.passos) == 0 {
+		return fmt.Errorf("envio fora do roteiro: %q", linha)
+	}
+	passo := s.passos[0]
+	if passo.envio != linha {
+		return fmt.Errorf("esperava enviar %q, mas foi enviado %q", passo.envio, linha)
+	}
+	s.passos = s.passos[1:]
+	s.pendente += linha + "\r\n" + passo.resposta
+	return nil
+}
+
+// LerAte implementa Sessao.
+func (s *sessaoRoteirizada) LerAte(padrao *regexp.Regexp) (string, error) {
+	if !padrao.MatchString(s.pendente) {
+		return s.pendente, fmt.Errorf("tempo esgotado aguardando o prompt em %q", s.pendente)
+	}
+	lido := s.pendente
+	s.pendente = ""
+	return lido, nil
+}
+
+// Fechar implementa Sessao.
+func (s *sessaoRoteirizada) Fechar() error {
+	s.fechada = true
+	return nil
+}
+
+// conferirRoteiro falha o teste se sobrou algum passo do roteiro sem ser executado.
+func (s *sessaoRoteirizada) conferirRoteiro(t *testing.T) {
+	t.Helper()
+	if len(s.passos) > 0 {
+		t.Errorf("passos não executados: %+v (enviados: %q)", s.passos, s.enviados)
+	}
+}
+
+func TestExecutarComandosModoConfiguracao(t *testing.T) {
+	sessao := novaSessaoRoteirizada("Bem-vindo\r\n<CORE-01>",
+		passoRoteiro{"screen-length 0 temporary", "Info: The configuration takes effect on the current user terminal interface only.\r\n<CORE-01>"},
+		passoRoteiro{"system-view", "Enter system view, return user view with return command.\r\n[CORE-01]"},
+		passoRoteiro{"interface GigabitEthernet0/0/1", "[CORE-01-GigabitEthernet0/0/1]"},
+		passoRoteiro{"return", "<CORE-01>"},
+	)
+	d := drivers.porNome["huawei"]
+
+	resultados, err := executarComandos(sessao, d, []string{"interface GigabitEthernet0/0/1"}, true, "")
+	if err != nil {
+		t.Fatalf("executarComandos: %v", err)
+	}
+	sessao.conferirRoteiro(t)
+	if len(resultados) != 1 || resultados[0].Comando != "interface GigabitEthernet0/0/1" {
+		t.Fatalf("resultados inesperados: %+v", resultados)
+	}
+}
+
+func TestExecutarComandosParaNoPrimeiroErro(t *testing.T) {
+	sessao := novaSessaoRoteirizada("CORE-01>",
+		passoRoteiro{"enable", "CORE-01#"},
+		passoRoteiro{"terminal length 0", "CORE-01#"},
+		passoRoteiro{"show version", "Cisco IOS Software\r\nCORE-01#"},
+		// "show clock" nunca devolve o prompt: a execução deve parar com erro.
+		passoRoteiro{"show clock", "*10:00:00.000 UTC"},
+	)
+
+	resultados, err := executarComandos(sessao, drivers.porNome["cisco"], []string{"show version", "show clock", "show ip route"}, false, "")
+	if err == nil || !strings.Contains(err.Error(), "show clock") {
+		t.Fatalf("esperava erro em 'show clock', veio %v", err)
+	}
+	if len(resultados) != 1 || resultados[0].Saida != "Cisco IOS Software" {
+		t.Fatalf("resultados até o erro: %+v", resultados)
+	}
+	for _, enviado := range sessao.enviados {
+		if enviado == "show ip route" {
+			t.Fatal("comando enviado depois do erro")
+		}
+	}
+}
+
+// leitorInfinito devolve sempre o mesmo texto, como um equipamento que não para de falar.
+type leitorInfinito struct{}
+
+func (leitorInfinito) Read(p []byte) (int, error) {
+	return copy(p, "saida\r\n"), nil
+}
+
+// leitorComErro falha na primeira leitura, como uma conexão derrubada.
+type leitorComErro struct{}
+
+func (leitorComErro) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestLerContinuamenteTerminaAoFechar(t *testing.T) {
+	for nome, leitor := range map[string]io.Reader{"saida": leitorInfinito{}, "erro": leitorComErro{}} {
+		t.Run(nome, func(t *testing.T) {
+			// Canais sem buffer: sem o 'fim', a goroutine ficaria presa no primeiro envio.
+			s := &sessaoSSH{saida: make(chan []byte), erros: make(chan error), fim: make(chan struct{})}
+			terminou := make(chan struct{})
+			go func() {
+				s.lerContinuamente(leitor)
+				close(terminou)
+			}()
+
+			s.fechar.Do(func() { close(s.fim) })
+			select {
+			case <-terminou:
+			case <-time.After(time.Second):
+				t.Fatal("a goroutine de leitura não terminou depois do Fechar")
+			}
+		})
+	}
+}
+
+func TestLerContinuamenteEntregaErro(t *testing.T) {
+	s := &sessaoSSH{saida: make(chan []byte, 1), erros: make(chan error, 1), fim: make(chan struct{})}
+	s.lerContinuamente(leitorComErro{})
+	if err := <-s.erros; !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("erro entregue: %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"database/sql"   // Acesso à tabela 'grupos_templates'.
+	"embed"          // Biblioteca de templates embutida no binário.
+	"encoding/csv"   // Exportação da tabela parseada em CSV.
+	"encoding/json"  // Exportação da tabela parseada em JSON.
+	"fmt"            // Formatação das mensagens de erro e da saída.
+	"io"             // Destino genérico das exportações.
+	"io/fs"          // Listagem dos templates embutidos.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"os"             // Leitura dos templates personalizados no disco.
+	"path/filepath"  // Montagem dos caminhos dos templates personalizados.
+	"sort"           // Ordenação da listagem de templates.
+	"strconv"        // Conversão do ID informado na linha de comando.
+	"strings"        // Manipulação dos nomes dos arquivos.
+	"text/tabwriter" // Exibição da tabela parseada no console.
+
+	"github.com/spf13/cobra"
+)
+
+// A diretiva abaixo embute todos os arquivos da pasta 'templates' no binário,
+// assim a biblioteca de templates acompanha o executável sem arquivos extras.
+//
+//go:embed templates/*.textfsm
+var templatesEmbutidos embed.FS
+
+// extensaoTemplate é a extensão dos arquivos de template.
+const extensaoTemplate = ".textfsm"
+
+// carregarTemplate procura o template pelo nome: primeiro na pasta personalizada
+// (se configurada), depois na biblioteca embutida.
+func carregarTemplate(nome string) (*TemplateTextFSM, error) {
+	arquivo := nome + extensaoTemplate
+
+	if configuracao.DiretorioTemplates != "" {
+		caminho := filepath.Join(configuracao.DiretorioTemplates, arquivo)
+		if dados, err := os.ReadFile(caminho); err == nil {
+			return compilarTemplate(nome, string(dados))
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	dados, err := templatesEmbutidos.ReadFile("templates/" + arquivo)
+	if err != nil {
+		return nil, fmt.Errorf("template '%s' não encontrado", nome)
+	}
+	return compilarTemplate(nome, string(dados))
+}
+
+// listarNomesTemplates devolve os nomes dos templates disponíveis e sua origem.
+func listarNomesTemplates() (map[string]string, error) {
+	nomes := map[string]string{}
+
+	embutidos, err := fs.Glob(templatesEmbutidos, "templates/*"+extensaoTemplate)
+	if err != nil {
+		return nil, err
+	}
+	for _, caminho := range embutidos {
+		nomes[strings.TrimSuffix(filepath.Base(caminho), extensaoTemplate)] = "embutido"
+	}
+
+	// Os templates personalizados com o mesmo nome substituem os embutidos.
+	if configuracao.DiretorioTemplates != "" {
+		personalizados, err := filepath.Glob(filepath.Join(configuracao.DiretorioTemplates, "*"+extensaoTemplate))
+		if err != nil {
+			return nil, err
+		}
+		for _, caminho := range personalizados {
+			nomes[strings.TrimSuffix(filepath.Base(caminho), extensaoTemplate)] = caminho
+		}
+	}
+	return nomes, nil
+}
+
+// lerTextoTemplate devolve o conteúdo de um template, para exibição.
+func lerTextoTemplate(nome string) (string, error) {
+	arquivo := nome + extensaoTemplate
+	if configuracao.DiretorioTemplates != "" {
+		if dados, err := os.ReadFile(filepath.Join(configuracao.DiretorioTemplates, arquivo)); err == nil {
+			return string(dados), nil
+		}
+	}
+	dados, err := templatesEmbutidos.ReadFile("templates/" + arquivo)
+	if err != nil {
+		return "", fmt.Errorf("template '%s' não encontrado", nome)
+	}
+	return string(dados), nil
+}
+
+// ============== ASSOCIAÇÃO DE TEMPLATES AOS GRUPOS ==============
+
+// associarTemplate liga um template a um comando de um grupo (substitui a associação anterior).
+func associarTemplate(grupoID int, comando, nomeTemplate string) error {
+	grupo, err := buscarGrupoComandos(grupoID)
+	if err != nil {
+		return err
+	}
+
+	// Só aceita comandos que realmente fazem parte do grupo.
+	encontrado := false
+	for _, c := range grupo.ListaComandos() {
+		if c == comando {
+			encontrado = true
+			break
+		}
+	}
+	if !encontrado {
+		return fmt.Errorf("o comando '%s' não faz parte do grupo '%s'", comando, grupo.Nome)
+	}
+
+	// Valida o template antes de gravar, para não associar um arquivo com erro.
+	if _, err := carregarTemplate(nomeTemplate); err != nil {
+		return err
+	}
+
+	_, err = bancoDeDados.Exec("INSERT OR REPLACE INTO grupos_templates(grupo_id, comando, template) VALUES(?, ?, ?)", grupoID, comando, nomeTemplate)
+	return err
+}
+
+// removerTemplate desfaz a associação de um template a um comando de um grupo.
+func removerTemplate(grupoID int, comando string) error {
+	res, err := bancoDeDados.Exec("DELETE FROM grupos_templates WHERE grupo_id = ? AND comando = ?", grupoID, comando)
+	if err != nil {
+		return err
+	}
+	linhasAfetadas, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if linhasAfetadas == 0 {
+		return fmt.Errorf("o comando '%s' não possui template no grupo %d", comando, grupoID)
+	}
+	return nil
+}
+
+// templateDoComando devolve o nome do template associado ao comando (vazio se não houver).
+func templateDoComando(grupoID int, comando string) (string, error) {
+	var nome string
+	err := bancoDeDados.QueryRow("SELECT template FROM grupos_templates WHERE grupo_id = ? AND comando = ?", grupoID, comando).Scan(&nome)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return nome, err
+}
+
+// ============== EXPORTAÇÃO DA TABELA PARSEADA ==============
+
+// textoCelula converte uma célula (string ou lista) para texto.
+func textoCelula(celula any) string {
+	if lista, ok := celula.([]string); ok {
+		return strings.Join(lista, ", ")
+	}
+	return fmt.Sprint(celula)
+}
+
+// escreverTabela exibe a tabela parseada alinhada no console.
+func (t *TabelaParseada) escreverTabela(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Campos, "\t"))
+	separadores := make([]string, len(t.Campos))
+	for i, campo := range t.Campos {
+		separadores[i] = strings.Repeat("-", len(campo))
+	}
+	fmt.Fprintln(tw, strings.Join(separadores, "\t"))
+
+	for _, linha := range t.Linhas {
+		celulas := make([]string, len(linha))
+		for i, celula := range linha {
+			celulas[i] = textoCelula(celula)
+		}
+		fmt.Fprintln(tw, strings.Join(celulas, "\t"))
+	}
+	return tw.Flush()
+}
+
+// escreverJSON exporta a tabela como uma lista de objetos (campo -> valor).
+func (t *TabelaParseada) escreverJSON(w io.Writer) error {
+	registros := make([]map[string]any, 0, len(t.Linhas))
+	for _, linha := range t.Linhas {
+		registro := map[string]any{}
+		for i, campo := range t.Campos {
+			registro[campo] = linha[i]
+		}
+		registros = append(registros, registro)
+	}
+	codificador := json.NewEncoder(w)
+	codificador.SetIndent("", "  ")
+	return codificador.Encode(registros)
+}
+
+// escreverCSV exporta a tabela em CSV, com os nomes dos campos no cabeçalho.
+func (t *TabelaParseada) escreverCSV(w io.Writer) error {
+	escritor := csv.NewWriter(w)
+	if err := escritor.Write(t.Campos); err != nil {
+		return err
+	}
+	for _, linha := range t.Linhas {
+		celulas := make([]string, len(linha))
+		for i, celula := range linha {
+			celulas[i] = textoCelula(celula)
+		}
+		if err := escritor.Write(celulas); err != nil {
+			return err
+		}
+	}
+	escritor.Flush()
+	return escritor.Error()
+}
+
+// escrever exporta a tabela no formato pedido: "tabela", "json" ou "csv".
+func (t *TabelaParseada) escrever(w io.Writer, formato string) error {
+	switch strings.ToLower(formato) {
+	case "", "tabela":
+		return t.escreverTabela(w)
+	case "json":
+		return t.escreverJSON(w)
+	case "csv":
+		return t.escreverCSV(w)
+	default:
+		return fmt.Errorf("formato '%s' desconhecido (use tabela, json ou csv)", formato)
+	}
+}
+
+// nomesOrdenados devolve as chaves de um mapa em ordem alfabética.
+func nomesOrdenados(m map[string]string) []string {
+	nomes := make([]string, 0, len(m))
+	for nome := range m {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoTemplate = &cobra.Command{
+	Use:     "template",
+	Short:   "Consulta e testa os templates de parsing de saídas.",
+	Aliases: []string{"tpl"},
+}
+
+var comandoListTemplate = &cobra.Command{
+	Use:         "list",
+	Short:       "Lista os templates disponíveis.",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		nomes, err := listarNomesTemplates()
+		if err != nil {
+			log.Fatalf("Erro ao listar templates: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NOME\tORIGEM")
+		fmt.Fprintln(w, "----\t------")
+		for _, nome := range nomesOrdenados(nomes) {
+			fmt.Fprintf(w, "%s\t%s\n", nome, nomes[nome])
+		}
+		w.Flush()
+	},
+}
+
+var comandoShowTemplate = &cobra.Command{
+	Use:         "show [nome]",
+	Short:       "Exibe o conteúdo de um template.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		texto, err := lerTextoTemplate(args[0])
+		if err != nil {
+			log.Fatalf("Erro ao ler template: %v", err)
+		}
+		fmt.Print(texto)
+	},
+}
+
+var comandoTestarTemplate = &cobra.Command{
+	Use:         "testar [nome] [arquivo-com-saida]",
+	Short:       "Aplica um template a uma saída salva em arquivo.",
+	Args:        cobra.ExactArgs(2),
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		template, err := carregarTemplate(args[0])
+		if err != nil {
+			log.Fatalf("Erro ao carregar template: %v", err)
+		}
+		dados, err := os.ReadFile(args[1])
+		if err != nil {
+			log.Fatalf("Erro ao ler arquivo: %v", err)
+		}
+		tabela, err := template.Processar(string(dados))
+		if err != nil {
+			log.Fatalf("Erro ao parsear a saída: %v", err)
+		}
+		formato, _ := cmd.Flags().GetString("formato")
+		if err := tabela.escrever(os.Stdout, formato); err != nil {
+			log.Fatalf("Erro ao exportar a saída: %v", err)
+		}
+	},
+}
+
+var comandoTemplateGrupo = &cobra.Command{
+	Use:         "template [ID]",
+	Short:       "Associa um template de parsing a um comando do grupo.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("ID inválido: '%s'. Deve ser um número.", args[0])
+		}
+		comando, _ := cmd.Flags().GetString("comando")
+		nomeTemplate, _ := cmd.Flags().GetString("template")
+		remover, _ := cmd.Flags().GetBool("remover")
+
+		if remover {
+			if err := removerTemplate(id, comando); err != nil {
+				log.Fatalf("Erro ao remover template: %v", err)
+			}
+			fmt.Println("Template removido com sucesso!")
+			return
+		}
+		if nomeTemplate == "" {
+			log.Fatal("Informe o template com --template (ou use --remover).")
+		}
+		if err := associarTemplate(id, comando, nomeTemplate); err != nil {
+			log.Fatalf("Erro ao associar template: %v", err)
+		}
+		fmt.Printf("Template '%s' associado ao comando '%s'.\n", nomeTemplate, comando)
+	},
+}
+
+func init() {
+	comandoRaiz.AddCommand(comandoTemplate)
+	comandoTemplate.AddCommand(comandoListTemplate, comandoShowTemplate, comandoTestarTemplate)
+	comandoTestarTemplate.Flags().String("formato", "tabela", "Formato da saída: tabela, json ou csv")
+
+	comandoGrupo.AddCommand(comandoTemplateGrupo)
+	comandoTemplateGrupo.Flags().String("comando", "", "Comando do grupo que receberá o template")
+	comandoTemplateGrupo.Flags().String("template", "", "Nome do template (veja 'template list')")
+	comandoTemplateGrupo.Flags().Bool("remover", false, "Remove o template associado ao comando")
+	comandoTemplateGrupo.MarkFlagRequired("comando")
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"   // Leitura do template e da saída linha a linha.
+	"fmt"     // Formatação das mensagens de erro.
+	"regexp"  // As regras do template são expressões regulares.
+	"strings" // Manipulação das linhas do template.
+)
+
+// ============== TEMPLATES NO ESTILO TEXTFSM ==============
+//
+// Um template descreve uma máquina de estados que transforma a saída de um comando
+// (texto livre) em uma tabela. Ele tem duas partes, separadas por uma linha em branco:
+//
+//	Value [Opcoes] NOME (regex)     <- declaração dos campos
+//
+//	Start                           <- estados, cada um com suas regras
+//	  ^regex com ${NOME} -> Acao
+//
+// Opções dos valores: Filldown, Fillup, Required e List. A opção Key do TextFSM original
+// só serve para quem compara tabelas e é recusada, em vez de aceita sem efeito.
+// Ações: Next/Continue, Record/NoRecord/Clear/Clearall, troca de estado e Error.
+
+// TabelaParseada é o resultado da aplicação de um template: uma linha por registro.
+// Cada célula é uma string, ou uma lista de strings para os valores com a opção List.
+type TabelaParseada struct {
+	Campos []string
+	Linhas [][]any
+}
+
+// valorTemplate é a declaração de um campo ('Value') do template.
+type valorTemplate struct {
+	nome   string
+	regex  string
+	opcoes map[string]bool
+}
+
+// regraTemplate é uma linha de regra dentro de um estado.
+type regraTemplate struct {
+	re          *regexp.Regexp
+	operacao    string // Next ou Continue.
+	registro    string // NoRecord, Record, Clear ou Clearall.
+	novoEstado  string
+	mensagemErr string // Preenchida quando a ação é Error.
+}
+
+// TemplateTextFSM é um template já compilado e pronto para uso.
+type TemplateTextFSM struct {
+	Nome    string
+	valores []*valorTemplate
+	estados map[string][]regraTemplate
+}
+
+var (
+	// reValor reconhece "Value [Opcoes] NOME (regex)".
+	reValor = regexp.MustCompile(`^Value\s+(?:([\w,]+)\s+)?(\w+)\s+(\(.*\))\s*$`)
+	// reAcao separa a regra da ação, que vem depois do último " -> ".
+	reAcao = regexp.MustCompile(`^(.*)\s+->\s+(.*)$`)
+	// reOperacoes reconhece "Continue.Record NovoEstado" e suas variações.
+	reOperacoes = regexp.MustCompile(`^(?:(Next|Continue)(?:\.(NoRecord|Record|Clearall|Clear))?|(NoRecord|Record|Clearall|Clear))?\s*(\w+)?$`)
+	// reErro reconhece "Error" com uma mensagem opcional entre aspas.
+	reErro = regexp.MustCompile(`^Error(?:\s+"(.*)")?$`)
+)
+
+// opcoesValidas lista as opções aceitas na declaração dos valores.
+var opcoesValidas = map[string]bool{"Filldown": true, "Fillup": true, "Required": true, "List": true}
+
+// compilarTemplate interpreta o texto de um template e compila suas expressões regulares.
+func compilarTemplate(nome, texto string) (*TemplateTextFSM, error) {
+	t := &TemplateTextFSM{Nome: nome, estados: map[string][]regraTemplate{}}
+	indicePorNome := map[string]*valorTemplate{}
+
+	leitor := bufio.NewScanner(strings.NewReader(texto))
+	numeroLinha := 0
+	lendoValores := true
+	estadoAtual := ""
+
+	for leitor.Scan() {
+		numeroLinha++
+		linha := strings.TrimRight(leitor.Text(), " \t\r")
+
+		// Comentários e linhas em branco dentro dos estados são ignorados.
+		if strings.HasPrefix(strings.TrimSpace(linha), "#") {
+			continue
+		}
+
+		if lendoValores {
+			if linha == "" {
+				if len(t.valores) > 0 {
+					lendoValores = false
+				}
+				continue
+			}
+			m := reValor.FindStringSubmatch(linha)
+			if m == nil {
+				return nil, fmt.Errorf("template '%s', linha %d: declaração 'Value' inválida", nome, numeroLinha)
+			}
+			v := &valorTemplate{nome: m[2], regex: m[3], opcoes: map[string]bool{}}
+			if m[1] != "" {
+				for _, opcao := range strings.Split(m[1], ",") {
+					if opcao == "Key" {
+						return nil, fmt.Errorf("template '%s', linha %d: a opção 'Key' não é suportada", nome, numeroLinha)
+					}
+					if !opcoesValidas[opcao] {
+						return nil, fmt.Errorf("template '%s', linha %d: opção '%s' desconhecida", nome, numeroLinha, opcao)
+					}
+					v.opcoes[opcao] = true
+				}
+			}
+			if _, existe := indicePorNome[v.nome]; existe {
+				return nil, fmt.Errorf("template '%s', linha %d: valor '%s' duplicado", nome, numeroLinha, v.nome)
+			}
+			indicePorNome[v.nome] = v
+			t.valores = append(t.valores, v)
+			continue
+		}
+
+		if linha == "" {
+			estadoAtual = ""
+			continue
+		}
+
+		// Linhas sem indentação abrem um novo estado.
+		if !strings.HasPrefix(linha, " ") && !strings.HasPrefix(linha, "\t") {
+			estadoAtual = linha
+			if _, existe := t.estados[estadoAtual]; existe {
+				return nil, fmt.Errorf("template '%s', linha %d: estado '%s' duplicado", nome, numeroLinha, estadoAtual)
+			}
+			t.estados[estadoAtual] = nil
+			continue
+		}
+
+		if estadoAtual == "" {
+			return nil, fmt.Errorf("template '%s', linha %d: regra fora de um estado", nome, numeroLinha)
+		}
+		regra, err := t.compilarRegra(strings.TrimSpace(linha), indicePorNome)
+		if err != nil {
+			return nil, fmt.Errorf("template '%s', linha %d: %w", nome, numeroLinha, err)
+		}
+		t.estados[estadoAtual] = append(t.estados[estadoAtual], regra)
+	}
+
+	if len(t.valores) == 0 {
+		return nil, fmt.Errorf("template '%s' não declara nenhum 'Value'", nome)
+	}
+	if _, ok := t.estados["Start"]; !ok {
+		return nil, fmt.Errorf("template '%s' não possui o estado 'Start'", nome)
+	}
+	// Confere se todas as trocas de estado apontam para estados existentes.
+	for estado, regras := range t.estados {
+		for _, r := range regras {
+			if r.novoEstado == "" || r.novoEstado == "End" || r.novoEstado == "EOF" {
+				continue
+			}
+			if _, ok := t.estados[r.novoEstado]; !ok {
+				return nil, fmt.Errorf("template '%s': estado '%s' aponta para '%s', que não existe", nome, estado, r.novoEstado)
+			}
+		}
+	}
+	return t, nil
+}
+
+// compilarRegra interpreta uma linha de regra ("^regex -> Acao").
+func (t *TemplateTextFSM) compilarRegra(linha string, valores map[string]*valorTemplate) (regraTemplate, error) {
+	regra := regraTemplate{operacao: "Next", registro: "NoRecord"}
+	if !strings.HasPrefix(linha, "^") {
+		return regra, fmt.Errorf("a regra deve começar com '^'")
+	}
+
+	padrao := linha
+	if m := reAcao.FindStringSubmatch(linha); m != nil {
+		padrao = strings.TrimSpace(m[1])
+		acao := strings.TrimSpace(m[2])
+
+		if e := reErro.FindStringSubmatch(acao); e != nil {
+			regra.operacao = "Error"
+			regra.mensagemErr = e[1]
+		} else {
+			o := reOperacoes.FindStringSubmatch(acao)
+			if o == nil {
+				return regra, fmt.Errorf("ação '%s' inválida", acao)
+			}
+			if o[1] != "" {
+				regra.operacao = o[1]
+			}
+			if o[2] != "" {
+				regra.registro = o[2]
+			}
+			if o[3] != "" {
+				regra.registro = o[3]
+			}
+			regra.novoEstado = o[4]
+			if regra.operacao == "Continue" && regra.novoEstado != "" {
+				return regra, fmt.Errorf("'Continue' não pode trocar de estado")
+			}
+		}
+	}
+
+	// Substitui ${NOME} pelo grupo nomeado correspondente e "$$" por "$".
+	var erroSubstituicao error
+	expandido := regexp.MustCompile(`\$\{(\w+)\}`).ReplaceAllStringFunc(padrao, func(trecho string) string {
+		nome := trecho[2 : len(trecho)-1]
+		v, ok := valores[nome]
+		if !ok {
+			erroSubstituicao = fmt.Errorf("valor '%s' não declarado", nome)
+			return trecho
+		}
+		return "(?P<" + nome + ">" + v.regex[1:]
+	})
+	if erroSubstituicao != nil {
+		return regra, erroSubstituicao
+	}
+	expandido = strings.ReplaceAll(expandido, "$$", "$")
+
+	re, err := regexp.Compile(expandido)
+	if err != nil {
+		return regra, fmt.Errorf("regex inválida: %w", err)
+	}
+	regra.re = re
+	return regra, nil
+}
+
+// Processar aplica o template à saída de um comando e devolve a tabela resultante.
+func (t *TemplateTextFSM) Processar(saida string) (*TabelaParseada, error) {
+	tabela := &TabelaParseada{}
+	for _, v := range t.valores {
+		tabela.Campos = append(tabela.Campos, v.nome)
+	}
+
+	// O estado da execução fica em variáveis locais, para que o template possa ser reutilizado.
+	atuais := make([]string, len(t.valores))
+	listas := make([][]string, len(t.valores))
+
+	limpar := func(tudo bool) {
+		for i, v := range t.valores {
+			if tudo || !v.opcoes["Filldown"] {
+				atuais[i] = ""
+				listas[i] = nil
+			}
+		}
+	}
+
+	gravar := func() {
+		vazio := true
+		linha := make([]any, len(t.valores))
+		for i, v := range t.valores {
+			if v.opcoes["List"] {
+				if v.opcoes["Required"] && len(listas[i]) == 0 {
+					limpar(false)
+					return
+				}
+				linha[i] = append([]string{}, listas[i]...)
+				vazio = vazio && len(listas[i]) == 0
+				continue
+			}
+			if v.opcoes["Required"] && atuais[i] == "" {
+				limpar(false)
+				return
+			}
+			linha[i] = atuais[i]
+			vazio = vazio && atuais[i] == ""
+		}
+		// Registros totalmente vazios não são gravados.
+		if !vazio {
+			tabela.Linhas = append(tabela.Linhas, linha)
+		}
+		limpar(false)
+	}
+
+	atribuir := func(i int, valor string) {
+		v := t.valores[i]
+		if v.opcoes["List"] {
+			listas[i] = append(listas[i], valor)
+			return
+		}
+		atuais[i] = valor
+		// Fillup preenche, de baixo para cima, os registros anteriores que ficaram vazios.
+		if v.opcoes["Fillup"] {
+			for j := len(tabela.Linhas) - 1; j >= 0; j-- {
+				if tabela.Linhas[j][i] != "" {
+					break
+				}
+				tabela.Linhas[j][i] = valor
+			}
+		}
+	}
+
+	indices := map[string]int{}
+	for i, v := range t.valores {
+		indices[v.nome] = i
+	}
+
+	estado := "Start"
+	leitor := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(saida, "\r", "")))
+	leitor.Buffer(make([]byte, 64*1024), 1024*1024)
+
+linhas:
+	for leitor.Scan() {
+		linha := leitor.Text()
+
+		for _, regra := range t.estados[estado] {
+			m := regra.re.FindStringSubmatch(linha)
+			if m == nil {
+				continue
+			}
+			for j, nome := range regra.re.SubexpNames() {
+				if i, ok := indices[nome]; ok && j < len(m) {
+					atribuir(i, m[j])
+				}
+			}
+
+			if regra.operacao == "Error" {
+				if regra.mensagemErr != "" {
+					return tabela, fmt.Errorf("template '%s': %s (linha: %q)", t.Nome, regra.mensagemErr, linha)
+				}
+				return tabela, fmt.Errorf("template '%s': linha não esperada: %q", t.Nome, linha)
+			}
+
+			switch regra.registro {
+			case "Record":
+				gravar()
+			case "Clear":
+				limpar(false)
+			case "Clearall":
+				limpar(true)
+			}
+
+			if regra.operacao == "Continue" {
+				continue
+			}
+			if regra.novoEstado != "" {
+				estado = regra.novoEstado
+			}
+			if estado == "End" || estado == "EOF" {
+				break linhas
+			}
+			continue linhas
+		}
+	}
+	if err := leitor.Err(); err != nil {
+		return tabela, err
+	}
+
+	// Ao final da saída há um "Record" implícito, a menos que o template declare
+	// um estado EOF vazio para desativá-lo.
+	if regrasEOF, declarado := t.estados["EOF"]; !declarado || len(regrasEOF) > 0 {
+		if estado != "End" {
+			gravar()
+		}
+	}
+	return tabela, nil
+}
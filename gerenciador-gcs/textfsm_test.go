@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// linhasDaTabela devolve cada registro da tabela com as células separadas por " | ".
+func linhasDaTabela(tabela *TabelaParseada) []string {
+	var linhas []string
+	for _, registro := range tabela.Linhas {
+		celulas := make([]string, len(registro))
+		for i, celula := range registro {
+			celulas[i] = fmt.Sprint(celula)
+		}
+		linhas = append(linhas, strings.Join(celulas, " | "))
+	}
+	return linhas
+}
+
+// conferirTabela compara os registros da tabela com os esperados.
+func conferirTabela(t *testing.T, nome string, tabela *TabelaParseada, esperadas []string) {
+	t.Helper()
+	if obtidas := linhasDaTabela(tabela); strings.Join(obtidas, "\n") != strings.Join(esperadas, "\n") {
+		t.Errorf("%s:\n%s\nesperava:\n%s", nome, strings.Join(obtidas, "\n"), strings.Join(esperadas, "\n"))
+	}
+}
+
+// Cada template embutido é aplicado a uma saída real do comando, gravada em
+// testdata/templates/<template>.txt.
+func TestTemplatesEmbutidos(t *testing.T) {
+	esperados := map[string][]string{
+		"cisco_show_cdp_neighbors_detail": {
+			"SW-CORE-01.empresa.local | 10.0.0.1 | cisco WS-C3850-48T | GigabitEthernet1/0/49 | TenGigabitEthernet1/1/1",
+			"AP-SALA-02 | 192.168.10.51 | cisco AIR-AP2802I-Z-K9 | GigabitEthernet1/0/11 | GigabitEthernet0",
+		},
+		"cisco_show_ip_interface_brief": {
+			"GigabitEthernet0/0 | 10.0.0.1 | up | up",
+			"GigabitEthernet0/1 | unassigned | administratively down | down",
+			"GigabitEthernet0/2 | unassigned | down | down",
+			"Vlan10 | 192.168.10.1 | up | down",
+		},
+		"cisco_show_version": {
+			"15.2(7)E4 | SW-ACESSO-01 | 1 year, 12 weeks, 3 days, 4 hours, 10 minutes | WS-C2960X-48FPD-L | FOC1234X0AB",
+		},
+		"huawei_display_interface_brief": {
+			"GigabitEthernet0/0/1 | up | up | 0.01% | 0.02% | 0 | 0",
+			"GigabitEthernet0/0/2 | *down | down | 0% | 0% | 0 | 0",
+			"LoopBack0 | up | up(s) | 0% | 0% | 0 | 0",
+			"NULL0 | up | up(s) | 0% | 0% | 0 | 0",
+			"Vlanif100 | up | up | 0.10% | 0.05% | 3 | 1",
+		},
+		"huawei_display_lldp_neighbor_brief": {
+			"GE0/0/1 | SW-ACESSO-02 | Gi1/0/48",
+			"XGE0/0/1 | PE-02 | 1/1/2",
+		},
+		"huawei_display_version": {
+			"8.180 | NE40E | V800R011C00SPC607B607 | 120 days, 3 hours, 25 minutes",
+		},
+		"huawei_olt_display_ont_info": {
+			"0/ 1/0 | 0 | 485754430A1B2C3D | active | online | normal | match",
+			"0/ 1/0 | 1 | 485754430A1B2C3E | active | offline | initial | initial",
+			"0/ 1/0 | 2 | 5A5445470A000001 | deactivated | offline | normal | match",
+		},
+		"huawei_olt_display_version": {
+			"MA5800V100R019C10 | SPH210 | MA5800-X7 | 35 day(s), 4 hour(s), 12 minute(s), 3 second(s)",
+		},
+		"nokia_show_port": {
+			"1/1/1 | Up | Yes | Up | 9212 | netw",
+			"1/1/2 | Up | No | Down | 9212 | netw",
+			"1/1/3 | Down | No | Down | 1514 | accs",
+		},
+		"nokia_show_system_lldp_neighbor": {
+			"1/1/1 | D0:99:D5:11:22:33 | 1/1/1 | PE-02",
+			"1/1/3 | 00:E0:FC:AA:BB:CC | GE0/0/3 | CORE-02",
+		},
+		"zte_show_gpon_onu_state": {
+			"1/2/1:1 | enable | enable | working | 1(GPON)",
+			"1/2/1:2 | enable | disable | LOS | 1(GPON)",
+			"1/2/1:3 | disable | disable | OffLine | 1(GPON)",
+		},
+		"zte_show_version": {
+			"ZXA10 C300 | V2.1.0 | 45 days 6 hours 2 minutes",
+		},
+	}
+
+	// Todo template da biblioteca precisa de uma amostra aqui.
+	embutidos, err := fs.Glob(templatesEmbutidos, "templates/*"+extensaoTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embutidos) != len(esperados) {
+		t.Errorf("%d templates embutidos, %d com amostra", len(embutidos), len(esperados))
+	}
+	for _, caminho := range embutidos {
+		nome := strings.TrimSuffix(filepath.Base(caminho), extensaoTemplate)
+		esperadas, ok := esperados[nome]
+		if !ok {
+			t.Errorf("template '%s' sem amostra em testdata/templates", nome)
+			continue
+		}
+		template, err := carregarTemplate(nome)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saida, err := os.ReadFile(filepath.Join("testdata", "templates", nome+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tabela, err := template.Processar(string(saida))
+		if err != nil {
+			t.Fatalf("%s: %v", nome, err)
+		}
+		conferirTabela(t, nome, tabela, esperadas)
+	}
+}
+
+func TestOpcoesDosValores(t *testing.T) {
+	// CHASSIS desce para os slots seguintes (Filldown), TOTAL sobe para os registros
+	// anteriores (Fillup), PORTAS acumula as linhas (List) e o registro sem SLOT é
+	// descartado (Required).
+	template, err := compilarTemplate("slots", `Value Filldown CHASSIS (\S+)
+Value Required SLOT (\d+)
+Value List PORTAS (\d+/\d+)
+Value Fillup TOTAL (\d+)
+
+Start
+  ^Chassis -> Continue.Record
+  ^Chassis ${CHASSIS}
+  ^Slot -> Continue.Record
+  ^Slot ${SLOT}
+  ^\s+porta ${PORTAS}
+  ^Total ${TOTAL}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tabela, err := template.Processar(`Chassis A
+Slot 1
+ porta 1/1
+ porta 1/2
+Slot 2
+Chassis B
+Slot 3
+ porta 3/1
+Total 3
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conferirTabela(t, "slots", tabela, []string{
+		"A | 1 | [1/1 1/2] | 3",
+		"A | 2 | [] | 3",
+		"B | 3 | [3/1] | 3",
+	})
+}
+
+func TestTemplateInvalido(t *testing.T) {
+	casos := []struct {
+		nome, texto, erro string
+	}{
+		{"key", "Value Key,Required NOME (\\S+)\n\nStart\n  ^${NOME} -> Record\n", "a opção 'Key' não é suportada"},
+		{"opcao", "Value Unica NOME (\\S+)\n\nStart\n  ^${NOME} -> Record\n", "opção 'Unica' desconhecida"},
+		{"duplicado", "Value NOME (\\S+)\nValue NOME (\\d+)\n\nStart\n  ^${NOME} -> Record\n", "valor 'NOME' duplicado"},
+	}
+	for _, caso := range casos {
+		if _, err := compilarTemplate(caso.nome, caso.texto); err == nil || !strings.Contains(err.Error(), caso.erro) {
+			t.Errorf("%s: esperava o erro %q, veio %v", caso.nome, caso.erro, err)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"database/sql"   // Acesso à tabela 'enlaces'.
+	"encoding/json"  // Exportação do grafo em JSON.
+	"fmt"            // Formatação das mensagens e das exportações.
+	"io"             // Destino genérico das exportações.
+	"log"            // Mensagens de erro fatais dos comandos.
+	"net"            // Reconhecimento de nomes de vizinhos que são endereços IP.
+	"os"             // Saída padrão e arquivo de exportação.
+	"regexp"         // Limpeza dos identificadores usados no DOT e no Mermaid.
+	"sort"           // Ordem estável dos nós e enlaces exportados.
+	"strings"        // Normalização dos nomes dos vizinhos.
+	"text/tabwriter" // Tabela com os enlaces.
+	"time"           // Data/hora da descoberta.
+
+	"github.com/spf13/cobra"
+)
+
+// ============== ESTRUTURAS ==============
+
+// Enlace representa uma linha da tabela 'enlaces': uma porta do equipamento ligada a um vizinho.
+// VizinhoID é zero quando o vizinho ainda não está no inventário.
+type Enlace struct {
+	ID                int
+	EquipamentoID     int
+	InterfaceLocal    string
+	VizinhoID         int
+	VizinhoNome       string
+	VizinhoInterface  string
+	VizinhoIP         string
+	VizinhoPlataforma string
+	Protocolo         string
+	DescobertoEm      string
+}
+
+// ColetorVizinhos executa o comando de vizinhos em um equipamento e devolve a saída.
+// A implementação padrão usa SSH; nos testes pode ser trocada por saídas gravadas.
+type ColetorVizinhos func(equip Equipamento, comando string) (string, error)
+
+// coletarVizinhosSSH é o ColetorVizinhos usado pela CLI.
+func coletarVizinhosSSH(equip Equipamento, comando string) (string, error) {
+	resultados, err := executarGrupoNoEquipamento(GrupoComandos{Nome: "topologia", Comandos: comando}, equip)
+	if err != nil {
+		return "", err
+	}
+	if len(resultados) == 0 {
+		return "", fmt.Errorf("o comando '%s' não devolveu saída", comando)
+	}
+	return resultados[0].Saida, nil
+}
+
+// ============== INTERPRETAÇÃO DOS VIZINHOS ==============
+
+// Os templates de vizinhos (LLDP e CDP) usam os mesmos nomes de coluna, para que a
+// topologia não dependa do fabricante. Apenas NEIGHBOR e LOCAL_INTERFACE são obrigatórias.
+const (
+	colunaVizinho           = "NEIGHBOR"
+	colunaInterfaceLocal    = "LOCAL_INTERFACE"
+	colunaInterfaceVizinho  = "NEIGHBOR_INTERFACE"
+	colunaIPVizinho         = "MGMT_IP"
+	colunaPlataformaVizinho = "PLATFORM"
+)
+
+// enlacesDaTabela converte a tabela parseada do comando de vizinhos em enlaces.
+func enlacesDaTabela(equipamentoID int, tabela *TabelaParseada, protocolo string) ([]Enlace, error) {
+	indices := map[string]int{}
+	for i, campo := range tabela.Campos {
+		indices[campo] = i
+	}
+	for _, obrigatoria := range []string{colunaVizinho, colunaInterfaceLocal} {
+		if _, ok := indices[obrigatoria]; !ok {
+			return nil, fmt.Errorf("o template de vizinhos não possui a coluna %s", obrigatoria)
+		}
+	}
+
+	celula := func(linha []any, coluna string) string {
+		if i, ok := indices[coluna]; ok {
+			return strings.TrimSpace(textoCelula(linha[i]))
+		}
+		return ""
+	}
+
+	var enlaces []Enlace
+	for _, linha := range tabela.Linhas {
+		e := Enlace{
+			EquipamentoID:     equipamentoID,
+			InterfaceLocal:    celula(linha, colunaInterfaceLocal),
+			VizinhoNome:       celula(linha, colunaVizinho),
+			VizinhoInterface:  celula(linha, colunaInterfaceVizinho),
+			VizinhoIP:         celula(linha, colunaIPVizinho),
+			VizinhoPlataforma: celula(linha, colunaPlataformaVizinho),
+			Protocolo:         protocolo,
+		}
+		if e.VizinhoNome == "" {
+			continue
+		}
+		enlaces = append(enlaces, e)
+	}
+	return enlaces, nil
+}
+
+// nomeCurto normaliza o nome anunciado pelo vizinho: minúsculo, sem domínio
+// (SW1.empresa.local -> sw1) e sem o número de série que o NX-OS acrescenta (SW1(FOX123)).
+func nomeCurto(nome string) string {
+	nome = strings.ToLower(strings.TrimSpace(nome))
+	if i := strings.Index(nome, "("); i > 0 {
+		nome = nome[:i]
+	}
+	if net.ParseIP(nome) != nil {
+		return nome
+	}
+	if i := strings.Index(nome, "."); i > 0 {
+		nome = nome[:i]
+	}
+	return nome
+}
+
+// resolverVizinhos procura cada vizinho no inventário: primeiro pelo IP de gerência,
+// depois pelo nome.
+func resolverVizinhos(enlaces []Enlace, inventario []Equipamento) {
+	porIP := map[string]int{}
+	porNome := map[string]int{}
+	for _, e := range inventario {
+		porIP[e.IP] = e.ID
+		porNome[nomeCurto(e.Nome)] = e.ID
+	}
+	for i := range enlaces {
+		if id, ok := porIP[enlaces[i].VizinhoIP]; ok && enlaces[i].VizinhoIP != "" {
+			enlaces[i].VizinhoID = id
+		} else if id, ok := porNome[nomeCurto(enlaces[i].VizinhoNome)]; ok {
+			enlaces[i].VizinhoID = id
+		}
+	}
+}
+
+// ============== LÓGICA DE CRUD - ENLACES ==============
+
+// gravarEnlaces substitui os enlaces de um equipamento pelos descobertos agora.
+func gravarEnlaces(equipamentoID int, enlaces []Enlace) error {
+	tx, err := bancoDeDados.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM enlaces WHERE equipamento_id = ?", equipamentoID); err != nil {
+		return err
+	}
+	agora := time.Now().Format(time.RFC3339)
+	for _, e := range enlaces {
+		var vizinhoID sql.NullInt64
+		if e.VizinhoID != 0 {
+			vizinhoID = sql.NullInt64{Int64: int64(e.VizinhoID), Valid: true}
+		}
+		_, err := tx.Exec(`INSERT INTO enlaces(equipamento_id, interface_local, vizinho_id, vizinho_nome, vizinho_interface,
+			vizinho_ip, vizinho_plataforma, protocolo, descoberto_em) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			equipamentoID, e.InterfaceLocal, vizinhoID, e.VizinhoNome, e.VizinhoInterface,
+			e.VizinhoIP, e.VizinhoPlataforma, e.Protocolo, agora)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// buscarEnlaces carrega todos os enlaces gravados.
+func buscarEnlaces() ([]Enlace, error) {
+	rows, err := bancoDeDados.Query(`SELECT id, equipamento_id, interface_local, COALESCE(vizinho_id, 0), vizinho_nome,
+		vizinho_interface, vizinho_ip, vizinho_plataforma, protocolo, descoberto_em FROM enlaces ORDER BY equipamento_id, interface_local`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var enlaces []Enlace
+	for rows.Next() {
+		var e Enlace
+		if err := rows.Scan(&e.ID, &e.EquipamentoID, &e.InterfaceLocal, &e.VizinhoID, &e.VizinhoNome,
+			&e.VizinhoInterface, &e.VizinhoIP, &e.VizinhoPlataforma, &e.Protocolo, &e.DescobertoEm); err != nil {
+			return nil, err
+		}
+		enlaces = append(enlaces, e)
+	}
+	return enlaces, rows.Err()
+}
+
+// ============== DESCOBERTA ==============
+
+// descobrirTopologia consulta os vizinhos de cada equipamento, grava os enlaces e
+// informa os vizinhos que ainda não estão no inventário. Uma falha em um equipamento
+// não interrompe os demais.
+func descobrirTopologia(equipamentos []Equipamento, coletar ColetorVizinhos) error {
+	inventario, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+	if err != nil {
+		return err
+	}
+
+	foraDoInventario := map[string]Enlace{}
+	for _, equip := range equipamentos {
+		driver, ok := drivers.resolver(equip.Vendor, equip.DevTipo).(DriverVizinhos)
+		if !ok {
+			fmt.Printf("%s: o driver não sabe consultar vizinhos, ignorado.\n", equip.Nome)
+			continue
+		}
+		comando, nomeTemplate, protocolo := driver.ConsultaVizinhos()
+		if comando == "" {
+			fmt.Printf("%s: o driver não sabe consultar vizinhos, ignorado.\n", equip.Nome)
+			continue
+		}
+
+		template, err := carregarTemplate(nomeTemplate)
+		if err != nil {
+			return err
+		}
+		saida, err := coletar(equip, comando)
+		if err != nil {
+			fmt.Printf("%s: ERRO: %v\n", equip.Nome, err)
+			continue
+		}
+		tabela, err := template.Processar(saida)
+		if err != nil {
+			fmt.Printf("%s: ERRO ao interpretar a saída de '%s': %v\n", equip.Nome, comando, err)
+			continue
+		}
+		enlaces, err := enlacesDaTabela(equip.ID, tabela, protocolo)
+		if err != nil {
+			return err
+		}
+		resolverVizinhos(enlaces, inventario)
+		if err := gravarEnlaces(equip.ID, enlaces); err != nil {
+			return err
+		}
+
+		novos := 0
+		for _, e := range enlaces {
+			if e.VizinhoID == 0 {
+				novos++
+				foraDoInventario[nomeCurto(e.VizinhoNome)] = e
+			}
+		}
+		fmt.Printf("%s: %d vizinho(s) via %s, %d fora do inventário.\n", equip.Nome, len(enlaces), strings.ToUpper(protocolo), novos)
+	}
+
+	if len(foraDoInventario) > 0 {
+		fmt.Println("\nVizinhos que ainda não estão no inventário:")
+		nomes := make([]string, 0, len(foraDoInventario))
+		for nome := range foraDoInventario {
+			nomes = append(nomes, nome)
+		}
+		sort.Strings(nomes)
+		for _, nome := range nomes {
+			e := foraDoInventario[nome]
+			fmt.Printf("  - %s (IP: %s, plataforma: %s)\n", e.VizinhoNome, valorOuTraco(e.VizinhoIP), valorOuTraco(e.VizinhoPlataforma))
+		}
+	}
+	return nil
+}
+
+// valorOuTraco troca textos vazios por "-" na exibição.
+func valorOuTraco(valor string) string {
+	if valor == "" {
+		return "-"
+	}
+	return valor
+}
+
+// ============== GRAFO E EXPORTAÇÃO ==============
+
+// NoTopologia é um equipamento (ou vizinho desconhecido) no grafo.
+type NoTopologia struct {
+	ID            string `json:"id"`
+	Nome          string `json:"nome"`
+	IP            string `json:"ip,omitempty"`
+	Vendor        string `json:"vendor,omitempty"`
+	EquipamentoID int    `json:"equipamento_id,omitempty"`
+	Inventario    bool   `json:"inventario"`
+}
+
+// ArestaTopologia é uma ligação entre duas portas.
+type ArestaTopologia struct {
+	Origem           string `json:"origem"`
+	InterfaceOrigem  string `json:"interface_origem"`
+	Destino          string `json:"destino"`
+	InterfaceDestino string `json:"interface_destino"`
+	Protocolo        string `json:"protocolo"`
+}
+
+// GrafoTopologia é o grafo exportado pelo comando 'topologia export'.
+type GrafoTopologia struct {
+	Nos     []NoTopologia     `json:"nos"`
+	Enlaces []ArestaTopologia `json:"enlaces"`
+}
+
+// caracteresInvalidosID são os caracteres que não podem aparecer nos identificadores do DOT/Mermaid.
+var caracteresInvalidosID = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// montarGrafo junta os enlaces gravados e o inventário. O mesmo enlace visto pelos
+// dois lados aparece uma única vez.
+func montarGrafo(enlaces []Enlace, inventario []Equipamento) GrafoTopologia {
+	equipamentos := map[int]Equipamento{}
+	for _, e := range inventario {
+		equipamentos[e.ID] = e
+	}
+
+	nos := map[string]NoTopologia{}
+	noDoEquipamento := func(id int) (string, bool) {
+		e, ok := equipamentos[id]
+		if !ok {
+			return "", false
+		}
+		chave := fmt.Sprintf("e%d", id)
+		nos[chave] = NoTopologia{ID: chave, Nome: e.Nome, IP: e.IP, Vendor: e.Vendor, EquipamentoID: e.ID, Inventario: true}
+		return chave, true
+	}
+
+	var grafo GrafoTopologia
+	vistas := map[string]bool{}
+	for _, e := range enlaces {
+		origem, ok := noDoEquipamento(e.EquipamentoID)
+		if !ok {
+			continue // O equipamento foi removido do inventário depois da descoberta.
+		}
+
+		destino, ok := noDoEquipamento(e.VizinhoID)
+		if !ok {
+			destino = "x_" + caracteresInvalidosID.ReplaceAllString(nomeCurto(e.VizinhoNome), "_")
+			nos[destino] = NoTopologia{ID: destino, Nome: e.VizinhoNome, IP: e.VizinhoIP}
+		}
+
+		// A chave não depende do lado que descobriu o enlace.
+		pontaA, pontaB := origem+"|"+e.InterfaceLocal, destino+"|"+e.VizinhoInterface
+		if pontaB < pontaA {
+			pontaA, pontaB = pontaB, pontaA
+		}
+		if vistas[pontaA+"--"+pontaB] {
+			continue
+		}
+		vistas[pontaA+"--"+pontaB] = true
+
+		grafo.Enlaces = append(grafo.Enlaces, ArestaTopologia{
+			Origem: origem, InterfaceOrigem: e.InterfaceLocal,
+			Destino: destino, InterfaceDestino: e.VizinhoInterface,
+			Protocolo: e.Protocolo,
+		})
+	}
+
+	for _, no := range nos {
+		grafo.Nos = append(grafo.Nos, no)
+	}
+	sort.Slice(grafo.Nos, func(i, j int) bool { return grafo.Nos[i].ID < grafo.Nos[j].ID })
+	return grafo
+}
+
+// escreverDOT exporta o grafo no formato do Graphviz. Vizinhos fora do inventário
+// aparecem tracejados em vermelho.
+func (g GrafoTopologia) escreverDOT(w io.Writer) error {
+	fmt.Fprintln(w, "graph topologia {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, no := range g.Nos {
+		rotulo := no.Nome
+		if no.IP != "" {
+			rotulo += "\n" + no.IP
+		}
+		estilo := ""
+		if !no.Inventario {
+			estilo = ", style=dashed, color=red"
+		}
+		fmt.Fprintf(w, "\t%s [label=%q%s];\n", no.ID, rotulo, estilo)
+	}
+	for _, a := range g.Enlaces {
+		fmt.Fprintf(w, "\t%s -- %s [taillabel=%q, headlabel=%q];\n", a.Origem, a.Destino, a.InterfaceOrigem, a.InterfaceDestino)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// escreverMermaid exporta o grafo como um fluxograma do Mermaid.
+func (g GrafoTopologia) escreverMermaid(w io.Writer) error {
+	fmt.Fprintln(w, "graph LR")
+	aspas := strings.NewReplacer(`"`, "#quot;")
+	for _, no := range g.Nos {
+		rotulo := aspas.Replace(no.Nome)
+		if no.IP != "" {
+			rotulo += "<br/>" + no.IP
+		}
+		fmt.Fprintf(w, "    %s[\"%s\"]\n", no.ID, rotulo)
+		if !no.Inventario {
+			fmt.Fprintf(w, "    class %s foraDoInventario\n", no.ID)
+		}
+	}
+	for _, a := range g.Enlaces {
+		fmt.Fprintf(w, "    %s ---|\"%s - %s\"| %s\n", a.Origem, aspas.Replace(a.InterfaceOrigem), aspas.Replace(a.InterfaceDestino), a.Destino)
+	}
+	_, err := fmt.Fprintln(w, "    classDef foraDoInventario stroke:#d00,stroke-dasharray:5 5")
+	return err
+}
+
+// escreverJSON exporta o grafo em JSON.
+func (g GrafoTopologia) escreverJSON(w io.Writer) error {
+	if g.Nos == nil {
+		g.Nos = []NoTopologia{}
+	}
+	if g.Enlaces == nil {
+		g.Enlaces = []ArestaTopologia{}
+	}
+	codificador := json.NewEncoder(w)
+	codificador.SetIndent("", "  ")
+	return codificador.Encode(g)
+}
+
+// escrever exporta o grafo no formato pedido.
+func (g GrafoTopologia) escrever(w io.Writer, formato string) error {
+	switch strings.ToLower(formato) {
+	case "dot":
+		return g.escreverDOT(w)
+	case "mermaid":
+		return g.escreverMermaid(w)
+	case "json":
+		return g.escreverJSON(w)
+	default:
+		return fmt.Errorf("formato '%s' inválido (use dot, mermaid ou json)", formato)
+	}
+}
+
+// listarEnlaces exibe os enlaces gravados, marcando os vizinhos fora do inventário.
+func listarEnlaces() error {
+	enlaces, err := buscarEnlaces()
+	if err != nil {
+		return err
+	}
+	inventario, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+	if err != nil {
+		return err
+	}
+	nomes := map[int]string{}
+	for _, e := range inventario {
+		nomes[e.ID] = e.Nome
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "EQUIPAMENTO\tINTERFACE\tVIZINHO\tINTERFACE VIZINHO\tPROTOCOLO\tINVENTÁRIO\tDESCOBERTO EM")
+	fmt.Fprintln(w, "-----------\t---------\t-------\t-----------------\t---------\t----------\t-------------")
+	for _, e := range enlaces {
+		noInventario := "sim"
+		if _, ok := nomes[e.VizinhoID]; !ok {
+			noInventario = "NÃO"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", nomes[e.EquipamentoID], e.InterfaceLocal, e.VizinhoNome,
+			valorOuTraco(e.VizinhoInterface), e.Protocolo, noInventario, e.DescobertoEm)
+	}
+	return w.Flush()
+}
+
+// ============== COMANDOS CLI ==============
+
+var comandoTopologia = &cobra.Command{
+	Use:     "topologia",
+	Short:   "Descobre e exporta como os equipamentos estão ligados (LLDP/CDP).",
+	Aliases: []string{"topo"},
+}
+
+var comandoDescobrirTopologia = &cobra.Command{
+	Use:         "descobrir",
+	Short:       "Consulta os vizinhos LLDP/CDP dos equipamentos selecionados e grava os enlaces.",
+	Annotations: exigir(PapelOperador),
+	Run: func(cmd *cobra.Command, args []string) {
+		var filtro FiltroEquipamentos
+		filtro.IDs, _ = cmd.Flags().GetIntSlice("equip")
+		filtro.Cidade, _ = cmd.Flags().GetString("cidade")
+		filtro.Tipo, _ = cmd.Flags().GetString("tipo")
+		filtro.Vendor, _ = cmd.Flags().GetString("vendor")
+		filtro.IncluirInativos, _ = cmd.Flags().GetBool("incluir-inativos")
+
+		equipamentos, err := buscarEquipamentos(filtro)
+		if err != nil {
+			log.Fatalf("Erro ao buscar equipamentos: %v", err)
+		}
+		if len(equipamentos) == 0 {
+			log.Fatal("Nenhum equipamento atende ao filtro informado.")
+		}
+		if err := descobrirTopologia(equipamentos, coletarVizinhosSSH); err != nil {
+			log.Fatalf("Erro ao descobrir a topologia: %v", err)
+		}
+	},
+}
+
+var comandoListTopologia = &cobra.Command{
+	Use:         "list",
+	Short:       "Lista os enlaces descobertos.",
+	Aliases:     []string{"ls"},
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listarEnlaces(); err != nil {
+			log.Fatalf("Erro ao listar enlaces: %v", err)
+		}
+	},
+}
+
+var comandoExportTopologia = &cobra.Command{
+	Use:         "export",
+	Short:       "Exporta a topologia em Graphviz DOT, Mermaid ou JSON.",
+	Annotations: exigir(PapelLeitura),
+	Run: func(cmd *cobra.Command, args []string) {
+		formato, _ := cmd.Flags().GetString("formato")
+		caminho, _ := cmd.Flags().GetString("saida")
+
+		enlaces, err := buscarEnlaces()
+		if err != nil {
+			log.Fatalf("Erro ao ler os enlaces: %v", err)
+		}
+		inventario, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+		if err != nil {
+			log.Fatalf("Erro ao ler o inventário: %v", err)
+		}
+		grafo := montarGrafo(enlaces, inventario)
+
+		var destino io.Writer = os.Stdout
+		if caminho != "" {
+			arquivo, err := os.Create(caminho)
+			if err != nil {
+				log.Fatalf("Erro ao criar o arquivo: %v", err)
+			}
+			defer arquivo.Close()
+			destino = arquivo
+		}
+		if err := grafo.escrever(destino, formato); err != nil {
+			log.Fatalf("Erro ao exportar a topologia: %v", err)
+		}
+		if caminho != "" {
+			fmt.Printf("Topologia exportada para '%s'.\n", caminho)
+		}
+	},
+}
+
+func init() {
+	comandoRaiz.AddCommand(comandoTopologia)
+	comandoTopologia.AddCommand(comandoDescobrirTopologia, comandoListTopologia, comandoExportTopologia)
+
+	comandoDescobrirTopologia.Flags().IntSlice("equip", nil, "IDs dos equipamentos (ex: --equip 1,2,3)")
+	comandoDescobrirTopologia.Flags().String("cidade", "", "Filtra os equipamentos pela cidade")
+	comandoDescobrirTopologia.Flags().String("tipo", "", "Filtra os equipamentos pelo tipo")
+	comandoDescobrirTopologia.Flags().String("vendor", "", "Filtra os equipamentos pelo vendor")
+	comandoDescobrirTopologia.Flags().Bool("incluir-inativos", false, "Consulta também os equipamentos em manutenção ou desativados")
+
+	comandoExportTopologia.Flags().String("formato", "dot", "Formato da exportação: dot, mermaid ou json")
+	comandoExportTopologia.Flags().String("saida", "", "Grava a exportação neste arquivo em vez da saída padrão")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// coletorGravado é um ColetorVizinhos que devolve saídas reais gravadas em
+// testdata/vizinhos/<equipamento>_<comando>.txt. Equipamentos sem arquivo falham como
+// um SSH que não conecta.
+func coletorGravado(t *testing.T) (ColetorVizinhos, *[]string) {
+	var consultados []string
+	coletar := func(equip Equipamento, comando string) (string, error) {
+		consultados = append(consultados, equip.Nome)
+		arquivo := strings.ToLower(equip.Nome) + "_" + strings.ReplaceAll(comando, " ", "_") + ".txt"
+		dados, err := os.ReadFile(filepath.Join("testdata", "vizinhos", arquivo))
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("falha ao conectar em %s: tempo esgotado", equip.IP)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(dados), nil
+	}
+	return coletar, &consultados
+}
+
+// cadastrarRedeDeTeste grava o inventário usado nos testes de topologia e devolve os
+// equipamentos com os IDs atribuídos, na ordem do cadastro.
+func cadastrarRedeDeTeste(t *testing.T) []Equipamento {
+	t.Helper()
+	for _, e := range []Equipamento{
+		{Nome: "CORE-01", IP: "10.0.0.1", Vendor: "huawei", DevTipo: "NE40E"},
+		{Nome: "SW-ACESSO-01", IP: "10.0.0.2", Vendor: "cisco"},
+		{Nome: "PE-01", IP: "10.0.0.3", Vendor: "nokia"},
+		{Nome: "N9K-DIST-02", IP: "10.0.0.4", Vendor: "cisco"}, // Sem saída gravada: a coleta falha.
+		{Nome: "OLT-JPA", IP: "10.0.0.5", Vendor: "zte"},       // O driver não consulta vizinhos.
+	} {
+		if err := adicionarEquipamento(e.Nome, e.IP, "JPA", "roteador", e.Vendor, e.DevTipo, StatusAtivo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	equipamentos, err := buscarEquipamentos(FiltroEquipamentos{IncluirInativos: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return equipamentos
+}
+
+func TestDescobrirTopologia(t *testing.T) {
+	abrirBancoDeTeste(t)
+	equipamentos := cadastrarRedeDeTeste(t)
+	coletar, consultados := coletorGravado(t)
+
+	// Roda duas vezes: a segunda descoberta substitui os enlaces, sem duplicar.
+	for range 2 {
+		if err := descobrirTopologia(equipamentos, coletar); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if esperado := "CORE-01 SW-ACESSO-01 PE-01 N9K-DIST-02"; strings.Join((*consultados)[:4], " ") != esperado {
+		t.Errorf("equipamentos consultados = %v, esperava %s", *consultados, esperado)
+	}
+
+	enlaces, err := buscarEnlaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obtidos []string
+	for _, e := range enlaces {
+		obtidos = append(obtidos, fmt.Sprintf("%d %s -> %d %s %s %s %q %s",
+			e.EquipamentoID, e.InterfaceLocal, e.VizinhoID, e.VizinhoNome, e.VizinhoInterface, e.VizinhoIP, e.VizinhoPlataforma, e.Protocolo))
+	}
+	esperados := []string{
+		// Huawei (LLDP brief): vizinhos resolvidos pelo nome, com ou sem domínio.
+		`1 GE0/0/1 -> 2 SW-ACESSO-01.empresa.local Gi1/0/48  "" lldp`,
+		`1 GE0/0/2 -> 3 PE-01 1/1/1  "" lldp`,
+		`1 GE0/0/3 -> 0 AP-LOJA-05 eth0  "" lldp`,
+		// Cisco (CDP detail): o Nexus é resolvido pelo IP de gerência, apesar do nome diferente.
+		`2 GigabitEthernet1/0/10 -> 0 AP-SALA-01.empresa.local GigabitEthernet0 192.168.10.50 "cisco AIR-AP2802I-Z-K9" cdp`,
+		`2 GigabitEthernet1/0/49 -> 4 DIST-02(FOX1234ABCD) Ethernet1/10 10.0.0.4 "N9K-C93180YC-EX" cdp`,
+		// Nokia (LLDP): o enlace com o CORE-01 também é visto deste lado.
+		`3 1/1/1 -> 1 CORE-01 GE0/0/2  "" lldp`,
+		`3 1/1/2 -> 0 PE-02 1/1/1  "" lldp`,
+	}
+	if strings.Join(obtidos, "\n") != strings.Join(esperados, "\n") {
+		t.Fatalf("enlaces gravados:\n%s\nesperava:\n%s", strings.Join(obtidos, "\n"), strings.Join(esperados, "\n"))
+	}
+
+	// No grafo, o enlace CORE-01 <-> PE-01 aparece uma única vez e os vizinhos fora do
+	// inventário viram nós próprios.
+	grafo := montarGrafo(enlaces, equipamentos)
+	var nos []string
+	for _, no := range grafo.Nos {
+		nos = append(nos, fmt.Sprintf("%s:%v", no.ID, no.Inventario))
+	}
+	if esperado := "e1:true e2:true e3:true e4:true x_ap_loja_05:false x_ap_sala_01:false x_pe_02:false"; strings.Join(nos, " ") != esperado {
+		t.Errorf("nós = %s, esperava %s", strings.Join(nos, " "), esperado)
+	}
+	if len(grafo.Enlaces) != len(esperados)-1 {
+		t.Errorf("arestas = %+v, esperava %d", grafo.Enlaces, len(esperados)-1)
+	}
+}
+
+func TestNomeCurto(t *testing.T) {
+	casos := map[string]string{
+		"SW-ACESSO-01.empresa.local": "sw-acesso-01",
+		"DIST-02(FOX1234ABCD)":       "dist-02",
+		" PE-01 ":                    "pe-01",
+		"10.0.0.4":                   "10.0.0.4",
+	}
+	for nome, esperado := range casos {
+		if obtido := nomeCurto(nome); obtido != esperado {
+			t.Errorf("nomeCurto(%q) = %q, esperava %q", nome, obtido, esperado)
+		}
+	}
+}
@@ -4,4 +4,4 @@ go 1.25.0
 
 replace exemplo/boas_vindas => ../modulos_go
 
-require exemplo/boas_vindas v0.0.0-00010101000000-000000000000 // indirect
+require exemplo/boas_vindas v0.0.0-00010101000000-000000000000